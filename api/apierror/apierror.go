@@ -0,0 +1,117 @@
+// Package apierror maps the sentinel errors of the domain packages to the
+// status and code of the problem rendered by every component.
+package apierror
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/timgluz/wasserspiegel/dashboard"
+	"github.com/timgluz/wasserspiegel/kvstore"
+	"github.com/timgluz/wasserspiegel/measurement"
+	"github.com/timgluz/wasserspiegel/response"
+	"github.com/timgluz/wasserspiegel/secret"
+	"github.com/timgluz/wasserspiegel/station"
+)
+
+// Codes of the errors of the domain packages.
+const (
+	CodeStationNotFound     = "station_not_found"
+	CodeStationExists       = "station_exists"
+	CodeInvalidStationID    = "invalid_station_id"
+	CodeNoProvider          = "no_provider_for_station"
+	CodeProviderUnavailable = "provider_unavailable"
+	CodeUpstreamInvalid     = "upstream_invalid_response"
+	CodeMeasurementExists   = "measurement_exists"
+	CodeMeasurementNotFound = "measurement_not_found"
+	CodeInvalidEpoch        = "invalid_epoch"
+	CodeNotEnoughSamples    = "not_enough_samples"
+	CodeUnknownUnit         = "unknown_unit"
+	CodeIncompatibleUnits   = "incompatible_units"
+	CodeUnknownLabel        = "unknown_label"
+	CodeDashboardNotFound   = "dashboard_not_found"
+	CodeDashboardExists     = "dashboard_exists"
+	CodeStorageUnavailable  = "storage_unavailable"
+	CodeKeyNotFound         = "api_key_not_found"
+	CodeKeyExpired          = "api_key_expired"
+	CodeKeyRevoked          = "api_key_revoked"
+	CodeUnknownScope        = "unknown_scope"
+	CodeInvalidShareToken   = "invalid_share_token"
+	CodeShareExpired        = "share_token_expired"
+	CodeShareRevoked        = "share_token_revoked"
+	CodeShareNotFound       = "share_link_not_found"
+)
+
+type mapping struct {
+	err    error
+	status int
+	code   string
+}
+
+// mappings are checked in order, so a more specific error must come before the errors it wraps.
+var mappings = []mapping{
+	{station.ErrStationNotFound, http.StatusNotFound, CodeStationNotFound},
+	{station.ErrStationExists, http.StatusConflict, CodeStationExists},
+	{station.ErrInvalidStationID, http.StatusBadRequest, CodeInvalidStationID},
+	{station.ErrResourceNotFound, http.StatusNotFound, response.CodeNotFound},
+	{station.ErrNoProviderForStation, http.StatusUnprocessableEntity, CodeNoProvider},
+	{station.ErrProviderNotReady, http.StatusServiceUnavailable, CodeProviderUnavailable},
+	{station.ErrCircuitOpen, http.StatusServiceUnavailable, CodeProviderUnavailable},
+	{station.ErrUnmarshalFailed, http.StatusBadGateway, CodeUpstreamInvalid},
+	{station.ErrNotSupported, http.StatusNotImplemented, response.CodeNotImplemented},
+	{station.ErrKVStoreNotAvailable, http.StatusServiceUnavailable, CodeStorageUnavailable},
+
+	{measurement.ErrMeasurementExists, http.StatusConflict, CodeMeasurementExists},
+	{measurement.ErrMeasurementNotFound, http.StatusNotFound, CodeMeasurementNotFound},
+	{measurement.ErrInvalidEpoch, http.StatusBadRequest, CodeInvalidEpoch},
+	{measurement.ErrNotEnoughSamples, http.StatusUnprocessableEntity, CodeNotEnoughSamples},
+	{measurement.ErrUnknownUnit, http.StatusBadRequest, CodeUnknownUnit},
+	{measurement.ErrIncompatibleUnits, http.StatusUnprocessableEntity, CodeIncompatibleUnits},
+	{measurement.ErrUnknownLabel, http.StatusBadRequest, CodeUnknownLabel},
+	{measurement.ErrDBNotAvailable, http.StatusServiceUnavailable, CodeStorageUnavailable},
+
+	{dashboard.ErrDashboardNotFound, http.StatusNotFound, CodeDashboardNotFound},
+	{dashboard.ErrDashboardExists, http.StatusConflict, CodeDashboardExists},
+	{dashboard.ErrKVStoreNotAvailable, http.StatusServiceUnavailable, CodeStorageUnavailable},
+
+	{secret.ErrSecretNotFound, http.StatusNotFound, CodeKeyNotFound},
+	{secret.ErrKeyExpired, http.StatusUnauthorized, CodeKeyExpired},
+	{secret.ErrKeyRevoked, http.StatusUnauthorized, CodeKeyRevoked},
+	{secret.ErrUnknownScope, http.StatusBadRequest, CodeUnknownScope},
+	{secret.ErrInvalidShareToken, http.StatusUnauthorized, CodeInvalidShareToken},
+	{secret.ErrShareExpired, http.StatusUnauthorized, CodeShareExpired},
+	{secret.ErrShareRevoked, http.StatusUnauthorized, CodeShareRevoked},
+
+	{kvstore.ErrKeyNotFound, http.StatusNotFound, response.CodeNotFound},
+	{response.ErrNotFound, http.StatusNotFound, response.CodeNotFound},
+	{context.DeadlineExceeded, http.StatusGatewayTimeout, response.CodeTimeout},
+}
+
+// Problem returns the problem of err: a wrapped *response.Problem is used
+// as is, a known sentinel error sets the status and code, and any other
+// error gets the fallback status.
+func Problem(err error, fallbackStatus int) *response.Problem {
+	var problem *response.Problem
+	if errors.As(err, &problem) {
+		return response.NewProblemFromError(err, fallbackStatus)
+	}
+
+	for _, m := range mappings {
+		if errors.Is(err, m.err) {
+			return response.NewProblem(m.status, m.code, err.Error())
+		}
+	}
+
+	return response.NewProblem(fallbackStatus, "", err.Error())
+}
+
+// Render writes the problem of err, see Problem.
+func Render(w http.ResponseWriter, err error, fallbackStatus int) {
+	response.RenderProblem(w, Problem(err, fallbackStatus))
+}
+
+// RenderFatal writes the problem of err with status 500 unless err is a known sentinel error.
+func RenderFatal(w http.ResponseWriter, err error) {
+	Render(w, err, http.StatusInternalServerError)
+}
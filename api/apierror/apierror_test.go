@@ -0,0 +1,51 @@
+package apierror
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/timgluz/wasserspiegel/dashboard"
+	"github.com/timgluz/wasserspiegel/measurement"
+	"github.com/timgluz/wasserspiegel/response"
+	"github.com/timgluz/wasserspiegel/secret"
+	"github.com/timgluz/wasserspiegel/station"
+)
+
+func TestProblem(t *testing.T) {
+	testCases := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{name: "station not found", err: fmt.Errorf("%w: bonn", station.ErrStationNotFound), status: http.StatusNotFound, code: CodeStationNotFound},
+		{name: "upstream resource not found", err: fmt.Errorf("fetch failed: %w", station.ErrResourceNotFound), status: http.StatusNotFound, code: response.CodeNotFound},
+		{name: "circuit open", err: station.ErrCircuitOpen, status: http.StatusServiceUnavailable, code: CodeProviderUnavailable},
+		{name: "invalid epoch", err: fmt.Errorf("%w: abc", measurement.ErrInvalidEpoch), status: http.StatusBadRequest, code: CodeInvalidEpoch},
+		{name: "measurement not found", err: fmt.Errorf("%w: bonn", measurement.ErrMeasurementNotFound), status: http.StatusNotFound, code: CodeMeasurementNotFound},
+		{name: "not enough samples", err: fmt.Errorf("%w: 2 in the window", measurement.ErrNotEnoughSamples), status: http.StatusUnprocessableEntity, code: CodeNotEnoughSamples},
+		{name: "incompatible units", err: fmt.Errorf("failed to add timeseries: %w", measurement.ErrIncompatibleUnits), status: http.StatusUnprocessableEntity, code: CodeIncompatibleUnits},
+		{name: "unknown label", err: fmt.Errorf("invalid filter: %w", measurement.ErrUnknownLabel), status: http.StatusBadRequest, code: CodeUnknownLabel},
+		{name: "dashboard exists", err: dashboard.ErrDashboardExists, status: http.StatusConflict, code: CodeDashboardExists},
+		{name: "share expired", err: secret.ErrShareExpired, status: http.StatusUnauthorized, code: CodeShareExpired},
+		{name: "unknown error", err: fmt.Errorf("disk full"), status: http.StatusInternalServerError, code: response.CodeInternal},
+		{
+			name:   "wrapped problem",
+			err:    fmt.Errorf("invalid: %w", response.NewValidationProblem().WithFieldError("start", "required")),
+			status: http.StatusBadRequest,
+			code:   response.CodeValidationFailed,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			problem := Problem(tc.err, http.StatusInternalServerError)
+			assert.Equal(t, tc.status, problem.Status)
+			assert.Equal(t, tc.code, problem.Code)
+			assert.Equal(t, http.StatusText(tc.status), problem.Title)
+		})
+	}
+}
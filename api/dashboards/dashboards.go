@@ -0,0 +1,259 @@
+// Package dashboards serves the dashboards and their share links of the /dashboards routes.
+package dashboards
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/sosodev/duration"
+
+	"github.com/timgluz/wasserspiegel/api/apierror"
+	"github.com/timgluz/wasserspiegel/dashboard"
+	"github.com/timgluz/wasserspiegel/health"
+	"github.com/timgluz/wasserspiegel/log"
+	"github.com/timgluz/wasserspiegel/measurement"
+	"github.com/timgluz/wasserspiegel/middleware"
+	"github.com/timgluz/wasserspiegel/ratelimit"
+	"github.com/timgluz/wasserspiegel/response"
+	"github.com/timgluz/wasserspiegel/secret"
+)
+
+const (
+	DefaultShareTTL = 7 * 24 * time.Hour
+	MaxShareTTL     = 365 * 24 * time.Hour
+)
+
+// Component holds the dependencies of the dashboard routes.
+type Component struct {
+	Logger        *slog.Logger
+	SecretStore   secret.Store
+	RateLimiter   *ratelimit.Limiter
+	ShareStore    *secret.ShareStore
+	Repository    dashboard.Repository
+	CachePolicies response.CachePolicies
+}
+
+// HealthDependencies lists the stores reported by `/dashboards/health`.
+func (c *Component) HealthDependencies() []health.Dependency {
+	return []health.Dependency{
+		health.Required("dashboards", c.Repository),
+		health.Required("secrets", c.SecretStore),
+		health.Required("shares", c.ShareStore),
+		health.Required("ratelimits", c.RateLimiter),
+	}
+}
+
+// NewRouter registers the dashboard and share link routes.
+func NewRouter(app *Component) *httprouter.Router {
+	dashboardRepo, secretStore, limiter, logger := app.Repository, app.SecretStore, app.RateLimiter, app.Logger
+
+	readOnly := func(h httprouter.Handle) httprouter.Handle {
+		return middleware.BearerAuth(middleware.RateLimit(h, limiter, ratelimit.ClassRead), secretStore, secret.ScopeDashboardsRead)
+	}
+	// dashboards can also be read with a share link instead of an API key
+	readShared := func(h httprouter.Handle) httprouter.Handle {
+		shared := middleware.RateLimit(h, limiter, ratelimit.ClassRead)
+		return middleware.ShareAuth(shared, readOnly(h), app.ShareStore, dashboardShareResource, secret.ScopeDashboardsRead)
+	}
+	writable := func(h httprouter.Handle) httprouter.Handle {
+		return middleware.BearerAuth(middleware.RateLimit(h, limiter, ratelimit.ClassWrite), secretStore, secret.ScopeDashboardsWrite)
+	}
+
+	router := httprouter.New()
+	cacheControl := app.CachePolicies.Get(response.CacheRouteDashboards)
+	router.GET("/dashboards/:id", middleware.ReservedSegment("id", "health", health.NewHandler("dashboards", app.HealthDependencies()...),
+		readShared(newDashboardGetHandler(dashboardRepo, cacheControl, logger))))
+	router.OPTIONS("/dashboards/:id", middleware.CORSPreflight)
+	router.GET("/dashboards", readOnly(newDashboardIndexHandler(dashboardRepo, cacheControl, logger)))
+
+	router.POST("/dashboards/:id/share", writable(newShareCreateHandler(app)))
+	router.GET("/dashboards/:id/share", writable(newShareListHandler(app)))
+	router.DELETE("/dashboards/:id/share/:share_id", writable(newShareRevokeHandler(app)))
+
+	router.NotFound = response.NewNotFoundHandler(logger)
+	router.MethodNotAllowed = response.NewMethodNotAllowedHandler()
+
+	return router
+}
+
+func dashboardShareResource(params httprouter.Params) string {
+	return dashboard.ShareResource(params.ByName("id"))
+}
+
+// ShareRequest is the optional body of POST /dashboards/:id/share.
+type ShareRequest struct {
+	ExpiresIn string `json:"expires_in,omitempty"` // ISO 8601 duration, default P7D
+}
+
+func newShareCreateHandler(app *Component) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		logger := log.FromContext(r.Context(), app.Logger)
+		dashboardID := params.ByName("id")
+
+		var req ShareRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			apierror.Render(w, fmt.Errorf("invalid request body: %w", err), http.StatusBadRequest)
+			return
+		}
+
+		ttl := DefaultShareTTL
+		if req.ExpiresIn != "" {
+			expiresIn, err := duration.Parse(req.ExpiresIn)
+			if err != nil || expiresIn.ToTimeDuration() <= 0 || expiresIn.ToTimeDuration() > MaxShareTTL {
+				response.RenderProblem(w, response.NewValidationProblem().
+					WithFieldError("expires_in", "expires_in must be a positive ISO 8601 duration of at most P365D"))
+				return
+			}
+			ttl = expiresIn.ToTimeDuration()
+		}
+
+		existing, err := app.Repository.GetByID(r.Context(), dashboardID)
+		if err != nil {
+			apierror.RenderFatal(w, err)
+			return
+		}
+		if existing == nil {
+			apierror.Render(w, fmt.Errorf("%w: %s", dashboard.ErrDashboardNotFound, dashboardID), http.StatusNotFound)
+			return
+		}
+
+		createdBy := ""
+		if apiKey, ok := middleware.APIKeyFromContext(r.Context()); ok {
+			createdBy = apiKey.ID
+		}
+
+		token, grant, err := app.ShareStore.Create(dashboardShareResource(params), ttl, createdBy)
+		if err != nil {
+			logger.Error("Failed to create share link", "dashboard", dashboardID, "error", err)
+			apierror.RenderFatal(w, fmt.Errorf("failed to create share link: %w", err))
+			return
+		}
+
+		response.RenderJSON(w, response.NewSuccessResponse("Share link created", dashboard.NewShareLink(dashboardID, grant, token)))
+	}
+}
+
+func newShareListHandler(app *Component) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		grants, err := app.ShareStore.List(dashboardShareResource(params))
+		if err != nil {
+			app.Logger.Error("Failed to list share links", "dashboard", params.ByName("id"), "error", err)
+			apierror.RenderFatal(w, fmt.Errorf("failed to list share links: %w", err))
+			return
+		}
+
+		response.RenderJSON(w, response.NewCollectionResponse(grants, nil))
+	}
+}
+
+func newShareRevokeHandler(app *Component) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		shareID := params.ByName("share_id")
+		if err := app.ShareStore.Revoke(shareID, dashboardShareResource(params)); err != nil {
+			if errors.Is(err, secret.ErrSecretNotFound) {
+				response.RenderProblem(w, response.NewProblem(http.StatusNotFound, apierror.CodeShareNotFound, "share link not found: "+shareID))
+				return
+			}
+
+			app.Logger.Error("Failed to revoke share link", "id", shareID, "error", err)
+			apierror.RenderFatal(w, fmt.Errorf("failed to revoke share link: %w", err))
+			return
+		}
+
+		response.RenderJSON(w, response.NewSuccessResponse("Share link revoked", nil))
+	}
+}
+
+func newDashboardIndexHandler(dashboardRepo dashboard.Repository, cacheControl string, logger *slog.Logger) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		pagination := response.NewPaginationFromRequest(r)
+		logger.Info("Handling dashboard index request", "limit", pagination.Limit, "offset", pagination.Offset)
+		dashboardCollection, err := dashboardRepo.List(r.Context(), pagination.Offset, pagination.Limit)
+		if err != nil {
+			logger.Error("Failed to list dashboards", "error", err)
+			apierror.Render(w, fmt.Errorf("failed to list dashboards: %w", err), http.StatusInternalServerError)
+			return
+		}
+
+		response.RenderConditionalJSON(w, r, dashboardCollection, response.Validators{CacheControl: cacheControl})
+	}
+}
+
+func newDashboardGetHandler(dashboardRepo dashboard.Repository, cacheControl string, logger *slog.Logger) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		dashboardID := params.ByName("id")
+		if dashboardID == "" {
+			apierror.Render(w, fmt.Errorf("dashboard ID is required"), http.StatusBadRequest)
+			return
+		}
+
+		if dashboardRepo == nil {
+			logger.Error("Dashboard repository is not ready")
+			apierror.RenderFatal(w, fmt.Errorf("%w: dashboard repository is not ready", dashboard.ErrKVStoreNotAvailable))
+			return
+		}
+
+		item, err := dashboardRepo.GetByID(r.Context(), dashboardID)
+		if errors.Is(err, dashboard.ErrDashboardNotFound) {
+			apierror.Render(w, err, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to get dashboard by ID", "id", dashboardID, "error", err)
+			apierror.Render(w, fmt.Errorf("failed to get dashboard: %w", err), http.StatusInternalServerError)
+			return
+		}
+
+		if item == nil {
+			apierror.Render(w, fmt.Errorf("%w: %s", dashboard.ErrDashboardNotFound, dashboardID), http.StatusNotFound)
+			return
+		}
+
+		now := time.Now()
+		item.UpdateFreshness(measurement.DefaultFreshnessPolicy(), now)
+		if item.Stale {
+			logger.Warn("Dashboard data is stale", "id", dashboardID, "station", item.Station.ID)
+		}
+		if err := item.Relocalize(now); err != nil {
+			logger.Error("Failed to localize dashboard", "id", dashboardID, "error", err)
+			apierror.RenderFatal(w, fmt.Errorf("failed to localize dashboard: %w", err))
+			return
+		}
+
+		if unit := r.URL.Query().Get("unit"); unit != "" {
+			if err := item.ConvertUnit(unit, now); err != nil {
+				if errors.Is(err, measurement.ErrUnknownUnit) || errors.Is(err, measurement.ErrIncompatibleUnits) {
+					response.RenderProblem(w, response.NewValidationProblem().WithFieldError("unit", err.Error()))
+					return
+				}
+
+				logger.Error("Failed to convert dashboard", "id", dashboardID, "unit", unit, "error", err)
+				apierror.RenderFatal(w, fmt.Errorf("failed to convert dashboard: %w", err))
+				return
+			}
+		}
+
+		response.RenderConditionalJSON(w, r, item, response.Validators{
+			LastModified: freshnessModified(item, now),
+			CacheControl: cacheControl,
+		})
+	}
+}
+
+// freshnessModified is the Last-Modified time of a dashboard whose freshness is
+// recomputed at now: its age changes every minute, so If-Modified-Since can't
+// keep a dashboard that turned stale in the cache.
+func freshnessModified(item *dashboard.Dashboard, now time.Time) time.Time {
+	truncated := now.Truncate(time.Minute)
+	if modified := item.LastModified(); modified.After(truncated) {
+		return modified
+	}
+
+	return truncated
+}
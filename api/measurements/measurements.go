@@ -0,0 +1,745 @@
+// Package measurements serves the measurements and their timeseries of the /measurements routes.
+package measurements
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/sosodev/duration"
+	"github.com/timgluz/wasserspiegel/api/apierror"
+	"github.com/timgluz/wasserspiegel/health"
+	"github.com/timgluz/wasserspiegel/log"
+	"github.com/timgluz/wasserspiegel/measurement"
+	"github.com/timgluz/wasserspiegel/middleware"
+	"github.com/timgluz/wasserspiegel/ratelimit"
+	"github.com/timgluz/wasserspiegel/response"
+	"github.com/timgluz/wasserspiegel/secret"
+)
+
+// Component holds the dependencies of the measurement routes.
+type Component struct {
+	MeasurementRepository measurement.Repository
+	SecretStore           secret.Store
+	RateLimiter           *ratelimit.Limiter
+	CachePolicies         response.CachePolicies
+	Logger                *slog.Logger
+}
+
+func (c *Component) IsReady() bool {
+	if c.Logger == nil {
+		fmt.Println("Logger of measurement Component is not initialized")
+		return false
+	}
+
+	if c.MeasurementRepository == nil {
+		c.Logger.Error("Measurement repository is not initialized")
+		return false
+	}
+
+	if !c.MeasurementRepository.IsReady() {
+		c.Logger.Error("Measurement repository is not ready")
+		return false
+	}
+
+	if c.SecretStore == nil {
+		c.Logger.Error("Secret store is not initialized")
+		return false
+	}
+
+	if c.RateLimiter == nil || !c.RateLimiter.IsReady() {
+		c.Logger.Error("Rate limiter is not initialized or not ready")
+		return false
+	}
+
+	return true
+}
+
+func (c *Component) Close() {
+	if c.MeasurementRepository != nil {
+		if err := c.MeasurementRepository.Close(); err != nil {
+			c.Logger.Error("Failed to close measurement repository", "error", err)
+		}
+	}
+
+	if c.SecretStore != nil {
+		if err := c.SecretStore.Close(); err != nil {
+			c.Logger.Error("Failed to close secret store", "error", err)
+		}
+	}
+
+	if c.RateLimiter != nil {
+		if err := c.RateLimiter.Close(); err != nil {
+			c.Logger.Error("Failed to close rate limiter", "error", err)
+		}
+	}
+
+	c.Logger.Info("Measurement component closed")
+}
+
+// HealthDependencies lists the DB and stores reported by `/measurements/health`.
+func (c *Component) HealthDependencies() []health.Dependency {
+	return []health.Dependency{
+		health.Required("measurements", c.MeasurementRepository),
+		health.Required("secrets", c.SecretStore),
+		health.Required("ratelimits", c.RateLimiter),
+	}
+}
+
+const (
+	// FreshnessSegment is the reserved measurement name of the freshness report.
+	FreshnessSegment = "_freshness"
+
+	// DefaultMaxGap is the longest gap between samples resampling fills, 4 missed PegelOnline samples.
+	DefaultMaxGap = time.Hour
+
+	// labelParamPrefix prefixes the labels the measurement catalogue is filtered by.
+	labelParamPrefix = "label."
+)
+
+// NewRouter registers the measurement routes.
+func NewRouter(c *Component) *httprouter.Router {
+	router := httprouter.New()
+	secretStore, limiter := c.SecretStore, c.RateLimiter
+	router.POST("/measurements", middleware.BearerAuth(middleware.RateLimit(newMeasurementCreationHandler(c), limiter, ratelimit.ClassWrite), secretStore, secret.ScopeMeasurementsWrite))
+	router.GET("/measurements", middleware.BearerAuth(middleware.RateLimit(newMeasurementListHandler(c), limiter, ratelimit.ClassRead), secretStore, secret.ScopeMeasurementsRead))
+	router.POST("/measurements/:name", middleware.BearerAuth(middleware.RateLimit(newTimeseriesCreationHandler(c), limiter, ratelimit.ClassWrite), secretStore, secret.ScopeMeasurementsWrite))
+	router.PUT("/measurements/:name", middleware.BearerAuth(middleware.RateLimit(newMeasurementUpdateHandler(c), limiter, ratelimit.ClassWrite), secretStore, secret.ScopeMeasurementsWrite))
+	router.DELETE("/measurements/:name", middleware.BearerAuth(middleware.RateLimit(newMeasurementDeleteHandler(c), limiter, ratelimit.ClassWrite), secretStore, secret.ScopeMeasurementsWrite))
+	router.GET("/measurements/:name", middleware.ReservedSegment("name", "health", health.NewHandler("measurements", c.HealthDependencies()...),
+		middleware.ReservedSegment("name", FreshnessSegment,
+			middleware.BearerAuth(middleware.RateLimit(newFreshnessHandler(c), limiter, ratelimit.ClassRead), secretStore, secret.ScopeMeasurementsRead),
+			middleware.BearerAuth(middleware.RateLimit(newGetTimeseriesHandler(c), limiter, ratelimit.ClassRead), secretStore, secret.ScopeMeasurementsRead))))
+	router.GET("/measurements/:name/forecast", middleware.BearerAuth(middleware.RateLimit(newForecastHandler(c), limiter, ratelimit.ClassRead), secretStore, secret.ScopeMeasurementsRead))
+	router.GET("/measurements/:name/stats", middleware.BearerAuth(middleware.RateLimit(newStatsHandler(c), limiter, ratelimit.ClassRead), secretStore, secret.ScopeMeasurementsRead))
+	router.NotFound = response.NewNotFoundHandler(c.Logger)
+	router.MethodNotAllowed = response.NewMethodNotAllowedHandler()
+
+	return router
+}
+
+func newMeasurementCreationHandler(appComponents *Component) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+
+		logger := log.FromContext(r.Context(), appComponents.Logger)
+		logger.Debug("Saving new measurement")
+		newMeasurement, err := newMeasurementFromRequest(r)
+		if err != nil {
+			apierror.Render(w, fmt.Errorf("failed to create measurement from request: %w", err), http.StatusBadRequest)
+			return
+		}
+		if newMeasurement.Name == "" {
+			response.RenderProblem(w, response.NewValidationProblem().WithFieldError("name", "measurement name is required"))
+			return
+		}
+
+		if err := appComponents.MeasurementRepository.AddMeasurement(r.Context(), newMeasurement); err != nil {
+			if errors.Is(err, measurement.ErrMeasurementExists) {
+				apierror.Render(w, err, http.StatusConflict)
+				return
+			}
+
+			logger.Error("Failed to add measurement", "error", err)
+			apierror.Render(w, fmt.Errorf("failed to add measurement: %w", err), http.StatusInternalServerError)
+			return
+		}
+
+		logger.Info("Measurement added successfully", "measurement_id", newMeasurement.ID)
+		response.RenderJSON(w, response.NewPostResponse(true, "new measurement added successfully", nil))
+	}
+}
+
+func newMeasurementListHandler(appComponents *Component) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		logger := log.FromContext(r.Context(), appComponents.Logger)
+		logger.Debug("Listing measurements")
+
+		filter, err := getMeasurementFilterFromRequest(r)
+		if err != nil {
+			apierror.Render(w, err, http.StatusBadRequest)
+			return
+		}
+
+		measurements, total, err := appComponents.MeasurementRepository.FindMeasurements(r.Context(), *filter)
+		if err != nil {
+			logger.Error("Failed to find measurements", "error", err)
+			apierror.Render(w, fmt.Errorf("failed to find measurements: %w", err), http.StatusInternalServerError)
+			return
+		}
+		if measurements == nil {
+			measurements = []measurement.Measurement{}
+		}
+
+		logger.Info("Measurements retrieved successfully", "count", len(measurements), "total", total)
+		pagination := response.NewPagination(filter.Offset, filter.Limit, total)
+		response.RenderConditionalJSON(w, r, response.NewCollectionResponse(measurements, &pagination), response.Validators{
+			CacheControl: appComponents.CachePolicies.Get(response.CacheRouteMeasurements),
+		})
+	}
+}
+
+func newMeasurementUpdateHandler(appComponents *Component) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		measurementName := params.ByName("name")
+		if measurementName == "" {
+			apierror.Render(w, fmt.Errorf("measurement name is required"), http.StatusBadRequest)
+			return
+		}
+
+		logger := log.FromContext(r.Context(), appComponents.Logger)
+		logger.Debug("Updating measurement", "name", measurementName)
+
+		updated, err := newMeasurementFromRequest(r)
+		if err != nil {
+			apierror.Render(w, fmt.Errorf("failed to create measurement from request: %w", err), http.StatusBadRequest)
+			return
+		}
+		if updated.Name != "" && updated.Name != measurementName {
+			response.RenderProblem(w, response.NewValidationProblem().WithFieldError("name", "the name of a measurement can't change"))
+			return
+		}
+		updated.Name = measurementName
+
+		repo := appComponents.MeasurementRepository
+		if err := repo.UpdateMeasurement(r.Context(), updated); err != nil {
+			logger.Error("Failed to update measurement", "name", measurementName, "error", err)
+			apierror.Render(w, fmt.Errorf("failed to update measurement: %w", err), http.StatusInternalServerError)
+			return
+		}
+
+		stored, err := repo.GetMeasurement(r.Context(), measurementName)
+		if err != nil {
+			logger.Error("Failed to get updated measurement", "name", measurementName, "error", err)
+			apierror.Render(w, fmt.Errorf("failed to get measurement: %w", err), http.StatusInternalServerError)
+			return
+		}
+
+		logger.Info("Measurement updated successfully", "name", measurementName)
+		response.RenderJSON(w, stored)
+	}
+}
+
+// newMeasurementDeleteHandler deletes the measurement with all its samples.
+func newMeasurementDeleteHandler(appComponents *Component) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		measurementName := params.ByName("name")
+		if measurementName == "" {
+			apierror.Render(w, fmt.Errorf("measurement name is required"), http.StatusBadRequest)
+			return
+		}
+
+		logger := log.FromContext(r.Context(), appComponents.Logger)
+		if err := appComponents.MeasurementRepository.DeleteMeasurement(r.Context(), measurementName); err != nil {
+			logger.Error("Failed to delete measurement", "name", measurementName, "error", err)
+			apierror.Render(w, fmt.Errorf("failed to delete measurement: %w", err), http.StatusInternalServerError)
+			return
+		}
+
+		logger.Info("Measurement deleted successfully", "name", measurementName)
+		response.RenderJSON(w, response.NewSuccessResponse("Measurement deleted", nil))
+	}
+}
+
+func newTimeseriesCreationHandler(appComponents *Component) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		measurementName := params.ByName("name")
+		if measurementName == "" {
+			apierror.Render(w, fmt.Errorf("measurement name is required"), http.StatusBadRequest)
+			return
+		}
+
+		logger := log.FromContext(r.Context(), appComponents.Logger)
+		logger.Debug("Saving timeseries for measurement", "name", measurementName)
+
+		timeseries, err := newTimeseriesFromRequest(r, measurementName)
+		if err != nil {
+			apierror.Render(w, fmt.Errorf("failed to create timeseries from request: %w", err), http.StatusBadRequest)
+			return
+		}
+
+		if err := appComponents.MeasurementRepository.AddTimeseries(r.Context(), timeseries); err != nil {
+			logger.Error("Failed to add timeseries", "error", err)
+			apierror.Render(w, fmt.Errorf("failed to add timeseries: %w", err), http.StatusInternalServerError)
+			return
+		}
+
+		logger.Info("Timeseries added successfully", "measurement_name", measurementName)
+		response.RenderJSON(w, response.NewPostResponse(true, "new timeseries added successfully", nil))
+	}
+}
+
+func newGetTimeseriesHandler(appComponents *Component) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		measurementName := params.ByName("name")
+		if measurementName == "" {
+			apierror.Render(w, fmt.Errorf("measurement name is required"), http.StatusBadRequest)
+			return
+		}
+
+		period, err := getPeriodFromRequest(r)
+		if err != nil {
+			apierror.Render(w, err, http.StatusBadRequest)
+			return
+		}
+
+		resampleOptions, err := getResampleOptionsFromRequest(r, *period)
+		if err != nil {
+			apierror.Render(w, err, http.StatusBadRequest)
+			return
+		}
+
+		excludeFlagged := false
+		switch flagged := r.URL.Query().Get("flagged"); flagged {
+		case "", "include":
+		case "exclude":
+			excludeFlagged = true
+		default:
+			response.RenderProblem(w, response.NewValidationProblem().WithFieldError("flagged", "must be include or exclude"))
+			return
+		}
+
+		logger := log.FromContext(r.Context(), appComponents.Logger)
+		logger.Debug("Getting timeseries for measurement", "name", measurementName)
+
+		timeseries, err := appComponents.MeasurementRepository.GetTimeseries(r.Context(), measurementName, *period)
+		if err != nil {
+			logger.Error("Failed to get timeseries", "error", err)
+			apierror.Render(w, fmt.Errorf("failed to get timeseries: %w", err), http.StatusInternalServerError)
+			return
+		}
+
+		if timeseries == nil {
+			logger.Info("No timeseries found for measurement", "name", measurementName)
+			response.RenderJSON(w, []measurement.Timeseries{})
+			return
+		}
+
+		// gaps are spans without delivered samples, flagged samples don't open one
+		unit := ""
+		if timeseries.Measurement != nil {
+			unit = timeseries.Measurement.Unit
+		}
+		timeseries.Gaps = measurement.QualityPolicyFor(unit).DetectGaps(timeseries.Samples)
+		converter, err := getUnitConverterFromRequest(r, unit)
+		if err != nil {
+			apierror.Render(w, err, http.StatusBadRequest)
+			return
+		}
+		if converter != nil {
+			timeseries.ConvertUnit(*converter)
+		}
+		if excludeFlagged {
+			excluded := timeseries.ExcludeFlagged(measurement.FlagsInvalid)
+			logger.Debug("Excluded flagged samples", "name", measurementName, "count", excluded)
+		}
+
+		if resampleOptions != nil {
+			resampled, err := timeseries.Resample(*resampleOptions)
+			if err != nil {
+				apierror.Render(w, response.NewValidationProblem().WithFieldError("step", err.Error()), http.StatusBadRequest)
+				return
+			}
+
+			logger.Info("Timeseries resampled successfully", "measurement_name", measurementName, "step", resampleOptions.Step, "points", len(resampled.Points))
+			response.RenderConditionalJSON(w, r, resampled, response.Validators{
+				LastModified: timeseries.LastModified(),
+				CacheControl: appComponents.CachePolicies.Get(response.CacheRouteTimeseries),
+			})
+			return
+		}
+
+		logger.Info("Timeseries retrieved successfully", "measurement_name", measurementName)
+		response.RenderConditionalJSON(w, r, timeseries, response.Validators{
+			LastModified: timeseries.LastModified(),
+			CacheControl: appComponents.CachePolicies.Get(response.CacheRouteTimeseries),
+		})
+	}
+}
+
+// newFreshnessHandler reports the stale series, with `?all=true` the fresh ones too.
+func newFreshnessHandler(appComponents *Component) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		logger := log.FromContext(r.Context(), appComponents.Logger)
+
+		all := false
+		if allString := r.URL.Query().Get("all"); allString != "" {
+			var err error
+			if all, err = strconv.ParseBool(allString); err != nil {
+				response.RenderProblem(w, response.NewValidationProblem().WithFieldError("all", "must be true or false"))
+				return
+			}
+		}
+
+		report, err := measurement.CheckFreshness(r.Context(), appComponents.MeasurementRepository, measurement.DefaultFreshnessPolicy(), time.Now())
+		if err != nil {
+			logger.Error("Failed to check freshness", "error", err)
+			apierror.Render(w, fmt.Errorf("failed to check freshness: %w", err), http.StatusInternalServerError)
+			return
+		}
+
+		if report.StaleCount > 0 {
+			logger.Warn("Stale measurements found", "stale", report.StaleCount, "total", report.Total)
+		}
+
+		if !all {
+			report = report.OnlyStale()
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		response.RenderJSON(w, report)
+	}
+}
+
+// newForecastHandler projects the samples of the window before now.
+func newForecastHandler(appComponents *Component) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		measurementName := params.ByName("name")
+		opts, err := getForecastOptionsFromRequest(r)
+		if err != nil {
+			apierror.Render(w, err, http.StatusBadRequest)
+			return
+		}
+
+		logger := log.FromContext(r.Context(), appComponents.Logger)
+		end := measurement.CurrentEpoch()
+		period := measurement.Period{Start: end - measurement.Epoch(opts.Window.Seconds()), End: end}
+		timeseries, err := appComponents.MeasurementRepository.GetTimeseries(r.Context(), measurementName, period)
+		if err != nil {
+			logger.Error("Failed to get timeseries", "error", err)
+			apierror.Render(w, fmt.Errorf("failed to get timeseries: %w", err), http.StatusInternalServerError)
+			return
+		}
+		if timeseries == nil {
+			apierror.Render(w, fmt.Errorf("%w: measurement %s", response.ErrNotFound, measurementName), http.StatusNotFound)
+			return
+		}
+
+		forecast, err := timeseries.Forecast(*opts)
+		if err != nil {
+			logger.Info("Failed to forecast measurement", "name", measurementName, "error", err)
+			apierror.Render(w, err, http.StatusUnprocessableEntity)
+			return
+		}
+
+		converter, err := getUnitConverterFromRequest(r, forecast.Unit)
+		if err != nil {
+			apierror.Render(w, err, http.StatusBadRequest)
+			return
+		}
+		if converter != nil {
+			forecast.ConvertUnit(*converter)
+		}
+
+		logger.Info("Forecast computed successfully", "measurement_name", measurementName, "model", forecast.Model, "samples", forecast.Samples)
+		response.RenderConditionalJSON(w, r, forecast, response.Validators{
+			LastModified: timeseries.LastModified(),
+			CacheControl: appComponents.CachePolicies.Get(response.CacheRouteTimeseries),
+		})
+	}
+}
+
+// newStatsHandler summarizes the samples of the period per calendar bucket of the time zone.
+func newStatsHandler(appComponents *Component) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		measurementName := params.ByName("name")
+		period, err := getPeriodFromRequest(r)
+		if err != nil {
+			apierror.Render(w, err, http.StatusBadRequest)
+			return
+		}
+
+		query := r.URL.Query()
+		groupBy, err := measurement.ParseGroupBy(query.Get("group_by"))
+		if err != nil {
+			response.RenderProblem(w, response.NewValidationProblem().WithFieldError("group_by", "group_by must be day, week or month"))
+			return
+		}
+
+		location, err := getLocationFromRequest(r)
+		if err != nil {
+			apierror.Render(w, err, http.StatusBadRequest)
+			return
+		}
+
+		buckets, err := measurement.CalendarBuckets(*period, groupBy, location)
+		if err != nil {
+			response.RenderProblem(w, response.NewValidationProblem().WithFieldError("group_by", err.Error()))
+			return
+		}
+
+		logger := log.FromContext(r.Context(), appComponents.Logger)
+		storedMeasurement, err := appComponents.MeasurementRepository.GetMeasurement(r.Context(), measurementName)
+		if err != nil {
+			logger.Error("Failed to get measurement", "error", err)
+			apierror.Render(w, fmt.Errorf("failed to get measurement: %w", err), http.StatusInternalServerError)
+			return
+		}
+		if storedMeasurement == nil {
+			apierror.Render(w, fmt.Errorf("%w: measurement %s", response.ErrNotFound, measurementName), http.StatusNotFound)
+			return
+		}
+
+		unit := storedMeasurement.Unit
+		converter, err := getUnitConverterFromRequest(r, unit)
+		if err != nil {
+			apierror.Render(w, err, http.StatusBadRequest)
+			return
+		}
+
+		bucketStats, err := appComponents.MeasurementRepository.GetStats(r.Context(), measurementName, buckets)
+		if err != nil {
+			logger.Error("Failed to get stats", "error", err)
+			apierror.Render(w, fmt.Errorf("failed to get stats: %w", err), http.StatusInternalServerError)
+			return
+		}
+		if bucketStats == nil {
+			apierror.Render(w, fmt.Errorf("%w: measurement %s", response.ErrNotFound, measurementName), http.StatusNotFound)
+			return
+		}
+		if converter != nil {
+			for i := range bucketStats {
+				bucketStats[i].ConvertUnit(*converter)
+			}
+			unit = converter.To()
+		}
+
+		logger.Info("Stats computed successfully", "measurement_name", measurementName, "group_by", groupBy, "buckets", len(bucketStats))
+		response.RenderConditionalJSON(w, r, measurement.Stats{
+			Name:     measurementName,
+			Start:    period.Start,
+			End:      period.End,
+			GroupBy:  groupBy,
+			Timezone: location.String(),
+			Unit:     unit,
+			Buckets:  bucketStats,
+		}, response.Validators{
+			CacheControl: appComponents.CachePolicies.Get(response.CacheRouteTimeseries),
+		})
+	}
+}
+
+// getUnitConverterFromRequest reads `unit` to convert the values of the unit of a
+// measurement to; it returns nil if `unit` is missing.
+func getUnitConverterFromRequest(r *http.Request, from string) (*measurement.UnitConverter, error) {
+	to := r.URL.Query().Get("unit")
+	if to == "" {
+		return nil, nil
+	}
+
+	if from == "" {
+		return nil, response.NewValidationProblem().WithFieldError("unit", "the measurement has no unit to convert from")
+	}
+
+	converter, err := measurement.NewUnitConverter(from, to)
+	if err != nil {
+		return nil, response.NewValidationProblem().WithFieldError("unit", err.Error())
+	}
+
+	return &converter, nil
+}
+
+// getMeasurementFilterFromRequest reads the `label.<key>` parameters, e.g.
+// `label.station_id=bonn`, and the pagination of the measurement catalogue.
+func getMeasurementFilterFromRequest(r *http.Request) (*measurement.MeasurementFilter, error) {
+	pagination := response.NewPaginationFromRequest(r)
+	if pagination.Offset < 0 {
+		return nil, response.NewValidationProblem().WithFieldError("offset", "offset must not be negative")
+	}
+	if pagination.Limit < 1 {
+		return nil, response.NewValidationProblem().WithFieldError("limit", "limit must be positive")
+	}
+
+	filter := measurement.MeasurementFilter{Offset: pagination.Offset, Limit: pagination.Limit}
+	for param, values := range r.URL.Query() {
+		key, ok := strings.CutPrefix(param, labelParamPrefix)
+		if !ok {
+			continue
+		}
+
+		if err := filter.SetLabel(key, values[0]); err != nil {
+			return nil, response.NewValidationProblem().WithFieldError(param, err.Error())
+		}
+	}
+
+	return &filter, nil
+}
+
+// getLocationFromRequest reads the IANA time zone of `tz`, UTC if it's missing.
+func getLocationFromRequest(r *http.Request) (*time.Location, error) {
+	name := r.URL.Query().Get("tz")
+	if name == "" {
+		return time.UTC, nil
+	}
+
+	// Local would be the zone of the server
+	location, err := time.LoadLocation(name)
+	if err != nil || name == "Local" {
+		return nil, response.NewValidationProblem().WithFieldError("tz", "tz must be an IANA time zone, e.g. Europe/Berlin")
+	}
+
+	return location, nil
+}
+
+// getForecastOptionsFromRequest reads `model`, `window`, `horizon`, `step` and
+// `confidence`, missing ones keep the defaults of measurement.DefaultForecastOptions.
+func getForecastOptionsFromRequest(r *http.Request) (*measurement.ForecastOptions, error) {
+	query := r.URL.Query()
+	opts := measurement.DefaultForecastOptions()
+
+	model, err := measurement.ParseForecastModel(query.Get("model"))
+	if err != nil {
+		return nil, response.NewValidationProblem().WithFieldError("model", "model must be linear or quadratic")
+	}
+	opts.Model = model
+
+	problem := response.NewValidationProblem()
+	for field, target := range map[string]*time.Duration{"window": &opts.Window, "horizon": &opts.Horizon, "step": &opts.Step} {
+		if value := query.Get(field); value != "" {
+			parsed, err := duration.Parse(value)
+			if err != nil {
+				problem.WithFieldError(field, field+" must be an ISO 8601 duration, e.g. PT24H")
+				continue
+			}
+			*target = parsed.ToTimeDuration()
+		}
+	}
+
+	if value := query.Get("confidence"); value != "" {
+		confidence, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			problem.WithFieldError("confidence", "confidence must be a number between 0 and 1, e.g. 0.9")
+		}
+		opts.Confidence = confidence
+	}
+
+	if len(problem.Errors) > 0 {
+		return nil, problem
+	}
+	var optionErr *measurement.OptionError
+	if err := opts.Validate(); errors.As(err, &optionErr) {
+		return nil, response.NewValidationProblem().WithFieldError(optionErr.Option, optionErr.Message)
+	}
+
+	return &opts, nil
+}
+
+func newMeasurementFromRequest(r *http.Request) (*measurement.Measurement, error) {
+	var m measurement.Measurement
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&m); err != nil {
+		return nil, fmt.Errorf("failed to decode measurement from request: %w", err)
+	}
+	r.Body.Close()
+
+	return &m, nil
+}
+
+// getResampleOptionsFromRequest returns nil without a `step`; `interpolation` defaults
+// to linear and `max_gap` to DefaultMaxGap, `max_gap=P0D` fills gaps of any length.
+func getResampleOptionsFromRequest(r *http.Request, period measurement.Period) (*measurement.ResampleOptions, error) {
+	query := r.URL.Query()
+	stepString := query.Get("step")
+	if stepString == "" {
+		if query.Get("interpolation") != "" || query.Get("max_gap") != "" {
+			return nil, response.NewValidationProblem().WithFieldError("step", "step is required to resample")
+		}
+		return nil, nil
+	}
+
+	step, err := measurement.ParseStep(stepString)
+	if err != nil {
+		return nil, response.NewValidationProblem().WithFieldError("step", "step must be an ISO 8601 duration of at least PT1M, e.g. PT15M")
+	}
+	if points := int64(period.End-period.Start) / int64(step.Seconds()); points >= measurement.MaxResamplePoints {
+		return nil, response.NewValidationProblem().WithFieldError("step", fmt.Sprintf("the period has more than %d steps, use a longer step", measurement.MaxResamplePoints))
+	}
+
+	interpolation, err := measurement.ParseInterpolation(query.Get("interpolation"))
+	if err != nil {
+		return nil, response.NewValidationProblem().WithFieldError("interpolation", "interpolation must be linear, previous or none")
+	}
+
+	maxGap := DefaultMaxGap
+	if maxGapString := query.Get("max_gap"); maxGapString != "" {
+		parsed, err := duration.Parse(maxGapString)
+		if err != nil || parsed.ToTimeDuration() < 0 {
+			return nil, response.NewValidationProblem().WithFieldError("max_gap", "max_gap must be an ISO 8601 duration, e.g. PT2H")
+		}
+		maxGap = parsed.ToTimeDuration()
+	}
+
+	return &measurement.ResampleOptions{Step: step, Interpolation: interpolation, MaxGap: maxGap}, nil
+}
+
+// getPeriodFromRequest reads either the period or the start and end parameters;
+// invalid parameters are returned as a validation problem.
+func getPeriodFromRequest(r *http.Request) (*measurement.Period, error) {
+	periodString := r.URL.Query().Get("period")
+	if periodString != "" {
+		period, err := measurement.NewFromISO8601Duration(periodString)
+		if err != nil {
+			return nil, response.NewValidationProblem().WithFieldError("period", "period must be an ISO 8601 duration, e.g. P7D")
+		}
+		if !period.IsValid() {
+			return nil, response.NewValidationProblem().WithFieldError("period", "period must be positive")
+		}
+		return period, nil
+	}
+
+	startString := r.URL.Query().Get("start")
+	endString := r.URL.Query().Get("end")
+	if startString == "" || endString == "" {
+		problem := response.NewValidationProblem()
+		if startString == "" {
+			problem.WithFieldError("start", "start is required without period")
+		}
+		if endString == "" {
+			problem.WithFieldError("end", "end is required without period")
+		}
+		return nil, problem
+	}
+
+	period := measurement.Period{}
+	startEpoch, err := measurement.ParseEpoch(startString)
+	if err != nil {
+		return nil, response.NewValidationProblem().WithFieldError("start", err.Error())
+	}
+	period.Start = startEpoch
+	period.End = measurement.CurrentEpoch()
+
+	if endString != "" {
+		endEpoch, err := measurement.ParseEpoch(endString)
+		if err != nil {
+			return nil, response.NewValidationProblem().WithFieldError("end", err.Error())
+		}
+		period.End = endEpoch
+	}
+
+	if !period.IsValid() {
+		return nil, response.NewValidationProblem().WithFieldError("start", "start must be before end")
+	}
+
+	return &period, nil
+}
+
+func newTimeseriesFromRequest(r *http.Request, measurementName string) (*measurement.Timeseries, error) {
+	var timeseries measurement.Timeseries
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&timeseries); err != nil {
+		return nil, fmt.Errorf("failed to decode timeseries from request: %w", err)
+	}
+	r.Body.Close()
+
+	timeseries.Name = measurementName
+	if timeseries.Measurement == nil {
+		timeseries.Measurement = &measurement.Measurement{Name: measurementName}
+	}
+
+	return &timeseries, nil
+}
@@ -0,0 +1,167 @@
+// Package search serves the station search of the /search routes.
+package search
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/timgluz/wasserspiegel/api/apierror"
+	"github.com/timgluz/wasserspiegel/health"
+	"github.com/timgluz/wasserspiegel/log"
+	"github.com/timgluz/wasserspiegel/middleware"
+	"github.com/timgluz/wasserspiegel/ratelimit"
+	"github.com/timgluz/wasserspiegel/response"
+	"github.com/timgluz/wasserspiegel/secret"
+	"github.com/timgluz/wasserspiegel/station"
+)
+
+const (
+	DefaultSearchLimit   = 10
+	MaxSearchLimit       = 100
+	MinSearchQueryLength = 3
+	MaxSearchQueryLength = 100
+)
+
+type SearchResponse struct {
+	Results    []station.Station   `json:"results"`
+	Pagination response.Pagination `json:"pagination"`
+}
+
+// Component holds the dependencies of the search routes.
+type Component struct {
+	Logger            *slog.Logger
+	StationRepository station.Repository
+	SecretStore       secret.Store
+	RateLimiter       *ratelimit.Limiter
+	CachePolicies     response.CachePolicies
+}
+
+// HealthDependencies lists the stores reported by `/search/health`.
+func (c *Component) HealthDependencies() []health.Dependency {
+	return []health.Dependency{
+		health.Required("stations", c.StationRepository),
+		health.Required("secrets", c.SecretStore),
+		health.Required("ratelimits", c.RateLimiter),
+	}
+}
+
+// NewRouter registers the search routes.
+func NewRouter(c *Component) *httprouter.Router {
+	router := httprouter.New()
+	router.GET("/search/stations", middleware.BearerAuth(
+		middleware.RateLimit(newStationSearchHandler(c), c.RateLimiter, ratelimit.ClassSearch),
+		c.SecretStore, secret.ScopeStationsRead,
+	))
+	router.GET("/search"+health.HealthPath, health.NewHandler("search", c.HealthDependencies()...))
+
+	router.NotFound = response.NewNotFoundHandler(c.Logger)
+	router.MethodNotAllowed = response.NewMethodNotAllowedHandler()
+	return router
+}
+
+func newStationSearchHandler(appComponents *Component) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		logger := log.FromContext(r.Context(), appComponents.Logger)
+
+		if !appComponents.IsReady() {
+			logger.Error("Station app components are not ready")
+			apierror.RenderFatal(w, fmt.Errorf("station app components are not ready"))
+			return
+		}
+
+		searchQuery := r.URL.Query().Get("q")
+		if searchQuery == "" {
+			logger.Warn("Search query is empty")
+			response.RenderProblem(w, response.NewValidationProblem().WithFieldError("q", "search query cannot be empty"))
+			return
+		}
+		if len(searchQuery) < MinSearchQueryLength {
+			logger.Warn("Search query is too short", "query", searchQuery)
+			response.RenderProblem(w, response.NewValidationProblem().
+				WithFieldError("q", fmt.Sprintf("search query must be at least %d characters long", MinSearchQueryLength)))
+			return
+		}
+		if len(searchQuery) > MaxSearchQueryLength {
+			logger.Warn("Search query is too long", "query", searchQuery)
+			response.RenderProblem(w, response.NewValidationProblem().
+				WithFieldError("q", fmt.Sprintf("search query must not exceed %d characters", MaxSearchQueryLength)))
+			return
+		}
+
+		searchQuery = strings.ToLower(strings.TrimSpace(searchQuery))
+		queryPagination := response.NewPaginationFromRequest(r)
+		collection, err := appComponents.StationRepository.List(context.Background(), queryPagination.Offset, -1)
+		if err != nil {
+			logger.Error("Failed to fetch stations", "error", err)
+			apierror.RenderFatal(w, err)
+			return
+		}
+
+		logger.Debug("Searching stations", "query", searchQuery)
+		results := make([]station.Station, 0)
+		for _, s := range collection.Stations {
+			if len(results) >= queryPagination.Limit {
+				logger.Debug("Reached maximum search results limit", "limit", MaxSearchLimit)
+				break
+			}
+
+			if strings.Contains(strings.ToLower(s.Name), searchQuery) ||
+				strings.Contains(strings.ToLower(s.ID), searchQuery) {
+				results = append(results, s)
+			}
+		}
+
+		queryPagination.Total = len(results)
+		response.RenderConditionalJSON(w, r, SearchResponse{
+			Results:    results,
+			Pagination: queryPagination,
+		}, response.Validators{CacheControl: appComponents.CachePolicies.Get(response.CacheRouteSearch)})
+	}
+}
+
+func (c *Component) IsReady() bool {
+	if c.Logger == nil {
+		fmt.Println("Logger of search Component is not initialized")
+		return false
+	}
+
+	if c.StationRepository == nil {
+		c.Logger.Error("Station repository is not initialized")
+		return false
+	}
+
+	if c.SecretStore == nil {
+		c.Logger.Error("Secret store is not initialized")
+		return false
+	}
+
+	if c.RateLimiter == nil || !c.RateLimiter.IsReady() {
+		c.Logger.Error("Rate limiter is not initialized or not ready")
+		return false
+	}
+
+	c.Logger.Debug("Search component is ready")
+	return true
+}
+
+func (c *Component) Close() error {
+	if c.StationRepository != nil {
+		if err := c.StationRepository.Close(); err != nil {
+			c.Logger.Error("Failed to close station repository", "error", err)
+			return err
+		}
+	}
+
+	if c.RateLimiter != nil {
+		if err := c.RateLimiter.Close(); err != nil {
+			c.Logger.Error("Failed to close rate limiter", "error", err)
+		}
+	}
+
+	c.Logger.Info("Search component closed successfully")
+	return nil
+}
@@ -0,0 +1,486 @@
+// Package stations serves the stations and their water levels of the /stations routes.
+package stations
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/sosodev/duration"
+	"github.com/timgluz/wasserspiegel/api/apierror"
+	"github.com/timgluz/wasserspiegel/health"
+	"github.com/timgluz/wasserspiegel/kvstore"
+	"github.com/timgluz/wasserspiegel/log"
+	"github.com/timgluz/wasserspiegel/measurement"
+	"github.com/timgluz/wasserspiegel/middleware"
+	"github.com/timgluz/wasserspiegel/ratelimit"
+	"github.com/timgluz/wasserspiegel/response"
+	"github.com/timgluz/wasserspiegel/secret"
+	"github.com/timgluz/wasserspiegel/station"
+)
+
+var (
+	ErrNotFound = fmt.Errorf("request resource does not exist")
+)
+
+type StationDashboard struct {
+	Station    *station.Station              `json:"station"`
+	WaterLevel *station.WaterLevelCollection `json:"water_level"`
+	// Historical ranks the latest water level against the baseline of its day of the year.
+	Historical *measurement.HistoricalContext `json:"historical,omitempty"`
+}
+
+// Component holds the stateful components for the station routes.
+// it is inspired by Clojure components library: https://github.com/stuartsierra/component
+type Component struct {
+	StationRepository station.Repository
+	// MeasurementRepository holds the baselines of the historical context, it's optional.
+	MeasurementRepository measurement.Repository
+	Providers             *station.Registry
+	ProviderCache         kvstore.Store
+	SecretStore           secret.Store
+	RateLimiter           *ratelimit.Limiter
+	CachePolicies         response.CachePolicies
+	Logger                *slog.Logger
+}
+
+func (s *Component) Close() {
+	if s.Providers != nil {
+		if err := s.Providers.Close(); err != nil {
+			s.Logger.Error("Failed to close station providers", "error", err)
+		}
+		s.Providers = nil
+	}
+
+	if s.ProviderCache != nil {
+		s.ProviderCache.Close()
+		s.ProviderCache = nil
+	}
+
+	if s.MeasurementRepository != nil {
+		if err := s.MeasurementRepository.Close(); err != nil {
+			s.Logger.Error("Failed to close measurement repository", "error", err)
+		}
+		s.MeasurementRepository = nil
+	}
+
+	if s.StationRepository == nil {
+		return
+	}
+
+	if err := s.StationRepository.Close(); err != nil {
+		s.Logger.Error("Failed to close station repository", "error", err)
+	}
+	s.StationRepository = nil
+}
+
+// IsReady checks if all components of the station app are ready.
+func (s *Component) IsReady() bool {
+	if s.Logger == nil {
+		fmt.Println("Logger of station Component is not initialized")
+		return false
+	}
+
+	if s.StationRepository == nil {
+		s.Logger.Error("Station repository is not initialized")
+		return false
+	}
+
+	if !s.StationRepository.IsReady() {
+		s.Logger.Error("Station repository is not ready")
+		return false
+	}
+
+	if s.Providers == nil {
+		s.Logger.Error("Station providers are not initialized")
+		return false
+	}
+
+	if !s.Providers.IsReady() {
+		s.Logger.Error("Station providers are not ready")
+		return false
+	}
+
+	if s.SecretStore == nil {
+		s.Logger.Error("Secret store is not initialized")
+		return false
+	}
+
+	if s.RateLimiter == nil || !s.RateLimiter.IsReady() {
+		s.Logger.Error("Rate limiter is not initialized or not ready")
+		return false
+	}
+
+	s.Logger.Info("Station component is ready")
+	return true
+}
+
+// HealthDependencies lists the stores and providers reported by `/stations/health`.
+func (s *Component) HealthDependencies() []health.Dependency {
+	dependencies := []health.Dependency{
+		health.Required("stations", s.StationRepository),
+		health.Required("secrets", s.SecretStore),
+		health.Required("ratelimits", s.RateLimiter),
+	}
+	if s.MeasurementRepository != nil {
+		dependencies = append(dependencies, health.Optional("measurements", s.MeasurementRepository))
+	}
+
+	if s.Providers != nil {
+		dependencies = append(dependencies, s.Providers.HealthDependencies()...)
+	}
+
+	return dependencies
+}
+
+// NewRouter registers the station routes.
+func NewRouter(c *Component) *httprouter.Router {
+	secretStore, limiter := c.SecretStore, c.RateLimiter
+	router := httprouter.New()
+	healthHandler := health.NewHandler("stations", c.HealthDependencies()...)
+	router.GET("/stations", middleware.BearerAuth(middleware.RateLimit(newStationsHandler(c), limiter, ratelimit.ClassRead), secretStore, secret.ScopeStationsRead))
+	router.GET("/stations/:id/waterlevel/", middleware.BearerAuth(middleware.RateLimit(newWaterLevelHandler(c), limiter, ratelimit.ClassRead), secretStore, secret.ScopeStationsRead))
+	router.GET("/stations/:id", middleware.ReservedSegment("id", "health", healthHandler,
+		middleware.BearerAuth(middleware.RateLimit(newStationHandler(c), limiter, ratelimit.ClassRead), secretStore, secret.ScopeStationsRead)))
+	router.NotFound = response.NewNotFoundHandler(c.Logger)
+	router.MethodNotAllowed = response.NewMethodNotAllowedHandler()
+
+	return router
+}
+
+func newStationsHandler(appComponents *Component) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		logger := log.FromContext(r.Context(), appComponents.Logger)
+
+		logger.Debug("Fetching all stations")
+		queryPagination := response.NewPaginationFromRequest(r)
+		stationCollection, err := fetchCachedStations(appComponents, queryPagination)
+		if err != nil {
+			logger.Error("Failed to fetch stations", "error", err)
+			apierror.RenderFatal(w, err)
+			return
+		}
+
+		if stationCollection == nil {
+			logger.Warn("No stations found in the collection")
+			apierror.Render(w, ErrNotFound, http.StatusNotFound)
+			return
+		}
+
+		queryPagination.Total = len(stationCollection.Stations)
+		response.RenderConditionalJSON(w, r, map[string]interface{}{
+			"stations":   stationCollection.Stations,
+			"pagination": queryPagination,
+		}, response.Validators{CacheControl: appComponents.CachePolicies.Get(response.CacheRouteStations)})
+	}
+}
+
+func newStationHandler(appComponents *Component) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		logger := log.FromContext(r.Context(), appComponents.Logger)
+		stationID := params.ByName("id")
+		if stationID == "" {
+			apierror.Render(w, ErrNotFound, http.StatusNotFound)
+			return
+		}
+
+		logger.Debug("Fetching station by ID", "id", stationID)
+		stationItem, err := fetchCachedStationByID(appComponents, stationID)
+		if err != nil {
+			logger.Error("Failed to fetch station by ID", "id", stationID, "error", err)
+			apierror.RenderFatal(w, err)
+			return
+		}
+
+		if stationItem == nil {
+			logger.Warn("Station not found", "id", stationID)
+			apierror.Render(w, fmt.Errorf("%w: %s", station.ErrStationNotFound, stationID), http.StatusNotFound)
+			return
+		}
+
+		trendOptions, err := getTrendOptionsFromRequest(r)
+		if err != nil {
+			apierror.Render(w, err, http.StatusBadRequest)
+			return
+		}
+
+		waterLevelCollection, err := fetchCachedWaterLevels(appComponents, *stationItem, trendOptions)
+		if err != nil {
+			logger.Error("Failed to fetch water levels for station", "id", stationID, "error", err)
+			waterLevelCollection = &station.WaterLevelCollection{
+				StationID:    stationItem.ID,
+				Start:        station.DefaultTimePeriod, // Default start period
+				End:          station.DefaultTimePeriod, // Default end period
+				Unit:         station.UnitCM,            // Default unit for water level measurements
+				Measurements: []station.Measurement{},
+			}
+		}
+
+		stationDashboard := &StationDashboard{
+			Station:    stationItem,
+			WaterLevel: waterLevelCollection,
+			Historical: fetchHistoricalContext(r.Context(), appComponents, *stationItem, waterLevelCollection.Latest),
+		}
+
+		if unit := r.URL.Query().Get("unit"); unit != "" {
+			if err := convertWaterLevelUnit(waterLevelCollection, stationDashboard.Historical, unit); err != nil {
+				response.RenderProblem(w, response.NewValidationProblem().WithFieldError("unit", err.Error()))
+				return
+			}
+		}
+
+		response.RenderConditionalJSON(w, r, stationDashboard, response.Validators{
+			LastModified: waterLevelCollection.LastModified(),
+			CacheControl: appComponents.CachePolicies.Get(response.CacheRouteWaterLevels),
+		})
+	}
+}
+
+func newWaterLevelHandler(appComponents *Component) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		logger := log.FromContext(r.Context(), appComponents.Logger)
+		stationID := params.ByName("id")
+		logger.Debug("Fetching water level for station", "id", stationID)
+
+		if stationID == "" {
+			apierror.Render(w, ErrNotFound, http.StatusNotFound)
+			return
+		}
+
+		stationItem, err := fetchCachedStationByID(appComponents, stationID)
+		if err != nil {
+			logger.Error("Failed to fetch station by ID", "id", stationID, "error", err)
+			apierror.RenderFatal(w, err)
+			return
+		}
+
+		if stationItem == nil {
+			logger.Warn("Station not found", "id", stationID)
+			apierror.Render(w, fmt.Errorf("%w: %s", station.ErrStationNotFound, stationID), http.StatusNotFound)
+			return
+		}
+
+		trendOptions, err := getTrendOptionsFromRequest(r)
+		if err != nil {
+			apierror.Render(w, err, http.StatusBadRequest)
+			return
+		}
+
+		waterLevelCollection, err := fetchCachedWaterLevels(appComponents, *stationItem, trendOptions)
+		if err != nil {
+			logger.Error("Failed to fetch water levels", "id", stationID, "error", err)
+			apierror.RenderFatal(w, err)
+			return
+		}
+
+		if waterLevelCollection == nil || len(waterLevelCollection.Measurements) == 0 {
+			logger.Warn("No water levels found for station", "id", stationID)
+			apierror.Render(w, ErrNotFound, http.StatusNotFound)
+			return
+		}
+
+		if unit := r.URL.Query().Get("unit"); unit != "" {
+			if err := convertWaterLevelUnit(waterLevelCollection, nil, unit); err != nil {
+				response.RenderProblem(w, response.NewValidationProblem().WithFieldError("unit", err.Error()))
+				return
+			}
+		}
+
+		response.RenderConditionalJSON(w, r, waterLevelCollection, response.Validators{
+			LastModified: waterLevelCollection.LastModified(),
+			CacheControl: appComponents.CachePolicies.Get(response.CacheRouteWaterLevels),
+		})
+	}
+}
+
+func fetchCachedStations(appComponents *Component, pagination response.Pagination) (*station.StationCollection, error) {
+	logger := appComponents.Logger
+	stationRepository := appComponents.StationRepository
+
+	if !stationRepository.IsReady() {
+		return nil, fmt.Errorf("station repository is not ready")
+	}
+
+	stationCollection, err := stationRepository.List(context.Background(), pagination.Offset, pagination.Limit)
+	if err != nil {
+		logger.Error("Failed to get stations from repository", "error", err)
+		return nil, err
+	}
+
+	logger.Debug("Stations found in repository, returning cached data", "count", len(stationCollection.Stations))
+	return stationCollection, nil
+}
+
+func fetchCachedStationByID(appComponents *Component, id string) (*station.Station, error) {
+	logger := appComponents.Logger
+	stationRepository := appComponents.StationRepository
+
+	if !stationRepository.IsReady() {
+		return nil, fmt.Errorf("%w: station repository is not ready", station.ErrKVStoreNotAvailable)
+	}
+
+	if id == "" {
+		return nil, fmt.Errorf("station ID cannot be empty")
+	}
+
+	logger.Debug("Checking if station exists in repository", "id", id)
+	station, err := stationRepository.GetByID(context.Background(), id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get station by ID: %w", err)
+	}
+
+	if err == nil && station != nil {
+		logger.Debug("Station found in repository, returning cached data", "id", id)
+		return station, nil
+	}
+
+	logger.Info("Successfully fetched and cached station", "id", id)
+	return station, nil
+}
+
+// fetchHistoricalContext ranks the latest water level against its baseline; it's nil
+// without a measurement repository, a baseline or a latest water level.
+func fetchHistoricalContext(ctx context.Context, appComponents *Component, stationItem station.Station, latest station.Measurement) *measurement.HistoricalContext {
+	if appComponents.MeasurementRepository == nil || latest.Timestamp == "" {
+		return nil
+	}
+
+	timestamp, err := station.ParseTimestamp(latest.Timestamp)
+	if err != nil {
+		appComponents.Logger.Warn("Failed to parse timestamp of latest water level", "id", stationItem.ID, "error", err)
+		return nil
+	}
+
+	measurementName := measurement.NewMeasurementName("waterlevel", stationItem.ID)
+	historical, err := measurement.RankAgainstBaseline(ctx, appComponents.MeasurementRepository, measurementName, latest.Value, measurement.Epoch(timestamp.Unix()))
+	if err != nil {
+		appComponents.Logger.Warn("Failed to rank latest water level against its baseline", "id", stationItem.ID, "error", err)
+		return nil
+	}
+
+	return historical
+}
+
+func fetchCachedWaterLevels(appComponents *Component, stationItem station.Station, trendOptions station.TrendOptions) (*station.WaterLevelCollection, error) {
+	logger := appComponents.Logger
+
+	stationID := stationItem.ID
+	provider, externalID, err := appComponents.Providers.ForStation(stationItem)
+	if err != nil {
+		logger.Error("Station has no external ID with a registered provider", "stationID", stationItem.ID, "error", err)
+		return nil, err
+	}
+
+	logger.Debug("Fetching water levels for station", "id", stationID, "provider", externalID.Name, "externalID", externalID.ID)
+	waterLevelCollection, err := provider.GetStationWaterLevel(context.Background(), externalID.ID)
+	if err != nil {
+		logger.Error("Failed to fetch water levels from provider", "id", stationID, "provider", externalID.Name, "externalID", externalID.ID, "error", err)
+		return nil, fmt.Errorf("failed to fetch water levels from provider: %w", err)
+	}
+
+	if waterLevelCollection != nil && waterLevelCollection.Stale {
+		logger.Warn("Serving stale water levels", "id", stationID, "fetchedAt", waterLevelCollection.FetchedAt)
+	}
+
+	if waterLevelCollection == nil || len(waterLevelCollection.Measurements) == 0 {
+		logger.Warn("No water levels found for station", "id", stationID)
+		return nil, fmt.Errorf("no water levels found for station with ID: %s", stationID)
+	}
+
+	// augment water level with latest measurement and unit
+	if waterLevelCollection.Unit == "" {
+		waterLevelCollection.Unit = station.UnitCM // Default unit for water level measurements
+	}
+	waterLevelCollection.Latest = waterLevelCollection.GetLatestMeasurement()
+	if err := waterLevelCollection.CalculateTrendsWithOptions(waterLevelCollection.Measurements, trendOptions); err != nil {
+		logger.Error("Failed to calculate trends for water levels", "id", stationID, "error", err)
+	}
+
+	logger.Debug("Successfully fetched water levels for station", "id", stationID, "count", len(waterLevelCollection.Measurements))
+	return waterLevelCollection, nil
+}
+
+// convertWaterLevelUnit converts the water levels, their trend and rate of change and
+// the optional historical context to the unit, e.g. m.
+func convertWaterLevelUnit(waterLevels *station.WaterLevelCollection, historical *measurement.HistoricalContext, unit string) error {
+	converter, err := measurement.NewUnitConverter(waterLevels.Unit, unit)
+	if err != nil {
+		return err
+	}
+
+	convert := func(m station.Measurement, value func(float64) float64) station.Measurement {
+		m.Value, m.Unit = value(m.Value), converter.To()
+		return m
+	}
+	for i := range waterLevels.Measurements {
+		waterLevels.Measurements[i] = convert(waterLevels.Measurements[i], converter.Value)
+	}
+	if waterLevels.Latest.Timestamp != "" {
+		waterLevels.Latest = convert(waterLevels.Latest, converter.Value)
+	}
+	for window, change := range waterLevels.Trend {
+		converted := convert(*change, converter.Delta)
+		waterLevels.Trend[window] = &converted
+	}
+	if waterLevels.RateOfChange != nil {
+		rateConverter, err := measurement.NewUnitConverter(waterLevels.RateOfChange.Unit, converter.To()+"/h")
+		if err != nil {
+			return err
+		}
+		rate := *waterLevels.RateOfChange
+		rate.Value, rate.Unit = rateConverter.Value(rate.Value), rateConverter.To()
+		waterLevels.RateOfChange = &rate
+	}
+	waterLevels.Unit = converter.To()
+
+	if historical != nil {
+		historical.ConvertUnit(converter)
+	}
+
+	return nil
+}
+
+// getTrendOptionsFromRequest reads the comma separated ISO 8601 durations of `trend`,
+// the IANA time zone `tz` of their day boundaries and `rate_window`; missing ones
+// keep the defaults of station.DefaultTrendOptions.
+func getTrendOptionsFromRequest(r *http.Request) (station.TrendOptions, error) {
+	query := r.URL.Query()
+	opts := station.DefaultTrendOptions()
+
+	if windows := query.Get("trend"); windows != "" {
+		opts.Windows = strings.Split(windows, ",")
+		if len(opts.Windows) > station.MaxTrendWindows {
+			return opts, response.NewValidationProblem().WithFieldError("trend", fmt.Sprintf("trend allows at most %d windows", station.MaxTrendWindows))
+		}
+		for _, window := range opts.Windows {
+			if _, err := station.ParseTrendWindow(window); err != nil {
+				return opts, response.NewValidationProblem().WithFieldError("trend", "trend must be ISO 8601 durations, e.g. P1D,P3D,P7D")
+			}
+		}
+	}
+
+	if name := query.Get("tz"); name != "" {
+		// Local would be the zone of the server
+		location, err := time.LoadLocation(name)
+		if err != nil || name == "Local" {
+			return opts, response.NewValidationProblem().WithFieldError("tz", "tz must be an IANA time zone, e.g. Europe/Berlin")
+		}
+		opts.Location = location
+	}
+
+	if rateWindow := query.Get("rate_window"); rateWindow != "" {
+		opts.RateWindow = 0
+		if parsed, err := duration.Parse(rateWindow); err == nil {
+			opts.RateWindow = parsed.ToTimeDuration()
+		}
+		if opts.RateWindow < station.MinRateWindow || opts.RateWindow > station.MaxRateWindow {
+			return opts, response.NewValidationProblem().WithFieldError("rate_window", "rate_window must be an ISO 8601 duration between PT15M and P1D")
+		}
+	}
+
+	return opts, nil
+}
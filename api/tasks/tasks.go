@@ -0,0 +1,341 @@
+// Package tasks runs the collection and dashboard jobs of the /tasks routes.
+package tasks
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/sosodev/duration"
+
+	"github.com/timgluz/wasserspiegel/api/apierror"
+	"github.com/timgluz/wasserspiegel/dashboard"
+	"github.com/timgluz/wasserspiegel/health"
+	"github.com/timgluz/wasserspiegel/i18n"
+	"github.com/timgluz/wasserspiegel/kvstore"
+	"github.com/timgluz/wasserspiegel/log"
+	"github.com/timgluz/wasserspiegel/measurement"
+	"github.com/timgluz/wasserspiegel/metrics"
+	"github.com/timgluz/wasserspiegel/middleware"
+	"github.com/timgluz/wasserspiegel/openapi"
+	"github.com/timgluz/wasserspiegel/ratelimit"
+	"github.com/timgluz/wasserspiegel/response"
+	"github.com/timgluz/wasserspiegel/secret"
+	"github.com/timgluz/wasserspiegel/station"
+	"github.com/timgluz/wasserspiegel/task"
+)
+
+// Component holds the repositories and providers used by the task routes.
+type Component struct {
+	MeasurementRepository measurement.Repository
+	DashboardRepository   dashboard.Repository
+	StationRepository     station.Repository
+
+	Providers     *station.Registry
+	ProviderCache kvstore.Store
+	SecretStore   secret.Store
+	RateLimiter   *ratelimit.Limiter
+	MetricsStore  *metrics.SQLStore
+
+	Logger *slog.Logger
+}
+
+// HealthDependencies lists the DB, stores and providers reported by `/tasks/health`; failing service metrics only degrade the tasks.
+func (c *Component) HealthDependencies() []health.Dependency {
+	dependencies := []health.Dependency{
+		health.Required("measurements", c.MeasurementRepository),
+		health.Required("dashboards", c.DashboardRepository),
+		health.Required("stations", c.StationRepository),
+		health.Optional("metrics", c.MetricsStore),
+		health.Required("secrets", c.SecretStore),
+		health.Required("ratelimits", c.RateLimiter),
+	}
+
+	if c.Providers != nil {
+		dependencies = append(dependencies, c.Providers.HealthDependencies()...)
+	}
+
+	return dependencies
+}
+
+// NewRouter registers the task routes.
+func NewRouter(app *Component) *httprouter.Router {
+	router := httprouter.New()
+	router.GET("/tasks"+health.HealthPath, health.NewHandler("tasks", app.HealthDependencies()...))
+	router.GET("/tasks/collectStationMeasurements", newOperationInfoHandler("Collect Station Measurements Info", http.MethodPost, "/tasks/collectStationMeasurements"))
+	router.POST("/tasks/collectStationMeasurements", middleware.BearerAuth(middleware.RateLimit(newCollectStationMeasurementsHandler(app), app.RateLimiter, ratelimit.ClassTask), app.SecretStore, secret.ScopeTasksRun))
+	router.GET("/tasks/buildDashboard", newOperationInfoHandler("Build Dashboard Info", http.MethodPost, "/tasks/buildDashboard"))
+	router.POST("/tasks/buildDashboard", middleware.BearerAuth(middleware.RateLimit(newBuildDashboardHandler(app), app.RateLimiter, ratelimit.ClassTask), app.SecretStore, secret.ScopeTasksRun))
+	router.GET("/tasks/buildBaselines", newOperationInfoHandler("Build Baselines Info", http.MethodPost, "/tasks/buildBaselines"))
+	router.POST("/tasks/buildBaselines", middleware.BearerAuth(middleware.RateLimit(newBuildBaselinesHandler(app), app.RateLimiter, ratelimit.ClassTask), app.SecretStore, secret.ScopeTasksRun))
+
+	router.NotFound = response.NewNotFoundHandler(app.Logger)
+	router.MethodNotAllowed = response.NewMethodNotAllowedHandler()
+	return router
+}
+
+// newOperationInfoHandler points GET requests of task endpoints to their OpenAPI
+// description in the document the task component serves.
+func newOperationInfoHandler(title, method, path string) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		link := openapi.OperationLink("/tasks", method, path)
+		w.Header().Add("Link", "<"+link+`>; rel="describedby"`)
+
+		response.RenderJSON(w, response.NewAPIDocumentationLinkResponse(title, link))
+	}
+}
+
+func newCollectStationMeasurementsHandler(app *Component) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		ctx := r.Context()
+		logger := log.FromContext(r.Context(), app.Logger)
+
+		// Example: Fetching a specific station ID from the request
+		stationID := r.URL.Query().Get("station_id")
+		if stationID == "" {
+			response.RenderProblem(w, response.NewValidationProblem().WithFieldError("station_id", "station_id is required"))
+			return
+		}
+
+		periodStr := r.URL.Query().Get("period")
+		if periodStr == "" {
+			periodStr = "P3D" // Default to 3 days if not provided
+		}
+
+		timePeriod, err := measurement.NewFromISO8601Duration(periodStr)
+		if err != nil {
+			logger.Error("Invalid time period format", "period", periodStr, "error", err)
+			response.RenderProblem(w, response.NewValidationProblem().WithFieldError("period", "period must be an ISO 8601 duration, e.g. P3D"))
+			return
+		}
+
+		logger.Info("Collecting water level measurements", "stationID", stationID, "period", timePeriod.String())
+		ctx, report := station.WithFetchReport(ctx)
+		job := task.NewStationWaterLevelCollector(app.MeasurementRepository,
+			app.StationRepository,
+			app.Providers,
+			logger,
+		).WithRecorder(app.MetricsStore)
+		err = job.Run(ctx, stationID, *timePeriod)
+
+		result := collectResult{
+			StationID:      stationID,
+			CircuitStates:  app.Providers.CircuitStates(),
+			ProviderEvents: report.Events(),
+		}
+		if err != nil {
+			logger.Error("Failed to collect water level measurements", "error", err, "providerEvents", len(result.ProviderEvents))
+			problem := apierror.Problem(fmt.Errorf("failed to collect water level measurements: %w", err), http.StatusInternalServerError)
+			problem.Data = result
+			response.RenderProblem(w, problem)
+			return
+		}
+
+		response.RenderJSON(w, response.NewPostResponse(true, "Water level successfully collected for station: "+stationID, result))
+	}
+}
+
+// collectResult reports the retries and circuit breaker events of the upstream requests of a collection run.
+type collectResult struct {
+	StationID      string               `json:"station_id"`
+	CircuitStates  map[string]string    `json:"circuit_states"`
+	ProviderEvents []station.FetchEvent `json:"provider_events,omitempty"`
+}
+
+func newBuildDashboardHandler(app *Component) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		ctx := r.Context()
+		logger := log.FromContext(r.Context(), app.Logger)
+
+		stationID := r.URL.Query().Get("station_id")
+		if stationID == "" {
+			response.RenderProblem(w, response.NewValidationProblem().WithFieldError("station_id", "station_id is required"))
+			return
+		}
+
+		builderOptions := task.NewDefaultDashboardBuilderOptions(stationID)
+
+		if languageCode := r.URL.Query().Get("language_code"); languageCode != "" {
+			if !i18n.IsSupportedLanguage(languageCode) {
+				response.RenderProblem(w, response.NewValidationProblem().WithFieldError("language_code", "language_code must be one of "+strings.Join(i18n.SupportedLanguages(), ", ")))
+				return
+			}
+			builderOptions.LanguageCode = languageCode
+		}
+
+		if timezone := r.URL.Query().Get("timezone"); timezone != "" {
+			if _, err := i18n.LoadLocation(timezone); err != nil {
+				response.RenderProblem(w, response.NewValidationProblem().WithFieldError("timezone", "timezone must be utc or an IANA time zone, e.g. Europe/Berlin"))
+				return
+			}
+			builderOptions.Timezone = timezone
+		}
+
+		if horizon := r.URL.Query().Get("forecast_horizon"); horizon != "" {
+			forecastHorizon := time.Duration(0)
+			if parsed, err := duration.Parse(horizon); err == nil {
+				forecastHorizon = parsed.ToTimeDuration()
+			}
+			if forecastHorizon < measurement.MinForecastHorizon || forecastHorizon > measurement.MaxForecastHorizon {
+				response.RenderProblem(w, response.NewValidationProblem().WithFieldError("forecast_horizon", "forecast_horizon must be an ISO 8601 duration between PT6H and PT48H"))
+				return
+			}
+			builderOptions.ForecastHorizon = forecastHorizon
+		}
+
+		if windows := r.URL.Query().Get("trend"); windows != "" {
+			builderOptions.TrendWindows = strings.Split(windows, ",")
+			trendOptions := station.TrendOptions{Windows: builderOptions.TrendWindows, RateWindow: station.DefaultRateWindow}
+			if err := trendOptions.Validate(); err != nil {
+				response.RenderProblem(w, response.NewValidationProblem().WithFieldError("trend", fmt.Sprintf("trend must be at most %d ISO 8601 durations, e.g. P1D,P3D,P7D", station.MaxTrendWindows)))
+				return
+			}
+		}
+
+		if window := r.URL.Query().Get("rate_window"); window != "" {
+			rateWindow := time.Duration(0)
+			if parsed, err := duration.Parse(window); err == nil {
+				rateWindow = parsed.ToTimeDuration()
+			}
+			if rateWindow < station.MinRateWindow || rateWindow > station.MaxRateWindow {
+				response.RenderProblem(w, response.NewValidationProblem().WithFieldError("rate_window", "rate_window must be an ISO 8601 duration between PT15M and P1D"))
+				return
+			}
+			builderOptions.RateWindow = rateWindow
+		}
+
+		logger.Info("Building dashboard", "stationID", stationID, "languageCode", builderOptions.LanguageCode, "timezone", builderOptions.Timezone)
+		job := task.NewDashboardBuilder(app.StationRepository,
+			app.DashboardRepository,
+			app.MeasurementRepository,
+			logger,
+		)
+		if err := job.Run(ctx, builderOptions); err != nil {
+			logger.Error("Failed to build dashboard", "error", err)
+			apierror.Render(w, fmt.Errorf("failed to build dashboard: %w", err), http.StatusInternalServerError)
+			return
+		}
+
+		response.RenderJSON(w, response.NewPostResponse(true, "Dashboard successfully built: "+stationID, builderOptions))
+	}
+}
+
+// newBuildBaselinesHandler recomputes the baselines of the station, or of all stations without `station_id`.
+func newBuildBaselinesHandler(app *Component) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		logger := log.FromContext(r.Context(), app.Logger)
+		opts := task.NewDefaultBaselineBuilderOptions(r.URL.Query().Get("station_id"))
+
+		if windowDays := r.URL.Query().Get("window_days"); windowDays != "" {
+			days, err := strconv.Atoi(windowDays)
+			if err != nil || days < 1 || days > measurement.MaxBaselineWindowDays {
+				response.RenderProblem(w, response.NewValidationProblem().WithFieldError("window_days", fmt.Sprintf("window_days must be between 1 and %d", measurement.MaxBaselineWindowDays)))
+				return
+			}
+			opts.WindowDays = days
+		}
+
+		logger.Info("Building baselines", "stationID", opts.StationID, "windowDays", opts.WindowDays)
+		results, err := task.NewBaselineBuilder(app.MeasurementRepository, logger).Run(r.Context(), opts)
+		if err != nil {
+			logger.Error("Failed to build baselines", "error", err)
+			apierror.Render(w, fmt.Errorf("failed to build baselines: %w", err), http.StatusInternalServerError)
+			return
+		}
+
+		response.RenderJSON(w, response.NewPostResponse(true, "Baselines successfully built", results))
+	}
+}
+
+func (c *Component) IsReady() bool {
+	if c.Logger == nil {
+		fmt.Println("Logger of task Component is not initialized")
+		return false
+	}
+
+	if c.MeasurementRepository == nil || !c.MeasurementRepository.IsReady() {
+		c.Logger.Error("Measurement repository is not initialized or not ready")
+		return false
+	}
+
+	if c.DashboardRepository == nil || !c.DashboardRepository.IsReady() {
+		c.Logger.Error("Dashboard repository is not initialized or not ready")
+		return false
+	}
+
+	if c.StationRepository == nil || !c.StationRepository.IsReady() {
+		c.Logger.Error("Station repository is not initialized or not ready")
+		return false
+	}
+
+	if c.Providers == nil || !c.Providers.IsReady() {
+		c.Logger.Error("Station providers are not initialized or not ready")
+		return false
+	}
+
+	if c.SecretStore == nil || !c.SecretStore.IsReady() {
+		c.Logger.Error("Secret store is not initialized or not ready")
+		return false
+	}
+
+	if c.MetricsStore == nil || !c.MetricsStore.IsReady() {
+		c.Logger.Error("Metrics store is not initialized or not ready")
+		return false
+	}
+
+	if c.RateLimiter == nil || !c.RateLimiter.IsReady() {
+		c.Logger.Error("Rate limiter is not initialized or not ready")
+		return false
+	}
+
+	return true
+}
+
+func (c *Component) Close() error {
+	if c.MeasurementRepository != nil {
+		if err := c.MeasurementRepository.Close(); err != nil {
+			c.Logger.Error("Failed to close measurement repository", "error", err)
+		}
+	}
+
+	if c.DashboardRepository != nil {
+		if err := c.DashboardRepository.Close(); err != nil {
+			c.Logger.Error("Failed to close dashboard repository", "error", err)
+		}
+	}
+
+	if c.StationRepository != nil {
+		if err := c.StationRepository.Close(); err != nil {
+			c.Logger.Error("Failed to close station repository", "error", err)
+		}
+	}
+
+	if c.Providers != nil {
+		if err := c.Providers.Close(); err != nil {
+			c.Logger.Error("Failed to close station provider", "error", err)
+		}
+	}
+
+	if c.ProviderCache != nil {
+		c.ProviderCache.Close()
+	}
+
+	if c.SecretStore != nil {
+		if err := c.SecretStore.Close(); err != nil {
+			c.Logger.Error("Failed to close secret store", "error", err)
+		}
+	}
+
+	if c.RateLimiter != nil {
+		if err := c.RateLimiter.Close(); err != nil {
+			c.Logger.Error("Failed to close rate limiter", "error", err)
+		}
+	}
+
+	c.Logger.Info("Task components closed successfully")
+	return nil
+}
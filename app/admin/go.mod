@@ -0,0 +1,17 @@
+module github.com/timgluz/wasserspiegel/app/admin
+
+go 1.25.1
+
+replace github.com/timgluz/wasserspiegel => ./../..
+
+require (
+	github.com/sosodev/duration v1.3.1
+	github.com/spinframework/spin-go-sdk/v2 v2.2.1
+	github.com/timgluz/wasserspiegel v0.0.0-00010101000000-000000000000
+)
+
+require (
+	github.com/gosimple/slug v1.15.0 // indirect
+	github.com/gosimple/unidecode v1.0.1 // indirect
+	github.com/julienschmidt/httprouter v1.3.0 // indirect
+)
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sosodev/duration"
+	spinhttp "github.com/spinframework/spin-go-sdk/v2/http"
+	spinvars "github.com/spinframework/spin-go-sdk/v2/variables"
+
+	"github.com/timgluz/wasserspiegel/api/apierror"
+	"github.com/timgluz/wasserspiegel/health"
+	"github.com/timgluz/wasserspiegel/log"
+	"github.com/timgluz/wasserspiegel/middleware"
+	"github.com/timgluz/wasserspiegel/openapi"
+	"github.com/timgluz/wasserspiegel/ratelimit"
+	"github.com/timgluz/wasserspiegel/response"
+	"github.com/timgluz/wasserspiegel/secret"
+)
+
+const (
+	MaxKeyNameLength = 100
+	MaxUsageDays     = ratelimit.UsageRetentionDays
+)
+
+type adminAppConfig struct {
+	SecretStoreName    string `json:"secret_store_name"`
+	RateLimitStoreName string `json:"ratelimit_store_name"`
+	RateLimits         string `json:"rate_limits"`
+	APIKey             string `json:"api_key"`
+	LogLevel           string `json:"log_level"`
+	LogFormat          string `json:"log_format"`
+}
+
+func newAdminAppConfigFromSpinVariables() (*adminAppConfig, error) {
+	apiKey, err := spinvars.Get("api_key")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get api_key: %w", err)
+	}
+
+	secretStoreName, err := spinvars.Get("secrets_store_name")
+	if err != nil || secretStoreName == "" {
+		secretStoreName = "secrets"
+	}
+
+	rateLimitStoreName, err := spinvars.Get("ratelimit_store_name")
+	if err != nil || rateLimitStoreName == "" {
+		rateLimitStoreName = "ratelimits"
+	}
+
+	rateLimits, err := spinvars.Get("rate_limits")
+	if err != nil {
+		rateLimits = ""
+	}
+
+	logLevel, err := spinvars.Get("log_level")
+	if err != nil {
+		logLevel = "info"
+	}
+
+	logFormat, err := spinvars.Get("log_format")
+	if err != nil {
+		logFormat = log.FormatText
+	}
+
+	return &adminAppConfig{
+		SecretStoreName:    secretStoreName,
+		RateLimitStoreName: rateLimitStoreName,
+		RateLimits:         rateLimits,
+		APIKey:             apiKey,
+		LogLevel:           logLevel,
+		LogFormat:          logFormat,
+	}, nil
+}
+
+type adminApp struct {
+	config      *adminAppConfig
+	keyStore    *secret.KVStore
+	rateLimiter *ratelimit.Limiter
+	logger      *slog.Logger
+}
+
+// CreateKeyRequest is the body of POST /admin/keys; ExpiresIn is an ISO 8601 duration, e.g. P90D.
+type CreateKeyRequest struct {
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	ExpiresIn string   `json:"expires_in,omitempty"`
+}
+
+func init() {
+	spinhttp.Handle(func(w http.ResponseWriter, r *http.Request) {
+		if openapi.ServeDocument(w, r, "/admin") {
+			return
+		}
+
+		config, err := newAdminAppConfigFromSpinVariables()
+		if err != nil {
+			apierror.RenderFatal(w, fmt.Errorf("failed to load admin app configuration: %w", err))
+			return
+		}
+
+		app, err := initAdminApp(*config)
+		if err != nil {
+			fmt.Println("Error initializing admin app components:", err)
+			apierror.RenderFatal(w, fmt.Errorf("failed to initialize admin app components"))
+			return
+		}
+		defer app.Close()
+
+		if !app.IsReady() {
+			if health.ServeUnready(w, r, "/admin", "admin", app.healthDependencies()...) {
+				return
+			}
+			apierror.RenderFatal(w, fmt.Errorf("admin app components are not ready"))
+			return
+		}
+
+		router := newAdminRouter(app)
+		w.Header().Set("Link", openapi.ServiceDescLink("/admin"))
+		middleware.ObserveRouter(router, app.logger).ServeHTTP(w, r)
+	})
+}
+
+func main() {}
+
+// healthDependencies lists the stores reported by `/admin/health`.
+func (c *adminApp) healthDependencies() []health.Dependency {
+	return []health.Dependency{
+		health.Required("secrets", c.keyStore),
+		health.Required("ratelimits", c.rateLimiter),
+	}
+}
+
+func newAdminRouter(app *adminApp) *spinhttp.Router {
+	router := spinhttp.NewRouter()
+
+	adminOnly := func(h spinhttp.RouterHandle) spinhttp.RouterHandle {
+		return middleware.BearerAuth(middleware.RateLimit(h, app.rateLimiter, ratelimit.ClassAdmin), app.keyStore, secret.ScopeKeysAdmin)
+	}
+
+	router.POST("/admin/keys", adminOnly(newCreateKeyHandler(app)))
+	router.GET("/admin/keys", adminOnly(newListKeysHandler(app)))
+	router.DELETE("/admin/keys/:id", adminOnly(newRevokeKeyHandler(app)))
+	router.GET("/admin/keys/:id/usage", adminOnly(newKeyUsageHandler(app)))
+	router.GET("/admin/usage", adminOnly(newDailyUsageHandler(app)))
+	router.GET("/admin"+health.HealthPath, health.NewHandler("admin", app.healthDependencies()...))
+
+	router.NotFound = response.NewNotFoundHandler(app.logger)
+	router.MethodNotAllowed = response.NewMethodNotAllowedHandler()
+	return router
+}
+
+func newCreateKeyHandler(app *adminApp) spinhttp.RouterHandle {
+	return func(w http.ResponseWriter, r *http.Request, params spinhttp.Params) {
+		logger := log.FromContext(r.Context(), app.logger)
+
+		var req CreateKeyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			logger.Error("Failed to decode key request", "error", err)
+			apierror.Render(w, fmt.Errorf("invalid request body: %w", err), http.StatusBadRequest)
+			return
+		}
+
+		problem := response.NewValidationProblem()
+		if req.Name == "" || len(req.Name) > MaxKeyNameLength {
+			problem.WithFieldError("name", fmt.Sprintf("name is required and must be at most %d characters", MaxKeyNameLength))
+		}
+
+		if len(req.Scopes) == 0 {
+			problem.WithFieldError("scopes", "at least one scope is required")
+		} else if err := secret.ValidateScopes(req.Scopes); err != nil {
+			problem.WithFieldError("scopes", err.Error())
+		}
+
+		var expiresAt int64
+		if req.ExpiresIn != "" {
+			expiresIn, err := duration.Parse(req.ExpiresIn)
+			if err != nil || expiresIn.ToTimeDuration() <= 0 {
+				problem.WithFieldError("expires_in", fmt.Sprintf("invalid expires_in duration: %s", req.ExpiresIn))
+			} else {
+				expiresAt = time.Now().Add(expiresIn.ToTimeDuration()).Unix()
+			}
+		}
+
+		if len(problem.Errors) > 0 {
+			response.RenderProblem(w, problem)
+			return
+		}
+
+		token, apiKey, err := app.keyStore.CreateKey(req.Name, req.Scopes, expiresAt)
+		if err != nil {
+			logger.Error("Failed to create API key", "name", req.Name, "error", err)
+			apierror.RenderFatal(w, fmt.Errorf("failed to create API key: %w", err))
+			return
+		}
+
+		response.RenderJSON(w, response.NewSuccessResponse("API key created", secret.IssuedKey{APIKey: *apiKey, Token: token}))
+	}
+}
+
+func newListKeysHandler(app *adminApp) spinhttp.RouterHandle {
+	return func(w http.ResponseWriter, r *http.Request, params spinhttp.Params) {
+		apiKeys, err := app.keyStore.ListKeys()
+		if err != nil {
+			app.logger.Error("Failed to list API keys", "error", err)
+			apierror.RenderFatal(w, fmt.Errorf("failed to list API keys: %w", err))
+			return
+		}
+
+		pagination := response.NewPagination(0, len(apiKeys), len(apiKeys))
+		response.RenderJSON(w, response.NewCollectionResponse(apiKeys, &pagination))
+	}
+}
+
+func newRevokeKeyHandler(app *adminApp) spinhttp.RouterHandle {
+	return func(w http.ResponseWriter, r *http.Request, params spinhttp.Params) {
+		keyID := params.ByName("id")
+		if keyID == "" {
+			apierror.Render(w, fmt.Errorf("key ID is required"), http.StatusBadRequest)
+			return
+		}
+
+		if err := app.keyStore.RevokeKey(keyID); err != nil {
+			if errors.Is(err, secret.ErrSecretNotFound) {
+				apierror.Render(w, fmt.Errorf("%w: %s", secret.ErrSecretNotFound, keyID), http.StatusNotFound)
+				return
+			}
+
+			app.logger.Error("Failed to revoke API key", "id", keyID, "error", err)
+			apierror.RenderFatal(w, fmt.Errorf("failed to revoke API key: %w", err))
+			return
+		}
+
+		response.RenderJSON(w, response.NewSuccessResponse("API key revoked", nil))
+	}
+}
+
+// newKeyUsageHandler returns the daily request counters of a key, newest day first.
+func newKeyUsageHandler(app *adminApp) spinhttp.RouterHandle {
+	return func(w http.ResponseWriter, r *http.Request, params spinhttp.Params) {
+		keyID := params.ByName("id")
+		if keyID == "" {
+			apierror.Render(w, fmt.Errorf("key ID is required"), http.StatusBadRequest)
+			return
+		}
+
+		days := 7
+		if value := r.URL.Query().Get("days"); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 1 || parsed > MaxUsageDays {
+				response.RenderProblem(w, response.NewValidationProblem().
+					WithFieldError("days", fmt.Sprintf("days must be between 1 and %d", MaxUsageDays)))
+				return
+			}
+			days = parsed
+		}
+
+		usages, err := app.rateLimiter.Usage(keyID, days)
+		if err != nil {
+			app.logger.Error("Failed to read key usage", "id", keyID, "error", err)
+			apierror.RenderFatal(w, fmt.Errorf("failed to read key usage: %w", err))
+			return
+		}
+
+		response.RenderJSON(w, response.NewCollectionResponse(usages, nil))
+	}
+}
+
+// newDailyUsageHandler returns the request counters of all keys on a UTC day, today by default.
+func newDailyUsageHandler(app *adminApp) spinhttp.RouterHandle {
+	return func(w http.ResponseWriter, r *http.Request, params spinhttp.Params) {
+		date := r.URL.Query().Get("date")
+		if date == "" {
+			date = time.Now().UTC().Format(time.DateOnly)
+		}
+
+		if _, err := time.Parse(time.DateOnly, date); err != nil {
+			response.RenderProblem(w, response.NewValidationProblem().WithFieldError("date", "date must be formatted as YYYY-MM-DD"))
+			return
+		}
+
+		usages, err := app.rateLimiter.UsageByDate(date)
+		if err != nil {
+			app.logger.Error("Failed to read daily usage", "date", date, "error", err)
+			apierror.RenderFatal(w, fmt.Errorf("failed to read daily usage: %w", err))
+			return
+		}
+
+		response.RenderJSON(w, response.NewCollectionResponse(usages, nil))
+	}
+}
+
+func initAdminApp(config adminAppConfig) (*adminApp, error) {
+	logger := log.NewLogger(config.LogLevel, config.LogFormat).With("component", "admin")
+
+	keyStore, err := secret.NewSpinKVStore(config.SecretStoreName, config.APIKey, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create secret store: %w", err)
+	}
+
+	rateLimiter, err := ratelimit.NewSpinLimiter(config.RateLimitStoreName, config.RateLimits, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rate limiter: %w", err)
+	}
+
+	return &adminApp{
+		config:      &config,
+		keyStore:    keyStore,
+		rateLimiter: rateLimiter,
+		logger:      logger,
+	}, nil
+}
+
+func (c *adminApp) IsReady() bool {
+	if c.logger == nil {
+		fmt.Println("Logger of admin app components is not initialized")
+		return false
+	}
+
+	if c.keyStore == nil || !c.keyStore.IsReady() {
+		c.logger.Error("Secret store is not initialized or not ready")
+		return false
+	}
+
+	if c.rateLimiter == nil || !c.rateLimiter.IsReady() {
+		c.logger.Error("Rate limiter is not initialized or not ready")
+		return false
+	}
+
+	return true
+}
+
+func (c *adminApp) Close() error {
+	if c.keyStore != nil {
+		if err := c.keyStore.Close(); err != nil {
+			c.logger.Error("Failed to close secret store", "error", err)
+		}
+	}
+
+	if c.rateLimiter != nil {
+		if err := c.rateLimiter.Close(); err != nil {
+			c.logger.Error("Failed to close rate limiter", "error", err)
+		}
+	}
+
+	return nil
+}
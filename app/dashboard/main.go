@@ -5,29 +5,38 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	_ "time/tzdata" // WASI has no zoneinfo for the time zones of the dashboards
 
 	spinhttp "github.com/spinframework/spin-go-sdk/v2/http"
 	spinvars "github.com/spinframework/spin-go-sdk/v2/variables"
 
+	"github.com/timgluz/wasserspiegel/api/apierror"
+	"github.com/timgluz/wasserspiegel/api/dashboards"
 	"github.com/timgluz/wasserspiegel/dashboard"
 	"github.com/timgluz/wasserspiegel/log"
 	"github.com/timgluz/wasserspiegel/middleware"
+	"github.com/timgluz/wasserspiegel/openapi"
+	"github.com/timgluz/wasserspiegel/ratelimit"
 	"github.com/timgluz/wasserspiegel/response"
 	"github.com/timgluz/wasserspiegel/secret"
 )
 
 type DashboardAppConfig struct {
-	StoreName string `json:"storeName"`
-	APIKey    string
-	LogLevel  string `json:"logLevel"`
+	StoreName          string `json:"storeName"`
+	SecretStoreName    string `json:"secretStoreName"`
+	RateLimitStoreName string `json:"rateLimitStoreName"`
+	RateLimits         string `json:"rateLimits"`
+	APIKey             string
+	ShareSigningKey    string
+	CachePolicies      response.CachePolicies `json:"cachePolicies"`
+	LogLevel           string                 `json:"logLevel"`
+	LogFormat          string                 `json:"logFormat"`
 }
 
 type DashboardApp struct {
-	Config      *DashboardAppConfig
-	Logger      *slog.Logger
-	SecretStore secret.Store
-	Repository  dashboard.Repository
-	Router      *spinhttp.Router
+	Config    *DashboardAppConfig
+	Component *dashboards.Component
+	Router    *spinhttp.Router
 }
 
 func newDashboardAppConfigFromSpinVariables() *DashboardAppConfig {
@@ -41,33 +50,81 @@ func newDashboardAppConfigFromSpinVariables() *DashboardAppConfig {
 		return nil
 	}
 
+	secretStoreName, err := spinvars.Get("secrets_store_name")
+	if err != nil || secretStoreName == "" {
+		secretStoreName = "secrets"
+	}
+
+	rateLimitStoreName, err := spinvars.Get("ratelimit_store_name")
+	if err != nil || rateLimitStoreName == "" {
+		rateLimitStoreName = "ratelimits"
+	}
+
+	rateLimits, err := spinvars.Get("rate_limits")
+	if err != nil {
+		rateLimits = ""
+	}
+
+	// share links are signed with the API key, unless a separate key is configured;
+	// changing the signing key invalidates all issued links
+	shareSigningKey, err := spinvars.Get("share_signing_key")
+	if err != nil || shareSigningKey == "" {
+		shareSigningKey = apiKey
+	}
+
 	logLevel, err := spinvars.Get("log_level")
 	if err != nil {
 		logLevel = "info"
 	}
 
+	logFormat, err := spinvars.Get("log_format")
+	if err != nil {
+		logFormat = log.FormatText
+	}
+
+	cacheControl, err := spinvars.Get("cache_control")
+	if err != nil {
+		cacheControl = ""
+	}
+	cachePolicies, err := response.ParseCachePolicies(cacheControl)
+	if err != nil {
+		fmt.Println("Invalid cache_control configuration:", err)
+		return nil
+	}
+
 	return &DashboardAppConfig{
-		StoreName: storeName,
-		APIKey:    apiKey,
-		LogLevel:  logLevel,
+		StoreName:          storeName,
+		SecretStoreName:    secretStoreName,
+		RateLimitStoreName: rateLimitStoreName,
+		RateLimits:         rateLimits,
+		APIKey:             apiKey,
+		ShareSigningKey:    shareSigningKey,
+		CachePolicies:      cachePolicies,
+		LogLevel:           logLevel,
+		LogFormat:          logFormat,
 	}
 }
 
 func init() {
 	spinhttp.Handle(func(w http.ResponseWriter, r *http.Request) {
+		if openapi.ServeDocument(w, r, "/dashboards") {
+			return
+		}
+
 		fmt.Println("Initializing dashboard app...")
 		app, err := initDashboardApp()
 		if err != nil {
-			response.RenderFatal(w, fmt.Errorf("failed to initialize dashboard app: %w", err))
+			apierror.RenderFatal(w, fmt.Errorf("failed to initialize dashboard app: %w", err))
 			return
 		}
 
 		if app == nil {
-			response.RenderFatal(w, fmt.Errorf("failed to initialize dashboard app"))
+			apierror.RenderFatal(w, fmt.Errorf("failed to initialize dashboard app"))
 			return
 		}
 
-		app.Router.ServeHTTP(w, r)
+		w.Header().Set("Link", openapi.ServiceDescLink("/dashboards"))
+		middleware.ObserveRouter(app.Router, app.Component.Logger).ServeHTTP(w, r)
 	})
 }
 
@@ -77,109 +134,67 @@ func initDashboardApp() (*DashboardApp, error) {
 		return nil, fmt.Errorf("failed to load dashboard app configuration")
 	}
 	logger := newLogger(config)
-	secretStore := newSecretStore(config)
+	secretStore := newSecretStore(config, logger)
 	if secretStore == nil {
 		return nil, fmt.Errorf("failed to create secret store")
 	}
+	rateLimiter, err := ratelimit.NewSpinLimiter(config.RateLimitStoreName, config.RateLimits, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rate limiter: %w", err)
+	}
+	shareStore, err := secret.NewSpinShareStore(config.SecretStoreName, config.ShareSigningKey, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create share store: %w", err)
+	}
 	dashboardRepo, err := newDashboardRepository(config, logger)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create dashboard repository: %w", err)
 	}
-	router := newDashboardRouter(dashboardRepo, secretStore, logger)
-	if router == nil {
-		return nil, fmt.Errorf("failed to create dashboard router")
-	}
-
-	return &DashboardApp{
-		Config:      config,
-		Logger:      logger,
-		SecretStore: secretStore,
-		Repository:  dashboardRepo,
-		Router:      router,
-	}, nil
 
-}
-
-func newDashboardRouter(dashboardRepo dashboard.Repository, secretStore secret.Store, logger *slog.Logger) *spinhttp.Router {
-
-	router := spinhttp.NewRouter()
-	router.GET("/dashboards/:id", middleware.BearerAuth(newDashboardGetHandler(dashboardRepo, logger), secretStore))
-	router.GET("/dashboards", middleware.BearerAuth(newDashboardIndexHandler(dashboardRepo, logger), secretStore))
-
-	router.NotFound = response.NewNotFoundHandler(logger)
-
-	return router
-}
-
-func newDashboardIndexHandler(dashboardRepo dashboard.Repository, logger *slog.Logger) spinhttp.RouterHandle {
-	return func(w http.ResponseWriter, r *http.Request, params spinhttp.Params) {
-		pagination := response.NewPaginationFromRequest(r)
-		logger.Info("Handling dashboard index request", "limit", pagination.Limit, "offset", pagination.Offset)
-		dashboardCollection, err := dashboardRepo.List(r.Context(), pagination.Offset, pagination.Limit)
-		if err != nil {
-			logger.Error("Failed to list dashboards", "error", err)
-			response.RenderError(w, fmt.Errorf("failed to list dashboards: %w", err), http.StatusInternalServerError)
-			return
-		}
-
-		response.RenderJSON(w, dashboardCollection)
+	app := &DashboardApp{
+		Config: config,
+		Component: &dashboards.Component{
+			Logger:        logger,
+			SecretStore:   secretStore,
+			RateLimiter:   rateLimiter,
+			ShareStore:    shareStore,
+			Repository:    dashboardRepo,
+			CachePolicies: config.CachePolicies,
+		},
 	}
-}
-
-func newDashboardGetHandler(dashboardRepo dashboard.Repository, logger *slog.Logger) spinhttp.RouterHandle {
-	return func(w http.ResponseWriter, r *http.Request, params spinhttp.Params) {
-		dashboardID := params.ByName("id")
-		if dashboardID == "" {
-			response.RenderError(w, fmt.Errorf("dashboard ID is required"), http.StatusBadRequest)
-			return
-		}
-
-		if dashboardRepo == nil {
-			logger.Error("Dashboard repository is not ready")
-			response.RenderError(w, fmt.Errorf("dashboard repository is not ready"), http.StatusInternalServerError)
-			return
-		}
-
-		dashboard, err := dashboardRepo.GetByID(r.Context(), dashboardID)
-		if err != nil {
-			logger.Error("Failed to get dashboard by ID", "id", dashboardID, "error", err)
-			response.RenderError(w, fmt.Errorf("failed to get dashboard: %w", err), http.StatusInternalServerError)
-			return
-		}
-
-		if dashboard == nil {
-			response.RenderError(w, fmt.Errorf("dashboard not found"), http.StatusNotFound)
-			return
-		}
 
-		response.RenderJSON(w, dashboard)
+	app.Router = dashboards.NewRouter(app.Component)
+	if app.Router == nil {
+		return nil, fmt.Errorf("failed to create dashboard router")
 	}
+
+	return app, nil
 }
 
 func newLogger(config *DashboardAppConfig) *slog.Logger {
 	fmt.Println("Creating logger")
-	level := slog.LevelInfo
+	level, format := slog.LevelInfo, log.FormatText
 	if config != nil {
 		level = log.SlogLevelInfoFromString(config.LogLevel)
+		format = config.LogFormat
 	}
 
-	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+	logger := slog.New(log.NewHandler(os.Stdout, format, &slog.HandlerOptions{
 		Level: level,
 	}))
 
 	return logger
 }
 
-func newSecretStore(config *DashboardAppConfig) secret.Store {
+func newSecretStore(config *DashboardAppConfig, logger *slog.Logger) secret.Store {
 	fmt.Println("Creating secret store with API key")
 	if config == nil || config.APIKey == "" {
 		fmt.Println("Invalid dashboard app configuration: API key is required")
 		return nil
 	}
 
-	apiKey := config.APIKey
-	store := secret.NewInMemoryStore()
-	if err := store.Set(apiKey, apiKey); err != nil {
+	store, err := secret.NewSpinKVStore(config.SecretStoreName, config.APIKey, logger)
+	if err != nil {
 		return nil
 	}
 	return store
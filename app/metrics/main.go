@@ -0,0 +1,342 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	spinhttp "github.com/spinframework/spin-go-sdk/v2/http"
+	spinvars "github.com/spinframework/spin-go-sdk/v2/variables"
+
+	"github.com/timgluz/wasserspiegel/api/apierror"
+	"github.com/timgluz/wasserspiegel/health"
+	"github.com/timgluz/wasserspiegel/log"
+	"github.com/timgluz/wasserspiegel/measurement"
+	"github.com/timgluz/wasserspiegel/metrics"
+	"github.com/timgluz/wasserspiegel/middleware"
+	"github.com/timgluz/wasserspiegel/openapi"
+	"github.com/timgluz/wasserspiegel/response"
+	"github.com/timgluz/wasserspiegel/secret"
+	"github.com/timgluz/wasserspiegel/station"
+)
+
+var (
+	waterLevelMetric = metrics.Descriptor{
+		Name: "wasserspiegel_water_level_cm",
+		Type: metrics.TypeGauge,
+		Unit: "cm",
+		Help: "Latest water level reading of the station.",
+	}
+	waterLevelTimestampMetric = metrics.Descriptor{
+		Name: "wasserspiegel_water_level_timestamp_seconds",
+		Type: metrics.TypeGauge,
+		Unit: "seconds",
+		Help: "Unix time of the latest water level reading of the station.",
+	}
+	measurementTimestampMetric = metrics.Descriptor{
+		Name: "wasserspiegel_measurement_last_sample_timestamp_seconds",
+		Type: metrics.TypeGauge,
+		Unit: "seconds",
+		Help: "Unix time of the latest sample of the measurement.",
+	}
+)
+
+type metricsAppConfig struct {
+	MeasurementDBName string `json:"measurement_db_name"`
+	StationStoreName  string `json:"station_store_name"`
+	SecretStoreName   string `json:"secret_store_name"`
+	APIKey            string `json:"api_key"`
+	RequireAuth       bool   `json:"require_auth"`
+	LogLevel          string `json:"log_level"`
+	LogFormat         string `json:"log_format"`
+}
+
+func newMetricsAppConfigFromSpinVariables() (*metricsAppConfig, error) {
+	measurementDBName, err := spinvars.Get("measurement_db_name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get measurement_db_name: %w", err)
+	}
+
+	stationStoreName, err := spinvars.Get("stations_store_name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stations_store_name: %w", err)
+	}
+
+	apiKey, err := spinvars.Get("api_key")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get api_key: %w", err)
+	}
+
+	secretStoreName, err := spinvars.Get("secrets_store_name")
+	if err != nil || secretStoreName == "" {
+		secretStoreName = "secrets"
+	}
+
+	requireAuth := true
+	if value, err := spinvars.Get("metrics_require_auth"); err == nil && value != "" {
+		requireAuth, err = strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid metrics_require_auth value %q: %w", value, err)
+		}
+	}
+
+	logLevel, err := spinvars.Get("log_level")
+	if err != nil {
+		logLevel = "info"
+	}
+
+	logFormat, err := spinvars.Get("log_format")
+	if err != nil {
+		logFormat = log.FormatText
+	}
+
+	return &metricsAppConfig{
+		MeasurementDBName: measurementDBName,
+		StationStoreName:  stationStoreName,
+		SecretStoreName:   secretStoreName,
+		APIKey:            apiKey,
+		RequireAuth:       requireAuth,
+		LogLevel:          logLevel,
+		LogFormat:         logFormat,
+	}, nil
+}
+
+type metricsApp struct {
+	config *metricsAppConfig
+
+	measurementRepository measurement.Repository
+	stationRepository     station.Repository
+	metricsStore          *metrics.SQLStore
+	secretStore           secret.Store
+
+	logger *slog.Logger
+}
+
+func init() {
+	spinhttp.Handle(func(w http.ResponseWriter, r *http.Request) {
+		if openapi.ServeDocument(w, r, "/metrics") {
+			return
+		}
+
+		config, err := newMetricsAppConfigFromSpinVariables()
+		if err != nil {
+			apierror.RenderFatal(w, fmt.Errorf("failed to load metrics app configuration: %w", err))
+			return
+		}
+
+		app, err := initMetricsApp(*config)
+		if err != nil {
+			fmt.Println("Error initializing metrics app components:", err)
+			apierror.RenderFatal(w, fmt.Errorf("failed to initialize metrics app components"))
+			return
+		}
+		defer app.Close()
+
+		if !app.IsReady() {
+			if health.ServeUnready(w, r, "/metrics", "metrics", app.healthDependencies()...) {
+				return
+			}
+			apierror.RenderFatal(w, fmt.Errorf("metrics app components are not ready"))
+			return
+		}
+
+		router := newMetricsRouter(app)
+		w.Header().Set("Link", openapi.ServiceDescLink("/metrics"))
+		middleware.ObserveRouter(router, app.logger).ServeHTTP(w, r)
+	})
+}
+
+func main() {}
+
+// healthDependencies lists the DB and stores reported by `/metrics/health`; the
+// secrets are only required if the metrics require an API key.
+func (c *metricsApp) healthDependencies() []health.Dependency {
+	return []health.Dependency{
+		health.Required("measurements", c.measurementRepository),
+		health.Required("stations", c.stationRepository),
+		health.Required("metrics", c.metricsStore),
+		{Name: "secrets", Target: c.secretStore, Optional: !c.config.RequireAuth},
+	}
+}
+
+func newMetricsRouter(app *metricsApp) *spinhttp.Router {
+	router := spinhttp.NewRouter()
+
+	handler := newMetricsHandler(app)
+	if app.config.RequireAuth {
+		handler = middleware.BearerAuth(handler, app.secretStore, secret.ScopeMetricsRead)
+	}
+	router.GET("/metrics", handler)
+	router.GET("/metrics"+health.HealthPath, health.NewHandler("metrics", app.healthDependencies()...))
+
+	router.NotFound = response.NewNotFoundHandler(app.logger)
+	router.MethodNotAllowed = response.NewMethodNotAllowedHandler()
+	return router
+}
+
+func newMetricsHandler(app *metricsApp) spinhttp.RouterHandle {
+	return func(w http.ResponseWriter, r *http.Request, params spinhttp.Params) {
+		ctx := r.Context()
+		logger := log.FromContext(r.Context(), app.logger)
+
+		families, err := collectReadingFamilies(ctx, app)
+		if err != nil {
+			logger.Error("Failed to collect station readings", "error", err)
+			apierror.RenderFatal(w, fmt.Errorf("failed to collect station readings: %w", err))
+			return
+		}
+
+		serviceFamilies, err := app.metricsStore.Families(ctx)
+		if err != nil {
+			logger.Error("Failed to collect service metrics", "error", err)
+			apierror.RenderFatal(w, fmt.Errorf("failed to collect service metrics: %w", err))
+			return
+		}
+		families = append(families, serviceFamilies...)
+
+		var buf bytes.Buffer
+		if err := metrics.WriteOpenMetrics(&buf, families); err != nil {
+			logger.Error("Failed to render metrics", "error", err)
+			apierror.RenderFatal(w, fmt.Errorf("failed to render metrics: %w", err))
+			return
+		}
+
+		w.Header().Set("Content-Type", metrics.ContentType)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(buf.Bytes())
+	}
+}
+
+// collectReadingFamilies maps the latest sample of each measurement to gauges,
+// adding station labels for the water level measurements.
+func collectReadingFamilies(ctx context.Context, app *metricsApp) ([]*metrics.Family, error) {
+	latestSamples, err := app.measurementRepository.GetLatestSamples(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stationCollection, err := app.stationRepository.List(ctx, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	stationsByMeasurement := make(map[string]station.Station, len(stationCollection.Stations))
+	for _, s := range stationCollection.Stations {
+		stationsByMeasurement[measurement.NewMeasurementName("waterlevel", s.ID)] = s
+	}
+
+	waterLevels := metrics.NewFamily(waterLevelMetric)
+	waterLevelTimestamps := metrics.NewFamily(waterLevelTimestampMetric)
+	measurementTimestamps := metrics.NewFamily(measurementTimestampMetric)
+	for _, latest := range latestSamples {
+		measurementTimestamps.Add(
+			metrics.NewLabels("measurement", latest.Measurement.Name, "unit", latest.Measurement.Unit),
+			float64(latest.Sample.Timestamp),
+		)
+
+		stationItem, ok := stationsByMeasurement[latest.Measurement.Name]
+		if !ok || latest.Measurement.Unit != station.UnitCM {
+			continue
+		}
+
+		labels := metrics.NewLabels("station_id", stationItem.ID, "water", stationItem.Water, "name", stationItem.Name)
+		waterLevels.Add(labels, latest.Sample.Value)
+		waterLevelTimestamps.Add(labels, float64(latest.Sample.Timestamp))
+	}
+
+	return []*metrics.Family{waterLevels, waterLevelTimestamps, measurementTimestamps}, nil
+}
+
+func initMetricsApp(config metricsAppConfig) (*metricsApp, error) {
+	logger := log.NewLogger(config.LogLevel, config.LogFormat).With("component", "metrics")
+	logger.Info("Initializing metrics components")
+
+	measurementDB, err := measurement.NewSpinSqliteDB(config.MeasurementDBName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize SQLite DB: %w", err)
+	}
+
+	measurementRepository, err := measurement.NewSqlRepository(measurementDB, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create measurement repository: %w", err)
+	}
+
+	metricsStore, err := metrics.NewSQLStore(measurementDB, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metrics store: %w", err)
+	}
+
+	stationRepository, err := station.NewSpinKVRepository(config.StationStoreName, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create station repository: %w", err)
+	}
+
+	secretStore, err := secret.NewSpinKVStore(config.SecretStoreName, config.APIKey, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create secret store: %w", err)
+	}
+
+	return &metricsApp{
+		config:                &config,
+		measurementRepository: measurementRepository,
+		stationRepository:     stationRepository,
+		metricsStore:          metricsStore,
+		secretStore:           secretStore,
+		logger:                logger,
+	}, nil
+}
+
+func (c *metricsApp) IsReady() bool {
+	if c.logger == nil {
+		fmt.Println("Logger of metrics app components is not initialized")
+		return false
+	}
+
+	if c.measurementRepository == nil || !c.measurementRepository.IsReady() {
+		c.logger.Error("Measurement repository is not initialized or not ready")
+		return false
+	}
+
+	if c.stationRepository == nil || !c.stationRepository.IsReady() {
+		c.logger.Error("Station repository is not initialized or not ready")
+		return false
+	}
+
+	if c.metricsStore == nil || !c.metricsStore.IsReady() {
+		c.logger.Error("Metrics store is not initialized or not ready")
+		return false
+	}
+
+	if c.secretStore == nil || !c.secretStore.IsReady() {
+		c.logger.Error("Secret store is not initialized or not ready")
+		return false
+	}
+
+	return true
+}
+
+func (c *metricsApp) Close() error {
+	if c.measurementRepository != nil {
+		if err := c.measurementRepository.Close(); err != nil {
+			c.logger.Error("Failed to close measurement repository", "error", err)
+		}
+	}
+
+	if c.stationRepository != nil {
+		if err := c.stationRepository.Close(); err != nil {
+			c.logger.Error("Failed to close station repository", "error", err)
+		}
+	}
+
+	if c.secretStore != nil {
+		if err := c.secretStore.Close(); err != nil {
+			c.logger.Error("Failed to close secret store", "error", err)
+		}
+	}
+
+	c.logger.Info("Metrics app components closed successfully")
+	return nil
+}
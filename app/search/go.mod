@@ -11,6 +11,7 @@ require (
 	github.com/gosimple/slug v1.15.0 // indirect
 	github.com/gosimple/unidecode v1.0.1 // indirect
 	github.com/julienschmidt/httprouter v1.3.0 // indirect
+	github.com/sosodev/duration v1.3.1 // indirect
 )
 
 replace github.com/timgluz/wasserspiegel => ./../..
@@ -1,33 +1,32 @@
 package main
 
 import (
-	"context"
 	"fmt"
-	"log/slog"
 	"net/http"
-	"os"
-	"strings"
 
 	spinhttp "github.com/spinframework/spin-go-sdk/v2/http"
 	spinvars "github.com/spinframework/spin-go-sdk/v2/variables"
+	"github.com/timgluz/wasserspiegel/api/apierror"
+	"github.com/timgluz/wasserspiegel/api/search"
+	"github.com/timgluz/wasserspiegel/health"
 	"github.com/timgluz/wasserspiegel/log"
 	"github.com/timgluz/wasserspiegel/middleware"
+	"github.com/timgluz/wasserspiegel/openapi"
+	"github.com/timgluz/wasserspiegel/ratelimit"
 	"github.com/timgluz/wasserspiegel/response"
 	"github.com/timgluz/wasserspiegel/secret"
 	"github.com/timgluz/wasserspiegel/station"
 )
 
-const (
-	DefaultSearchLimit   = 10
-	MaxSearchLimit       = 100
-	MinSearchQueryLength = 3
-	MaxSearchQueryLength = 100
-)
-
 type SearchAppConfig struct {
 	StationStoreName string `json:"station_store_name"`
+	SecretStoreName  string `json:"secret_store_name"`
+	RateLimitStore   string `json:"ratelimit_store_name"`
+	RateLimits       string `json:"rate_limits"`
+	CachePolicies    response.CachePolicies
 	APIKey           string
 	LogLevel         string `json:"log_level"`
+	LogFormat        string `json:"log_format"`
 }
 
 func newSearchAppConfigFromSpinVariables() (*SearchAppConfig, error) {
@@ -41,56 +40,92 @@ func newSearchAppConfigFromSpinVariables() (*SearchAppConfig, error) {
 		return nil, fmt.Errorf("failed to get API key: %w", err)
 	}
 
+	secretStoreName, err := spinvars.Get("secrets_store_name")
+	if err != nil || secretStoreName == "" {
+		secretStoreName = "secrets"
+	}
+
+	rateLimitStoreName, err := spinvars.Get("ratelimit_store_name")
+	if err != nil || rateLimitStoreName == "" {
+		rateLimitStoreName = "ratelimits"
+	}
+
+	rateLimits, err := spinvars.Get("rate_limits")
+	if err != nil {
+		rateLimits = ""
+	}
+
+	cacheControl, err := spinvars.Get("cache_control")
+	if err != nil {
+		cacheControl = ""
+	}
+
+	cachePolicies, err := response.ParseCachePolicies(cacheControl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cache_control: %w", err)
+	}
+
+	logLevel, err := spinvars.Get("log_level")
+	if err != nil {
+		logLevel = "info"
+	}
+
+	logFormat, err := spinvars.Get("log_format")
+	if err != nil {
+		logFormat = log.FormatText
+	}
+
 	return &SearchAppConfig{
 		StationStoreName: stationStoreName,
+		SecretStoreName:  secretStoreName,
+		RateLimitStore:   rateLimitStoreName,
+		RateLimits:       rateLimits,
+		CachePolicies:    cachePolicies,
 		APIKey:           apiKey,
+		LogLevel:         logLevel,
+		LogFormat:        logFormat,
 	}, nil
 }
 
-type SearchResponse struct {
-	Results    []station.Station   `json:"results"`
-	Pagination response.Pagination `json:"pagination"`
-}
-
 func init() {
 	spinhttp.Handle(func(w http.ResponseWriter, r *http.Request) {
+		if openapi.ServeDocument(w, r, "/search") {
+			return
+		}
+
 		config, err := newSearchAppConfigFromSpinVariables()
 		if err != nil {
-			response.RenderFatal(w, fmt.Errorf("failed to load station app configuration: %w", err))
+			apierror.RenderFatal(w, fmt.Errorf("failed to load station app configuration: %w", err))
 			return
 		}
 
 		appComponents, err := initSearchAppComponent(*config)
 		if err != nil {
 			fmt.Printf("Error initializing station service: %v\n", err)
-			response.RenderFatal(w, fmt.Errorf("failed to initialize station app components"))
+			apierror.RenderFatal(w, fmt.Errorf("failed to initialize station app components"))
 			return
 		}
 		defer appComponents.Close()
 
 		if !appComponents.IsReady() {
 			fmt.Println("Station app components are not ready")
-			response.RenderFatal(w, fmt.Errorf("station app components are not ready"))
+			if health.ServeUnready(w, r, "/search", "search", appComponents.HealthDependencies()...) {
+				return
+			}
+			apierror.RenderFatal(w, fmt.Errorf("station app components are not ready"))
 			return
 		}
 
-		logger := appComponents.logger
+		logger := appComponents.Logger
 		logger.Info("Station AppComponents successfully initialized", "stationStore", config.StationStoreName)
 
-		router := spinhttp.NewRouter()
-		router.GET("/search/stations", middleware.BearerAuth(newStationSearchHandler(appComponents), appComponents.secretStore))
-
-		router.NotFound = response.NewNotFoundHandler(logger)
-
-		router.ServeHTTP(w, r)
+		w.Header().Set("Link", openapi.ServiceDescLink("/search"))
+		middleware.ObserveRouter(search.NewRouter(appComponents), logger).ServeHTTP(w, r)
 	})
 }
 
-func initSearchAppComponent(config SearchAppConfig) (*searchAppComponent, error) {
-	loggerOptions := slog.HandlerOptions{
-		Level: log.SlogLevelInfoFromString(config.LogLevel),
-	}
-	logger := slog.New(slog.NewTextHandler(os.Stderr, &loggerOptions)).With("component", "search")
+func initSearchAppComponent(config SearchAppConfig) (*search.Component, error) {
+	logger := log.NewLogger(config.LogLevel, config.LogFormat).With("component", "search")
 
 	logger.Info("Initializing station service")
 
@@ -101,113 +136,23 @@ func initSearchAppComponent(config SearchAppConfig) (*searchAppComponent, error)
 		return nil, fmt.Errorf("failed to create station repository: %w", err)
 	}
 
-	secretStore := secret.NewInMemoryStore()
+	secretStore, err := secret.NewSpinKVStore(config.SecretStoreName, config.APIKey, logger)
 	if err != nil {
 		logger.Error("Failed to create secret store", "error", err)
 		return nil, fmt.Errorf("failed to create secret store: %w", err)
 	}
 
-	secretStore.Set(config.APIKey, config.APIKey)
-
-	return &searchAppComponent{
-		logger:            logger,
-		stationRepository: stationRepository,
-		secretStore:       secretStore,
-	}, nil
-}
-
-func newStationSearchHandler(appComponents *searchAppComponent) spinhttp.RouterHandle {
-	return func(w http.ResponseWriter, r *http.Request, _ spinhttp.Params) {
-		logger := appComponents.logger
-
-		if !appComponents.IsReady() {
-			logger.Error("Station app components are not ready")
-			response.RenderFatal(w, fmt.Errorf("station app components are not ready"))
-			return
-		}
-
-		searchQuery := r.URL.Query().Get("q")
-		if searchQuery == "" {
-			logger.Warn("Search query is empty")
-			response.RenderError(w, fmt.Errorf("search query cannot be empty"), http.StatusBadRequest)
-			return
-		}
-		if len(searchQuery) < MinSearchQueryLength {
-			logger.Warn("Search query is too short", "query", searchQuery)
-			response.RenderError(w, fmt.Errorf("search query must be at least 3 characters long"), http.StatusBadRequest)
-			return
-		}
-		if len(searchQuery) > MaxSearchQueryLength {
-			logger.Warn("Search query is too long", "query", searchQuery)
-			response.RenderError(w, fmt.Errorf("search query must not exceed 100 characters"), http.StatusBadRequest)
-			return
-		}
-
-		searchQuery = strings.ToLower(strings.TrimSpace(searchQuery))
-		queryPagination := response.NewPaginationFromRequest(r)
-		collection, err := appComponents.stationRepository.List(context.Background(), queryPagination.Offset, -1)
-		if err != nil {
-			logger.Error("Failed to fetch stations", "error", err)
-			response.RenderFatal(w, err)
-			return
-		}
-
-		logger.Debug("Searching stations", "query", searchQuery)
-		results := make([]station.Station, 0)
-		for _, s := range collection.Stations {
-			if len(results) >= queryPagination.Limit {
-				logger.Debug("Reached maximum search results limit", "limit", MaxSearchLimit)
-				break
-			}
-
-			if strings.Contains(strings.ToLower(s.Name), searchQuery) ||
-				strings.Contains(strings.ToLower(s.ID), searchQuery) {
-				results = append(results, s)
-			}
-		}
-
-		queryPagination.Total = len(results)
-		response.RenderJSONResponse(w, SearchResponse{
-			Results:    results,
-			Pagination: queryPagination,
-		})
-	}
-}
-
-type searchAppComponent struct {
-	logger            *slog.Logger
-	stationRepository station.Repository
-	secretStore       secret.Store
-}
-
-func (c *searchAppComponent) IsReady() bool {
-	if c.logger == nil {
-		fmt.Println("Logger of SearchAppComponent is not initialized")
-		return false
-	}
-
-	if c.stationRepository == nil {
-		c.logger.Error("Station repository is not initialized")
-		return false
-	}
-
-	if c.secretStore == nil {
-		c.logger.Error("Secret store is not initialized")
-		return false
-	}
-
-	c.logger.Debug("SearchAppComponent is ready")
-	return true
-}
-
-func (c *searchAppComponent) Close() error {
-	if c.stationRepository != nil {
-		if err := c.stationRepository.Close(); err != nil {
-			c.logger.Error("Failed to close station repository", "error", err)
-			return err
-		}
+	rateLimiter, err := ratelimit.NewSpinLimiter(config.RateLimitStore, config.RateLimits, logger)
+	if err != nil {
+		logger.Error("Failed to create rate limiter", "error", err)
+		return nil, fmt.Errorf("failed to create rate limiter: %w", err)
 	}
 
-	c.logger.Info("SearchAppComponent closed successfully")
-	return nil
+	return &search.Component{
+		Logger:            logger,
+		StationRepository: stationRepository,
+		SecretStore:       secretStore,
+		RateLimiter:       rateLimiter,
+		CachePolicies:     config.CachePolicies,
+	}, nil
 }
@@ -1,17 +1,23 @@
 package main
 
 import (
-	"context"
-	"encoding/json"
 	"fmt"
 	"log/slog"
 	"net/http"
-	"os"
+	_ "time/tzdata" // WASI has no zoneinfo for the time zones of the trends
 
 	spinhttp "github.com/spinframework/spin-go-sdk/v2/http"
 	spinvars "github.com/spinframework/spin-go-sdk/v2/variables"
 
+	"github.com/timgluz/wasserspiegel/api/apierror"
+	"github.com/timgluz/wasserspiegel/api/stations"
+	"github.com/timgluz/wasserspiegel/health"
+	"github.com/timgluz/wasserspiegel/kvstore"
+	"github.com/timgluz/wasserspiegel/log"
+	"github.com/timgluz/wasserspiegel/measurement"
 	"github.com/timgluz/wasserspiegel/middleware"
+	"github.com/timgluz/wasserspiegel/openapi"
+	"github.com/timgluz/wasserspiegel/ratelimit"
 	"github.com/timgluz/wasserspiegel/response"
 	"github.com/timgluz/wasserspiegel/secret"
 	"github.com/timgluz/wasserspiegel/station"
@@ -27,15 +33,20 @@ const (
 	MaxSearchQueryLength = 100
 )
 
-var (
-	ErrFailedToMarshal = fmt.Errorf("failed to marshal data")
-	ErrNotFound        = fmt.Errorf("request resource does not exist")
-)
-
 type StationAppConfig struct {
-	StoreName   string `validate:"required"`
-	APIEndpoint string `validate:"required"`
-	APIKey      string `validate:"required"` // Optional, if needed for authentication
+	StoreName          string `validate:"required"`
+	SecretStoreName    string `validate:"required"`
+	RateLimitStoreName string `validate:"required"`
+	RateLimits         string
+	APIEndpoint        string `validate:"required"`
+	APIKey             string `validate:"required"` // Optional, if needed for authentication
+	CachePolicies      response.CachePolicies
+	ProviderCacheStore string `validate:"required"`
+	ProviderCacheTTLs  station.CacheTTLs
+	MeasurementDBName  string // optional, holds the baselines of the historical context
+	Providers          []station.GenericProviderConfig
+	LogLevel           string
+	LogFormat          string
 }
 
 func NewStationAppConfigFromSpinVariables() (*StationAppConfig, error) {
@@ -54,117 +65,133 @@ func NewStationAppConfigFromSpinVariables() (*StationAppConfig, error) {
 		return nil, fmt.Errorf("failed to get base_url from Spin variables: %w", err)
 	}
 
-	return &StationAppConfig{
-		StoreName:   storeName,
-		APIEndpoint: apiEndpoint,
-		APIKey:      apiKey,
-	}, nil
+	secretStoreName, err := spinvars.Get("secrets_store_name")
+	if err != nil || secretStoreName == "" {
+		secretStoreName = "secrets"
+	}
 
-}
+	rateLimitStoreName, err := spinvars.Get("ratelimit_store_name")
+	if err != nil || rateLimitStoreName == "" {
+		rateLimitStoreName = "ratelimits"
+	}
 
-type APIResponse struct {
-	Success bool   `json:"success"`
-	Message string `json:"message,omitempty"`
-}
+	rateLimits, err := spinvars.Get("rate_limits")
+	if err != nil {
+		rateLimits = ""
+	}
 
-// stationAppSystem holds the stateful components for the station service.
-// it is inspired by Clojure components library: https://github.com/stuartsierra/component
-type stationAppComponent struct {
-	stationRepository station.Repository
-	stationProvider   station.Provider
-	secretStore       secret.Store
-	logger            *slog.Logger
-}
+	cacheControl, err := spinvars.Get("cache_control")
+	if err != nil {
+		cacheControl = ""
+	}
 
-func (s *stationAppComponent) Close() {
-	if s.stationRepository != nil {
-		return
+	cachePolicies, err := response.ParseCachePolicies(cacheControl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cache_control: %w", err)
 	}
 
-	if err := s.stationRepository.Close(); err != nil {
-		s.logger.Error("Failed to close station repository", "error", err)
+	providerCacheStore, err := spinvars.Get("provider_cache_store_name")
+	if err != nil || providerCacheStore == "" {
+		providerCacheStore = "providercache"
+	}
+
+	providerCacheTTLs, err := spinvars.Get("provider_cache_ttls")
+	if err != nil {
+		providerCacheTTLs = ""
 	}
-	s.stationRepository = nil
-}
 
-// IsReady checks if all components of the station app are ready.
-func (s *stationAppComponent) IsReady() bool {
-	if s.logger == nil {
-		fmt.Println("Logger of stationAppComponent is not initialized")
-		return false
+	cacheTTLs, err := station.ParseCacheTTLs(providerCacheTTLs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid provider_cache_ttls: %w", err)
 	}
 
-	if s.stationRepository == nil {
-		s.logger.Error("Station repository is not initialized")
-		return false
+	providersSpec, err := spinvars.Get("providers")
+	if err != nil {
+		providersSpec = ""
 	}
 
-	if !s.stationRepository.IsReady() {
-		s.logger.Error("Station repository is not ready")
-		return false
+	providers, err := station.ParseGenericProviderConfigs(providersSpec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid providers: %w", err)
 	}
 
-	if s.stationProvider == nil {
-		s.logger.Error("Station provider is not initialized")
-		return false
+	measurementDBName, err := spinvars.Get("measurement_db_name")
+	if err != nil {
+		measurementDBName = ""
 	}
 
-	if !s.stationProvider.IsReady() {
-		s.logger.Error("Station provider is not ready")
-		return false
+	logLevel, err := spinvars.Get("log_level")
+	if err != nil {
+		logLevel = "info"
 	}
 
-	if s.secretStore == nil {
-		s.logger.Error("Secret store is not initialized")
-		return false
+	logFormat, err := spinvars.Get("log_format")
+	if err != nil {
+		logFormat = log.FormatText
 	}
 
-	s.logger.Info("Station app component is ready")
-	return true
+	return &StationAppConfig{
+		StoreName:          storeName,
+		SecretStoreName:    secretStoreName,
+		RateLimitStoreName: rateLimitStoreName,
+		RateLimits:         rateLimits,
+		APIEndpoint:        apiEndpoint,
+		APIKey:             apiKey,
+		CachePolicies:      cachePolicies,
+		ProviderCacheStore: providerCacheStore,
+		ProviderCacheTTLs:  cacheTTLs,
+		MeasurementDBName:  measurementDBName,
+		Providers:          providers,
+		LogLevel:           logLevel,
+		LogFormat:          logFormat,
+	}, nil
+
 }
 
-type StationDashboard struct {
-	Station    *station.Station              `json:"station"`
-	WaterLevel *station.WaterLevelCollection `json:"water_level"`
+type APIResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message,omitempty"`
 }
 
 func init() {
 	spinhttp.Handle(func(w http.ResponseWriter, r *http.Request) {
+		if openapi.ServeDocument(w, r, "/stations") {
+			return
+		}
+
 		config, err := NewStationAppConfigFromSpinVariables()
 		if err != nil {
-			response.RenderFatal(w, fmt.Errorf("failed to load station app configuration: %w", err))
+			apierror.RenderFatal(w, fmt.Errorf("failed to load station app configuration: %w", err))
 			return
 		}
 
 		appComponents, err := initSystemAppComponent(*config)
 		if err != nil {
 			fmt.Printf("Error initializing station service: %v\n", err)
-			response.RenderFatal(w, fmt.Errorf("failed to initialize station app"))
+			apierror.RenderFatal(w, fmt.Errorf("failed to initialize station app"))
 			return
 		}
 		defer appComponents.Close()
 
 		if !appComponents.IsReady() {
 			fmt.Println("Station app components are not ready")
-			response.RenderFatal(w, fmt.Errorf("station app components are not ready"))
+			if health.ServeUnready(w, r, "/stations", "stations", appComponents.HealthDependencies()...) {
+				return
+			}
+			apierror.RenderFatal(w, fmt.Errorf("station app components are not ready"))
 			return
 		}
 
-		logger := appComponents.logger
+		logger := appComponents.Logger
 		logger.Info("Station AppComponents successfully initialized", "storeName", config.StoreName)
 
-		router := spinhttp.NewRouter()
-		router.GET("/stations", middleware.BearerAuth(newStationsHandler(appComponents), appComponents.secretStore))
-		router.GET("/stations/:id/waterlevel/", middleware.BearerAuth(newWaterLevelHandler(appComponents), appComponents.secretStore))
-		router.GET("/stations/:id", middleware.BearerAuth(newStationHandler(appComponents), appComponents.secretStore))
-		router.NotFound = response.NewNotFoundHandler(logger)
-
-		router.ServeHTTP(w, r)
+		w.Header().Set("Link", openapi.ServiceDescLink("/stations"))
+		middleware.ObserveRouter(stations.NewRouter(appComponents), logger).ServeHTTP(w, r)
 	})
 }
 
-func initSystemAppComponent(config StationAppConfig) (*stationAppComponent, error) {
-	logger := slog.New(slog.NewTextHandler(os.Stderr, nil)).With("component", "station")
+func initSystemAppComponent(config StationAppConfig) (*stations.Component, error) {
+	logger := log.NewLogger(config.LogLevel, config.LogFormat).With("component", "station")
 	logger.Info("Initializing station service")
 
 	// Initialize the Spin KV repository for station data
@@ -174,203 +201,69 @@ func initSystemAppComponent(config StationAppConfig) (*stationAppComponent, erro
 		return nil, fmt.Errorf("failed to create station repository: %w", err)
 	}
 
-	spinHTTPClient := spinhttp.NewClient()
-	stationProvider := station.NewPegelOnlineProvider(config.APIEndpoint, spinHTTPClient, logger)
-
-	secretStore := secret.NewInMemoryStore()
-	secretStore.Set(config.APIKey, config.APIKey)
-
-	return &stationAppComponent{stationRepository, stationProvider, secretStore, logger}, nil
-}
-
-func main() {}
-
-func newStationsHandler(appComponents *stationAppComponent) spinhttp.RouterHandle {
-	return func(w http.ResponseWriter, r *http.Request, params spinhttp.Params) {
-		logger := appComponents.logger
-
-		logger.Debug("Fetching all stations")
-		queryPagination := response.NewPaginationFromRequest(r)
-		stationCollection, err := fetchCachedStations(appComponents, queryPagination)
-		if err != nil {
-			logger.Error("Failed to fetch stations", "error", err)
-			response.RenderFatal(w, err)
-			return
-		}
-
-		if stationCollection == nil {
-			logger.Warn("No stations found in the collection")
-			response.RenderError(w, ErrNotFound, http.StatusNotFound)
-			return
-		}
-
-		queryPagination.Total = len(stationCollection.Stations)
-		response.RenderJSON(w, map[string]interface{}{
-			"stations":   stationCollection.Stations,
-			"pagination": queryPagination,
-		})
-	}
-}
-
-func newStationHandler(appComponents *stationAppComponent) spinhttp.RouterHandle {
-	return func(w http.ResponseWriter, r *http.Request, params spinhttp.Params) {
-		logger := appComponents.logger
-		stationID := params.ByName("id")
-		if stationID == "" {
-			response.RenderError(w, ErrNotFound, http.StatusNotFound)
-		}
-
-		logger.Debug("Fetching station by ID", "id", stationID)
-		stationItem, err := fetchCachedStationByID(appComponents, stationID)
-		if err != nil {
-			logger.Error("Failed to fetch station by ID", "id", stationID, "error", err)
-			response.RenderError(w, ErrNotFound, http.StatusNotFound)
-			return
-		}
-
-		if stationItem == nil {
-			logger.Warn("Station not found", "id", stationID)
-			response.RenderError(w, ErrNotFound, http.StatusNotFound)
-			return
-		}
-
-		waterLevelCollection, err := fetchCachedWaterLevels(appComponents, *stationItem)
-		if err != nil {
-			logger.Error("Failed to fetch water levels for station", "id", stationID, "error", err)
-			waterLevelCollection = &station.WaterLevelCollection{
-				StationID:    stationItem.ID,
-				Start:        station.DefaultTimePeriod, // Default start period
-				End:          station.DefaultTimePeriod, // Default end period
-				Unit:         station.UnitCM,            // Default unit for water level measurements
-				Measurements: []station.Measurement{},
-			}
-		}
-
-		stationDashboard := &StationDashboard{
-			Station:    stationItem,
-			WaterLevel: waterLevelCollection,
-		}
-
-		response.RenderJSON(w, stationDashboard)
-	}
-}
-
-func newWaterLevelHandler(appComponents *stationAppComponent) spinhttp.RouterHandle {
-	return func(w http.ResponseWriter, r *http.Request, params spinhttp.Params) {
-		logger := appComponents.logger
-		stationID := params.ByName("id")
-		logger.Debug("Fetching water level for station", "id", stationID)
-
-		if stationID == "" {
-			response.RenderError(w, ErrNotFound, http.StatusNotFound)
-			return
-		}
-
-		stationItem, err := fetchCachedStationByID(appComponents, stationID)
-		if err != nil {
-			logger.Error("Failed to fetch station by ID", "id", stationID, "error", err)
-			response.RenderFatal(w, err)
-			return
-		}
-
-		waterLevelCollection, err := fetchCachedWaterLevels(appComponents, *stationItem)
-		if err != nil {
-			logger.Error("Failed to fetch water levels", "id", stationID, "error", err)
-			response.RenderFatal(w, err)
-			return
-		}
-
-		if waterLevelCollection == nil || len(waterLevelCollection.Measurements) == 0 {
-			logger.Warn("No water levels found for station", "id", stationID)
-			response.RenderError(w, ErrNotFound, http.StatusNotFound)
-			return
-		}
-
-		jsonData, err := json.Marshal(waterLevelCollection)
-		if err != nil {
-			logger.Error("Failed to marshal water level data", "id", stationID, "error", err)
-			response.RenderFatal(w, ErrFailedToMarshal)
-			return
-		}
-
-		response.RenderSuccess(w, jsonData)
-	}
-}
-
-func fetchCachedStations(appComponents *stationAppComponent, pagination response.Pagination) (*station.StationCollection, error) {
-	logger := appComponents.logger
-	stationRepository := appComponents.stationRepository
-
-	if !stationRepository.IsReady() {
-		return nil, fmt.Errorf("station repository is not ready")
-	}
-
-	stationCollection, err := stationRepository.List(context.Background(), pagination.Offset, pagination.Limit)
+	// the provider cache keeps the decoded responses, the upstream validators for
+	// conditional requests and the state of the circuit breaker
+	providerCache, err := kvstore.OpenSpinStore(config.ProviderCacheStore)
 	if err != nil {
-		logger.Error("Failed to get stations from repository", "error", err)
-		return nil, err
+		logger.Error("Failed to open provider cache store", "store", config.ProviderCacheStore, "error", err)
+		return nil, fmt.Errorf("failed to open provider cache store: %w", err)
 	}
 
-	logger.Debug("Stations found in repository, returning cached data", "count", len(stationCollection.Stations))
-	return stationCollection, nil
-}
-
-func fetchCachedStationByID(appComponents *stationAppComponent, id string) (*station.Station, error) {
-	logger := appComponents.logger
-	stationRepository := appComponents.stationRepository
-
-	if !stationRepository.IsReady() {
-		return nil, fmt.Errorf("station repository is not ready")
-	}
-
-	if id == "" {
-		return nil, fmt.Errorf("station ID cannot be empty")
+	providers, err := station.NewRegistryFromConfig(station.RegistryConfig{
+		PegelOnlineEndpoint: config.APIEndpoint,
+		GenericProviders:    config.Providers,
+		Store:               providerCache,
+		CacheTTLs:           config.ProviderCacheTTLs,
+	}, spinhttp.NewClient(), logger)
+	if err != nil {
+		logger.Error("Failed to create provider registry", "error", err)
+		return nil, fmt.Errorf("failed to create provider registry: %w", err)
 	}
 
-	logger.Debug("Checking if station exists in repository", "id", id)
-	station, err := stationRepository.GetByID(context.Background(), id)
+	secretStore, err := secret.NewSpinKVStore(config.SecretStoreName, config.APIKey, logger)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get station by ID: %w", err)
+		logger.Error("Failed to create secret store", "error", err)
+		return nil, fmt.Errorf("failed to create secret store: %w", err)
 	}
 
-	if err == nil && station != nil {
-		logger.Debug("Station found in repository, returning cached data", "id", id)
-		return station, nil
+	rateLimiter, err := ratelimit.NewSpinLimiter(config.RateLimitStoreName, config.RateLimits, logger)
+	if err != nil {
+		logger.Error("Failed to create rate limiter", "error", err)
+		return nil, fmt.Errorf("failed to create rate limiter: %w", err)
 	}
 
-	logger.Info("Successfully fetched and cached station", "id", id)
-	return station, nil
+	return &stations.Component{
+		StationRepository:     stationRepository,
+		MeasurementRepository: openMeasurementRepository(config.MeasurementDBName, logger),
+		Providers:             providers,
+		ProviderCache:         providerCache,
+		SecretStore:           secretStore,
+		RateLimiter:           rateLimiter,
+		CachePolicies:         config.CachePolicies,
+		Logger:                logger,
+	}, nil
 }
 
-func fetchCachedWaterLevels(appComponents *stationAppComponent, stationItem station.Station) (*station.WaterLevelCollection, error) {
-	logger := appComponents.logger
-
-	stationID := stationItem.ID
-	pegelOnlineID, ok := stationItem.GetExternalID(station.PegelOnlineProviderName)
-	if !ok || pegelOnlineID == "" {
-		logger.Error("Station does not have a valid PegelOnline ID", "stationID", stationItem.ID)
-		return nil, fmt.Errorf("station does not have a valid PegelOnline ID: %s", stationItem.ID)
+// openMeasurementRepository returns nil without a measurement DB; the stations
+// are served without historical context then.
+func openMeasurementRepository(dbName string, logger *slog.Logger) measurement.Repository {
+	if dbName == "" {
+		return nil
 	}
 
-	logger.Debug("Fetching water levels for station", "id", stationID, "pegelOnlineID", pegelOnlineID)
-	waterLevelCollection, err := appComponents.stationProvider.GetStationWaterLevel(context.Background(), pegelOnlineID)
+	db, err := measurement.NewSpinSqliteDB(dbName)
 	if err != nil {
-		logger.Error("Failed to fetch water levels from provider", "id", stationID, "pegelOnlineID", pegelOnlineID, "error", err)
-		return nil, fmt.Errorf("failed to fetch water levels from provider: %w", err)
-	}
-
-	if waterLevelCollection == nil || len(waterLevelCollection.Measurements) == 0 {
-		logger.Warn("No water levels found for station", "id", stationID)
-		return nil, fmt.Errorf("no water levels found for station with ID: %s", stationID)
+		logger.Warn("Failed to open measurement DB, serving stations without historical context", "db", dbName, "error", err)
+		return nil
 	}
 
-	// augment water level with latest measurement and unit
-	waterLevelCollection.Unit = station.UnitCM // Default unit for water level measurements
-	waterLevelCollection.Latest = waterLevelCollection.GetLatestMeasurement()
-	if err := waterLevelCollection.CalculateTrends(waterLevelCollection.Measurements); err != nil {
-		logger.Error("Failed to calculate trends for water levels", "id", stationID, "error", err)
+	repository, err := measurement.NewSqlRepository(db, logger)
+	if err != nil {
+		logger.Warn("Failed to create measurement repository", "error", err)
+		return nil
 	}
 
-	logger.Debug("Successfully fetched water levels for station", "id", stationID, "count", len(waterLevelCollection.Measurements))
-	return waterLevelCollection, nil
+	return repository
 }
+
+func main() {}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/timgluz/wasserspiegel/measurement"
+	"github.com/timgluz/wasserspiegel/station"
+)
+
+const (
+	// PegelOnline keeps the measurements of the last 31 days.
+	MaxHistory = 31 * 24 * time.Hour
+	// DefaultStart is the period returned without a start parameter.
+	DefaultStart = "P1D"
+)
+
+// MockAPI serves the PegelOnline endpoints used by station.PegelOnlineProvider.
+type MockAPI struct {
+	fixtures *Fixtures
+	series   map[string]Series // by station UUID, nil for stations without water levels
+	interval time.Duration
+	location *time.Location
+	now      func() time.Time
+
+	logger *slog.Logger
+}
+
+func NewMockAPI(fixtures *Fixtures, config SeriesConfig, interval time.Duration, logger *slog.Logger) (*MockAPI, error) {
+	if interval < time.Second {
+		return nil, fmt.Errorf("interval must be at least a second")
+	}
+
+	location, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load timezone of PegelOnline: %w", err)
+	}
+
+	api := &MockAPI{
+		fixtures: fixtures,
+		series:   make(map[string]Series, len(fixtures.Stations)),
+		interval: interval,
+		location: location,
+		now:      time.Now,
+		logger:   logger,
+	}
+
+	for i, item := range fixtures.Stations {
+		var recording *Recording
+		if r, ok := fixtures.Recordings[item.UUID]; ok {
+			recording = &r
+		}
+
+		series, err := NewSeries(config, recording, i)
+		if err != nil {
+			return nil, err
+		}
+		api.series[item.UUID] = series
+	}
+
+	return api, nil
+}
+
+// ServeHTTP routes /stations.json, /stations/{id}.json and /stations/{id}/W/measurements.json.
+func (a *MockAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if r.URL.Path == "/stations.json" {
+		writeJSON(w, http.StatusOK, a.fixtures.Stations)
+		return
+	}
+
+	resource, ok := strings.CutPrefix(r.URL.Path, "/stations/")
+	if !ok {
+		writeError(w, http.StatusNotFound, "resource not found")
+		return
+	}
+
+	if id, ok := strings.CutSuffix(resource, "/W/measurements.json"); ok && !strings.Contains(id, "/") {
+		a.getMeasurements(w, r, id)
+		return
+	}
+
+	if id, ok := strings.CutSuffix(resource, ".json"); ok && !strings.Contains(id, "/") {
+		a.getStation(w, id)
+		return
+	}
+
+	writeError(w, http.StatusNotFound, "resource not found")
+}
+
+func (a *MockAPI) getStation(w http.ResponseWriter, id string) {
+	item, ok := a.fixtures.FindStation(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, "station not found: "+id)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, item)
+}
+
+func (a *MockAPI) getMeasurements(w http.ResponseWriter, r *http.Request, id string) {
+	item, ok := a.fixtures.FindStation(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, "station not found: "+id)
+		return
+	}
+
+	now := a.now()
+	start, end, err := parsePeriod(r.URL.Query().Get("start"), r.URL.Query().Get("end"), now)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	measurements := make(station.PegelOnlineMeasurementList, 0)
+	series := a.series[item.UUID]
+	if series != nil {
+		for at := start.Truncate(a.interval); !at.After(end); at = at.Add(a.interval) {
+			if at.Before(start) {
+				continue
+			}
+
+			measurements = append(measurements, station.PegelOnlineMeasurement{
+				Timestamp: at.In(a.location).Format(time.RFC3339),
+				Value:     series.Level(at),
+			})
+		}
+	}
+
+	a.logger.Debug("Serving measurements", "station", item.LongName, "start", start, "end", end, "count", len(measurements))
+	writeJSON(w, http.StatusOK, measurements)
+}
+
+// parsePeriod reads start and end like PegelOnline: either is an RFC 3339
+// timestamp or an ISO 8601 duration relative to now, and end defaults to now.
+func parsePeriod(startParam, endParam string, now time.Time) (time.Time, time.Time, error) {
+	end := now
+	if endParam != "" {
+		at, err := parsePoint(endParam, now)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid end: %w", err)
+		}
+		end = at
+	}
+	if end.After(now) {
+		end = now
+	}
+
+	if startParam == "" {
+		startParam = DefaultStart
+	}
+	start, err := parsePoint(startParam, end)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid start: %w", err)
+	}
+	if oldest := now.Add(-MaxHistory); start.Before(oldest) {
+		start = oldest
+	}
+
+	if start.After(end) {
+		return time.Time{}, time.Time{}, fmt.Errorf("start is after end")
+	}
+
+	return start, end, nil
+}
+
+func parsePoint(value string, until time.Time) (time.Time, error) {
+	if strings.HasPrefix(value, "P") {
+		start, err := measurement.ParseISO8601Duration(value, measurement.Epoch(until.Unix()))
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(int64(start), 0), nil
+	}
+
+	return time.Parse(time.RFC3339, value)
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]any{"status": status, "message": message})
+}
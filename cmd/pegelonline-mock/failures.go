@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FailureRule makes requests for paths with the given prefix fail; an empty
+// path matches every request. Count limits the rule to the next n requests,
+// 0 keeps it until the rules are cleared.
+type FailureRule struct {
+	Path      string `json:"path"`
+	Status    int    `json:"status,omitempty"`
+	Count     int    `json:"count,omitempty"`
+	Delay     string `json:"delay,omitempty"`
+	Malformed bool   `json:"malformed,omitempty"` // answer 200 with a truncated JSON body
+
+	delay time.Duration
+}
+
+func (r *FailureRule) validate() error {
+	if r.Count < 0 {
+		return fmt.Errorf("count must not be negative")
+	}
+
+	if r.Status != 0 && (r.Status < 100 || r.Status > 599) {
+		return fmt.Errorf("invalid status %d", r.Status)
+	}
+
+	if r.Delay != "" {
+		delay, err := time.ParseDuration(r.Delay)
+		if err != nil {
+			return fmt.Errorf("invalid delay: %w", err)
+		}
+		r.delay = delay
+	}
+
+	if r.Status == 0 && !r.Malformed && r.delay == 0 {
+		return fmt.Errorf("rule needs a status, a delay or malformed")
+	}
+
+	return nil
+}
+
+// FailureInjector fails requests by the rules added at runtime and, at
+// random, by the configured failure rate.
+type FailureInjector struct {
+	mu     sync.Mutex
+	rules  []*FailureRule
+	rate   float64
+	status int
+	random *rand.Rand
+}
+
+func NewFailureInjector(rate float64, status int, seed int64) *FailureInjector {
+	return &FailureInjector{
+		rate:   rate,
+		status: status,
+		random: rand.New(rand.NewSource(seed)),
+	}
+}
+
+func (f *FailureInjector) Add(rule FailureRule) error {
+	if err := rule.validate(); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.rules = append(f.rules, &rule)
+	return nil
+}
+
+func (f *FailureInjector) Rules() []FailureRule {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	rules := make([]FailureRule, 0, len(f.rules))
+	for _, rule := range f.rules {
+		rules = append(rules, *rule)
+	}
+
+	return rules
+}
+
+func (f *FailureInjector) Clear() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.rules = nil
+}
+
+// Next returns the failure of the request, if any; it uses up one request of a counted rule.
+func (f *FailureInjector) Next(path string) (FailureRule, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i, rule := range f.rules {
+		if !strings.HasPrefix(path, rule.Path) {
+			continue
+		}
+
+		if rule.Count > 0 {
+			rule.Count--
+			if rule.Count == 0 {
+				f.rules = append(f.rules[:i], f.rules[i+1:]...)
+			}
+		}
+
+		return *rule, true
+	}
+
+	if f.rate > 0 && f.random.Float64() < f.rate {
+		return FailureRule{Status: f.status}, true
+	}
+
+	return FailureRule{}, false
+}
+
+// Middleware answers requests with their injected failure before they reach next.
+func (f *FailureInjector) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rule, ok := f.Next(r.URL.Path)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if rule.delay > 0 {
+			select {
+			case <-time.After(rule.delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		switch {
+		case rule.Malformed:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`[{"timestamp": "`))
+		case rule.Status != 0:
+			writeError(w, rule.Status, "injected failure")
+		default:
+			next.ServeHTTP(w, r) // only delayed
+		}
+	})
+}
+
+// ServeHTTP lists the rules on GET, adds one on POST and removes all on DELETE.
+func (f *FailureInjector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, f.Rules())
+	case http.MethodPost:
+		var rule FailureRule
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid failure rule: "+err.Error())
+			return
+		}
+
+		if err := f.Add(rule); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, rule)
+	case http.MethodDelete:
+		f.Clear()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/timgluz/wasserspiegel/station"
+)
+
+//go:embed fixtures
+var embeddedFixtures embed.FS
+
+const (
+	stationsFixture     = "stations.json"
+	measurementsFixture = "measurements"
+)
+
+// Fixtures are the stations served by the mock and the recorded water
+// levels of some of them, keyed by the station UUID.
+type Fixtures struct {
+	Stations   station.PegelOnlineStationList
+	Recordings map[string]Recording
+}
+
+// Recording is a series of water levels recorded at a fixed interval.
+type Recording struct {
+	Values   []float64
+	Interval time.Duration
+}
+
+// Mean returns the average level of the recording.
+func (r Recording) Mean() float64 {
+	if len(r.Values) == 0 {
+		return 0
+	}
+
+	var sum float64
+	for _, value := range r.Values {
+		sum += value
+	}
+
+	return sum / float64(len(r.Values))
+}
+
+// DefaultFixtures returns the fixtures compiled into the binary.
+func DefaultFixtures() fs.FS {
+	fixtures, err := fs.Sub(embeddedFixtures, "fixtures")
+	if err != nil {
+		panic(err) // the directory is embedded at build time
+	}
+
+	return fixtures
+}
+
+// LoadFixtures reads stations.json and measurements/<uuid>.json from the file system;
+// recordings are optional and may be missing for any station.
+func LoadFixtures(fsys fs.FS) (*Fixtures, error) {
+	blob, err := fs.ReadFile(fsys, stationsFixture)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", stationsFixture, err)
+	}
+
+	fixtures := &Fixtures{Recordings: make(map[string]Recording)}
+	if err := json.Unmarshal(blob, &fixtures.Stations); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", stationsFixture, err)
+	}
+
+	for _, item := range fixtures.Stations {
+		if item.UUID == "" {
+			return nil, fmt.Errorf("station %q in %s has no uuid", item.LongName, stationsFixture)
+		}
+
+		recording, err := loadRecording(fsys, path.Join(measurementsFixture, item.UUID+".json"))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		fixtures.Recordings[item.UUID] = *recording
+	}
+
+	return fixtures, nil
+}
+
+// FindStation looks up a station by UUID or, like PegelOnline, by its short name.
+func (f *Fixtures) FindStation(id string) (*station.PegelOnlineStation, bool) {
+	for i := range f.Stations {
+		item := &f.Stations[i]
+		if item.UUID == id || strings.EqualFold(item.ShortName, id) {
+			return item, true
+		}
+	}
+
+	return nil, false
+}
+
+func loadRecording(fsys fs.FS, name string) (*Recording, error) {
+	blob, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var measurements station.PegelOnlineMeasurementList
+	if err := json.Unmarshal(blob, &measurements); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", name, err)
+	}
+	if len(measurements) == 0 {
+		return nil, fmt.Errorf("recording %s has no measurements", name)
+	}
+
+	recording := &Recording{Interval: DefaultInterval}
+	if len(measurements) > 1 {
+		first, err := time.Parse(time.RFC3339, measurements[0].Timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp in %s: %w", name, err)
+		}
+		second, err := time.Parse(time.RFC3339, measurements[1].Timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp in %s: %w", name, err)
+		}
+		if interval := second.Sub(first); interval > 0 {
+			recording.Interval = interval
+		}
+	}
+
+	for _, m := range measurements {
+		recording.Values = append(recording.Values, m.Value)
+	}
+
+	return recording, nil
+}
@@ -0,0 +1,190 @@
+// pegelonline-mock command serves the PegelOnline REST endpoints used by the
+// station provider from fixtures, so local runs and e2e tests work offline.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	_ "time/tzdata" // PegelOnline timestamps are in Europe/Berlin
+
+	"github.com/timgluz/wasserspiegel/log"
+)
+
+const (
+	DefaultAddr     = "127.0.0.1:8090"
+	DefaultInterval = 15 * time.Minute // PegelOnline publishes a water level every 15 minutes
+
+	// ControlPath is the endpoint to inject failures at runtime.
+	ControlPath = "/_mock/failures"
+)
+
+type Config struct {
+	Addr        string
+	FixturesDir string
+	Interval    time.Duration
+	Series      SeriesConfig
+
+	FailureRate   float64
+	FailureStatus int
+	Latency       time.Duration
+	Seed          int64
+	LogLevel      string
+}
+
+func newConfig(args []string, startedAt time.Time) (*Config, error) {
+	flags := flag.NewFlagSet("pegelonline-mock", flag.ContinueOnError)
+
+	addr := flags.String("addr", envOr("PEGELONLINE_MOCK_ADDR", DefaultAddr), "address to listen on [PEGELONLINE_MOCK_ADDR]")
+	fixturesDir := flags.String("fixtures", "", "directory with stations.json and measurements/<uuid>.json, defaults to the embedded fixtures")
+	interval := flags.Duration("interval", DefaultInterval, "time between two measurements")
+
+	series := flags.String("series", SeriesFixture, "water levels to serve: fixture, sine or flood")
+	base := flags.Float64("base", 300, "level in cm of synthetic series for stations without a recording")
+	amplitude := flags.Float64("amplitude", 50, "amplitude in cm of the sine series")
+	period := flags.Duration("period", 24*time.Hour, "period of the sine series")
+	floodHeight := flags.Float64("flood-height", 400, "height in cm of the flood wave above the base level")
+	floodPeak := flags.Duration("flood-peak", 24*time.Hour, "time from the start of the mock until the crest of the flood wave")
+	floodRise := flags.Duration("flood-rise", 12*time.Hour, "how fast the flood wave rises")
+	floodFall := flags.Duration("flood-fall", 48*time.Hour, "how fast the flood wave recedes")
+
+	failureRate := flags.Float64("failure-rate", 0, "share of requests failing at random, between 0 and 1")
+	failureStatus := flags.Int("failure-status", http.StatusServiceUnavailable, "status code of random failures")
+	latency := flags.Duration("latency", 0, "delay added to every response")
+	seed := flags.Int64("seed", 1, "seed of the random failures")
+	logLevel := flags.String("log-level", "info", "debug, info, warn or error")
+
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *failureRate < 0 || *failureRate > 1 {
+		return nil, fmt.Errorf("failure rate must be between 0 and 1")
+	}
+
+	return &Config{
+		Addr:        *addr,
+		FixturesDir: *fixturesDir,
+		Interval:    *interval,
+		Series: SeriesConfig{
+			Kind:        *series,
+			Base:        *base,
+			Amplitude:   *amplitude,
+			Period:      *period,
+			FloodHeight: *floodHeight,
+			FloodPeakAt: startedAt.Add(*floodPeak),
+			FloodRise:   *floodRise,
+			FloodFall:   *floodFall,
+		},
+		FailureRate:   *failureRate,
+		FailureStatus: *failureStatus,
+		Latency:       *latency,
+		Seed:          *seed,
+		LogLevel:      *logLevel,
+	}, nil
+}
+
+func envOr(name string, defaultValue string) string {
+	if value, ok := os.LookupEnv(name); ok {
+		return value
+	}
+
+	return defaultValue
+}
+
+func main() {
+	config, err := newConfig(os.Args[1:], time.Now())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error loading config:", err)
+		os.Exit(2)
+	}
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		Level: log.SlogLevelInfoFromString(config.LogLevel),
+	}))
+
+	handler, err := newHandler(*config, logger)
+	if err != nil {
+		logger.Error("Failed to initialize mock", "error", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := listenAndServe(ctx, config.Addr, handler, logger); err != nil {
+		logger.Error("Mock stopped with error", "error", err)
+		os.Exit(1)
+	}
+}
+
+func newHandler(config Config, logger *slog.Logger) (http.Handler, error) {
+	fixturesFS := DefaultFixtures()
+	if config.FixturesDir != "" {
+		fixturesFS = os.DirFS(config.FixturesDir)
+	}
+
+	fixtures, err := LoadFixtures(fixturesFS)
+	if err != nil {
+		return nil, err
+	}
+
+	api, err := NewMockAPI(fixtures, config.Series, config.Interval, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	failures := NewFailureInjector(config.FailureRate, config.FailureStatus, config.Seed)
+	logger.Info("Loaded fixtures", "stations", len(fixtures.Stations), "recordings", len(fixtures.Recordings), "series", config.Series.Kind)
+
+	mux := http.NewServeMux()
+	mux.Handle(ControlPath, failures)
+	mux.Handle("/", failures.Middleware(api))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.Debug("Request", "method", r.Method, "path", r.URL.Path, "query", r.URL.RawQuery)
+		if config.Latency > 0 && r.URL.Path != ControlPath {
+			time.Sleep(config.Latency)
+		}
+
+		mux.ServeHTTP(w, r)
+	}), nil
+}
+
+func listenAndServe(ctx context.Context, addr string, handler http.Handler, logger *slog.Logger) error {
+	httpServer := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		logger.Info("Listening", "addr", addr)
+		errCh <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	logger.Info("Shutting down mock")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := httpServer.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	SeriesFixture = "fixture"
+	SeriesSine    = "sine"
+	SeriesFlood   = "flood"
+)
+
+// Series computes the water level of a station at any point in time, so
+// repeated requests for overlapping periods return the same values.
+type Series interface {
+	Level(at time.Time) float64
+}
+
+// SeriesConfig shapes the synthetic series; all levels are in cm.
+type SeriesConfig struct {
+	Kind string
+	Base float64
+
+	Amplitude float64
+	Period    time.Duration
+
+	FloodHeight float64
+	FloodPeakAt time.Time
+	FloodRise   time.Duration
+	FloodFall   time.Duration
+}
+
+// NewSeries returns the series of the station at the given position in the
+// fixtures; each station is shifted by an hour, so they don't move in lockstep.
+func NewSeries(config SeriesConfig, recording *Recording, position int) (Series, error) {
+	base := config.Base
+	if recording != nil {
+		base = recording.Mean()
+	}
+	shift := time.Duration(position) * time.Hour
+
+	switch config.Kind {
+	case SeriesFixture, "":
+		if recording == nil {
+			return nil, nil
+		}
+		return replaySeries{recording: *recording}, nil
+	case SeriesSine:
+		if config.Period <= 0 {
+			return nil, fmt.Errorf("sine period must be positive")
+		}
+		return sineSeries{base: base, amplitude: config.Amplitude, period: config.Period, shift: shift}, nil
+	case SeriesFlood:
+		if config.FloodRise <= 0 || config.FloodFall <= 0 {
+			return nil, fmt.Errorf("flood rise and fall must be positive")
+		}
+		return floodSeries{
+			base:   base,
+			height: config.FloodHeight,
+			peakAt: config.FloodPeakAt.Add(shift),
+			rise:   config.FloodRise,
+			fall:   config.FloodFall,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown series %q, expected %s, %s or %s", config.Kind, SeriesFixture, SeriesSine, SeriesFlood)
+	}
+}
+
+// replaySeries plays a recording in a loop.
+type replaySeries struct {
+	recording Recording
+}
+
+func (s replaySeries) Level(at time.Time) float64 {
+	slot := at.Unix() / int64(s.recording.Interval/time.Second)
+	n := int64(len(s.recording.Values))
+	return s.recording.Values[((slot%n)+n)%n]
+}
+
+type sineSeries struct {
+	base      float64
+	amplitude float64
+	period    time.Duration
+	shift     time.Duration
+}
+
+func (s sineSeries) Level(at time.Time) float64 {
+	phase := float64(at.Add(s.shift).UnixNano()%int64(s.period)) / float64(s.period)
+	return math.Round(s.base + s.amplitude*math.Sin(2*math.Pi*phase))
+}
+
+// floodSeries is a single flood wave: the level rises to the crest at peakAt
+// and recedes more slowly than it rose, like on a real river.
+type floodSeries struct {
+	base   float64
+	height float64
+	peakAt time.Time
+	rise   time.Duration
+	fall   time.Duration
+}
+
+func (s floodSeries) Level(at time.Time) float64 {
+	offset := at.Sub(s.peakAt)
+	width := s.fall
+	if offset < 0 {
+		width = s.rise
+	}
+
+	x := float64(offset) / float64(width)
+	return math.Round(s.base + s.height*math.Exp(-x*x))
+}
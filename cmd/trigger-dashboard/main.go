@@ -10,6 +10,8 @@ import (
 	"time"
 
 	"github.com/timgluz/wasserspiegel/dashboard"
+	"github.com/timgluz/wasserspiegel/middleware"
+	"github.com/timgluz/wasserspiegel/response"
 	"github.com/timgluz/wasserspiegel/task"
 )
 
@@ -161,6 +163,9 @@ func triggerDashboardBuild(config *Config, opts task.DashboardBuilderOptions) er
 	req.Header.Set("Authorization", "Bearer "+config.APIKey)
 	req.Header.Set("Accept", "application/json")
 
+	requestID := middleware.NewRequestID()
+	req.Header.Set(response.RequestIDHeader, requestID)
+
 	q := req.URL.Query()
 	q.Add("station_id", stationID)
 	q.Add("language_code", opts.LanguageCode)
@@ -169,7 +174,7 @@ func triggerDashboardBuild(config *Config, opts task.DashboardBuilderOptions) er
 
 	resp, err := httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to perform HTTP request: %w", err)
+		return fmt.Errorf("failed to perform HTTP request %s: %w", requestID, err)
 	}
 	defer func(resp *http.Response) {
 		if err := resp.Body.Close(); err != nil {
@@ -179,7 +184,7 @@ func triggerDashboardBuild(config *Config, opts task.DashboardBuilderOptions) er
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API returned non-200/202 status: %d, body: %s", resp.StatusCode, string(bodyBytes))
+		return fmt.Errorf("API returned non-200/202 status for request %s: %d, body: %s", requestID, resp.StatusCode, string(bodyBytes))
 	}
 
 	return nil
@@ -11,6 +11,8 @@ import (
 	"os"
 	"time"
 
+	"github.com/timgluz/wasserspiegel/middleware"
+	"github.com/timgluz/wasserspiegel/response"
 	"github.com/timgluz/wasserspiegel/station"
 )
 
@@ -175,6 +177,10 @@ func triggerMeasurementTask(client *http.Client, config *Config, stationID strin
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("Authorization", "Bearer "+config.APIKey)
+
+	// the task logs carry the request ID, so a failed run can be found in them
+	requestID := middleware.NewRequestID()
+	req.Header.Set(response.RequestIDHeader, requestID)
 	q := req.URL.Query()
 
 	// Add required query parameters
@@ -184,7 +190,7 @@ func triggerMeasurementTask(client *http.Client, config *Config, stationID strin
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("request failed: %w", err)
+		return fmt.Errorf("request %s failed: %w", requestID, err)
 	}
 	defer func(resp *http.Response) {
 		if err := resp.Body.Close(); err != nil {
@@ -198,8 +204,9 @@ func triggerMeasurementTask(client *http.Client, config *Config, stationID strin
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status code: %d - %s", resp.StatusCode, string(content))
+		return fmt.Errorf("unexpected status code of request %s: %d - %s", requestID, resp.StatusCode, string(content))
 	}
 
+	fmt.Printf("Triggered measurement for station %s, request ID: %s\n", stationID, requestID)
 	return nil
 }
@@ -0,0 +1,483 @@
+// Command wasserspiegel-server runs all components of wasserspiegel in one
+// native process, with file-backed KV stores and a SQLite database instead of
+// the Spin host.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+	_ "time/tzdata" // the stats accept any IANA time zone, also without zoneinfo on the host
+
+	_ "modernc.org/sqlite"
+
+	"github.com/timgluz/wasserspiegel/api/dashboards"
+	"github.com/timgluz/wasserspiegel/api/measurements"
+	"github.com/timgluz/wasserspiegel/api/search"
+	"github.com/timgluz/wasserspiegel/api/stations"
+	"github.com/timgluz/wasserspiegel/api/tasks"
+	"github.com/timgluz/wasserspiegel/dashboard"
+	"github.com/timgluz/wasserspiegel/kvstore"
+	"github.com/timgluz/wasserspiegel/log"
+	"github.com/timgluz/wasserspiegel/measurement"
+	"github.com/timgluz/wasserspiegel/metrics"
+	"github.com/timgluz/wasserspiegel/middleware"
+	"github.com/timgluz/wasserspiegel/openapi"
+	"github.com/timgluz/wasserspiegel/ratelimit"
+	"github.com/timgluz/wasserspiegel/response"
+	"github.com/timgluz/wasserspiegel/secret"
+	"github.com/timgluz/wasserspiegel/station"
+)
+
+const (
+	DefaultAddr           = ":8080"
+	DefaultDataDir        = "data"
+	DefaultRequestTimeout = 30 * time.Second
+	DefaultPegelOnlineURL = "https://www.pegelonline.wsv.de/webservices/rest-api/v2"
+
+	// cacheFlushInterval batches the writes of the KV stores that are rebuilt
+	// anyway, the rate limits and the provider cache, which change on most requests.
+	cacheFlushInterval = 5 * time.Second
+
+	// spinVariablePrefix lets the server share the environment of `spin up`,
+	// e.g. SPIN_VARIABLE_API_KEY sets the api_key variable of both.
+	spinVariablePrefix = "SPIN_VARIABLE_"
+)
+
+type ServerConfig struct {
+	Addr    string
+	DataDir string
+
+	APIKey            string
+	ShareSigningKey   string
+	PegelOnlineURL    string
+	RateLimits        string
+	CachePolicies     response.CachePolicies
+	ProviderCacheTTLs station.CacheTTLs
+	Providers         []station.GenericProviderConfig
+	ClientTimeout     time.Duration
+	LogLevel          string
+	LogFormat         string
+
+	StationStoreName       string
+	DashboardStoreName     string
+	SecretStoreName        string
+	RateLimitStoreName     string
+	ProviderCacheStoreName string
+	MeasurementDBName      string
+}
+
+// newServerConfig reads the flags; every flag defaults to its environment variable.
+func newServerConfig(args []string) (*ServerConfig, error) {
+	flags := flag.NewFlagSet("wasserspiegel-server", flag.ContinueOnError)
+
+	addr := flags.String("addr", envOr("WS_ADDR", DefaultAddr), "address to listen on [WS_ADDR]")
+	dataDir := flags.String("data-dir", envOr("WS_DATA_DIR", DefaultDataDir), "directory of the KV store files and the SQLite database [WS_DATA_DIR]")
+	clientTimeout := flags.Duration("client-timeout", 10*time.Second, "timeout of upstream provider requests")
+
+	apiKey := flags.String("api-key", spinVar("api_key", ""), "master API key")
+	shareSigningKey := flags.String("share-signing-key", spinVar("share_signing_key", ""), "signs dashboard share links, falls back to the API key")
+	pegelOnlineURL := flags.String("pegelonline-api-url", spinVar("pegelonline_api_url", DefaultPegelOnlineURL), "base URL of the PegelOnline API")
+	rateLimits := flags.String("rate-limits", spinVar("rate_limits", ""), `overrides of the per key limits, e.g. "search=30/m,task=10/h:5"`)
+	cacheControl := flags.String("cache-control", spinVar("cache_control", ""), `overrides of the Cache-Control values per route, e.g. "dashboards=public, max-age=300"`)
+	providerCacheTTLs := flags.String("provider-cache-ttls", spinVar("provider_cache_ttls", ""), `overrides of the provider cache TTLs, e.g. "waterlevel=5m,stations=12h"`)
+	providers := flags.String("providers", spinVar("providers", ""), "JSON array of generic JSON/CSV providers")
+	logLevel := flags.String("log-level", spinVar("log_level", "info"), "debug, info, warn or error")
+	logFormat := flags.String("log-format", spinVar("log_format", log.FormatText), "text or json")
+
+	stationStoreName := flags.String("stations-store-name", spinVar("stations_store_name", "stations"), "name of the stations KV store")
+	dashboardStoreName := flags.String("dashboard-store-name", spinVar("dashboard_store_name", "dashboards"), "name of the dashboards KV store")
+	secretStoreName := flags.String("secrets-store-name", spinVar("secrets_store_name", "secrets"), "name of the secrets KV store")
+	rateLimitStoreName := flags.String("ratelimit-store-name", spinVar("ratelimit_store_name", "ratelimits"), "name of the rate limits KV store")
+	providerCacheStoreName := flags.String("provider-cache-store-name", spinVar("provider_cache_store_name", "providercache"), "name of the provider cache KV store")
+	measurementDBName := flags.String("measurement-db-name", spinVar("measurement_db_name", "measurements"), "name of the SQLite database of the measurements")
+
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *apiKey == "" {
+		return nil, fmt.Errorf("api key is required, set -api-key or %sAPI_KEY", spinVariablePrefix)
+	}
+
+	if *shareSigningKey == "" {
+		*shareSigningKey = *apiKey
+	}
+
+	cachePolicies, err := response.ParseCachePolicies(*cacheControl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cache_control: %w", err)
+	}
+
+	cacheTTLs, err := station.ParseCacheTTLs(*providerCacheTTLs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid provider_cache_ttls: %w", err)
+	}
+
+	providerConfigs, err := station.ParseGenericProviderConfigs(*providers)
+	if err != nil {
+		return nil, fmt.Errorf("invalid providers: %w", err)
+	}
+
+	return &ServerConfig{
+		Addr:                   *addr,
+		DataDir:                *dataDir,
+		APIKey:                 *apiKey,
+		ShareSigningKey:        *shareSigningKey,
+		PegelOnlineURL:         *pegelOnlineURL,
+		RateLimits:             *rateLimits,
+		CachePolicies:          cachePolicies,
+		ProviderCacheTTLs:      cacheTTLs,
+		Providers:              providerConfigs,
+		ClientTimeout:          *clientTimeout,
+		LogLevel:               *logLevel,
+		LogFormat:              *logFormat,
+		StationStoreName:       *stationStoreName,
+		DashboardStoreName:     *dashboardStoreName,
+		SecretStoreName:        *secretStoreName,
+		RateLimitStoreName:     *rateLimitStoreName,
+		ProviderCacheStoreName: *providerCacheStoreName,
+		MeasurementDBName:      *measurementDBName,
+	}, nil
+}
+
+func envOr(name string, defaultValue string) string {
+	if value, ok := os.LookupEnv(name); ok {
+		return value
+	}
+
+	return defaultValue
+}
+
+func spinVar(name string, defaultValue string) string {
+	return envOr(spinVariablePrefix+strings.ToUpper(name), defaultValue)
+}
+
+func main() {
+	config, err := newServerConfig(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error loading config:", err)
+		os.Exit(2)
+	}
+
+	logger := log.NewLogger(config.LogLevel, config.LogFormat)
+
+	server, err := newServer(*config, logger)
+	if err != nil {
+		logger.Error("Failed to initialize server", "error", err)
+		os.Exit(1)
+	}
+	defer server.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := server.ListenAndServe(ctx); err != nil {
+		logger.Error("Server stopped with error", "error", err)
+		os.Exit(1)
+	}
+}
+
+// server holds the stores shared by all components; unlike the Spin
+// components, they are opened once and live as long as the process.
+type server struct {
+	config ServerConfig
+	stores map[string]*kvstore.FileStore
+	db     *sql.DB
+	router http.Handler
+
+	logger *slog.Logger
+}
+
+func newServer(config ServerConfig, logger *slog.Logger) (*server, error) {
+	s := &server{
+		config: config,
+		stores: make(map[string]*kvstore.FileStore),
+		logger: logger,
+	}
+
+	if err := s.init(); err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *server) init() error {
+	config, logger := s.config, s.logger
+
+	db, err := openSqliteDB(filepath.Join(config.DataDir, config.MeasurementDBName+".db"))
+	if err != nil {
+		return err
+	}
+	s.db = db
+
+	if err := measurement.ApplySchema(context.Background(), db); err != nil {
+		return err
+	}
+
+	measurementRepository, err := measurement.NewSqlRepository(db, logger.With("component", "measurement"))
+	if err != nil {
+		return fmt.Errorf("failed to create measurement repository: %w", err)
+	}
+
+	metricsStore, err := metrics.NewSQLStore(db, logger.With("component", "metrics"))
+	if err != nil {
+		return fmt.Errorf("failed to create metrics store: %w", err)
+	}
+
+	stationStore, err := s.openStore(config.StationStoreName)
+	if err != nil {
+		return err
+	}
+	dashboardStore, err := s.openStore(config.DashboardStoreName)
+	if err != nil {
+		return err
+	}
+	secretKVStore, err := s.openStore(config.SecretStoreName)
+	if err != nil {
+		return err
+	}
+	rateLimitStore, err := s.openStore(config.RateLimitStoreName)
+	if err != nil {
+		return err
+	}
+	rateLimitStore.FlushEvery(cacheFlushInterval)
+	providerCache, err := s.openStore(config.ProviderCacheStoreName)
+	if err != nil {
+		return err
+	}
+	providerCache.FlushEvery(cacheFlushInterval)
+
+	rateLimitPolicies, err := ratelimit.ParsePolicies(config.RateLimits)
+	if err != nil {
+		return fmt.Errorf("invalid rate_limits: %w", err)
+	}
+
+	stationRepository := station.NewKVRepository(stationStore, logger.With("component", "station"))
+	dashboardRepository := dashboard.NewKVRepository(dashboardStore, logger.With("component", "dashboard"))
+	secretStore := secret.NewKVStore(secretKVStore, config.APIKey, logger)
+	shareStore := secret.NewShareStore(secretKVStore, config.ShareSigningKey, logger)
+	rateLimiter := ratelimit.NewLimiter(rateLimitStore, rateLimitPolicies, logger)
+
+	client := &http.Client{Timeout: config.ClientTimeout}
+	stationProviders, err := station.NewRegistryFromConfig(station.RegistryConfig{
+		PegelOnlineEndpoint: config.PegelOnlineURL,
+		GenericProviders:    config.Providers,
+		Store:               providerCache,
+		CacheTTLs:           config.ProviderCacheTTLs,
+	}, client, logger.With("component", "station"))
+	if err != nil {
+		return fmt.Errorf("failed to create provider registry: %w", err)
+	}
+
+	// collection runs aren't waited for by users and can afford longer retries
+	taskProviders, err := station.NewRegistryFromConfig(station.RegistryConfig{
+		PegelOnlineEndpoint: config.PegelOnlineURL,
+		GenericProviders:    config.Providers,
+		Store:               providerCache,
+		RetryPolicy:         station.RetryPolicy{MaxAttempts: 4, BaseDelay: time.Second, MaxDelay: 10 * time.Second},
+	}, client, logger.With("component", "task"))
+	if err != nil {
+		return fmt.Errorf("failed to create provider registry: %w", err)
+	}
+
+	stationsComponent := &stations.Component{
+		StationRepository:     stationRepository,
+		MeasurementRepository: measurementRepository,
+		Providers:             stationProviders,
+		ProviderCache:         providerCache,
+		SecretStore:           secretStore,
+		RateLimiter:           rateLimiter,
+		CachePolicies:         config.CachePolicies,
+		Logger:                logger.With("component", "station"),
+	}
+	searchComponent := &search.Component{
+		StationRepository: stationRepository,
+		SecretStore:       secretStore,
+		RateLimiter:       rateLimiter,
+		CachePolicies:     config.CachePolicies,
+		Logger:            logger.With("component", "search"),
+	}
+	measurementsComponent := &measurements.Component{
+		MeasurementRepository: measurementRepository,
+		SecretStore:           secretStore,
+		RateLimiter:           rateLimiter,
+		CachePolicies:         config.CachePolicies,
+		Logger:                logger.With("component", "measurement"),
+	}
+	dashboardsComponent := &dashboards.Component{
+		Repository:    dashboardRepository,
+		SecretStore:   secretStore,
+		RateLimiter:   rateLimiter,
+		ShareStore:    shareStore,
+		CachePolicies: config.CachePolicies,
+		Logger:        logger.With("component", "dashboard"),
+	}
+	tasksComponent := &tasks.Component{
+		MeasurementRepository: measurementRepository,
+		DashboardRepository:   dashboardRepository,
+		StationRepository:     stationRepository,
+		Providers:             taskProviders,
+		ProviderCache:         providerCache,
+		SecretStore:           secretStore,
+		RateLimiter:           rateLimiter,
+		MetricsStore:          metricsStore,
+		Logger:                logger.With("component", "task"),
+	}
+
+	if !stationsComponent.IsReady() || !searchComponent.IsReady() || !measurementsComponent.IsReady() || !tasksComponent.IsReady() {
+		return fmt.Errorf("server components are not ready")
+	}
+
+	s.router = newServerRouter(routes{
+		"/stations":     middleware.ObserveRouter(stations.NewRouter(stationsComponent), stationsComponent.Logger),
+		"/search":       middleware.ObserveRouter(search.NewRouter(searchComponent), searchComponent.Logger),
+		"/measurements": middleware.ObserveRouter(measurements.NewRouter(measurementsComponent), measurementsComponent.Logger),
+		"/dashboards":   middleware.ObserveRouter(dashboards.NewRouter(dashboardsComponent), dashboardsComponent.Logger),
+		"/tasks":        middleware.ObserveRouter(tasks.NewRouter(tasksComponent), tasksComponent.Logger),
+	}, logger)
+
+	return nil
+}
+
+func (s *server) openStore(name string) (*kvstore.FileStore, error) {
+	if store, ok := s.stores[name]; ok {
+		return store, nil
+	}
+
+	store, err := kvstore.OpenFileStore(s.config.DataDir, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open KV store %s: %w", name, err)
+	}
+
+	s.stores[name] = store
+	return store, nil
+}
+
+// openSqliteDB opens the database file; SQLite allows a single writer, so the
+// pool is limited to one connection instead of failing with SQLITE_BUSY.
+func openSqliteDB(path string) (*sql.DB, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	db, err := sql.Open("sqlite", path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite DB %s: %w", path, err)
+	}
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping SQLite DB: %w", err)
+	}
+
+	return db, nil
+}
+
+// routes maps the path prefix of each component to its router, wrapped by middleware.ObserveRouter.
+type routes map[string]http.Handler
+
+// newServerRouter dispatches requests by path prefix, as the Spin triggers do.
+func newServerRouter(components routes, logger *slog.Logger) http.Handler {
+	document := middleware.Observe(openapi.Handler(openapi.NewWasserspiegelDocument()), logger, nil)
+
+	mux := http.NewServeMux()
+	for prefix, router := range components {
+		mux.Handle(prefix, withServiceDesc(router, prefix))
+		mux.Handle(prefix+"/", withServiceDesc(router, prefix))
+		mux.Handle(http.MethodGet+" "+prefix+openapi.DocumentPath, withServiceDesc(document, prefix))
+	}
+
+	mux.Handle(http.MethodGet+" "+openapi.DocumentPath, withServiceDesc(document, ""))
+	mux.Handle("/", withServiceDesc(middleware.Observe(response.NewNotFoundHandler(logger), logger, nil), ""))
+
+	return mux
+}
+
+// withServiceDesc links the responses of a component to the document below its prefix, as the Spin components do.
+func withServiceDesc(next http.Handler, prefix string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", openapi.ServiceDescLink(prefix))
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *server) ListenAndServe(ctx context.Context) error {
+	httpServer := &http.Server{
+		Addr:              s.config.Addr,
+		Handler:           newTimeoutHandler(s.router, DefaultRequestTimeout),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		s.logger.Info("Listening", "addr", s.config.Addr, "dataDir", s.config.DataDir)
+		errCh <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	s.logger.Info("Shutting down server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := httpServer.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// newTimeoutHandler answers requests running longer than the timeout with a problem.
+func newTimeoutHandler(h http.Handler, timeout time.Duration) http.Handler {
+	body, _ := json.Marshal(response.NewProblem(http.StatusServiceUnavailable, response.CodeTimeout, "request timed out"))
+	timeoutHandler := http.TimeoutHandler(h, timeout, string(body))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeoutHandler.ServeHTTP(timeoutResponseWriter{w}, r)
+	})
+}
+
+// timeoutResponseWriter sets the content type of the timeout problem,
+// http.TimeoutHandler writes the body without one.
+type timeoutResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (w timeoutResponseWriter) WriteHeader(status int) {
+	if status == http.StatusServiceUnavailable && w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", response.ProblemContentType)
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (s *server) Close() {
+	for _, store := range s.stores {
+		store.Close()
+	}
+
+	if s.db != nil {
+		if err := s.db.Close(); err != nil {
+			s.logger.Error("Failed to close SQLite DB", "error", err)
+		}
+	}
+}
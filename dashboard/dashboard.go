@@ -3,6 +3,7 @@ package dashboard
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/gosimple/slug"
 	"github.com/timgluz/wasserspiegel/measurement"
@@ -15,11 +16,23 @@ type Dashboard struct {
 	Description string                 `json:"description"`
 	Station     station.Station        `json:"station"`
 	WaterLevel  measurement.Timeseries `json:"water_level"`
+	Forecast    *measurement.Forecast  `json:"forecast,omitempty"` // of the water level, missing without enough samples
+	// Historical ranks the latest water level against the baseline of its day of the year.
+	Historical *measurement.HistoricalContext `json:"historical,omitempty"`
+	// Trend and RateOfChange of the latest water level, with the day boundaries in the timezone of the dashboard.
+	Trend        station.MeasurementTrend `json:"trend,omitempty"`
+	RateOfChange *station.Measurement     `json:"rate_of_change,omitempty"`
+	Display      *Display                 `json:"display,omitempty"`
 
 	LanguageCode string `json:"language_code"`
 	Timezone     string `json:"timezone"`
 	CreatedAt    int64  `json:"created_at"`
 	UpdatedAt    int64  `json:"updated_at"`
+
+	// Stale tells displays to warn that the water levels stopped updating,
+	// DataAge is the age of the newest water level in seconds or null without one.
+	Stale   bool   `json:"stale"`
+	DataAge *int64 `json:"data_age"`
 }
 
 func NewEmptyDashboard(stationID, languageCode, timezone string) *Dashboard {
@@ -40,6 +53,9 @@ func (d *Dashboard) Merge(other *Dashboard) {
 	if other == nil {
 		return
 	}
+	if other.ID != "" {
+		d.ID = other.ID
+	}
 	if other.Name != "" {
 		d.Name = other.Name
 	}
@@ -52,6 +68,21 @@ func (d *Dashboard) Merge(other *Dashboard) {
 	if len(other.WaterLevel.Samples) > 0 {
 		d.WaterLevel = other.WaterLevel
 	}
+	if other.Forecast != nil {
+		d.Forecast = other.Forecast
+	}
+	if other.Historical != nil {
+		d.Historical = other.Historical
+	}
+	if len(other.Trend) > 0 {
+		d.Trend = other.Trend
+	}
+	if other.RateOfChange != nil {
+		d.RateOfChange = other.RateOfChange
+	}
+	if other.Display != nil {
+		d.Display = other.Display
+	}
 
 	if other.LanguageCode != "" {
 		d.LanguageCode = other.LanguageCode
@@ -69,6 +100,32 @@ func (d *Dashboard) Merge(other *Dashboard) {
 	}
 }
 
+// LastModified returns the time the dashboard was last built.
+func (d *Dashboard) LastModified() time.Time {
+	if d.UpdatedAt > 0 {
+		return time.Unix(d.UpdatedAt, 0)
+	}
+
+	if d.CreatedAt > 0 {
+		return time.Unix(d.CreatedAt, 0)
+	}
+
+	return time.Time{}
+}
+
+// UpdateFreshness sets Stale and DataAge from the water levels at now. The
+// age is truncated to minutes, so the ETag of a served dashboard stays stable.
+func (d *Dashboard) UpdateFreshness(policy measurement.FreshnessPolicy, now time.Time) {
+	freshness := policy.Evaluate(d.WaterLevel.Name, d.WaterLevel.Samples, 0, now)
+
+	d.Stale = freshness.Stale
+	d.DataAge = nil
+	if freshness.LatestSample > 0 {
+		age := freshness.Lag - freshness.Lag%60
+		d.DataAge = &age
+	}
+}
+
 func (d *Dashboard) IsSaved() bool {
 	return d.ID != ""
 }
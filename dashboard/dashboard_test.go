@@ -0,0 +1,93 @@
+package dashboard_test
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/timgluz/wasserspiegel/dashboard"
+	"github.com/timgluz/wasserspiegel/i18n"
+	"github.com/timgluz/wasserspiegel/measurement"
+	"github.com/timgluz/wasserspiegel/station"
+)
+
+func TestDashboardUpdateFreshness(t *testing.T) {
+	policy := measurement.DefaultFreshnessPolicy()
+	d := dashboard.NewEmptyDashboard("bonn", "en", "utc")
+
+	d.UpdateFreshness(policy, time.Unix(1700000000, 0))
+	assert.True(t, d.Stale, "a dashboard without water levels is stale")
+	assert.Nil(t, d.DataAge)
+
+	d.WaterLevel.Samples = []measurement.Sample{{Timestamp: 1700000000}, {Timestamp: 1700000900}}
+	d.UpdateFreshness(policy, time.Unix(1700000900+25*60+17, 0))
+	assert.False(t, d.Stale)
+	if assert.NotNil(t, d.DataAge) {
+		assert.Equal(t, int64(25*60), *d.DataAge, "the age is truncated to minutes")
+	}
+
+	d.UpdateFreshness(policy, time.Unix(1700000900+3*3600, 0))
+	assert.True(t, d.Stale)
+}
+
+func TestDashboardLocalize(t *testing.T) {
+	localizer, err := i18n.NewLocalizer("de", "Europe/Berlin")
+	assert.NoError(t, err)
+
+	d := dashboard.NewEmptyDashboard("bonn", "de", "Europe/Berlin")
+	d.WaterLevel = measurement.Timeseries{
+		Samples:     []measurement.Sample{{Timestamp: 1743415200, Value: 312}}, // 2025-03-31T10:00:00Z
+		Measurement: &measurement.Measurement{Unit: station.UnitCM},
+	}
+	d.Trend = station.MeasurementTrend{"p1d": {Value: -12.5, Unit: station.UnitCM}}
+	d.RateOfChange = &station.Measurement{Value: 1.5, Unit: "cm/h"}
+
+	d.Localize(localizer, time.Unix(1743415200+15*60, 0))
+	if assert.NotNil(t, d.Display) {
+		assert.Equal(t, "312,0 cm", d.Display.LatestValue)
+		assert.Equal(t, "31.03.2025, 12:00 CEST", d.Display.LatestTime)
+		assert.Equal(t, "vor 15 Minuten", d.Display.LatestAge)
+		assert.Equal(t, map[string]string{"p1d": "fallend um 12,5 cm"}, d.Display.Trend)
+		assert.Equal(t, "steigend um 1,50 cm/h", d.Display.RateOfChange)
+	}
+}
+
+func TestDashboardConvertUnit(t *testing.T) {
+	d := dashboard.NewEmptyDashboard("bonn", "en", "utc")
+	d.WaterLevel = measurement.Timeseries{
+		Samples:     []measurement.Sample{{Timestamp: 1743415200, Value: 312}},
+		Measurement: &measurement.Measurement{Unit: station.UnitCM},
+	}
+	d.Trend = station.MeasurementTrend{"p1d": {Value: -12.5, Unit: station.UnitCM}}
+	d.RateOfChange = &station.Measurement{Value: 1.5, Unit: "cm/h"}
+
+	assert.NoError(t, d.ConvertUnit("m", time.Unix(1743415200+15*60, 0)))
+	assert.Equal(t, 3.12, d.WaterLevel.Samples[0].Value)
+	assert.Equal(t, "m", d.WaterLevel.Measurement.Unit)
+	assert.Equal(t, -0.125, d.Trend["p1d"].Value)
+	assert.Equal(t, station.Measurement{Value: 0.015, Unit: "m/h"}, *d.RateOfChange)
+
+	assert.ErrorIs(t, d.ConvertUnit("C", time.Now()), measurement.ErrIncompatibleUnits)
+}
+
+func TestDashboardRelocalize(t *testing.T) {
+	d := dashboard.NewEmptyDashboard("bonn", "de", "Europe/Berlin")
+	assert.NoError(t, d.Relocalize(time.Now()))
+	assert.Nil(t, d.Display, "dashboards without display fields are left as they are")
+
+	d.WaterLevel = measurement.Timeseries{
+		Samples:     []measurement.Sample{{Timestamp: 1743415200, Value: 312}},
+		Measurement: &measurement.Measurement{Unit: station.UnitCM},
+	}
+	d.Display = &dashboard.Display{LatestAge: "vor 15 Minuten"}
+
+	assert.NoError(t, d.Relocalize(time.Unix(1743415200+3*3600, 0)))
+	assert.Equal(t, "vor 3 Stunden", d.Display.LatestAge)
+	assert.Equal(t, "31.03.2025, 15:00 CEST", d.Display.GeneratedAt)
+
+	assert.NoError(t, d.ConvertUnit("m", time.Unix(1743415200+4*3600, 0)))
+	assert.Equal(t, "vor 4 Stunden", d.Display.LatestAge)
+	assert.Equal(t, "3,1 m", d.Display.LatestValue)
+}
@@ -0,0 +1,152 @@
+package dashboardtest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/timgluz/wasserspiegel/dashboard"
+	"github.com/timgluz/wasserspiegel/measurement"
+)
+
+// RunRepositoryContract checks the pagination, not-found and duplicate
+// semantics shared by all dashboard.Repository implementations;
+// newRepository must return an empty repository.
+func RunRepositoryContract(t *testing.T, newRepository func(t *testing.T) dashboard.Repository) {
+	ctx := context.Background()
+
+	newDashboard := func(stationID string) *dashboard.Dashboard {
+		d := dashboard.NewEmptyDashboard(stationID, "en", "utc")
+		d.Name = "Dashboard for " + stationID
+		return d
+	}
+
+	t.Run("empty repository lists no dashboards", func(t *testing.T) {
+		repo := newRepository(t)
+
+		collection, err := repo.List(ctx, 0, 10)
+		assert.NoError(t, err)
+		assert.NotNil(t, collection)
+		assert.Empty(t, collection.Items)
+		assert.Equal(t, 0, collection.Pagination.Total)
+	})
+
+	t.Run("list pages through dashboards ordered by ID", func(t *testing.T) {
+		repo := newRepository(t)
+		for _, stationID := range []string{"d", "b", "e", "a", "c"} {
+			assert.NoError(t, repo.Add(ctx, newDashboard(stationID)))
+		}
+
+		testCases := []struct {
+			name     string
+			offset   int
+			limit    int
+			expected []string
+		}{
+			{name: "first page", offset: 0, limit: 2, expected: []string{"a-en-utc", "b-en-utc"}},
+			{name: "last partial page", offset: 4, limit: 2, expected: []string{"e-en-utc"}},
+			{name: "offset past the end", offset: 5, limit: 2, expected: []string{}},
+			{name: "default limit", offset: 3, limit: 0, expected: []string{"d-en-utc", "e-en-utc"}},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				collection, err := repo.List(ctx, tc.offset, tc.limit)
+				assert.NoError(t, err)
+				assert.NotNil(t, collection)
+				assert.Equal(t, 5, collection.Pagination.Total)
+				assert.Equal(t, tc.offset, collection.Pagination.Offset)
+
+				ids := make([]string, 0, len(collection.Items))
+				for _, item := range collection.Items {
+					ids = append(ids, item.ID)
+				}
+				assert.Equal(t, tc.expected, ids)
+			})
+		}
+	})
+
+	t.Run("added dashboard gets an ID and timestamps", func(t *testing.T) {
+		repo := newRepository(t)
+		added := newDashboard("koeln")
+		assert.NoError(t, repo.Add(ctx, added))
+		assert.Equal(t, "koeln-en-utc", added.ID)
+		assert.NotZero(t, added.CreatedAt)
+
+		found, err := repo.GetByID(ctx, added.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, added.ID, found.ID)
+		assert.Equal(t, added.Name, found.Name)
+		assert.Equal(t, "koeln", found.Station.ID)
+		assert.Equal(t, added.CreatedAt, found.CreatedAt)
+		assert.Equal(t, added.CreatedAt, found.UpdatedAt)
+	})
+
+	t.Run("missing dashboard is not found", func(t *testing.T) {
+		repo := newRepository(t)
+
+		found, err := repo.GetByID(ctx, "missing")
+		assert.ErrorIs(t, err, dashboard.ErrDashboardNotFound)
+		assert.Nil(t, found)
+
+		missing := newDashboard("missing")
+		missing.ID = "missing"
+		assert.ErrorIs(t, repo.Update(ctx, missing), dashboard.ErrDashboardNotFound)
+	})
+
+	t.Run("duplicate dashboard is rejected", func(t *testing.T) {
+		repo := newRepository(t)
+		assert.NoError(t, repo.Add(ctx, newDashboard("bonn")))
+
+		duplicate := newDashboard("bonn")
+		duplicate.Name = "Other"
+		assert.ErrorIs(t, repo.Add(ctx, duplicate), dashboard.ErrDashboardExists)
+
+		found, err := repo.GetByID(ctx, "bonn-en-utc")
+		assert.NoError(t, err)
+		assert.Equal(t, "Dashboard for bonn", found.Name)
+	})
+
+	t.Run("update replaces the dashboard and keeps its creation time", func(t *testing.T) {
+		repo := newRepository(t)
+		added := newDashboard("mainz")
+		added.Description = "Mainz"
+		added.WaterLevel = measurement.Timeseries{Name: "old", Samples: []measurement.Sample{{Timestamp: 1, Value: 100}}}
+		added.Forecast = &measurement.Forecast{Rate: 1}
+		assert.NoError(t, repo.Add(ctx, added))
+
+		update := newDashboard("mainz")
+		update.ID = added.ID
+		update.Name = "Renamed"
+		update.Stale = true
+		update.WaterLevel = measurement.Timeseries{Name: "new"}
+		assert.NoError(t, repo.Update(ctx, update))
+
+		found, err := repo.GetByID(ctx, added.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "Renamed", found.Name)
+		assert.Equal(t, "", found.Description)
+		assert.Equal(t, "mainz", found.Station.ID)
+		assert.Equal(t, "new", found.WaterLevel.Name)
+		assert.Empty(t, found.WaterLevel.Samples)
+		assert.Nil(t, found.Forecast, "a missing forecast clears the stored one")
+		assert.True(t, found.Stale)
+		assert.Nil(t, found.DataAge)
+		assert.Equal(t, added.CreatedAt, found.CreatedAt)
+		assert.GreaterOrEqual(t, found.UpdatedAt, added.UpdatedAt)
+	})
+
+	t.Run("deleted dashboard is not found", func(t *testing.T) {
+		repo := newRepository(t)
+		added := newDashboard("worms")
+		assert.NoError(t, repo.Add(ctx, added))
+
+		assert.NoError(t, repo.Delete(ctx, added.ID))
+		_, err := repo.GetByID(ctx, added.ID)
+		assert.ErrorIs(t, err, dashboard.ErrDashboardNotFound)
+
+		// deleting is idempotent
+		assert.NoError(t, repo.Delete(ctx, added.ID))
+	})
+}
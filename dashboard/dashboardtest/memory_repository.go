@@ -0,0 +1,143 @@
+// Package dashboardtest provides an in-memory dashboard repository for tests
+// and the contract tests every dashboard.Repository has to pass.
+package dashboardtest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/timgluz/wasserspiegel/dashboard"
+	"github.com/timgluz/wasserspiegel/measurement"
+	"github.com/timgluz/wasserspiegel/response"
+	"github.com/timgluz/wasserspiegel/station"
+)
+
+// MemoryRepository keeps dashboards in memory, listed in the order of their IDs.
+type MemoryRepository struct {
+	mu         sync.RWMutex
+	dashboards map[string]dashboard.Dashboard
+	now        func() int64
+}
+
+var _ dashboard.Repository = (*MemoryRepository)(nil)
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		dashboards: make(map[string]dashboard.Dashboard),
+		now:        measurement.CurrentUnix,
+	}
+}
+
+func (r *MemoryRepository) List(ctx context.Context, offset int, limit int) (*dashboard.Collection, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if limit <= 0 {
+		limit = dashboard.DefaultLimit
+	}
+	if offset < 0 {
+		offset = dashboard.DefaultOffset
+	}
+
+	ids := make([]string, 0, len(r.dashboards))
+	for id := range r.dashboards {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	pagination := response.Pagination{Limit: limit, Offset: offset, Total: len(ids)}
+	if offset >= len(ids) {
+		return dashboard.NewDashboardListCollection(nil, pagination), nil
+	}
+
+	until := offset + limit
+	if until > len(ids) {
+		until = len(ids)
+	}
+
+	dashboards := make([]dashboard.Dashboard, 0, until-offset)
+	for _, id := range ids[offset:until] {
+		dashboards = append(dashboards, r.dashboards[id])
+	}
+
+	return dashboard.NewDashboardListCollection(dashboards, pagination), nil
+}
+
+func (r *MemoryRepository) GetByID(ctx context.Context, id string) (*dashboard.Dashboard, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	d, ok := r.dashboards[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", dashboard.ErrDashboardNotFound, id)
+	}
+
+	d = cloneDashboard(d)
+	return &d, nil
+}
+
+func (r *MemoryRepository) Add(ctx context.Context, d *dashboard.Dashboard) error {
+	if d == nil {
+		return fmt.Errorf("dashboard cannot be nil")
+	}
+
+	if d.ID == "" {
+		id, err := dashboard.GenerateDashboardID(d)
+		if err != nil {
+			return fmt.Errorf("failed to generate dashboard ID: %w", err)
+		}
+		d.ID = id
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.dashboards[d.ID]; ok {
+		return fmt.Errorf("%w: %s", dashboard.ErrDashboardExists, d.ID)
+	}
+
+	d.CreatedAt = r.now()
+	d.UpdatedAt = d.CreatedAt
+	r.dashboards[d.ID] = cloneDashboard(*d)
+	return nil
+}
+
+func (r *MemoryRepository) Update(ctx context.Context, d *dashboard.Dashboard) error {
+	if d == nil {
+		return fmt.Errorf("dashboard cannot be nil")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.dashboards[d.ID]
+	if !ok {
+		return fmt.Errorf("%w: %s", dashboard.ErrDashboardNotFound, d.ID)
+	}
+
+	// the dashboard replaces the existing one, only its creation time is kept
+	d.CreatedAt = existing.CreatedAt
+	d.UpdatedAt = r.now()
+	r.dashboards[d.ID] = cloneDashboard(*d)
+	return nil
+}
+
+func (r *MemoryRepository) Delete(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.dashboards, id)
+	return nil
+}
+
+func (r *MemoryRepository) IsReady() bool { return true }
+func (r *MemoryRepository) Close() error  { return nil }
+
+// cloneDashboard copies the slices, so callers can't change stored dashboards.
+func cloneDashboard(d dashboard.Dashboard) dashboard.Dashboard {
+	d.Station.ExternalIDs = append([]station.ExternalID(nil), d.Station.ExternalIDs...)
+	d.WaterLevel.Samples = append([]measurement.Sample(nil), d.WaterLevel.Samples...)
+	return d
+}
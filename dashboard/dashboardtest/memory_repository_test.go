@@ -0,0 +1,13 @@
+package dashboardtest
+
+import (
+	"testing"
+
+	"github.com/timgluz/wasserspiegel/dashboard"
+)
+
+func TestMemoryRepositoryContract(t *testing.T) {
+	RunRepositoryContract(t, func(t *testing.T) dashboard.Repository {
+		return NewMemoryRepository()
+	})
+}
@@ -0,0 +1,122 @@
+package dashboard
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/timgluz/wasserspiegel/i18n"
+	"github.com/timgluz/wasserspiegel/measurement"
+	"github.com/timgluz/wasserspiegel/station"
+)
+
+// Display holds the fields of a dashboard preformatted in its language and
+// timezone, so displays can show them as they are.
+type Display struct {
+	LatestValue     string            `json:"latest_value,omitempty"` // e.g. 312,0 cm
+	LatestTime      string            `json:"latest_time,omitempty"`
+	LatestAge       string            `json:"latest_age,omitempty"` // relative to GeneratedAt, e.g. vor 15 Minuten
+	Trend           map[string]string `json:"trend,omitempty"`      // keyed like the trend of the dashboard
+	RateOfChange    string            `json:"rate_of_change,omitempty"`
+	HistoricalClass string            `json:"historical_class,omitempty"` // e.g. sehr hoch
+	GeneratedAt     string            `json:"generated_at"`
+}
+
+// Localize sets the display fields from the water levels at now.
+func (d *Dashboard) Localize(localizer *i18n.Localizer, now time.Time) {
+	display := &Display{GeneratedAt: localizer.FormatTime(now)}
+
+	unit := ""
+	if d.WaterLevel.Measurement != nil {
+		unit = d.WaterLevel.Measurement.Unit
+	}
+
+	if samples := d.WaterLevel.Samples; len(samples) > 0 {
+		latest := samples[len(samples)-1]
+		latestTime := time.Unix(int64(latest.Timestamp), 0)
+
+		display.LatestValue = localizer.FormatNumber(latest.Value, 1)
+		if unit != "" {
+			display.LatestValue += " " + unit
+		}
+		display.LatestTime = localizer.FormatTime(latestTime)
+		display.LatestAge = localizer.FormatRelative(latestTime, now)
+	}
+
+	if len(d.Trend) > 0 {
+		display.Trend = make(map[string]string, len(d.Trend))
+		for window, change := range d.Trend {
+			display.Trend[window] = localizer.FormatTrend(change.Value, 1, change.Unit)
+		}
+	}
+
+	if d.RateOfChange != nil {
+		display.RateOfChange = localizer.FormatTrend(d.RateOfChange.Value, 2, d.RateOfChange.Unit)
+	}
+
+	if d.Historical != nil {
+		display.HistoricalClass = localizer.T("class." + string(d.Historical.Class))
+	}
+
+	d.Display = display
+}
+
+// Relocalize formats the display fields of a built dashboard again at now, so
+// the age of the latest water level moves on while the dashboard is served.
+func (d *Dashboard) Relocalize(now time.Time) error {
+	if d.Display == nil {
+		return nil
+	}
+
+	localizer, err := i18n.NewLocalizer(d.LanguageCode, d.Timezone)
+	if err != nil {
+		return err
+	}
+
+	d.Localize(localizer, now)
+	return nil
+}
+
+// ConvertUnit converts the water levels and the values derived from them to the
+// unit, e.g. m, and formats the display fields again at now.
+func (d *Dashboard) ConvertUnit(unit string, now time.Time) error {
+	from := ""
+	if d.WaterLevel.Measurement != nil {
+		from = d.WaterLevel.Measurement.Unit
+	}
+	if from == "" {
+		return fmt.Errorf("%w: the water levels have no unit to convert from", measurement.ErrIncompatibleUnits)
+	}
+
+	converter, err := measurement.NewUnitConverter(from, unit)
+	if err != nil {
+		return err
+	}
+
+	d.WaterLevel.ConvertUnit(converter)
+	if d.Forecast != nil {
+		d.Forecast.ConvertUnit(converter)
+	}
+	if d.Historical != nil {
+		d.Historical.ConvertUnit(converter)
+	}
+	for window, change := range d.Trend {
+		d.Trend[window] = &station.Measurement{
+			Timestamp: change.Timestamp,
+			Value:     converter.Delta(change.Value),
+			Unit:      converter.To(),
+			StartAt:   change.StartAt,
+			EndAt:     change.EndAt,
+		}
+	}
+	if d.RateOfChange != nil {
+		rateConverter, err := measurement.NewUnitConverter(d.RateOfChange.Unit, converter.To()+"/h")
+		if err != nil {
+			return err
+		}
+		rate := *d.RateOfChange
+		rate.Value, rate.Unit = rateConverter.Value(rate.Value), rateConverter.To()
+		d.RateOfChange = &rate
+	}
+
+	return d.Relocalize(now)
+}
@@ -4,4 +4,6 @@ import "fmt"
 
 var (
 	ErrKVStoreNotAvailable = fmt.Errorf("Spin KV store is not available")
+	ErrDashboardNotFound   = fmt.Errorf("dashboard not found")
+	ErrDashboardExists     = fmt.Errorf("dashboard already exists")
 )
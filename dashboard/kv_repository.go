@@ -0,0 +1,297 @@
+package dashboard
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/timgluz/wasserspiegel/health"
+	"github.com/timgluz/wasserspiegel/kvstore"
+	"github.com/timgluz/wasserspiegel/measurement"
+	"github.com/timgluz/wasserspiegel/response"
+)
+
+// KVRepository stores dashboards as JSON in a key-value store, keyed by their ID;
+// dashboards are listed in the order of their IDs.
+type KVRepository struct {
+	db     kvstore.Store
+	logger *slog.Logger
+}
+
+func NewKVRepository(db kvstore.Store, logger *slog.Logger) *KVRepository {
+	return &KVRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// -- Component interface implementation --
+
+func (r *KVRepository) IsReady() bool {
+	if r.logger == nil {
+		fmt.Println("Logger of KVRepository is not initialized")
+		return false
+	}
+
+	if r.db == nil {
+		r.logger.Error("KV store is not initialized")
+		return false
+	}
+
+	return true
+}
+
+// CheckHealth reads the KV store and reports the time of the newest dashboard build.
+func (r *KVRepository) CheckHealth(ctx context.Context) health.Check {
+	check := kvstore.CheckHealth(ctx, "dashboards", r.db)
+	if check.Status != health.StatusUp {
+		return check
+	}
+
+	newest, err := r.newestUpdate()
+	if err != nil {
+		check.Status, check.Error = health.StatusDown, err.Error()
+		return check
+	}
+
+	return check.WithAge("newest_build", newest, time.Now())
+}
+
+// newestUpdate returns the latest UpdatedAt of all dashboards, or zero without dashboards.
+func (r *KVRepository) newestUpdate() (time.Time, error) {
+	keys, err := r.db.GetKeys()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to list dashboards: %w", err)
+	}
+
+	var newest int64
+	for _, key := range keys {
+		var stamps struct {
+			UpdatedAt int64 `json:"updated_at"`
+		}
+		if _, err := kvstore.GetJSON(r.db, key, &stamps); err != nil {
+			r.logger.Warn("Failed to decode dashboard", "key", key, "error", err)
+			continue
+		}
+
+		newest = max(newest, stamps.UpdatedAt)
+	}
+
+	if newest == 0 {
+		return time.Time{}, nil
+	}
+
+	return time.Unix(newest, 0), nil
+}
+
+func (r *KVRepository) Close() error {
+	if r.db == nil {
+		return nil // No action needed if db is not initialized
+	}
+
+	r.db.Close()
+	r.logger.Info("KV store closed successfully")
+	return nil
+}
+
+// -- Repository interface implementation --
+func (r *KVRepository) List(ctx context.Context, offset int, limit int) (*Collection, error) {
+	defer ctx.Done()
+
+	if !r.IsReady() {
+		return nil, ErrKVStoreNotAvailable
+	}
+
+	if limit <= 0 {
+		limit = 100 // Default limit
+	}
+	if offset < 0 {
+		offset = 0 // Default offset
+	}
+
+	keys, err := r.db.GetKeys()
+	if err != nil {
+		r.logger.Error("Failed to retrieve keys from KV store", "error", err)
+		return nil, err
+	}
+	sort.Strings(keys)
+
+	pagination := response.Pagination{
+		Limit:  limit,
+		Offset: offset,
+		Total:  len(keys),
+	}
+
+	if offset >= len(keys) {
+		r.logger.Debug("Offset exceeds total number of dashboards", "offset", offset, "total", len(keys))
+		return NewDashboardListCollection(nil, pagination), nil
+	}
+
+	until := offset + limit
+	if until > len(keys) {
+		until = len(keys)
+	}
+
+	var dashboards []Dashboard
+	for _, key := range keys[offset:until] {
+		dashboard, err := r.GetByID(ctx, key)
+		if err != nil {
+			r.logger.Error("Failed to get dashboard by key", "key", key, "error", err)
+			continue
+		}
+
+		if dashboard == nil {
+			r.logger.Warn("Dashboard not found for key", "key", key)
+			continue
+		}
+
+		dashboards = append(dashboards, *dashboard)
+	}
+
+	r.logger.Debug("Listed dashboards from KV store", "count", len(dashboards), "offset", offset, "limit", limit)
+	collection := NewDashboardListCollection(dashboards, pagination)
+	return collection, nil
+}
+
+func (r *KVRepository) GetByID(ctx context.Context, id string) (*Dashboard, error) {
+	defer ctx.Done()
+
+	if !r.IsReady() {
+		return nil, ErrKVStoreNotAvailable
+	}
+
+	ok, err := r.db.Exists(id)
+	if err != nil {
+		r.logger.Error("Failed to check if dashboard exists", "id", id, "error", err)
+		return nil, err
+	}
+
+	if !ok {
+		r.logger.Warn("Dashboard not found", "id", id)
+		return nil, fmt.Errorf("%w: %s", ErrDashboardNotFound, id)
+	}
+
+	jsonBlob, err := r.db.Get(id)
+	if err != nil {
+		r.logger.Error("Failed to get dashboard by ID", "id", id, "error", err)
+		return nil, err
+	}
+
+	dashboard := &Dashboard{}
+	if err := json.Unmarshal(jsonBlob, dashboard); err != nil {
+		r.logger.Error("Failed to unmarshal dashboard JSON", "id", id, "error", err)
+		return nil, fmt.Errorf("failed to unmarshal dashboard with ID %s: %w", id, err)
+	}
+
+	r.logger.Debug("Retrieved dashboard by ID", "id", id)
+	return dashboard, nil
+}
+
+func (r *KVRepository) Add(ctx context.Context, dashboard *Dashboard) error {
+	defer ctx.Done()
+
+	if !r.IsReady() {
+		return ErrKVStoreNotAvailable
+	}
+
+	if dashboard == nil {
+		return fmt.Errorf("dashboard cannot be nil")
+	}
+
+	if dashboard.ID == "" {
+		r.logger.Debug("Dashboard ID is empty, generating a new ID")
+		if id, err := GenerateDashboardID(dashboard); err != nil {
+			r.logger.Error("Failed to generate dashboard ID", "error", err)
+			return fmt.Errorf("failed to generate dashboard ID: %w", err)
+		} else {
+			dashboard.ID = id
+		}
+	}
+
+	if ok, err := r.db.Exists(dashboard.ID); err != nil {
+		r.logger.Error("Failed to check if dashboard exists", "id", dashboard.ID, "error", err)
+		return err
+	} else if ok {
+		return fmt.Errorf("%w: %s", ErrDashboardExists, dashboard.ID)
+	}
+
+	dashboard.CreatedAt = measurement.CurrentUnix()
+	dashboard.UpdatedAt = dashboard.CreatedAt
+
+	jsonBlob, err := json.Marshal(dashboard)
+	if err != nil {
+		r.logger.Error("Failed to marshal dashboard", "error", err)
+		return fmt.Errorf("failed to marshal dashboard: %w", err)
+	}
+
+	if err := r.db.Set(dashboard.ID, jsonBlob); err != nil {
+		r.logger.Error("Failed to add dashboard to KV store", "id", dashboard.ID, "error", err)
+		return fmt.Errorf("failed to add dashboard with ID %s: %w", dashboard.ID, err)
+	}
+
+	r.logger.Debug("Added dashboard to KV store", "id", dashboard.ID)
+	return nil
+}
+
+func (r *KVRepository) Update(ctx context.Context, dashboard *Dashboard) error {
+	defer ctx.Done()
+
+	if !r.IsReady() {
+		return ErrKVStoreNotAvailable
+	}
+
+	if dashboard == nil {
+		return fmt.Errorf("dashboard cannot be nil")
+	}
+
+	existingDashboard, err := r.GetByID(ctx, dashboard.ID)
+	if err != nil {
+		r.logger.Error("Failed to get existing dashboard for update", "id", dashboard.ID, "error", err)
+		return fmt.Errorf("failed to get existing dashboard with ID %s: %w", dashboard.ID, err)
+	}
+
+	if existingDashboard != nil && existingDashboard.ID == dashboard.ID {
+		r.logger.Debug("Dashboard already exists, updating it", "id", dashboard.ID)
+		// the dashboard replaces the existing one, only its creation time is kept
+		dashboard.CreatedAt = existingDashboard.CreatedAt
+	}
+
+	dashboard.UpdatedAt = measurement.CurrentUnix()
+
+	jsonBlob, err := json.Marshal(dashboard)
+	if err != nil {
+		r.logger.Error("Failed to marshal dashboard", "error", err)
+		return fmt.Errorf("failed to marshal dashboard: %w", err)
+	}
+
+	if err := r.db.Set(dashboard.ID, jsonBlob); err != nil {
+		r.logger.Error("Failed to update dashboard in KV store", "id", dashboard.ID, "error", err)
+		return fmt.Errorf("failed to update dashboard with ID %s: %w", dashboard.ID, err)
+	}
+
+	r.logger.Debug("Updated dashboard in KV store", "id", dashboard.ID)
+	return nil
+}
+
+func (r *KVRepository) Delete(ctx context.Context, id string) error {
+	defer ctx.Done()
+	if id == "" {
+		r.logger.Warn("Cannot delete dashboard: ID is empty")
+		return nil
+	}
+
+	if !r.IsReady() {
+		return ErrKVStoreNotAvailable
+	}
+
+	if err := r.db.Delete(id); err != nil {
+		r.logger.Error("Failed to delete dashboard from KV store", "id", id, "error", err)
+		return fmt.Errorf("failed to delete dashboard with ID %s: %w", id, err)
+	}
+
+	r.logger.Debug("Deleted dashboard from KV store", "id", id)
+	return nil
+}
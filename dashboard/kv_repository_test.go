@@ -0,0 +1,16 @@
+package dashboard_test
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/timgluz/wasserspiegel/dashboard"
+	"github.com/timgluz/wasserspiegel/dashboard/dashboardtest"
+	"github.com/timgluz/wasserspiegel/kvstore"
+)
+
+func TestKVRepositoryContract(t *testing.T) {
+	dashboardtest.RunRepositoryContract(t, func(t *testing.T) dashboard.Repository {
+		return dashboard.NewKVRepository(kvstore.NewMemoryStore(), slog.Default())
+	})
+}
@@ -12,6 +12,8 @@ type Repository interface {
 
 	GetByID(ctx context.Context, id string) (*Dashboard, error)
 	Add(ctx context.Context, dashboard *Dashboard) error
+	// Update replaces the stored dashboard and keeps its CreatedAt; callers
+	// merge the fields they want to keep, see Dashboard.Merge.
 	Update(ctx context.Context, dashboard *Dashboard) error
 	Delete(ctx context.Context, id string) error
 
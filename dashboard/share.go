@@ -0,0 +1,27 @@
+package dashboard
+
+import (
+	"net/url"
+
+	"github.com/timgluz/wasserspiegel/secret"
+)
+
+// ShareLink is returned once when a dashboard is shared; the token is not stored.
+type ShareLink struct {
+	secret.ShareGrant
+	Token string `json:"token"`
+	URL   string `json:"url"` // relative URL of the shared dashboard
+}
+
+func NewShareLink(dashboardID string, grant *secret.ShareGrant, token string) ShareLink {
+	return ShareLink{
+		ShareGrant: *grant,
+		Token:      token,
+		URL:        "/dashboards/" + url.PathEscape(dashboardID) + "?" + secret.ShareTokenParam + "=" + url.QueryEscape(token),
+	}
+}
+
+// ShareResource is the resource name of the dashboard in share grants.
+func ShareResource(dashboardID string) string {
+	return "dashboard:" + dashboardID
+}
@@ -0,0 +1,43 @@
+package health
+
+import (
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/timgluz/wasserspiegel/response"
+)
+
+// HealthPath is the route of the health report below the prefix of a component, e.g. `/stations/health`.
+const HealthPath = "/health"
+
+// NewHandler renders the report of the dependencies. It requires no API key,
+// so uptime monitors can poll it, and is never cached.
+func NewHandler(component string, dependencies ...Dependency) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		report := Run(r.Context(), component, dependencies...)
+
+		w.Header().Set("Cache-Control", "no-store")
+		response.RenderJSONWithStatus(w, report, report.HTTPStatus())
+	}
+}
+
+// ServeUnready answers a request of the health path of a component that
+// isn't ready, so the report shows the failing dependency instead of a generic
+// error. It returns false for other paths.
+func ServeUnready(w http.ResponseWriter, r *http.Request, prefix string, component string, dependencies ...Dependency) bool {
+	if r.Method != http.MethodGet || r.URL.Path != prefix+HealthPath {
+		return false
+	}
+
+	NewHandler(component, dependencies...)(w, r, nil)
+	return true
+}
+
+// HTTPStatus is 503 if the component is down, monitors treat any other status as up.
+func (r *Report) HTTPStatus() int {
+	if r.Status == StatusDown {
+		return http.StatusServiceUnavailable
+	}
+
+	return http.StatusOK
+}
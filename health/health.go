@@ -0,0 +1,197 @@
+// Package health reports the status of the dependencies of a component in a
+// format for uptime monitors: the overall status is also the HTTP status,
+// 200 while the component can serve requests and 503 if it can't.
+package health
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusUp       Status = "up"
+	StatusDegraded Status = "degraded" // the component serves requests, e.g. from cached data
+	StatusDown     Status = "down"
+)
+
+// DefaultTimeout limits the checks of a single report, upstream probes included.
+const DefaultTimeout = 5 * time.Second
+
+// Check is the status of a single dependency.
+type Check struct {
+	Name      string         `json:"name"`
+	Status    Status         `json:"status"`
+	Optional  bool           `json:"optional,omitempty"` // a failing optional dependency only degrades the component
+	LatencyMS float64        `json:"latency_ms,omitempty"`
+	Error     string         `json:"error,omitempty"`
+	Details   map[string]any `json:"details,omitempty"`
+}
+
+func Up(name string) Check {
+	return Check{Name: name, Status: StatusUp}
+}
+
+func Down(name string, err error) Check {
+	check := Check{Name: name, Status: StatusDown}
+	if err != nil {
+		check.Error = err.Error()
+	}
+
+	return check
+}
+
+func Degraded(name string, reason string) Check {
+	return Check{Name: name, Status: StatusDegraded, Error: reason}
+}
+
+// WithDetail adds a value to the details of the check, e.g. the schema version.
+func (c Check) WithDetail(key string, value any) Check {
+	if c.Details == nil {
+		c.Details = make(map[string]any)
+	}
+
+	c.Details[key] = value
+	return c
+}
+
+// WithLatency sets the duration of the probe, e.g. of an upstream request.
+func (c Check) WithLatency(latency time.Duration) Check {
+	c.LatencyMS = float64(latency.Microseconds()) / 1000
+	return c
+}
+
+// WithAge adds the time and the age in seconds of the newest item, e.g. `newest_sample`.
+// A zero time means there is no item yet.
+func (c Check) WithAge(key string, newest time.Time, now time.Time) Check {
+	if newest.IsZero() {
+		return c.WithDetail(key+"_at", nil)
+	}
+
+	return c.WithDetail(key+"_at", newest.UTC().Format(time.RFC3339)).
+		WithDetail(key+"_age_s", int64(now.Sub(newest).Seconds()))
+}
+
+// Checker is implemented by dependencies that probe themselves, e.g. by reading their store.
+type Checker interface {
+	CheckHealth(ctx context.Context) Check
+}
+
+// CheckerFunc adapts a function to a Checker, e.g. to add details to the check of another dependency.
+type CheckerFunc func(ctx context.Context) Check
+
+func (f CheckerFunc) CheckHealth(ctx context.Context) Check {
+	return f(ctx)
+}
+
+// Readier is the fallback for dependencies without a probe.
+type Readier interface {
+	IsReady() bool
+}
+
+// Dependency names a dependency of a component, it must be a Checker or a Readier.
+type Dependency struct {
+	Name     string
+	Target   any
+	Optional bool
+}
+
+// Required is a dependency the component can't serve requests without.
+func Required(name string, target any) Dependency {
+	return Dependency{Name: name, Target: target}
+}
+
+// Optional is a dependency whose failure degrades the component, e.g. an upstream API served from a cache.
+func Optional(name string, target any) Dependency {
+	return Dependency{Name: name, Target: target, Optional: true}
+}
+
+// CheckDependency probes the dependency; interfaces holding a nil pointer count as missing.
+func CheckDependency(ctx context.Context, dependency Dependency) Check {
+	var check Check
+	switch target := dependency.Target.(type) {
+	case nil:
+		check = Down(dependency.Name, fmt.Errorf("not initialized"))
+	case Checker, Readier:
+		if value := reflect.ValueOf(target); value.Kind() == reflect.Pointer && value.IsNil() {
+			check = Down(dependency.Name, fmt.Errorf("not initialized"))
+			break
+		}
+
+		check = checkTarget(ctx, dependency.Name, target)
+	default:
+		check = Down(dependency.Name, fmt.Errorf("unsupported dependency %T", target))
+	}
+
+	check.Name = dependency.Name
+	check.Optional = dependency.Optional
+	return check
+}
+
+func checkTarget(ctx context.Context, name string, target any) Check {
+	var check Check
+	switch target := target.(type) {
+	case Checker:
+		check = target.CheckHealth(ctx)
+	case Readier:
+		check = Up(name)
+		if !target.IsReady() {
+			check = Down(name, fmt.Errorf("not ready"))
+		}
+	}
+
+	return check
+}
+
+// Report is the health of a component.
+type Report struct {
+	Component  string    `json:"component"`
+	Status     Status    `json:"status"`
+	CheckedAt  time.Time `json:"checked_at"`
+	DurationMS float64   `json:"duration_ms"`
+	Checks     []Check   `json:"checks"`
+}
+
+// NewReport summarizes the checks: the component is down if a required
+// dependency is down and degraded if any other check fails.
+func NewReport(component string, checks []Check) *Report {
+	status := StatusUp
+	for _, check := range checks {
+		switch {
+		case check.Status == StatusDown && !check.Optional:
+			status = StatusDown
+		case check.Status != StatusUp && status == StatusUp:
+			status = StatusDegraded
+		}
+	}
+
+	if checks == nil {
+		checks = []Check{}
+	}
+
+	return &Report{
+		Component: component,
+		Status:    status,
+		CheckedAt: time.Now().UTC(),
+		Checks:    checks,
+	}
+}
+
+// Run checks the dependencies one after another, within DefaultTimeout.
+func Run(ctx context.Context, component string, dependencies ...Dependency) *Report {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, DefaultTimeout)
+	defer cancel()
+
+	checks := make([]Check, 0, len(dependencies))
+	for _, dependency := range dependencies {
+		checks = append(checks, CheckDependency(ctx, dependency))
+	}
+
+	report := NewReport(component, checks)
+	report.DurationMS = float64(time.Since(start).Microseconds()) / 1000
+	return report
+}
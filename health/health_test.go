@@ -0,0 +1,66 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type readier bool
+
+func (r readier) IsReady() bool { return bool(r) }
+
+type pointerReadier struct{}
+
+func (r *pointerReadier) IsReady() bool { return true }
+
+func TestRun(t *testing.T) {
+	var missing *pointerReadier
+
+	report := Run(context.Background(), "stations",
+		Required("stations", readier(true)),
+		Required("secrets", missing),
+		Optional("provider:pegelonline", CheckerFunc(func(ctx context.Context) Check {
+			return Down("pegelonline", fmt.Errorf("connection refused"))
+		})),
+	)
+
+	assert.Equal(t, "stations", report.Component)
+	assert.Equal(t, StatusDown, report.Status)
+	if assert.Len(t, report.Checks, 3) {
+		assert.Equal(t, StatusUp, report.Checks[0].Status)
+		assert.Equal(t, "not initialized", report.Checks[1].Error, "a nil pointer is not a dependency")
+		assert.Equal(t, "provider:pegelonline", report.Checks[2].Name, "the dependency names the check")
+		assert.True(t, report.Checks[2].Optional)
+	}
+}
+
+func TestNewReport(t *testing.T) {
+	assert.Equal(t, StatusUp, NewReport("search", nil).Status)
+	assert.Equal(t, StatusDegraded, NewReport("search", []Check{Up("stations"), {Name: "provider", Status: StatusDown, Optional: true}}).Status)
+	assert.Equal(t, StatusDegraded, NewReport("search", []Check{Degraded("measurements", "schema is outdated")}).Status)
+	assert.Equal(t, StatusDown, NewReport("search", []Check{Degraded("measurements", ""), Down("stations", nil)}).Status)
+}
+
+func TestNewHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	NewHandler("tasks", Required("measurements", readier(false)))(rec, httptest.NewRequest(http.MethodGet, "/tasks/health", nil), nil)
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+
+	var report Report
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	assert.Equal(t, StatusDown, report.Status)
+	assert.Equal(t, "not ready", report.Checks[0].Error)
+
+	rec = httptest.NewRecorder()
+	assert.False(t, ServeUnready(rec, httptest.NewRequest(http.MethodGet, "/tasks/buildDashboard", nil), "/tasks", "tasks"))
+	assert.True(t, ServeUnready(rec, httptest.NewRequest(http.MethodGet, "/tasks/health", nil), "/tasks", "tasks"))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
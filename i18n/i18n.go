@@ -0,0 +1,203 @@
+// Package i18n formats the display fields of dashboards with the message
+// catalogues of locales/ in a language and time zone.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DefaultLanguageCode = "en"
+
+var (
+	ErrUnsupportedLanguage = fmt.Errorf("unsupported language")
+	ErrUnknownTimezone     = fmt.Errorf("unknown timezone")
+)
+
+// catalogues of the messages by key, <language code>.json
+//
+//go:embed locales/*.json
+var locales embed.FS
+
+var catalogues = mustLoadCatalogues()
+
+func mustLoadCatalogues() map[string]map[string]string {
+	entries, err := locales.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+
+	loaded := make(map[string]map[string]string, len(entries))
+	for _, entry := range entries {
+		content, err := locales.ReadFile("locales/" + entry.Name())
+		if err != nil {
+			panic(err)
+		}
+
+		var catalogue map[string]string
+		if err := json.Unmarshal(content, &catalogue); err != nil {
+			panic(fmt.Errorf("invalid message catalogue %s: %w", entry.Name(), err))
+		}
+		loaded[strings.TrimSuffix(entry.Name(), ".json")] = catalogue
+	}
+
+	return loaded
+}
+
+// SupportedLanguages returns the sorted codes of the languages with a message catalogue.
+func SupportedLanguages() []string {
+	codes := make([]string, 0, len(catalogues))
+	for code := range catalogues {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	return codes
+}
+
+// IsSupportedLanguage reports whether there is a catalogue for the language code, e.g. de or de-DE.
+func IsSupportedLanguage(languageCode string) bool {
+	_, ok := catalogues[baseLanguage(languageCode)]
+	return ok
+}
+
+// LoadLocation loads an IANA time zone; utc is accepted in any case, Local is
+// rejected as it would be the zone of the server.
+func LoadLocation(timezone string) (*time.Location, error) {
+	if strings.EqualFold(timezone, "utc") {
+		return time.UTC, nil
+	}
+
+	location, err := time.LoadLocation(timezone)
+	if err != nil || timezone == "" || timezone == "Local" {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownTimezone, timezone)
+	}
+
+	return location, nil
+}
+
+// Localizer translates messages and formats numbers and times for a language and time zone.
+type Localizer struct {
+	languageCode string
+	location     *time.Location
+	catalogue    map[string]string
+}
+
+func NewLocalizer(languageCode, timezone string) (*Localizer, error) {
+	catalogue, ok := catalogues[baseLanguage(languageCode)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q, supported are %s", ErrUnsupportedLanguage, languageCode, strings.Join(SupportedLanguages(), ", "))
+	}
+
+	location, err := LoadLocation(timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Localizer{languageCode: baseLanguage(languageCode), location: location, catalogue: catalogue}, nil
+}
+
+func (l *Localizer) LanguageCode() string {
+	return l.languageCode
+}
+
+func (l *Localizer) Location() *time.Location {
+	return l.location
+}
+
+// T formats the message of the key with the args; missing messages fall back
+// to English and then to the key itself.
+func (l *Localizer) T(key string, args ...any) string {
+	message, ok := l.catalogue[key]
+	if !ok {
+		message, ok = catalogues[DefaultLanguageCode][key]
+	}
+	if !ok {
+		return key
+	}
+
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+// FormatNumber formats the value with the decimals and the separators of the language, e.g. 1.234,5 in German.
+func (l *Localizer) FormatNumber(value float64, decimals int) string {
+	formatted := strconv.FormatFloat(math.Abs(value), 'f', decimals, 64)
+	integer, fraction, hasFraction := strings.Cut(formatted, ".")
+
+	var grouped strings.Builder
+	if value < 0 && strings.Trim(formatted, "0.") != "" {
+		grouped.WriteString("-")
+	}
+	for i, digit := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			grouped.WriteString(l.T("number.group"))
+		}
+		grouped.WriteRune(digit)
+	}
+	if hasFraction {
+		grouped.WriteString(l.T("number.decimal"))
+		grouped.WriteString(fraction)
+	}
+
+	return grouped.String()
+}
+
+// FormatTime formats the time in the time zone with the layout of the language.
+func (l *Localizer) FormatTime(t time.Time) string {
+	return t.In(l.location).Format(l.T("time.layout"))
+}
+
+// FormatRelative describes how long before now the time was, e.g. "vor 15 Minuten";
+// times after now are "just now".
+func (l *Localizer) FormatRelative(t, now time.Time) string {
+	age := now.Sub(t)
+	switch {
+	case age < time.Minute:
+		return l.T("relative.now")
+	case age < time.Hour:
+		return l.plural("relative.minutes", int(age/time.Minute))
+	case age < 24*time.Hour:
+		return l.plural("relative.hours", int(age/time.Hour))
+	default:
+		return l.plural("relative.days", int(age/(24*time.Hour)))
+	}
+}
+
+// FormatTrend words the change, e.g. "rising by 2.0 cm"; changes that round to zero are steady.
+func (l *Localizer) FormatTrend(change float64, decimals int, unit string) string {
+	amount := l.FormatNumber(math.Abs(change), decimals)
+	if strings.Trim(amount, "0"+l.T("number.decimal")+l.T("number.group")) == "" {
+		return l.T("trend.steady")
+	}
+
+	if unit != "" {
+		amount += " " + unit
+	}
+	if change > 0 {
+		return l.T("trend.rising", amount)
+	}
+	return l.T("trend.falling", amount)
+}
+
+func (l *Localizer) plural(key string, n int) string {
+	if n == 1 {
+		return l.T(key+".one", n)
+	}
+	return l.T(key+".other", n)
+}
+
+// baseLanguage returns the language of a tag, e.g. de for de-DE.
+func baseLanguage(languageCode string) string {
+	base, _, _ := strings.Cut(strings.ToLower(languageCode), "-")
+	base, _, _ = strings.Cut(base, "_")
+	return base
+}
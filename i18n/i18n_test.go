@@ -0,0 +1,50 @@
+package i18n
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCataloguesHaveTheSameMessages(t *testing.T) {
+	assert.Equal(t, []string{"de", "en"}, SupportedLanguages())
+
+	for code, catalogue := range catalogues {
+		for key := range catalogues[DefaultLanguageCode] {
+			assert.Contains(t, catalogue, key, "catalogue %s misses a message", code)
+		}
+		assert.Len(t, catalogue, len(catalogues[DefaultLanguageCode]), "catalogue %s has extra messages", code)
+	}
+}
+
+func TestLocalizer(t *testing.T) {
+	de, err := NewLocalizer("de-DE", "Europe/Berlin")
+	assert.NoError(t, err)
+	en, err := NewLocalizer("en", "UTC")
+	assert.NoError(t, err)
+
+	assert.Equal(t, "1.234,5", de.FormatNumber(1234.54, 1))
+	assert.Equal(t, "-1,234,567.00", en.FormatNumber(-1234567, 2))
+	assert.Equal(t, "0.0", en.FormatNumber(-0.01, 1), "no minus for values rounding to zero")
+
+	measuredAt := time.Date(2025, 3, 31, 10, 0, 0, 0, time.UTC)
+	assert.Equal(t, "31.03.2025, 12:00 CEST", de.FormatTime(measuredAt))
+	assert.Equal(t, "Mar 31, 2025, 10:00 AM UTC", en.FormatTime(measuredAt))
+
+	assert.Equal(t, "vor 15 Minuten", de.FormatRelative(measuredAt, measuredAt.Add(15*time.Minute+20*time.Second)))
+	assert.Equal(t, "1 hour ago", en.FormatRelative(measuredAt, measuredAt.Add(90*time.Minute)))
+	assert.Equal(t, "just now", en.FormatRelative(measuredAt.Add(time.Minute), measuredAt))
+
+	assert.Equal(t, "steigend um 2,0 cm", de.FormatTrend(2, 1, "cm"))
+	assert.Equal(t, "falling by 0.25 cm/h", en.FormatTrend(-0.25, 2, "cm/h"))
+	assert.Equal(t, "gleichbleibend", de.FormatTrend(0.04, 1, "cm"))
+
+	_, err = NewLocalizer("fr", "UTC")
+	assert.ErrorIs(t, err, ErrUnsupportedLanguage)
+	_, err = NewLocalizer("en", "Local")
+	assert.ErrorIs(t, err, ErrUnknownTimezone)
+	_, err = NewLocalizer("en", "Mars/Base")
+	assert.ErrorIs(t, err, ErrUnknownTimezone)
+}
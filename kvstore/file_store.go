@@ -0,0 +1,254 @@
+package kvstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FileStore keeps all values in memory and writes them to a JSON file after
+// every change; it replaces a Spin KV store when running as a native server.
+// The file is owned by a single process, so a store must not be opened twice.
+// Stores that can be rebuilt, e.g. rate limits, write their changes in batches
+// with FlushEvery instead.
+type FileStore struct {
+	mu     sync.RWMutex
+	path   string
+	values map[string][]byte
+
+	// dirty marks changes the flusher of FlushEvery hasn't written yet
+	dirty   bool
+	stop    chan struct{}
+	done    chan struct{}
+	version int // of the values, increased by every change
+
+	writeMu sync.Mutex // guards written
+	written int        // version of the file, older snapshots are not written
+}
+
+// OpenFileStore loads the store with the given name from <dir>/<name>.json; a missing file is an empty store.
+func OpenFileStore(dir string, name string) (*FileStore, error) {
+	if name == "" {
+		return nil, fmt.Errorf("store name is empty")
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory %s: %w", dir, err)
+	}
+
+	store := &FileStore{
+		path:   filepath.Join(dir, name+".json"),
+		values: make(map[string][]byte),
+	}
+
+	blob, err := os.ReadFile(store.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read store %s: %w", store.path, err)
+	}
+
+	if len(blob) > 0 {
+		if err := json.Unmarshal(blob, &store.values); err != nil {
+			return nil, fmt.Errorf("failed to decode store %s: %w", store.path, err)
+		}
+	}
+
+	return store, nil
+}
+
+func (s *FileStore) Get(key string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	value, ok := s.values[key]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+
+	return append([]byte(nil), value...), nil
+}
+
+func (s *FileStore) Set(key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, existed := s.values[key]
+	s.values[key] = append([]byte(nil), value...)
+	s.version++
+	if s.stop != nil {
+		s.dirty = true
+		return nil
+	}
+	if err := s.flush(); err != nil {
+		if existed {
+			s.values[key] = previous
+		} else {
+			delete(s.values, key)
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s *FileStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, existed := s.values[key]
+	if !existed {
+		return nil
+	}
+
+	delete(s.values, key)
+	s.version++
+	if s.stop != nil {
+		s.dirty = true
+		return nil
+	}
+	if err := s.flush(); err != nil {
+		s.values[key] = previous
+		return err
+	}
+
+	return nil
+}
+
+func (s *FileStore) Exists(key string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.values[key]
+	return ok, nil
+}
+
+func (s *FileStore) GetKeys() ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]string, 0, len(s.values))
+	for key := range s.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+// FlushEvery writes the changes in batches every interval instead of after
+// every change, so a crash loses the changes of the last interval at most;
+// Close writes the remaining changes.
+func (s *FileStore) FlushEvery(interval time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop != nil {
+		return
+	}
+
+	s.stop, s.done = make(chan struct{}), make(chan struct{})
+	go func(stop, done chan struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				_ = s.flushDirty() // the changes stay dirty and are retried
+			case <-stop:
+				return
+			}
+		}
+	}(s.stop, s.done)
+}
+
+// Close stops the flusher of FlushEvery and writes the remaining changes,
+// later changes are written right away again.
+func (s *FileStore) Close() {
+	s.mu.Lock()
+	stop, done := s.stop, s.done
+	s.stop, s.done = nil, nil
+	s.mu.Unlock()
+	if stop == nil {
+		return
+	}
+
+	close(stop)
+	<-done
+	_ = s.flushDirty()
+}
+
+// flushDirty writes the changes not written yet without blocking reads and writes meanwhile.
+func (s *FileStore) flushDirty() error {
+	s.mu.Lock()
+	if !s.dirty {
+		s.mu.Unlock()
+		return nil
+	}
+	blob, err := json.Marshal(s.values)
+	version := s.version
+	s.dirty = false
+	s.mu.Unlock()
+
+	if err == nil {
+		err = s.writeFile(blob, version)
+	}
+	if err != nil {
+		s.mu.Lock()
+		s.dirty = true
+		s.mu.Unlock()
+	}
+
+	return err
+}
+
+// flush writes the values while the caller holds the lock.
+func (s *FileStore) flush() error {
+	blob, err := json.Marshal(s.values)
+	if err != nil {
+		return fmt.Errorf("failed to encode store %s: %w", s.path, err)
+	}
+
+	return s.writeFile(blob, s.version)
+}
+
+// writeFile replaces the file atomically, so a crash never leaves a partially written store.
+func (s *FileStore) writeFile(blob []byte, version int) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if version <= s.written {
+		return nil // a newer snapshot is already written
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file for %s: %w", s.path, err)
+	}
+	defer os.Remove(tmp.Name()) // no-op after a successful rename
+
+	if _, err := tmp.Write(blob); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write store %s: %w", s.path, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync store %s: %w", s.path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close store %s: %w", s.path, err)
+	}
+
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to replace store %s: %w", s.path, err)
+	}
+	s.written = version
+
+	return nil
+}
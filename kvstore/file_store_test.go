@@ -0,0 +1,100 @@
+package kvstore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileStorePersistsChanges(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := OpenFileStore(dir, "stations")
+	assert.NoError(t, err)
+
+	assert.NoError(t, store.Set("b", []byte("2")))
+	assert.NoError(t, store.Set("a", []byte("1")))
+	assert.NoError(t, store.Delete("b"))
+	assert.NoError(t, SetJSON(store, "c", map[string]int{"value": 3}))
+
+	reopened, err := OpenFileStore(dir, "stations")
+	assert.NoError(t, err)
+
+	keys, err := reopened.GetKeys()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "c"}, keys)
+
+	value, err := reopened.Get("a")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("1"), value)
+
+	_, err = reopened.Get("b")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+
+	var decoded map[string]int
+	ok, err := GetJSON(reopened, "c", &decoded)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 3, decoded["value"])
+}
+
+func TestFileStoreStartsEmpty(t *testing.T) {
+	store, err := OpenFileStore(t.TempDir(), "secrets")
+	assert.NoError(t, err)
+
+	keys, err := store.GetKeys()
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+
+	ok, err := store.Exists("missing")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestFileStoreFlushesBatches(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := OpenFileStore(dir, "ratelimits")
+	assert.NoError(t, err)
+	store.FlushEvery(time.Hour)
+
+	assert.NoError(t, store.Set("a", []byte("1")))
+	value, err := store.Get("a")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("1"), value)
+
+	unflushed, err := OpenFileStore(dir, "ratelimits")
+	assert.NoError(t, err)
+	ok, err := unflushed.Exists("a")
+	assert.NoError(t, err)
+	assert.False(t, ok, "the change waits for the next flush")
+
+	store.Close()
+	assert.NoError(t, store.Set("b", []byte("2")), "changes after closing are written right away")
+
+	reopened, err := OpenFileStore(dir, "ratelimits")
+	assert.NoError(t, err)
+	keys, err := reopened.GetKeys()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, keys)
+}
+
+func TestFileStoreFlushesEveryInterval(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := OpenFileStore(dir, "providercache")
+	assert.NoError(t, err)
+	store.FlushEvery(10 * time.Millisecond)
+	defer store.Close()
+
+	assert.NoError(t, store.Set("a", []byte("1")))
+	assert.Eventually(t, func() bool {
+		reopened, err := OpenFileStore(dir, "providercache")
+		if err != nil {
+			return false
+		}
+		ok, _ := reopened.Exists("a")
+		return ok
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,30 @@
+package kvstore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/timgluz/wasserspiegel/health"
+)
+
+// healthProbeKey is looked up by CheckHealth; it is never written.
+const healthProbeKey = "__health_probe"
+
+// CheckHealth reads from the store, so a store that opened but can't be read is reported as down.
+func CheckHealth(ctx context.Context, name string, store Store) health.Check {
+	if store == nil {
+		return health.Down(name, fmt.Errorf("KV store is not initialized"))
+	}
+
+	if err := ctx.Err(); err != nil {
+		return health.Down(name, err)
+	}
+
+	start := time.Now()
+	if _, err := store.Exists(healthProbeKey); err != nil {
+		return health.Down(name, fmt.Errorf("failed to read KV store: %w", err)).WithLatency(time.Since(start))
+	}
+
+	return health.Up(name).WithLatency(time.Since(start))
+}
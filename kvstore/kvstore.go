@@ -0,0 +1,67 @@
+package kvstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+var ErrKeyNotFound = fmt.Errorf("key not found")
+
+// Store is the key-value API shared by the Spin KV store and its native replacements.
+type Store interface {
+	Get(key string) ([]byte, error)
+	Set(key string, value []byte) error
+	Delete(key string) error
+	Exists(key string) (bool, error)
+	GetKeys() ([]string, error)
+	Close()
+}
+
+// GetJSON decodes the value of the key into v; it returns false if the key does not exist.
+func GetJSON(store Store, key string, v any) (bool, error) {
+	ok, err := store.Exists(key)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		return false, nil
+	}
+
+	blob, err := store.Get(key)
+	if err != nil {
+		return false, err
+	}
+
+	if err := json.Unmarshal(blob, v); err != nil {
+		return false, fmt.Errorf("failed to unmarshal value of %s: %w", key, err)
+	}
+
+	return true, nil
+}
+
+func SetJSON(store Store, key string, v any) error {
+	blob, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal value of %s: %w", key, err)
+	}
+
+	return store.Set(key, blob)
+}
+
+// KeysWithPrefix returns all keys of the store starting with the prefix.
+func KeysWithPrefix(store Store, prefix string) ([]string, error) {
+	keys, err := store.GetKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	matching := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if strings.HasPrefix(key, prefix) {
+			matching = append(matching, key)
+		}
+	}
+
+	return matching, nil
+}
@@ -0,0 +1,12 @@
+//go:build tinygo || wasm
+
+package kvstore
+
+import (
+	"github.com/spinframework/spin-go-sdk/v2/kv"
+)
+
+// OpenSpinStore opens the Spin KV store with the given name.
+func OpenSpinStore(name string) (Store, error) {
+	return kv.OpenStore(name)
+}
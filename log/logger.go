@@ -0,0 +1,46 @@
+package log
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+type contextKey struct{}
+
+// NewLogger returns a logger writing to stderr with the given level and format.
+func NewLogger(level string, format string) *slog.Logger {
+	return slog.New(NewHandler(os.Stderr, format, &slog.HandlerOptions{
+		Level: SlogLevelInfoFromString(level),
+	}))
+}
+
+// NewHandler returns a JSON handler for the format "json" and a text handler otherwise.
+func NewHandler(w io.Writer, format string, opts *slog.HandlerOptions) slog.Handler {
+	if strings.EqualFold(strings.TrimSpace(format), FormatJSON) {
+		return slog.NewJSONHandler(w, opts)
+	}
+
+	return slog.NewTextHandler(w, opts)
+}
+
+// WithLogger returns a copy of the context carrying the request-scoped logger.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger of the request or the fallback, if the context has none.
+func FromContext(ctx context.Context, fallback *slog.Logger) *slog.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok && logger != nil {
+		return logger
+	}
+
+	return fallback
+}
@@ -5,11 +5,19 @@ import (
 	"net/http"
 
 	spinhttp "github.com/spinframework/spin-go-sdk/v2/http"
+
+	"github.com/timgluz/wasserspiegel/openapi"
 )
 
 func init() {
 	spinhttp.Handle(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == openapi.DocumentPath {
+			openapi.Handler(openapi.NewWasserspiegelDocument())(w, r)
+			return
+		}
+
 		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Link", openapi.ServiceDescLink(""))
 		fmt.Fprintln(w, "Hello World!")
 	})
 }
@@ -0,0 +1,203 @@
+package measurement
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	// DefaultBaselineWindowDays is the number of days before and after a day of
+	// the year whose samples of all years form its baseline.
+	DefaultBaselineWindowDays = 15
+	MaxBaselineWindowDays     = 45
+
+	// MinBaselineDays is the least number of days with samples in the window,
+	// fewer don't give a meaningful distribution.
+	MinBaselineDays = 14
+
+	daysOfYear = 366
+)
+
+// Baseline is the distribution of the good samples of all years within the
+// window around a day of the year.
+type Baseline struct {
+	DayOfYear  int     `json:"day_of_year"`
+	WindowDays int     `json:"window_days"`
+	Samples    int     `json:"samples"`
+	Days       int     `json:"days"` // with samples
+	Min        float64 `json:"min"`
+	P5         float64 `json:"p5"`
+	P10        float64 `json:"p10"`
+	P25        float64 `json:"p25"`
+	P50        float64 `json:"p50"`
+	P75        float64 `json:"p75"`
+	P90        float64 `json:"p90"`
+	P95        float64 `json:"p95"`
+	Max        float64 `json:"max"`
+
+	HistoryStart Epoch `json:"history_start"` // of the samples of all days of the year
+	HistoryEnd   Epoch `json:"history_end"`
+	ComputedAt   Epoch `json:"computed_at"`
+}
+
+// LevelClass classifies a value by its percentile in the baseline.
+type LevelClass string
+
+const (
+	LevelVeryLow  LevelClass = "very_low"  // below P10
+	LevelLow      LevelClass = "low"       // below P25
+	LevelNormal   LevelClass = "normal"    // between P25 and P75
+	LevelHigh     LevelClass = "high"      // up to P90
+	LevelVeryHigh LevelClass = "very_high" // above P90
+)
+
+// HistoricalContext ranks a value against the baseline of its day of the year.
+type HistoricalContext struct {
+	Value      float64    `json:"value"`
+	Timestamp  Epoch      `json:"timestamp"`
+	Percentile float64    `json:"percentile"` // 0 to 100
+	Class      LevelClass `json:"class"`
+	Baseline   Baseline   `json:"baseline"`
+}
+
+// DayOfYear returns the day of the year in UTC, from 1 to 366, counted as in a
+// leap year, so a date has the same day in every year.
+func DayOfYear(ts Epoch) int {
+	t := time.Unix(int64(ts), 0).UTC()
+	return time.Date(2000, t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).YearDay()
+}
+
+// ComputeBaselines computes the baseline of every day of the year with samples
+// on at least MinBaselineDays days in its window; flagged samples are left out.
+// Samples of the windowDays before computedAt are left out too, so the levels
+// ranked against the baselines of the coming days aren't part of their history.
+func ComputeBaselines(samples []Sample, windowDays int, computedAt Epoch) []Baseline {
+	values := make([][]float64, daysOfYear+1)  // by day of the year
+	days := make([]map[int]bool, daysOfYear+1) // years with samples on the day
+	recent := computedAt - Epoch(windowDays*24*60*60)
+	var historyStart, historyEnd Epoch
+	for _, sample := range samples {
+		if sample.Flags.Has(FlagsInvalid) || sample.Timestamp > recent {
+			continue
+		}
+
+		day := DayOfYear(sample.Timestamp)
+		values[day] = append(values[day], sample.Value)
+		if days[day] == nil {
+			days[day] = make(map[int]bool)
+		}
+		days[day][time.Unix(int64(sample.Timestamp), 0).UTC().Year()] = true
+
+		if historyStart == 0 || sample.Timestamp < historyStart {
+			historyStart = sample.Timestamp
+		}
+		historyEnd = max(historyEnd, sample.Timestamp)
+	}
+
+	var baselines []Baseline
+	for day := 1; day <= daysOfYear; day++ {
+		var window []float64
+		dayCount := 0
+		for offset := -windowDays; offset <= windowDays; offset++ {
+			other := (day-1+offset+daysOfYear)%daysOfYear + 1
+			window = append(window, values[other]...)
+			dayCount += len(days[other])
+		}
+		if dayCount < MinBaselineDays {
+			continue
+		}
+
+		sort.Float64s(window)
+		at := func(p float64) float64 {
+			lower, upper, fraction := percentileRanks(len(window), p)
+			return roundHundredths(window[lower] + fraction*(window[upper]-window[lower]))
+		}
+
+		baselines = append(baselines, Baseline{
+			DayOfYear:    day,
+			WindowDays:   windowDays,
+			Samples:      len(window),
+			Days:         dayCount,
+			Min:          window[0],
+			P5:           at(0.05),
+			P10:          at(0.1),
+			P25:          at(0.25),
+			P50:          at(0.5),
+			P75:          at(0.75),
+			P90:          at(0.9),
+			P95:          at(0.95),
+			Max:          window[len(window)-1],
+			HistoryStart: historyStart,
+			HistoryEnd:   historyEnd,
+			ComputedAt:   computedAt,
+		})
+	}
+
+	return baselines
+}
+
+// Rank returns the percentile of the value, interpolated between the stored
+// percentiles, and its class.
+func (b Baseline) Rank(value float64, ts Epoch) HistoricalContext {
+	percentile := b.percentileOf(value)
+
+	class := LevelNormal
+	switch {
+	case percentile < 10:
+		class = LevelVeryLow
+	case percentile < 25:
+		class = LevelLow
+	case percentile > 90:
+		class = LevelVeryHigh
+	case percentile > 75:
+		class = LevelHigh
+	}
+
+	return HistoricalContext{
+		Value:      value,
+		Timestamp:  ts,
+		Percentile: math.Round(percentile*10) / 10,
+		Class:      class,
+		Baseline:   b,
+	}
+}
+
+// RankAgainstBaseline ranks the value against the stored baseline of the day of
+// its timestamp; it returns nil if there is no baseline for the day yet.
+func RankAgainstBaseline(ctx context.Context, repo Repository, measurementName string, value float64, ts Epoch) (*HistoricalContext, error) {
+	baseline, err := repo.GetBaseline(ctx, measurementName, DayOfYear(ts))
+	if err != nil || baseline == nil {
+		return nil, err
+	}
+
+	historical := baseline.Rank(value, ts)
+	return &historical, nil
+}
+
+func (b Baseline) percentileOf(value float64) float64 {
+	knots := []struct{ percentile, value float64 }{
+		{0, b.Min}, {5, b.P5}, {10, b.P10}, {25, b.P25}, {50, b.P50},
+		{75, b.P75}, {90, b.P90}, {95, b.P95}, {100, b.Max},
+	}
+	if value < b.Min {
+		return 0
+	}
+	if value > b.Max {
+		return 100
+	}
+
+	first := sort.Search(len(knots), func(i int) bool { return knots[i].value >= value })
+	if knots[first].value == value {
+		// the middle of the percentiles that share the value, e.g. of a dry gauge
+		last := first
+		for last+1 < len(knots) && knots[last+1].value == value {
+			last++
+		}
+		return (knots[first].percentile + knots[last].percentile) / 2
+	}
+
+	lower, upper := knots[first-1], knots[first]
+	return lower.percentile + (value-lower.value)/(upper.value-lower.value)*(upper.percentile-lower.percentile)
+}
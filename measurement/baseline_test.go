@@ -0,0 +1,87 @@
+package measurement_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/timgluz/wasserspiegel/measurement"
+)
+
+func TestComputeBaselines(t *testing.T) {
+	// a sample at noon of every day in June of two years, 300 cm to 329 cm by day of the month
+	var samples []measurement.Sample
+	for _, year := range []int{2023, 2024} {
+		for day := 1; day <= 30; day++ {
+			ts := time.Date(year, time.June, day, 12, 0, 0, 0, time.UTC).Unix()
+			samples = append(samples, measurement.Sample{Timestamp: measurement.Epoch(ts), Value: float64(299 + day)})
+		}
+	}
+	samples = append(samples, measurement.Sample{Timestamp: samples[0].Timestamp + 900, Value: -777, Flags: measurement.FlagRange})
+
+	baselines := measurement.ComputeBaselines(samples, measurement.DefaultBaselineWindowDays, 1800000000)
+	assert.NotEmpty(t, baselines)
+	for _, baseline := range baselines {
+		assert.GreaterOrEqual(t, baseline.Days, measurement.MinBaselineDays)
+	}
+
+	midJune := measurement.DayOfYear(measurement.Epoch(time.Date(2025, time.June, 15, 0, 0, 0, 0, time.UTC).Unix()))
+	var baseline *measurement.Baseline
+	for i := range baselines {
+		if baselines[i].DayOfYear == midJune {
+			baseline = &baselines[i]
+		}
+	}
+	if assert.NotNil(t, baseline) {
+		assert.Equal(t, 60, baseline.Days, "the window covers June of both years")
+		assert.Equal(t, 300.0, baseline.Min, "flagged samples are left out")
+		assert.Equal(t, 329.0, baseline.Max)
+		assert.Equal(t, 314.5, baseline.P50)
+	}
+
+	// January has no samples
+	for _, baseline := range baselines {
+		assert.NotEqual(t, 15, baseline.DayOfYear)
+	}
+}
+
+func TestComputeBaselinesLeavesOutRecentSamples(t *testing.T) {
+	computedAt := time.Date(2025, time.June, 30, 0, 0, 0, 0, time.UTC)
+	var samples []measurement.Sample
+	for day := 1; day <= 60; day++ {
+		ts := computedAt.AddDate(0, 0, -day).Add(12 * time.Hour)
+		samples = append(samples, measurement.Sample{Timestamp: measurement.Epoch(ts.Unix()), Value: 400})
+	}
+
+	lateJune := measurement.DayOfYear(measurement.Epoch(computedAt.Unix()))
+	for _, baseline := range measurement.ComputeBaselines(samples, measurement.DefaultBaselineWindowDays, measurement.Epoch(computedAt.Unix())) {
+		assert.NotEqual(t, lateJune, baseline.DayOfYear, "only the levels to rank are around the day")
+		assert.LessOrEqual(t, baseline.HistoryEnd, measurement.Epoch(computedAt.AddDate(0, 0, -measurement.DefaultBaselineWindowDays).Unix()))
+	}
+}
+
+func TestBaselineRank(t *testing.T) {
+	baseline := measurement.Baseline{Min: 100, P5: 150, P10: 200, P25: 250, P50: 300, P75: 350, P90: 400, P95: 450, Max: 500}
+
+	testCases := []struct {
+		value      float64
+		percentile float64
+		class      measurement.LevelClass
+	}{
+		{value: 50, percentile: 0, class: measurement.LevelVeryLow},
+		{value: 225, percentile: 17.5, class: measurement.LevelLow},
+		{value: 300, percentile: 50, class: measurement.LevelNormal},
+		{value: 375, percentile: 82.5, class: measurement.LevelHigh},
+		{value: 600, percentile: 100, class: measurement.LevelVeryHigh},
+	}
+
+	for _, tc := range testCases {
+		context := baseline.Rank(tc.value, 1700000000)
+		assert.Equal(t, tc.percentile, context.Percentile, "value %v", tc.value)
+		assert.Equal(t, tc.class, context.Class, "value %v", tc.value)
+	}
+
+	dry := measurement.Baseline{}
+	assert.Equal(t, 50.0, dry.Rank(0, 1700000000).Percentile, "a constant level is the median")
+}
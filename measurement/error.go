@@ -3,5 +3,20 @@ package measurement
 import "fmt"
 
 var (
-	ErrDBNotAvailable = fmt.Errorf("SQLite DB is not available")
+	ErrDBNotAvailable      = fmt.Errorf("SQLite DB is not available")
+	ErrMeasurementExists   = fmt.Errorf("measurement already exists")
+	ErrMeasurementNotFound = fmt.Errorf("measurement not found")
+	ErrSchemaOutdated      = fmt.Errorf("measurement schema is outdated, apply measurement/migrations")
+	ErrSchemaIncomplete    = fmt.Errorf("measurement schema is incomplete, apply measurement/schema.sql")
+
+	ErrInvalidStep          = fmt.Errorf("invalid step")
+	ErrInvalidInterpolation = fmt.Errorf("invalid interpolation")
+	ErrTooManyPoints        = fmt.Errorf("too many points")
+	ErrInvalidForecast      = fmt.Errorf("invalid forecast options")
+	ErrNotEnoughSamples     = fmt.Errorf("not enough samples")
+	ErrInvalidGroupBy       = fmt.Errorf("invalid group_by")
+	ErrTooManyBuckets       = fmt.Errorf("too many buckets")
+	ErrUnknownUnit          = fmt.Errorf("unknown unit")
+	ErrIncompatibleUnits    = fmt.Errorf("incompatible units")
+	ErrUnknownLabel         = fmt.Errorf("unknown label")
 )
@@ -0,0 +1,365 @@
+package measurement
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ForecastModel is the curve fitted to the recent samples.
+type ForecastModel string
+
+const (
+	ForecastLinear    ForecastModel = "linear"
+	ForecastQuadratic ForecastModel = "quadratic" // follows a crest, but diverges fast on long horizons
+)
+
+const (
+	MinForecastHorizon = 6 * time.Hour
+	MaxForecastHorizon = 48 * time.Hour
+	MinForecastWindow  = 3 * time.Hour
+	MaxForecastWindow  = 7 * 24 * time.Hour
+
+	// MinForecastSamples is the least number of good samples in the window.
+	MinForecastSamples = 6
+
+	bisquareTuning   = 4.685 // 95% efficiency for normal residuals
+	maxIRLSIteration = 20
+)
+
+// ParseForecastModel returns the model of a name, linear if the name is empty.
+func ParseForecastModel(name string) (ForecastModel, error) {
+	switch model := ForecastModel(strings.ToLower(strings.TrimSpace(name))); model {
+	case "":
+		return ForecastLinear, nil
+	case ForecastLinear, ForecastQuadratic:
+		return model, nil
+	default:
+		return "", fmt.Errorf("%w: unknown model %q, expected linear or quadratic", ErrInvalidForecast, name)
+	}
+}
+
+// ForecastOptions configure the fit over the Window before the latest sample
+// and the projection over the Horizon after it.
+type ForecastOptions struct {
+	Model      ForecastModel
+	Window     time.Duration
+	Horizon    time.Duration
+	Step       time.Duration
+	Confidence float64 // of the prediction interval, e.g. 0.9
+}
+
+// DefaultForecastOptions project a day from the last day of samples.
+func DefaultForecastOptions() ForecastOptions {
+	return ForecastOptions{
+		Model:      ForecastLinear,
+		Window:     24 * time.Hour,
+		Horizon:    24 * time.Hour,
+		Step:       time.Hour,
+		Confidence: 0.9,
+	}
+}
+
+// OptionError tells which option of a forecast is invalid; it wraps ErrInvalidForecast.
+type OptionError struct {
+	Option  string
+	Message string
+}
+
+func (e *OptionError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrInvalidForecast, e.Message)
+}
+
+func (e *OptionError) Unwrap() error {
+	return ErrInvalidForecast
+}
+
+// Validate checks the ranges of the options.
+func (o ForecastOptions) Validate() error {
+	if _, err := ParseForecastModel(string(o.Model)); err != nil {
+		return &OptionError{Option: "model", Message: "model must be linear or quadratic"}
+	}
+	if o.Window < MinForecastWindow || o.Window > MaxForecastWindow {
+		return &OptionError{Option: "window", Message: fmt.Sprintf("window must be between %s and %s", MinForecastWindow, MaxForecastWindow)}
+	}
+	if o.Horizon < MinForecastHorizon || o.Horizon > MaxForecastHorizon {
+		return &OptionError{Option: "horizon", Message: fmt.Sprintf("horizon must be between %s and %s", MinForecastHorizon, MaxForecastHorizon)}
+	}
+	if o.Step < MinStep || o.Step > o.Horizon {
+		return &OptionError{Option: "step", Message: fmt.Sprintf("step must be between %s and the horizon", MinStep)}
+	}
+	if o.Confidence <= 0 || o.Confidence >= 1 {
+		return &OptionError{Option: "confidence", Message: "confidence must be between 0 and 1"}
+	}
+
+	return nil
+}
+
+// ForecastPoint is a projected value and its prediction interval.
+type ForecastPoint struct {
+	Timestamp Epoch   `json:"timestamp"`
+	Value     float64 `json:"value"`
+	Lower     float64 `json:"lower"`
+	Upper     float64 `json:"upper"`
+}
+
+// Forecast is the projection of a timeseries; durations are in seconds.
+type Forecast struct {
+	Name       string          `json:"name"`
+	Model      ForecastModel   `json:"model"`
+	IssuedAt   Epoch           `json:"issued_at"` // the latest sample the projection starts from
+	Window     int64           `json:"window"`
+	Horizon    int64           `json:"horizon"`
+	Step       int64           `json:"step"`
+	Confidence float64         `json:"confidence"`
+	Samples    int             `json:"samples"`        // fitted samples
+	Rate       float64         `json:"rate"`           // change per hour at the latest sample
+	Sigma      float64         `json:"sigma"`          // robust standard deviation of the residuals
+	Unit       string          `json:"unit,omitempty"` // of the values, the rate is per hour
+	Points     []ForecastPoint `json:"points"`
+}
+
+// Forecast fits the model to the good samples of the window before the latest
+// sample and projects it to the multiples of the step within the horizon. The fit
+// is a least squares regression reweighted with Tukey's bisquare, so outliers the
+// quality checks missed barely move it; the intervals widen with the distance
+// from the fitted samples.
+func (t *Timeseries) Forecast(opts ForecastOptions) (*Forecast, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	samples := make([]Sample, 0, len(t.Samples))
+	for _, sample := range t.Samples {
+		if !sample.Flags.Has(FlagsInvalid) {
+			samples = append(samples, sample)
+		}
+	}
+	sort.SliceStable(samples, func(i, j int) bool { return samples[i].Timestamp < samples[j].Timestamp })
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("%w: no samples", ErrNotEnoughSamples)
+	}
+
+	latest := samples[len(samples)-1].Timestamp
+	windowStart := latest - Epoch(opts.Window.Seconds())
+	first := sort.Search(len(samples), func(i int) bool { return samples[i].Timestamp >= windowStart })
+	samples = samples[first:]
+	if len(samples) < MinForecastSamples {
+		return nil, fmt.Errorf("%w: %d in the window, need %d", ErrNotEnoughSamples, len(samples), MinForecastSamples)
+	}
+
+	degree := 1
+	if opts.Model == ForecastQuadratic {
+		degree = 2
+	}
+
+	// hours before the latest sample keep the normal equations well conditioned
+	hours := func(ts Epoch) float64 { return float64(ts-latest) / 3600 }
+	xs := make([]float64, len(samples))
+	ys := make([]float64, len(samples))
+	for i, sample := range samples {
+		xs[i], ys[i] = hours(sample.Timestamp), sample.Value
+	}
+
+	fit, err := fitRobustPolynomial(xs, ys, degree)
+	if err != nil {
+		return nil, err
+	}
+
+	z := math.Sqrt2 * math.Erfinv(opts.Confidence)
+	step := Epoch(opts.Step.Seconds())
+	forecast := &Forecast{
+		Name:       t.Name,
+		Model:      opts.Model,
+		IssuedAt:   latest,
+		Window:     int64(opts.Window.Seconds()),
+		Horizon:    int64(opts.Horizon.Seconds()),
+		Step:       int64(step),
+		Confidence: opts.Confidence,
+		Samples:    len(samples),
+		Rate:       roundHundredths(fit.coefficients[1]),
+		Sigma:      roundHundredths(fit.sigma),
+		Points:     []ForecastPoint{},
+	}
+	if t.Measurement != nil {
+		forecast.Unit = t.Measurement.Unit
+	}
+
+	end := latest + Epoch(opts.Horizon.Seconds())
+	for ts := (latest/step + 1) * step; ts <= end; ts += step {
+		value, stdErr := fit.predict(hours(ts))
+		forecast.Points = append(forecast.Points, ForecastPoint{
+			Timestamp: ts,
+			Value:     roundHundredths(value),
+			Lower:     roundHundredths(value - z*stdErr),
+			Upper:     roundHundredths(value + z*stdErr),
+		})
+	}
+
+	return forecast, nil
+}
+
+// roundHundredths drops the digits of rounding errors, samples have at most two decimals.
+func roundHundredths(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+type polynomialFit struct {
+	coefficients []float64   // constant first
+	covariance   [][]float64 // (XᵀWX)⁻¹
+	sigma        float64
+}
+
+// predict returns the value at x and the standard error of a new observation there.
+func (f polynomialFit) predict(x float64) (float64, float64) {
+	basis := polynomialBasis(x, len(f.coefficients)-1)
+
+	value, leverage := 0.0, 0.0
+	for i := range basis {
+		value += f.coefficients[i] * basis[i]
+		for j := range basis {
+			leverage += basis[i] * f.covariance[i][j] * basis[j]
+		}
+	}
+
+	return value, f.sigma * math.Sqrt(1+leverage)
+}
+
+func polynomialBasis(x float64, degree int) []float64 {
+	basis := make([]float64, degree+1)
+	basis[0] = 1
+	for i := 1; i <= degree; i++ {
+		basis[i] = basis[i-1] * x
+	}
+
+	return basis
+}
+
+// fitRobustPolynomial fits by iteratively reweighted least squares with bisquare weights.
+func fitRobustPolynomial(xs, ys []float64, degree int) (polynomialFit, error) {
+	weights := make([]float64, len(xs))
+	for i := range weights {
+		weights[i] = 1
+	}
+
+	var fit polynomialFit
+	residuals := make([]float64, len(xs))
+	for iteration := 0; iteration < maxIRLSIteration; iteration++ {
+		coefficients, covariance, err := weightedLeastSquares(xs, ys, weights, degree)
+		if err != nil {
+			return fit, err
+		}
+		fit.coefficients, fit.covariance = coefficients, covariance
+
+		for i := range xs {
+			value, _ := polynomialFit{coefficients: coefficients, covariance: covariance}.predict(xs[i])
+			residuals[i] = ys[i] - value
+		}
+
+		scale := medianAbsolute(residuals) / 0.6745
+		if scale < 1e-9 {
+			break // the samples lie on the curve
+		}
+
+		changed := 0.0
+		for i, residual := range residuals {
+			weight := 0.0
+			if u := residual / (bisquareTuning * scale); math.Abs(u) < 1 {
+				weight = (1 - u*u) * (1 - u*u)
+			}
+			changed = math.Max(changed, math.Abs(weight-weights[i]))
+			weights[i] = weight
+		}
+		if changed < 1e-6 {
+			break
+		}
+	}
+
+	sumSquares, sumWeights := 0.0, 0.0
+	for i, residual := range residuals {
+		sumSquares += weights[i] * residual * residual
+		sumWeights += weights[i]
+	}
+	if freedom := sumWeights - float64(degree+1); freedom > 0 {
+		fit.sigma = math.Sqrt(sumSquares / freedom)
+	}
+
+	return fit, nil
+}
+
+// weightedLeastSquares solves the normal equations XᵀWX β = XᵀWy by Gauss-Jordan elimination.
+func weightedLeastSquares(xs, ys, weights []float64, degree int) ([]float64, [][]float64, error) {
+	size := degree + 1
+	matrix := make([][]float64, size) // XᵀWX, augmented with the identity to get its inverse
+	rhs := make([]float64, size)
+	for i := range matrix {
+		matrix[i] = make([]float64, 2*size)
+		matrix[i][size+i] = 1
+	}
+
+	for k := range xs {
+		basis := polynomialBasis(xs[k], degree)
+		for i := 0; i < size; i++ {
+			rhs[i] += weights[k] * basis[i] * ys[k]
+			for j := 0; j < size; j++ {
+				matrix[i][j] += weights[k] * basis[i] * basis[j]
+			}
+		}
+	}
+
+	for col := 0; col < size; col++ {
+		pivot := col
+		for row := col + 1; row < size; row++ {
+			if math.Abs(matrix[row][col]) > math.Abs(matrix[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(matrix[pivot][col]) < 1e-12 {
+			return nil, nil, fmt.Errorf("%w: the samples don't span the model", ErrNotEnoughSamples)
+		}
+		matrix[col], matrix[pivot] = matrix[pivot], matrix[col]
+		rhs[col], rhs[pivot] = rhs[pivot], rhs[col]
+
+		divisor := matrix[col][col]
+		for j := range matrix[col] {
+			matrix[col][j] /= divisor
+		}
+		rhs[col] /= divisor
+
+		for row := 0; row < size; row++ {
+			if row == col {
+				continue
+			}
+			factor := matrix[row][col]
+			for j := range matrix[row] {
+				matrix[row][j] -= factor * matrix[col][j]
+			}
+			rhs[row] -= factor * rhs[col]
+		}
+	}
+
+	covariance := make([][]float64, size)
+	for i := range matrix {
+		covariance[i] = matrix[i][size:]
+	}
+
+	return rhs, covariance, nil
+}
+
+// medianAbsolute is the median of the absolute values, the residuals of a fit are centered.
+func medianAbsolute(values []float64) float64 {
+	deviations := make([]float64, len(values))
+	for i, value := range values {
+		deviations[i] = math.Abs(value)
+	}
+	sort.Float64s(deviations)
+
+	middle := len(deviations) / 2
+	if len(deviations)%2 == 0 {
+		return (deviations[middle-1] + deviations[middle]) / 2
+	}
+
+	return deviations[middle]
+}
@@ -0,0 +1,90 @@
+package measurement_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/timgluz/wasserspiegel/measurement"
+)
+
+// syntheticSeries samples level(hours since start) every 15 minutes for a day, with
+// deterministic noise of ±2 cm.
+func syntheticSeries(level func(hours float64) float64) *measurement.Timeseries {
+	const start = measurement.Epoch(1700002800) // a full hour
+	timeseries := &measurement.Timeseries{Name: "waterlevel-bonn"}
+	for i := 0; i <= 96; i++ {
+		hours := float64(i) / 4
+		noise := 2 * math.Sin(float64(i)*1.7)
+		timeseries.Samples = append(timeseries.Samples, measurement.Sample{
+			Timestamp: start + measurement.Epoch(i*900),
+			Value:     level(hours) + noise,
+		})
+	}
+
+	return timeseries
+}
+
+func TestTimeseriesForecastLinear(t *testing.T) {
+	rising := func(hours float64) float64 { return 300 + 3*hours } // 3 cm/h
+	timeseries := syntheticSeries(rising)
+	// outliers the quality checks missed don't move the robust fit
+	timeseries.Samples[90].Value += 60
+	timeseries.Samples[95].Value -= 60
+
+	forecast, err := timeseries.Forecast(measurement.DefaultForecastOptions())
+	assert.NoError(t, err)
+	assert.Equal(t, measurement.ForecastLinear, forecast.Model)
+	assert.Equal(t, 97, forecast.Samples)
+	assert.InDelta(t, 3, forecast.Rate, 0.1)
+	assert.InDelta(t, 1.4, forecast.Sigma, 0.3, "the noise has a standard deviation of about 1.4 cm")
+
+	if assert.Len(t, forecast.Points, 24) {
+		first, last := forecast.Points[0], forecast.Points[23]
+		assert.Equal(t, timeseries.Samples[96].Timestamp+3600, first.Timestamp)
+		assert.InDelta(t, rising(25), first.Value, 1)
+		assert.InDelta(t, rising(48), last.Value, 3)
+		assert.Less(t, last.Lower, rising(48))
+		assert.Greater(t, last.Upper, rising(48))
+		assert.Greater(t, last.Upper-last.Lower, first.Upper-first.Lower, "the interval widens with the horizon")
+	}
+}
+
+func TestTimeseriesForecastQuadratic(t *testing.T) {
+	crest := func(hours float64) float64 { return 400 + 10*hours - 0.2*hours*hours } // peaks after 25 hours
+
+	opts := measurement.DefaultForecastOptions()
+	opts.Model = measurement.ForecastQuadratic
+	opts.Horizon = 12 * time.Hour
+	forecast, err := syntheticSeries(crest).Forecast(opts)
+	assert.NoError(t, err)
+	assert.InDelta(t, 0.4, forecast.Rate, 0.3, "the rise slows down")
+	if assert.Len(t, forecast.Points, 12) {
+		assert.InDelta(t, crest(36), forecast.Points[11].Value, 3)
+	}
+
+	linear, err := syntheticSeries(crest).Forecast(measurement.DefaultForecastOptions())
+	assert.NoError(t, err)
+	assert.Greater(t, linear.Points[11].Value, crest(36)+20, "a line overshoots the crest")
+}
+
+func TestTimeseriesForecastErrors(t *testing.T) {
+	timeseries := syntheticSeries(func(hours float64) float64 { return 300 })
+
+	opts := measurement.DefaultForecastOptions()
+	opts.Horizon = 72 * time.Hour
+	_, err := timeseries.Forecast(opts)
+	assert.ErrorIs(t, err, measurement.ErrInvalidForecast)
+
+	timeseries.Samples = timeseries.Samples[:5]
+	_, err = timeseries.Forecast(measurement.DefaultForecastOptions())
+	assert.ErrorIs(t, err, measurement.ErrNotEnoughSamples)
+
+	for i := range timeseries.Samples {
+		timeseries.Samples[i].Flags = measurement.FlagRange
+	}
+	_, err = timeseries.Forecast(measurement.DefaultForecastOptions())
+	assert.ErrorIs(t, err, measurement.ErrNotEnoughSamples, "flagged samples aren't fitted")
+}
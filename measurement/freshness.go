@@ -0,0 +1,185 @@
+package measurement
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	// CadenceWindow is the span before the latest sample whose intervals give the cadence.
+	CadenceWindow = 24 * time.Hour
+
+	// maxCadenceSamples limits the intervals of a series, PegelOnline publishes 96 a day.
+	maxCadenceSamples = 97
+)
+
+// FreshnessPolicy decides when a series is stale: when its lag exceeds Factor
+// times its cadence, but at least MinLag so a late delivery isn't reported at once.
+type FreshnessPolicy struct {
+	Factor         float64       `json:"factor"`
+	MinLag         time.Duration `json:"min_lag"`
+	DefaultCadence time.Duration `json:"default_cadence"` // for series with less than two samples
+}
+
+// DefaultFreshnessPolicy follows PegelOnline, which publishes a water level every 15 minutes.
+func DefaultFreshnessPolicy() FreshnessPolicy {
+	return FreshnessPolicy{
+		Factor:         4,
+		MinLag:         time.Hour,
+		DefaultCadence: 15 * time.Minute,
+	}
+}
+
+// MaxLag is the longest lag of a fresh series with the given cadence.
+func (p FreshnessPolicy) MaxLag(cadence time.Duration) time.Duration {
+	return max(time.Duration(float64(cadence)*p.Factor), p.MinLag)
+}
+
+// Freshness tells whether a series still receives samples; durations are in seconds.
+type Freshness struct {
+	Name         string `json:"name"`
+	LatestSample Epoch  `json:"latest_sample,omitempty"` // 0 without samples
+	Cadence      int64  `json:"cadence"`                 // median interval of the recent samples
+	Lag          int64  `json:"lag"`                     // time since the latest sample
+	MaxLag       int64  `json:"max_lag"`
+	Stale        bool   `json:"stale"`
+	Reason       string `json:"reason,omitempty"`
+}
+
+// Evaluate returns the freshness of a series from its recent samples; the
+// latest sample may be newer than the samples, e.g. if they end earlier.
+func (p FreshnessPolicy) Evaluate(name string, samples []Sample, latest Epoch, now time.Time) Freshness {
+	for _, sample := range samples {
+		latest = max(latest, sample.Timestamp)
+	}
+
+	cadence, ok := InferCadence(samples)
+	if !ok {
+		cadence = p.DefaultCadence
+	}
+
+	freshness := Freshness{
+		Name:         name,
+		LatestSample: latest,
+		Cadence:      int64(cadence.Seconds()),
+		MaxLag:       int64(p.MaxLag(cadence).Seconds()),
+	}
+
+	if latest == 0 {
+		freshness.Stale = true
+		freshness.Reason = "no samples"
+		return freshness
+	}
+
+	freshness.Lag = max(0, now.Unix()-int64(latest))
+	if freshness.Lag > freshness.MaxLag {
+		freshness.Stale = true
+		freshness.Reason = fmt.Sprintf("no sample for %s, expected one every %s", time.Duration(freshness.Lag)*time.Second, cadence)
+	}
+
+	return freshness
+}
+
+// InferCadence returns the median interval between the last samples; it
+// fails with less than two distinct timestamps. The median ignores single gaps.
+func InferCadence(samples []Sample) (time.Duration, bool) {
+	timestamps := make([]Epoch, 0, len(samples))
+	for _, sample := range samples {
+		timestamps = append(timestamps, sample.Timestamp)
+	}
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+
+	if len(timestamps) > maxCadenceSamples {
+		timestamps = timestamps[len(timestamps)-maxCadenceSamples:]
+	}
+
+	intervals := make([]int64, 0, len(timestamps))
+	for i := 1; i < len(timestamps); i++ {
+		if interval := int64(timestamps[i] - timestamps[i-1]); interval > 0 {
+			intervals = append(intervals, interval)
+		}
+	}
+
+	if len(intervals) == 0 {
+		return 0, false
+	}
+
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i] < intervals[j] })
+	return time.Duration(intervals[len(intervals)/2]) * time.Second, true
+}
+
+// FreshnessReport lists the freshness of the measurements, stale series first.
+type FreshnessReport struct {
+	CheckedAt  Epoch       `json:"checked_at"`
+	Total      int         `json:"total"`
+	StaleCount int         `json:"stale_count"`
+	Series     []Freshness `json:"series"`
+}
+
+// CheckFreshness evaluates every measurement; measurements without samples are stale.
+// The cadence of each series is inferred from the samples of CadenceWindow before its latest sample.
+func CheckFreshness(ctx context.Context, repo Repository, policy FreshnessPolicy, now time.Time) (*FreshnessReport, error) {
+	measurements, err := repo.GetMeasurements(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list measurements: %w", err)
+	}
+
+	latestSamples, err := repo.GetLatestSamples(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest samples: %w", err)
+	}
+
+	latestByName := make(map[string]Epoch, len(latestSamples))
+	for _, latest := range latestSamples {
+		latestByName[latest.Measurement.Name] = latest.Sample.Timestamp
+	}
+
+	report := &FreshnessReport{CheckedAt: Epoch(now.Unix()), Series: []Freshness{}}
+	for _, m := range measurements {
+		latest := latestByName[m.Name]
+
+		var samples []Sample
+		if latest > 0 {
+			window := Period{Start: latest - Epoch(CadenceWindow.Seconds()), End: latest}
+			timeseries, err := repo.GetTimeseries(ctx, m.Name, window)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get recent samples of %s: %w", m.Name, err)
+			}
+			if timeseries != nil {
+				samples = timeseries.Samples
+			}
+		}
+
+		freshness := policy.Evaluate(m.Name, samples, latest, now)
+		if freshness.Stale {
+			report.StaleCount++
+		}
+		report.Series = append(report.Series, freshness)
+	}
+
+	sort.SliceStable(report.Series, func(i, j int) bool {
+		if report.Series[i].Stale != report.Series[j].Stale {
+			return report.Series[i].Stale
+		}
+		return report.Series[i].Name < report.Series[j].Name
+	})
+	report.Total = len(report.Series)
+
+	return report, nil
+}
+
+// OnlyStale drops the fresh series from the report.
+func (r *FreshnessReport) OnlyStale() *FreshnessReport {
+	stale := make([]Freshness, 0, r.StaleCount)
+	for _, series := range r.Series {
+		if series.Stale {
+			stale = append(stale, series)
+		}
+	}
+
+	filtered := *r
+	filtered.Series = stale
+	return &filtered
+}
@@ -0,0 +1,87 @@
+package measurement_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/timgluz/wasserspiegel/measurement"
+	"github.com/timgluz/wasserspiegel/measurement/measurementtest"
+)
+
+func samplesEvery(start measurement.Epoch, interval time.Duration, count int) []measurement.Sample {
+	samples := make([]measurement.Sample, 0, count)
+	for i := 0; i < count; i++ {
+		samples = append(samples, measurement.Sample{Timestamp: start + measurement.Epoch(i)*measurement.Epoch(interval.Seconds()), Value: 400})
+	}
+
+	return samples
+}
+
+func TestInferCadence(t *testing.T) {
+	samples := samplesEvery(1700000000, 15*time.Minute, 10)
+	samples = append(samples, measurement.Sample{Timestamp: samples[9].Timestamp + 6*3600}) // a single gap
+
+	cadence, ok := measurement.InferCadence(samples)
+	assert.True(t, ok)
+	assert.Equal(t, 15*time.Minute, cadence)
+
+	_, ok = measurement.InferCadence(samples[:1])
+	assert.False(t, ok)
+}
+
+func TestFreshnessPolicyEvaluate(t *testing.T) {
+	policy := measurement.DefaultFreshnessPolicy()
+	samples := samplesEvery(1700000000, 15*time.Minute, 4)
+	latest := time.Unix(int64(samples[3].Timestamp), 0)
+
+	fresh := policy.Evaluate("bonn", samples, 0, latest.Add(30*time.Minute))
+	assert.False(t, fresh.Stale)
+	assert.Equal(t, int64(900), fresh.Cadence)
+	assert.Equal(t, int64(1800), fresh.Lag)
+	assert.Equal(t, int64(3600), fresh.MaxLag)
+
+	stale := policy.Evaluate("bonn", samples, 0, latest.Add(2*time.Hour))
+	assert.True(t, stale.Stale)
+	assert.NotEmpty(t, stale.Reason)
+
+	empty := policy.Evaluate("koeln", nil, 0, latest)
+	assert.True(t, empty.Stale)
+	assert.Equal(t, "no samples", empty.Reason)
+}
+
+func TestCheckFreshness(t *testing.T) {
+	ctx := context.Background()
+	repo := measurementtest.NewMemoryRepository()
+	now := time.Unix(1700000000, 0)
+
+	assert.NoError(t, repo.AddTimeseries(ctx, &measurement.Timeseries{
+		Name:        "bonn",
+		Measurement: &measurement.Measurement{Name: "bonn", Unit: "cm"},
+		Samples:     samplesEvery(measurement.Epoch(now.Add(-time.Hour).Unix()), 15*time.Minute, 4),
+	}))
+	assert.NoError(t, repo.AddTimeseries(ctx, &measurement.Timeseries{
+		Name:        "koeln",
+		Measurement: &measurement.Measurement{Name: "koeln", Unit: "cm"},
+		Samples:     samplesEvery(measurement.Epoch(now.Add(-48*time.Hour).Unix()), time.Hour, 6),
+	}))
+	assert.NoError(t, repo.AddMeasurement(ctx, &measurement.Measurement{Name: "mainz", Unit: "cm"}))
+
+	report, err := measurement.CheckFreshness(ctx, repo, measurement.DefaultFreshnessPolicy(), now)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, report.Total)
+	assert.Equal(t, 2, report.StaleCount)
+	if assert.Len(t, report.Series, 3) {
+		assert.Equal(t, "koeln", report.Series[0].Name, "stale series come first")
+		assert.Equal(t, int64(3600), report.Series[0].Cadence)
+		assert.Equal(t, "mainz", report.Series[1].Name)
+		assert.Equal(t, "bonn", report.Series[2].Name)
+		assert.False(t, report.Series[2].Stale)
+	}
+
+	stale := report.OnlyStale()
+	assert.Len(t, stale.Series, 2)
+	assert.Len(t, report.Series, 3, "the report is not modified")
+}
@@ -0,0 +1,70 @@
+package measurement
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Keys of the labels a measurement catalogue can be filtered by.
+const (
+	LabelStationID = "station_id"
+	LabelParameter = "parameter"
+	LabelProvider  = "provider"
+	LabelUnit      = "unit" // matches the unit of the measurement
+)
+
+// Labels describe what a measurement measures, e.g. the water level of a station.
+type Labels struct {
+	StationID string `json:"station_id,omitempty"`
+	Parameter string `json:"parameter,omitempty"` // e.g. waterlevel
+	Provider  string `json:"provider,omitempty"`  // e.g. pegelonline
+}
+
+// MeasurementFilter selects a page of the measurements whose labels equal all
+// set labels; a Limit of 0 returns all measurements after the Offset.
+type MeasurementFilter struct {
+	Labels Labels
+	Unit   string
+	Offset int
+	Limit  int
+}
+
+// SetLabel sets the label of the key, it fails with ErrUnknownLabel.
+func (f *MeasurementFilter) SetLabel(key, value string) error {
+	switch key {
+	case LabelStationID:
+		f.Labels.StationID = value
+	case LabelParameter:
+		f.Labels.Parameter = value
+	case LabelProvider:
+		f.Labels.Provider = value
+	case LabelUnit:
+		unit, err := NormalizeUnit(value)
+		if err != nil {
+			return err
+		}
+		f.Unit = unit
+	default:
+		return fmt.Errorf("%w: %q, supported are %s", ErrUnknownLabel, key, strings.Join(LabelKeys(), ", "))
+	}
+
+	return nil
+}
+
+// Matches reports whether the measurement has all labels of the filter.
+func (f MeasurementFilter) Matches(m Measurement) bool {
+	matches := func(want, got string) bool { return want == "" || want == got }
+
+	return matches(f.Labels.StationID, m.Labels.StationID) &&
+		matches(f.Labels.Parameter, m.Labels.Parameter) &&
+		matches(f.Labels.Provider, m.Labels.Provider) &&
+		matches(f.Unit, m.Unit)
+}
+
+// LabelKeys returns the sorted keys of the labels.
+func LabelKeys() []string {
+	keys := []string{LabelStationID, LabelParameter, LabelProvider, LabelUnit}
+	sort.Strings(keys)
+	return keys
+}
@@ -2,6 +2,7 @@ package measurement
 
 import (
 	"strings"
+	"time"
 
 	"github.com/gosimple/slug"
 )
@@ -13,6 +14,7 @@ type Measurement struct {
 	Name        string `json:"name"`
 	Description string `json:"description,omitempty"` // Optional field for additional info
 	Unit        string `json:"unit"`                  // Unit of measurement (e.g., "Celsius", "Pascal", "liters")
+	Labels      Labels `json:"labels"`
 }
 
 func NewMeasurementName(keys ...string) string {
@@ -25,7 +27,8 @@ type Sample struct {
 	ID            int64   `json:"id"`
 	MeasurementID int64   `json:"measurement_id"`
 	Value         float64 `json:"value"`
-	Timestamp     Epoch   `json:"timestamp"` // ISO 8601 format
+	Timestamp     Epoch   `json:"timestamp"`       // ISO 8601 format
+	Flags         Flags   `json:"flags,omitempty"` // set on ingest by the QualityPolicy of the measurement
 }
 
 type Timeseries struct {
@@ -33,6 +36,29 @@ type Timeseries struct {
 	Samples []Sample `json:"samples"`
 	Start   Epoch    `json:"start"` // epoch time in seconds
 	End     Epoch    `json:"end"`   // epoch time in seconds
+	Gaps    []Gap    `json:"gaps,omitempty"`
 
 	Measurement *Measurement `json:"measurement,omitempty"` // Optional field to include measurement details
 }
+
+// LastModified returns the time of the most recent sample, or the zero time for empty timeseries.
+func (t *Timeseries) LastModified() time.Time {
+	var latest Epoch
+	for _, sample := range t.Samples {
+		if sample.Timestamp > latest {
+			latest = sample.Timestamp
+		}
+	}
+
+	if latest == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(int64(latest), 0)
+}
+
+// LatestSample pairs a measurement with its most recent sample.
+type LatestSample struct {
+	Measurement Measurement `json:"measurement"`
+	Sample      Sample      `json:"sample"`
+}
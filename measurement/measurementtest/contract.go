@@ -0,0 +1,343 @@
+package measurementtest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/timgluz/wasserspiegel/measurement"
+)
+
+// RunRepositoryContract checks the ordering, not-found and duplicate
+// semantics shared by all measurement.Repository implementations;
+// newRepository must return an empty repository.
+func RunRepositoryContract(t *testing.T, newRepository func(t *testing.T) measurement.Repository) {
+	ctx := context.Background()
+
+	newTimeseries := func(name string, samples ...measurement.Sample) *measurement.Timeseries {
+		return &measurement.Timeseries{
+			Name:        name,
+			Samples:     samples,
+			Measurement: &measurement.Measurement{Name: name, Unit: "cm"},
+		}
+	}
+
+	sample := func(ts measurement.Epoch, value float64) measurement.Sample {
+		return measurement.Sample{Timestamp: ts, Value: value}
+	}
+
+	t.Run("empty repository has no measurements", func(t *testing.T) {
+		repo := newRepository(t)
+
+		measurements, err := repo.GetMeasurements(ctx)
+		assert.NoError(t, err)
+		assert.Empty(t, measurements)
+
+		latest, err := repo.GetLatestSamples(ctx)
+		assert.NoError(t, err)
+		assert.Empty(t, latest)
+	})
+
+	t.Run("unknown measurement returns no timeseries", func(t *testing.T) {
+		repo := newRepository(t)
+
+		timeseries, err := repo.GetTimeseries(ctx, "missing", measurement.Period{Start: 0, End: 100})
+		assert.NoError(t, err)
+		assert.Nil(t, timeseries)
+	})
+
+	t.Run("duplicate measurement is rejected", func(t *testing.T) {
+		repo := newRepository(t)
+
+		m := &measurement.Measurement{Name: "level", Unit: "cm"}
+		assert.NoError(t, repo.AddMeasurement(ctx, m))
+		assert.ErrorIs(t, repo.AddMeasurement(ctx, m), measurement.ErrMeasurementExists)
+	})
+
+	t.Run("measurements are ordered by name", func(t *testing.T) {
+		repo := newRepository(t)
+		for _, name := range []string{"c", "a", "b"} {
+			assert.NoError(t, repo.AddMeasurement(ctx, &measurement.Measurement{Name: name, Unit: "cm"}))
+		}
+
+		measurements, err := repo.GetMeasurements(ctx)
+		assert.NoError(t, err)
+		if assert.Len(t, measurements, 3) {
+			assert.Equal(t, "a", measurements[0].Name)
+			assert.Equal(t, "b", measurements[1].Name)
+			assert.Equal(t, "c", measurements[2].Name)
+			assert.Equal(t, "cm", measurements[0].Unit)
+		}
+	})
+
+	t.Run("measurements are filtered by their labels and paginated", func(t *testing.T) {
+		repo := newRepository(t)
+		for _, m := range []measurement.Measurement{
+			{Name: "waterlevel-koeln", Unit: "cm", Labels: measurement.Labels{StationID: "koeln", Parameter: "waterlevel", Provider: "pegelonline"}},
+			{Name: "waterlevel-bonn", Unit: "cm", Labels: measurement.Labels{StationID: "bonn", Parameter: "waterlevel", Provider: "pegelonline"}},
+			{Name: "discharge-bonn", Unit: "m3/s", Labels: measurement.Labels{StationID: "bonn", Parameter: "discharge", Provider: "pegelonline"}},
+			{Name: "unlabelled", Unit: "cm", Description: "no labels"},
+		} {
+			assert.NoError(t, repo.AddMeasurement(ctx, &m))
+		}
+
+		measurements, total, err := repo.FindMeasurements(ctx, measurement.MeasurementFilter{Labels: measurement.Labels{StationID: "bonn"}})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, total)
+		if assert.Len(t, measurements, 2) {
+			assert.Equal(t, "discharge-bonn", measurements[0].Name)
+			assert.Equal(t, measurement.Labels{StationID: "bonn", Parameter: "waterlevel", Provider: "pegelonline"}, measurements[1].Labels)
+		}
+
+		measurements, total, err = repo.FindMeasurements(ctx, measurement.MeasurementFilter{Unit: "cm", Offset: 1, Limit: 1})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, total, "the total ignores the pagination")
+		if assert.Len(t, measurements, 1) {
+			assert.Equal(t, "waterlevel-bonn", measurements[0].Name)
+		}
+
+		measurements, total, err = repo.FindMeasurements(ctx, measurement.MeasurementFilter{Offset: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, 4, total)
+		assert.Empty(t, measurements)
+
+		unlabelled, err := repo.GetMeasurement(ctx, "unlabelled")
+		assert.NoError(t, err)
+		if assert.NotNil(t, unlabelled) {
+			assert.Equal(t, "no labels", unlabelled.Description)
+			assert.Equal(t, measurement.Labels{}, unlabelled.Labels)
+		}
+	})
+
+	t.Run("update replaces the description and labels but not the unit", func(t *testing.T) {
+		repo := newRepository(t)
+		assert.NoError(t, repo.AddMeasurement(ctx, &measurement.Measurement{Name: "level", Unit: "cm", Description: "old"}))
+
+		labels := measurement.Labels{StationID: "bonn", Parameter: "waterlevel"}
+		assert.NoError(t, repo.UpdateMeasurement(ctx, &measurement.Measurement{Name: "level", Unit: "CM", Description: "new", Labels: labels}))
+		assert.ErrorIs(t, repo.UpdateMeasurement(ctx, &measurement.Measurement{Name: "level", Unit: "m"}), measurement.ErrIncompatibleUnits)
+		assert.ErrorIs(t, repo.UpdateMeasurement(ctx, &measurement.Measurement{Name: "missing"}), measurement.ErrMeasurementNotFound)
+
+		stored, err := repo.GetMeasurement(ctx, "level")
+		assert.NoError(t, err)
+		if assert.NotNil(t, stored) {
+			assert.Equal(t, "new", stored.Description)
+			assert.Equal(t, "cm", stored.Unit)
+			assert.Equal(t, labels, stored.Labels)
+		}
+	})
+
+	t.Run("delete removes the samples and baselines of the measurement", func(t *testing.T) {
+		repo := newRepository(t)
+		assert.NoError(t, repo.AddTimeseries(ctx, newTimeseries("level", sample(10, 1))))
+		assert.NoError(t, repo.AddTimeseries(ctx, newTimeseries("other", sample(10, 2))))
+		assert.NoError(t, repo.SaveBaselines(ctx, "level", []measurement.Baseline{{DayOfYear: 1, P50: 1}}))
+
+		assert.NoError(t, repo.DeleteMeasurement(ctx, "level"))
+		assert.ErrorIs(t, repo.DeleteMeasurement(ctx, "level"), measurement.ErrMeasurementNotFound)
+
+		timeseries, err := repo.GetTimeseries(ctx, "level", measurement.Period{Start: 0, End: 100})
+		assert.NoError(t, err)
+		assert.Nil(t, timeseries)
+
+		baseline, err := repo.GetBaseline(ctx, "level", 1)
+		assert.NoError(t, err)
+		assert.Nil(t, baseline)
+
+		// a new measurement of the name starts without the deleted samples
+		assert.NoError(t, repo.AddMeasurement(ctx, &measurement.Measurement{Name: "level", Unit: "cm"}))
+		timeseries, err = repo.GetTimeseries(ctx, "level", measurement.Period{Start: 0, End: 100})
+		assert.NoError(t, err)
+		if assert.NotNil(t, timeseries) {
+			assert.Empty(t, timeseries.Samples)
+		}
+
+		latest, err := repo.GetLatestSamples(ctx)
+		assert.NoError(t, err)
+		if assert.Len(t, latest, 1) {
+			assert.Equal(t, "other", latest[0].Measurement.Name)
+		}
+	})
+
+	t.Run("timeseries creates its measurement", func(t *testing.T) {
+		repo := newRepository(t)
+
+		assert.NoError(t, repo.AddTimeseries(ctx, newTimeseries("level", sample(10, 1))))
+
+		timeseries, err := repo.GetTimeseries(ctx, "level", measurement.Period{Start: 0, End: 100})
+		assert.NoError(t, err)
+		if assert.NotNil(t, timeseries) {
+			assert.Equal(t, "level", timeseries.Name)
+			assert.Len(t, timeseries.Samples, 1)
+			if assert.NotNil(t, timeseries.Measurement) {
+				assert.Equal(t, "cm", timeseries.Measurement.Unit)
+			}
+		}
+	})
+
+	t.Run("samples in another unit are converted to the unit of the measurement", func(t *testing.T) {
+		repo := newRepository(t)
+		assert.NoError(t, repo.AddMeasurement(ctx, &measurement.Measurement{Name: "level", Unit: "CM"}))
+
+		stored, err := repo.GetMeasurement(ctx, "level")
+		assert.NoError(t, err)
+		if assert.NotNil(t, stored) {
+			assert.Equal(t, "cm", stored.Unit, "the unit is stored with its canonical symbol")
+		}
+
+		inMeters := newTimeseries("level", sample(10, 3.12))
+		inMeters.Measurement.Unit = "m"
+		assert.NoError(t, repo.AddTimeseries(ctx, inMeters))
+
+		inCelsius := newTimeseries("level", sample(20, 12))
+		inCelsius.Measurement.Unit = "C"
+		assert.ErrorIs(t, repo.AddTimeseries(ctx, inCelsius), measurement.ErrIncompatibleUnits)
+		assert.ErrorIs(t, repo.AddMeasurement(ctx, &measurement.Measurement{Name: "speed", Unit: "knots"}), measurement.ErrUnknownUnit)
+
+		timeseries, err := repo.GetTimeseries(ctx, "level", measurement.Period{Start: 0, End: 100})
+		assert.NoError(t, err)
+		if assert.NotNil(t, timeseries) && assert.Len(t, timeseries.Samples, 1) {
+			assert.Equal(t, 312.0, timeseries.Samples[0].Value)
+		}
+
+		unknown, err := repo.GetMeasurement(ctx, "unknown")
+		assert.NoError(t, err)
+		assert.Nil(t, unknown)
+	})
+
+	t.Run("samples with a known timestamp are not overwritten", func(t *testing.T) {
+		repo := newRepository(t)
+
+		assert.NoError(t, repo.AddTimeseries(ctx, newTimeseries("level", sample(10, 1))))
+		assert.NoError(t, repo.AddTimeseries(ctx, newTimeseries("level", sample(10, 2), sample(20, 3))))
+
+		timeseries, err := repo.GetTimeseries(ctx, "level", measurement.Period{Start: 0, End: 100})
+		assert.NoError(t, err)
+		if assert.NotNil(t, timeseries) && assert.Len(t, timeseries.Samples, 2) {
+			assert.Equal(t, 1.0, timeseries.Samples[0].Value)
+			assert.Equal(t, 3.0, timeseries.Samples[1].Value)
+		}
+	})
+
+	t.Run("period is inclusive and samples are ordered by timestamp", func(t *testing.T) {
+		repo := newRepository(t)
+
+		assert.NoError(t, repo.AddTimeseries(ctx, newTimeseries("level",
+			sample(40, 4), sample(10, 1), sample(30, 3), sample(20, 2), sample(50, 5))))
+
+		timeseries, err := repo.GetTimeseries(ctx, "level", measurement.Period{Start: 20, End: 40})
+		assert.NoError(t, err)
+		if assert.NotNil(t, timeseries) && assert.Len(t, timeseries.Samples, 3) {
+			assert.Equal(t, measurement.Epoch(20), timeseries.Samples[0].Timestamp)
+			assert.Equal(t, measurement.Epoch(30), timeseries.Samples[1].Timestamp)
+			assert.Equal(t, measurement.Epoch(40), timeseries.Samples[2].Timestamp)
+			assert.Equal(t, timeseries.Measurement.ID, timeseries.Samples[0].MeasurementID)
+		}
+	})
+
+	t.Run("invalid timeseries are rejected", func(t *testing.T) {
+		repo := newRepository(t)
+
+		assert.Error(t, repo.AddTimeseries(ctx, nil))
+		assert.Error(t, repo.AddTimeseries(ctx, newTimeseries("")))
+		assert.Error(t, repo.AddTimeseries(ctx, &measurement.Timeseries{Name: "unknown"}))
+		assert.Error(t, repo.AddTimeseries(ctx, newTimeseries("level", sample(0, 1))))
+	})
+
+	t.Run("samples are flagged on ingest with the stored samples as context", func(t *testing.T) {
+		repo := newRepository(t)
+		const quarter = 900
+
+		assert.NoError(t, repo.AddTimeseries(ctx, newTimeseries("level",
+			sample(1*quarter, 400), sample(2*quarter, 401), sample(3*quarter, -777))))
+		assert.NoError(t, repo.AddTimeseries(ctx, newTimeseries("level",
+			sample(3*quarter, 402), sample(4*quarter, 900), sample(5*quarter, 402), sample(20*quarter, 403))))
+
+		timeseries, err := repo.GetTimeseries(ctx, "level", measurement.Period{Start: 0, End: 100 * quarter})
+		assert.NoError(t, err)
+		if assert.NotNil(t, timeseries) && assert.Len(t, timeseries.Samples, 6) {
+			assert.Equal(t, measurement.Flags(0), timeseries.Samples[1].Flags)
+			assert.Equal(t, measurement.FlagRange, timeseries.Samples[2].Flags, "stored flags are kept")
+			assert.Equal(t, measurement.FlagSpike, timeseries.Samples[3].Flags)
+			assert.Equal(t, measurement.Flags(0), timeseries.Samples[4].Flags, "compared with the last good sample")
+			assert.Equal(t, measurement.FlagGap, timeseries.Samples[5].Flags)
+		}
+	})
+
+	t.Run("stats summarize the good samples of every bucket", func(t *testing.T) {
+		repo := newRepository(t)
+		const quarter = 900
+
+		stats, err := repo.GetStats(ctx, "missing", []measurement.Bucket{{Start: 0, End: 100}})
+		assert.NoError(t, err)
+		assert.Nil(t, stats)
+
+		assert.NoError(t, repo.AddTimeseries(ctx, newTimeseries("level",
+			sample(1*quarter, 400), sample(2*quarter, 402), sample(3*quarter, 400), sample(4*quarter, -777),
+			sample(5*quarter, 404), sample(6*quarter, 402), sample(110*quarter, 300))))
+
+		stats, err = repo.GetStats(ctx, "level", []measurement.Bucket{
+			{Label: "first", Start: 0, End: 100 * quarter}, {Label: "empty", Start: 200 * quarter, End: 300 * quarter},
+		})
+		assert.NoError(t, err)
+		if assert.Len(t, stats, 2) {
+			first := stats[0]
+			assert.Equal(t, "first", first.Label)
+			assert.Equal(t, 5, first.Count, "the flagged sample is left out")
+			assert.Equal(t, &measurement.Extreme{Value: 400, Timestamp: quarter}, first.Min, "the first of equal minimums")
+			assert.Equal(t, &measurement.Extreme{Value: 404, Timestamp: 5 * quarter}, first.Max)
+			assert.Equal(t, 401.6, *first.Mean)
+			assert.Equal(t, 402.0, *first.Median)
+			assert.Equal(t, 400.0, *first.P5)
+			assert.Equal(t, 403.6, *first.P95)
+
+			assert.Equal(t, 0, stats[1].Count)
+			assert.Nil(t, stats[1].Min)
+			assert.Nil(t, stats[1].Median)
+		}
+	})
+
+	t.Run("saved baselines replace the previous ones", func(t *testing.T) {
+		repo := newRepository(t)
+
+		baseline := func(day int, median float64, computedAt measurement.Epoch) measurement.Baseline {
+			return measurement.Baseline{DayOfYear: day, WindowDays: 15, Samples: 100, Days: 20, P50: median, ComputedAt: computedAt}
+		}
+		assert.ErrorIs(t, repo.SaveBaselines(ctx, "missing", []measurement.Baseline{baseline(1, 400, 10)}), measurement.ErrMeasurementNotFound)
+
+		assert.NoError(t, repo.AddMeasurement(ctx, &measurement.Measurement{Name: "level", Unit: "cm"}))
+		assert.NoError(t, repo.SaveBaselines(ctx, "level", []measurement.Baseline{baseline(1, 400, 10), baseline(2, 410, 10)}))
+		assert.NoError(t, repo.SaveBaselines(ctx, "level", []measurement.Baseline{baseline(2, 420, 20)}))
+
+		stored, err := repo.GetBaseline(ctx, "level", 2)
+		assert.NoError(t, err)
+		assert.Equal(t, &measurement.Baseline{DayOfYear: 2, WindowDays: 15, Samples: 100, Days: 20, P50: 420, ComputedAt: 20}, stored)
+
+		stored, err = repo.GetBaseline(ctx, "level", 1)
+		assert.NoError(t, err)
+		assert.Nil(t, stored, "days without a new baseline are dropped")
+
+		stored, err = repo.GetBaseline(ctx, "missing", 2)
+		assert.NoError(t, err)
+		assert.Nil(t, stored)
+	})
+
+	t.Run("latest samples skip measurements without samples", func(t *testing.T) {
+		repo := newRepository(t)
+
+		assert.NoError(t, repo.AddMeasurement(ctx, &measurement.Measurement{Name: "empty", Unit: "cm"}))
+		assert.NoError(t, repo.AddTimeseries(ctx, newTimeseries("b", sample(10, 1), sample(30, 3), sample(20, 2))))
+		assert.NoError(t, repo.AddTimeseries(ctx, newTimeseries("a", sample(5, 7))))
+
+		latest, err := repo.GetLatestSamples(ctx)
+		assert.NoError(t, err)
+		if assert.Len(t, latest, 2) {
+			assert.Equal(t, "a", latest[0].Measurement.Name)
+			assert.Equal(t, 7.0, latest[0].Sample.Value)
+			assert.Equal(t, "b", latest[1].Measurement.Name)
+			assert.Equal(t, measurement.Epoch(30), latest[1].Sample.Timestamp)
+			assert.Equal(t, 3.0, latest[1].Sample.Value)
+		}
+	})
+}
@@ -0,0 +1,325 @@
+// Package measurementtest provides an in-memory measurement repository for
+// tests and the contract tests every measurement.Repository has to pass.
+package measurementtest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/timgluz/wasserspiegel/measurement"
+)
+
+// MemoryRepository keeps measurements and their samples in memory.
+type MemoryRepository struct {
+	mu           sync.RWMutex
+	measurements map[string]measurement.Measurement
+	samples      map[int64][]measurement.Sample         // by measurement ID, ordered by timestamp
+	baselines    map[int64]map[int]measurement.Baseline // by measurement ID and day of the year
+	lastID       int64
+	lastSampleID int64
+}
+
+var _ measurement.Repository = (*MemoryRepository)(nil)
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		measurements: make(map[string]measurement.Measurement),
+		samples:      make(map[int64][]measurement.Sample),
+		baselines:    make(map[int64]map[int]measurement.Baseline),
+	}
+}
+
+func (r *MemoryRepository) AddMeasurement(ctx context.Context, m *measurement.Measurement) error {
+	if m == nil {
+		return fmt.Errorf("measurement cannot be nil")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.addMeasurement(*m)
+}
+
+func (r *MemoryRepository) addMeasurement(m measurement.Measurement) error {
+	unit, err := measurement.NormalizeUnit(m.Unit)
+	if err != nil {
+		return err
+	}
+	m.Unit = unit
+
+	if _, ok := r.measurements[m.Name]; ok {
+		return fmt.Errorf("%w: %s", measurement.ErrMeasurementExists, m.Name)
+	}
+
+	r.lastID++
+	m.ID = r.lastID
+	r.measurements[m.Name] = m
+	return nil
+}
+
+func (r *MemoryRepository) AddTimeseries(ctx context.Context, timeseries *measurement.Timeseries) error {
+	if timeseries == nil {
+		return fmt.Errorf("timeseries cannot be nil")
+	}
+	if timeseries.Name == "" {
+		return fmt.Errorf("timeseries name cannot be empty")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.measurements[timeseries.Name]; !ok {
+		if timeseries.Measurement == nil {
+			return fmt.Errorf("measurement cannot be nil for timeseries")
+		}
+		if err := r.addMeasurement(*timeseries.Measurement); err != nil {
+			return err
+		}
+	}
+
+	m, ok := r.measurements[timeseries.Name]
+	if !ok {
+		return fmt.Errorf("measurement not found after adding: %s", timeseries.Name)
+	}
+
+	newSamples, err := timeseries.SamplesInUnit(m.Unit)
+	if err != nil {
+		return err
+	}
+
+	for _, sample := range r.flagNewSamples(m, newSamples) {
+		if sample.Timestamp == 0 {
+			return fmt.Errorf("sample timestamp cannot be zero")
+		}
+
+		samples := r.samples[m.ID]
+		i := sort.Search(len(samples), func(i int) bool { return samples[i].Timestamp >= sample.Timestamp })
+		if i < len(samples) && samples[i].Timestamp == sample.Timestamp {
+			continue // samples are never overwritten
+		}
+
+		r.lastSampleID++
+		sample.ID = r.lastSampleID
+		sample.MeasurementID = m.ID
+
+		samples = append(samples, measurement.Sample{})
+		copy(samples[i+1:], samples[i:])
+		samples[i] = sample
+		r.samples[m.ID] = samples
+	}
+
+	return nil
+}
+
+// flagNewSamples flags the samples that aren't stored yet like the SQL repository.
+func (r *MemoryRepository) flagNewSamples(m measurement.Measurement, samples []measurement.Sample) []measurement.Sample {
+	stored := r.samples[m.ID]
+	var newSamples []measurement.Sample
+	earliest := measurement.Epoch(0)
+	for _, sample := range samples {
+		i := sort.Search(len(stored), func(i int) bool { return stored[i].Timestamp >= sample.Timestamp })
+		if i < len(stored) && stored[i].Timestamp == sample.Timestamp {
+			continue
+		}
+
+		if earliest == 0 || sample.Timestamp < earliest {
+			earliest = sample.Timestamp
+		}
+		newSamples = append(newSamples, sample)
+	}
+
+	end := sort.Search(len(stored), func(i int) bool { return stored[i].Timestamp >= earliest })
+	start := max(0, end-measurement.QualityContextSamples)
+
+	return measurement.QualityPolicyFor(m.Unit).Flag(stored[start:end], newSamples)
+}
+
+func (r *MemoryRepository) GetTimeseries(ctx context.Context, measurementName string, period measurement.Period) (*measurement.Timeseries, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	m, ok := r.measurements[measurementName]
+	if !ok {
+		return nil, nil
+	}
+
+	var samples []measurement.Sample
+	for _, sample := range r.samples[m.ID] {
+		if sample.Timestamp >= period.Start && sample.Timestamp <= period.End {
+			samples = append(samples, sample)
+		}
+	}
+
+	return &measurement.Timeseries{
+		Name:        m.Name,
+		Samples:     samples,
+		Start:       period.Start,
+		End:         period.End,
+		Measurement: &m,
+	}, nil
+}
+
+func (r *MemoryRepository) GetMeasurement(ctx context.Context, measurementName string) (*measurement.Measurement, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	m, ok := r.measurements[measurementName]
+	if !ok {
+		return nil, nil
+	}
+
+	return &m, nil
+}
+
+func (r *MemoryRepository) UpdateMeasurement(ctx context.Context, m *measurement.Measurement) error {
+	if m == nil {
+		return fmt.Errorf("measurement cannot be nil")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.measurements[m.Name]
+	if !ok {
+		return fmt.Errorf("%w: %s", measurement.ErrMeasurementNotFound, m.Name)
+	}
+	if m.Unit != "" {
+		unit, err := measurement.NormalizeUnit(m.Unit)
+		if err != nil {
+			return err
+		}
+		if unit != stored.Unit {
+			return fmt.Errorf("%w: the unit %s of a measurement can't change to %s", measurement.ErrIncompatibleUnits, stored.Unit, unit)
+		}
+	}
+
+	stored.Description = m.Description
+	stored.Labels = m.Labels
+	r.measurements[m.Name] = stored
+	return nil
+}
+
+func (r *MemoryRepository) DeleteMeasurement(ctx context.Context, measurementName string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m, ok := r.measurements[measurementName]
+	if !ok {
+		return fmt.Errorf("%w: %s", measurement.ErrMeasurementNotFound, measurementName)
+	}
+
+	delete(r.samples, m.ID)
+	delete(r.baselines, m.ID)
+	delete(r.measurements, measurementName)
+	return nil
+}
+
+func (r *MemoryRepository) GetMeasurements(ctx context.Context) ([]measurement.Measurement, error) {
+	measurements, _, err := r.FindMeasurements(ctx, measurement.MeasurementFilter{})
+	return measurements, err
+}
+
+func (r *MemoryRepository) FindMeasurements(ctx context.Context, filter measurement.MeasurementFilter) ([]measurement.Measurement, int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var measurements []measurement.Measurement
+	for _, m := range r.measurements {
+		if filter.Matches(m) {
+			measurements = append(measurements, m)
+		}
+	}
+	sort.Slice(measurements, func(i, j int) bool { return measurements[i].Name < measurements[j].Name })
+
+	total := len(measurements)
+	start := min(max(filter.Offset, 0), total)
+	end := total
+	if filter.Limit > 0 {
+		end = min(start+filter.Limit, total)
+	}
+
+	return measurements[start:end], total, nil
+}
+
+func (r *MemoryRepository) GetLatestSamples(ctx context.Context) ([]measurement.LatestSample, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var latest []measurement.LatestSample
+	for _, m := range r.measurements {
+		samples := r.samples[m.ID]
+		if len(samples) == 0 {
+			continue
+		}
+
+		latest = append(latest, measurement.LatestSample{
+			Measurement: summarize(m),
+			Sample:      samples[len(samples)-1],
+		})
+	}
+	sort.Slice(latest, func(i, j int) bool { return latest[i].Measurement.Name < latest[j].Measurement.Name })
+
+	return latest, nil
+}
+
+func (r *MemoryRepository) GetStats(ctx context.Context, measurementName string, buckets []measurement.Bucket) ([]measurement.BucketStats, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	m, ok := r.measurements[measurementName]
+	if !ok {
+		return nil, nil
+	}
+
+	stats := make([]measurement.BucketStats, 0, len(buckets))
+	for _, bucket := range buckets {
+		stats = append(stats, measurement.ComputeBucketStats(bucket, r.samples[m.ID]))
+	}
+
+	return stats, nil
+}
+
+func (r *MemoryRepository) SaveBaselines(ctx context.Context, measurementName string, baselines []measurement.Baseline) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m, ok := r.measurements[measurementName]
+	if !ok {
+		return fmt.Errorf("%w: %s", measurement.ErrMeasurementNotFound, measurementName)
+	}
+
+	byDay := make(map[int]measurement.Baseline, len(baselines))
+	for _, baseline := range baselines {
+		byDay[baseline.DayOfYear] = baseline
+	}
+	r.baselines[m.ID] = byDay
+
+	return nil
+}
+
+func (r *MemoryRepository) GetBaseline(ctx context.Context, measurementName string, dayOfYear int) (*measurement.Baseline, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	m, ok := r.measurements[measurementName]
+	if !ok {
+		return nil, nil
+	}
+
+	baseline, ok := r.baselines[m.ID][dayOfYear]
+	if !ok {
+		return nil, nil
+	}
+
+	return &baseline, nil
+}
+
+func (r *MemoryRepository) IsReady() bool { return true }
+func (r *MemoryRepository) Close() error  { return nil }
+
+// summarize returns the fields of a measurement the SQL repository reads back.
+func summarize(m measurement.Measurement) measurement.Measurement {
+	return measurement.Measurement{ID: m.ID, Name: m.Name, Unit: m.Unit}
+}
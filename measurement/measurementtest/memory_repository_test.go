@@ -0,0 +1,13 @@
+package measurementtest
+
+import (
+	"testing"
+
+	"github.com/timgluz/wasserspiegel/measurement"
+)
+
+func TestMemoryRepositoryContract(t *testing.T) {
+	RunRepositoryContract(t, func(t *testing.T) measurement.Repository {
+		return NewMemoryRepository()
+	})
+}
@@ -0,0 +1,4 @@
+-- quality flags of the samples, see measurement.Flags
+ALTER TABLE samples ADD COLUMN flags INTEGER NOT NULL DEFAULT 0;
+
+PRAGMA user_version = 2;
@@ -0,0 +1,24 @@
+-- percentiles of the samples around every day of the year, see measurement.Baseline
+CREATE TABLE IF NOT EXISTS baselines (
+  measurement_id INTEGER NOT NULL,
+  day_of_year INTEGER NOT NULL,
+  window_days INTEGER NOT NULL,
+  samples INTEGER NOT NULL,
+  days INTEGER NOT NULL,
+  min FLOAT NOT NULL,
+  p5 FLOAT NOT NULL,
+  p10 FLOAT NOT NULL,
+  p25 FLOAT NOT NULL,
+  p50 FLOAT NOT NULL,
+  p75 FLOAT NOT NULL,
+  p90 FLOAT NOT NULL,
+  p95 FLOAT NOT NULL,
+  max FLOAT NOT NULL,
+  history_start INTEGER NOT NULL,
+  history_end INTEGER NOT NULL,
+  computed_at INTEGER NOT NULL,
+  PRIMARY KEY (measurement_id, day_of_year),
+  FOREIGN KEY (measurement_id) REFERENCES measurements (id) ON DELETE CASCADE
+);
+
+PRAGMA user_version = 3;
@@ -0,0 +1,13 @@
+-- labels of the measurements, see measurement.Labels
+ALTER TABLE measurements ADD COLUMN station_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE measurements ADD COLUMN parameter TEXT NOT NULL DEFAULT '';
+ALTER TABLE measurements ADD COLUMN provider TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_measurement_labels ON measurements (station_id, parameter);
+
+-- the water levels collected so far are named waterlevel-<station ID>
+UPDATE measurements
+SET station_id = substr(name, length('waterlevel-') + 1), parameter = 'waterlevel'
+WHERE name LIKE 'waterlevel-%';
+
+PRAGMA user_version = 4;
@@ -47,7 +47,7 @@ func NewFromISO8601Duration(periodStr string) (*Period, error) {
 func ParseEpoch(epochString string) (Epoch, error) {
 	epoch, err := strconv.ParseInt(epochString, 10, 64)
 	if err != nil {
-		return 0, err
+		return 0, fmt.Errorf("%w: %s", ErrInvalidEpoch, epochString)
 	}
 
 	if epoch < 0 {
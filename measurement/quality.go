@@ -0,0 +1,227 @@
+package measurement
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Flags marks the quality problems of a sample; samples without flags are good.
+type Flags uint8
+
+const (
+	FlagRange    Flags = 1 << iota // the value is outside of the plausible range, e.g. -777
+	FlagSpike                      // the value changes faster than possible
+	FlagFlatline                   // the value hasn't changed for too long, the sensor may be stuck or the river steady
+	FlagGap                        // the sample follows a gap, its value is fine
+)
+
+// QualityContextSamples is the number of stored samples before new ones the checks consider.
+const QualityContextSamples = 96
+
+// FlagsInvalid are the flags of samples that shouldn't be displayed or used for calculations.
+// Flatlines and gaps are only notes: gauges report whole centimetres, so a
+// steady or regulated river reads the same value for hours.
+const FlagsInvalid = FlagRange | FlagSpike
+
+var flagNames = []struct {
+	flag Flags
+	name string
+}{
+	{FlagRange, "range"},
+	{FlagSpike, "spike"},
+	{FlagFlatline, "flatline"},
+	{FlagGap, "gap"},
+}
+
+// ParseFlag returns the flag of a name like "spike".
+func ParseFlag(name string) (Flags, error) {
+	for _, item := range flagNames {
+		if strings.EqualFold(strings.TrimSpace(name), item.name) {
+			return item.flag, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown quality flag: %q", name)
+}
+
+// Names returns the names of the set flags.
+func (f Flags) Names() []string {
+	names := []string{}
+	for _, item := range flagNames {
+		if f&item.flag != 0 {
+			names = append(names, item.name)
+		}
+	}
+
+	return names
+}
+
+// Has tells whether any of the given flags is set.
+func (f Flags) Has(flags Flags) bool {
+	return f&flags != 0
+}
+
+// MarshalJSON renders the flags as a list of names, e.g. ["range","gap"].
+func (f Flags) MarshalJSON() ([]byte, error) {
+	return json.Marshal(f.Names())
+}
+
+func (f *Flags) UnmarshalJSON(data []byte) error {
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return fmt.Errorf("quality flags must be a list of names: %w", err)
+	}
+
+	*f = 0
+	for _, name := range names {
+		flag, err := ParseFlag(name)
+		if err != nil {
+			return err
+		}
+		*f |= flag
+	}
+
+	return nil
+}
+
+// QualityPolicy holds the thresholds of the quality checks; a zero MaxRate or
+// FlatlineDuration disables the spike or flatline check.
+type QualityPolicy struct {
+	Min              float64
+	Max              float64
+	MaxRate          float64 // largest plausible change per hour
+	FlatlineDuration time.Duration
+	GapFactor        float64 // a gap is longer than GapFactor times the cadence
+	DefaultCadence   time.Duration
+}
+
+// DefaultQualityPolicy checks water levels in cm; PegelOnline reports
+// missing values as -777 and rivers rarely change more than 30 cm an hour.
+func DefaultQualityPolicy() QualityPolicy {
+	return QualityPolicy{
+		Min:              -100,
+		Max:              2500,
+		MaxRate:          100,
+		FlatlineDuration: 6 * time.Hour,
+		GapFactor:        4,
+		DefaultCadence:   15 * time.Minute,
+	}
+}
+
+// QualityPolicyFor returns the policy for the unit of a measurement, other
+// units than cm are only checked for flatlines and gaps.
+func QualityPolicyFor(unit string) QualityPolicy {
+	policy := DefaultQualityPolicy()
+	if strings.EqualFold(unit, "cm") {
+		return policy
+	}
+
+	policy.Min, policy.Max = math.Inf(-1), math.Inf(1)
+	policy.MaxRate = 0
+	return policy
+}
+
+// Flag sets the flags of the new samples, which must follow the stored
+// previous samples. The previous samples give the context of the checks:
+// the cadence, the last good value and the start of a flatline; their flags
+// aren't changed, so a flatline is flagged from the sample that exceeds the duration.
+func (p QualityPolicy) Flag(previous []Sample, samples []Sample) []Sample {
+	flagged := make([]Sample, len(samples))
+	copy(flagged, samples)
+	sort.SliceStable(flagged, func(i, j int) bool { return flagged[i].Timestamp < flagged[j].Timestamp })
+
+	history := append(append([]Sample{}, previous...), flagged...)
+	cadence, ok := InferCadence(history)
+	if !ok {
+		cadence = p.DefaultCadence
+	}
+	maxInterval := Epoch(p.GapFactor * cadence.Seconds())
+
+	var lastGood, last *Sample
+	flatSince := Epoch(0)
+	for i := range previous {
+		sample := previous[i]
+		if !sample.Flags.Has(FlagRange | FlagSpike) {
+			lastGood = &previous[i]
+		}
+		if last == nil || sample.Value != last.Value {
+			flatSince = sample.Timestamp
+		}
+		last = &previous[i]
+	}
+
+	for i := range flagged {
+		sample := &flagged[i]
+		sample.Flags = 0
+
+		if last != nil && maxInterval > 0 && sample.Timestamp-last.Timestamp > maxInterval {
+			sample.Flags |= FlagGap
+		}
+
+		if sample.Value < p.Min || sample.Value > p.Max || math.IsNaN(sample.Value) {
+			sample.Flags |= FlagRange
+		} else if p.MaxRate > 0 && lastGood != nil && sample.Timestamp > lastGood.Timestamp {
+			hours := float64(sample.Timestamp-lastGood.Timestamp) / 3600
+			if math.Abs(sample.Value-lastGood.Value)/hours > p.MaxRate {
+				sample.Flags |= FlagSpike
+			}
+		}
+
+		if last == nil || sample.Value != last.Value {
+			flatSince = sample.Timestamp
+		} else if p.FlatlineDuration > 0 && sample.Timestamp-flatSince >= Epoch(p.FlatlineDuration.Seconds()) {
+			sample.Flags |= FlagFlatline
+		}
+
+		if !sample.Flags.Has(FlagRange | FlagSpike) {
+			lastGood = sample
+		}
+		last = sample
+	}
+
+	return flagged
+}
+
+// Gap is a span without samples between two samples.
+type Gap struct {
+	Start    Epoch `json:"start"`    // timestamp of the sample before the gap
+	End      Epoch `json:"end"`      // timestamp of the sample after the gap
+	Duration int64 `json:"duration"` // seconds
+}
+
+// DetectGaps returns the gaps between the ordered samples; a gap is longer
+// than GapFactor times the cadence inferred from the samples.
+func (p QualityPolicy) DetectGaps(samples []Sample) []Gap {
+	cadence, ok := InferCadence(samples)
+	if !ok {
+		cadence = p.DefaultCadence
+	}
+	maxInterval := Epoch(p.GapFactor * cadence.Seconds())
+
+	gaps := []Gap{}
+	for i := 1; i < len(samples) && maxInterval > 0; i++ {
+		if interval := samples[i].Timestamp - samples[i-1].Timestamp; interval > maxInterval {
+			gaps = append(gaps, Gap{Start: samples[i-1].Timestamp, End: samples[i].Timestamp, Duration: int64(interval)})
+		}
+	}
+
+	return gaps
+}
+
+// ExcludeFlagged removes the samples with any of the given flags and returns their number.
+func (t *Timeseries) ExcludeFlagged(flags Flags) int {
+	kept := t.Samples[:0]
+	for _, sample := range t.Samples {
+		if !sample.Flags.Has(flags) {
+			kept = append(kept, sample)
+		}
+	}
+
+	excluded := len(t.Samples) - len(kept)
+	t.Samples = kept
+	return excluded
+}
@@ -0,0 +1,54 @@
+package measurement_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/timgluz/wasserspiegel/measurement"
+)
+
+func TestFlagsJSON(t *testing.T) {
+	data, err := json.Marshal(measurement.Sample{Timestamp: 10, Value: -777, Flags: measurement.FlagRange | measurement.FlagGap})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"id":0,"measurement_id":0,"timestamp":10,"value":-777,"flags":["range","gap"]}`, string(data))
+
+	var sample measurement.Sample
+	assert.NoError(t, json.Unmarshal([]byte(`{"timestamp":10,"value":1,"flags":["spike"]}`), &sample))
+	assert.Equal(t, measurement.FlagSpike, sample.Flags)
+	assert.Error(t, json.Unmarshal([]byte(`{"flags":["broken"]}`), &sample))
+}
+
+func TestQualityPolicyFlagsFlatline(t *testing.T) {
+	policy := measurement.DefaultQualityPolicy()
+	samples := samplesEvery(1700000000, time.Hour, 9) // the same value for 8 hours
+
+	flagged := policy.Flag(samples[:3], samples[3:])
+	for i, sample := range flagged {
+		if i+3 < 6 {
+			assert.Equal(t, measurement.Flags(0), sample.Flags, "within 6 hours")
+		} else {
+			assert.Equal(t, measurement.FlagFlatline, sample.Flags)
+		}
+	}
+
+	other := measurement.QualityPolicyFor("°C").Flag(nil, []measurement.Sample{{Timestamp: 1, Value: -777}})
+	assert.Equal(t, measurement.Flags(0), other[0].Flags, "ranges are only known for cm")
+}
+
+func TestQualityPolicyDetectGaps(t *testing.T) {
+	samples := samplesEvery(1700000000, 15*time.Minute, 8)
+	samples = append(samples, measurement.Sample{Timestamp: samples[7].Timestamp + 3*3600})
+
+	gaps := measurement.DefaultQualityPolicy().DetectGaps(samples)
+	if assert.Len(t, gaps, 1) {
+		assert.Equal(t, samples[7].Timestamp, gaps[0].Start)
+		assert.Equal(t, int64(3*3600), gaps[0].Duration)
+	}
+
+	timeseries := measurement.Timeseries{Samples: []measurement.Sample{{Timestamp: 1}, {Timestamp: 2, Flags: measurement.FlagSpike}, {Timestamp: 3, Flags: measurement.FlagGap}}}
+	assert.Equal(t, 1, timeseries.ExcludeFlagged(measurement.FlagsInvalid))
+	assert.Len(t, timeseries.Samples, 2)
+}
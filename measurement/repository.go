@@ -7,8 +7,27 @@ type Repository interface {
 	AddTimeseries(ctx context.Context, timeseries *Timeseries) error
 
 	AddMeasurement(ctx context.Context, measurement *Measurement) error
-	// TODO: we should add pagination to this method
+	// UpdateMeasurement replaces the description and labels of a measurement; the unit
+	// can't change, it fails with ErrIncompatibleUnits or ErrMeasurementNotFound.
+	UpdateMeasurement(ctx context.Context, measurement *Measurement) error
+	// DeleteMeasurement deletes a measurement with its samples and baselines, it fails with ErrMeasurementNotFound.
+	DeleteMeasurement(ctx context.Context, measurementName string) error
+	// GetMeasurement returns the measurement of the name, nil if it's unknown.
+	GetMeasurement(ctx context.Context, measurementName string) (*Measurement, error)
+	// GetMeasurements returns all measurements ordered by name.
 	GetMeasurements(ctx context.Context) ([]Measurement, error)
+	// FindMeasurements returns the page of the measurements matching the filter, ordered
+	// by name, and the total number of matching measurements.
+	FindMeasurements(ctx context.Context, filter MeasurementFilter) ([]Measurement, int, error)
+	// GetLatestSamples returns the most recent sample of every measurement that has samples.
+	GetLatestSamples(ctx context.Context) ([]LatestSample, error)
+	// GetStats summarizes the samples of every bucket, it returns nil for an unknown measurement.
+	GetStats(ctx context.Context, measurementName string, buckets []Bucket) ([]BucketStats, error)
+
+	// SaveBaselines replaces the baselines of a measurement, it fails with ErrMeasurementNotFound.
+	SaveBaselines(ctx context.Context, measurementName string, baselines []Baseline) error
+	// GetBaseline returns the baseline of the day of the year, nil if there is none.
+	GetBaseline(ctx context.Context, measurementName string, dayOfYear int) (*Baseline, error)
 
 	// IsReady checks if the repository is ready for operations.
 	IsReady() bool
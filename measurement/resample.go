@@ -0,0 +1,175 @@
+package measurement
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sosodev/duration"
+)
+
+// Interpolation fills the grid points between two samples.
+type Interpolation string
+
+const (
+	InterpolationLinear   Interpolation = "linear"   // the value on the line between the samples around
+	InterpolationPrevious Interpolation = "previous" // the value of the sample before
+	InterpolationNone     Interpolation = "none"     // only grid points with a sample have a value
+)
+
+const (
+	// MinStep is the smallest step of a grid, the samples have a resolution of seconds.
+	MinStep = time.Minute
+
+	// MaxResamplePoints limits the size of a grid, e.g. 1 year with a step of 1 hour.
+	MaxResamplePoints = 10000
+)
+
+// ParseInterpolation returns the interpolation of a name, linear if the name is empty.
+func ParseInterpolation(name string) (Interpolation, error) {
+	switch interpolation := Interpolation(strings.ToLower(strings.TrimSpace(name))); interpolation {
+	case "":
+		return InterpolationLinear, nil
+	case InterpolationLinear, InterpolationPrevious, InterpolationNone:
+		return interpolation, nil
+	default:
+		return "", fmt.Errorf("%w: %q, expected linear, previous or none", ErrInvalidInterpolation, name)
+	}
+}
+
+// ParseStep parses an ISO 8601 duration like PT15M into a step of at least MinStep.
+func ParseStep(iso8601 string) (time.Duration, error) {
+	parsed, err := duration.Parse(iso8601)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s", ErrInvalidStep, iso8601)
+	}
+
+	step := parsed.ToTimeDuration()
+	if step < MinStep {
+		return 0, fmt.Errorf("%w: %s is shorter than %s", ErrInvalidStep, iso8601, MinStep)
+	}
+
+	return step, nil
+}
+
+// ResampleOptions configure the grid of Resample; a zero MaxGap fills gaps of any length.
+type ResampleOptions struct {
+	Step          time.Duration
+	Interpolation Interpolation
+	MaxGap        time.Duration // longer gaps between samples stay null
+}
+
+// Point is a value on the grid; Value is null if it can't be filled.
+type Point struct {
+	Timestamp    Epoch    `json:"timestamp"`
+	Value        *float64 `json:"value"`
+	Interpolated bool     `json:"interpolated,omitempty"`
+}
+
+// ResampledTimeseries holds the values of a timeseries on a regular grid.
+type ResampledTimeseries struct {
+	Name          string        `json:"name"`
+	Start         Epoch         `json:"start"`
+	End           Epoch         `json:"end"`
+	Step          int64         `json:"step"` // seconds
+	Interpolation Interpolation `json:"interpolation"`
+	MaxGap        int64         `json:"max_gap,omitempty"` // seconds
+	Points        []Point       `json:"points"`
+	Gaps          []Gap         `json:"gaps,omitempty"`
+
+	Measurement *Measurement `json:"measurement,omitempty"`
+}
+
+// Resample returns the values at the multiples of the step between the start and
+// end of the timeseries, so series of different stations share the grid. A grid
+// point without a sample is filled by the interpolation, unless the samples around
+// it are further apart than MaxGap. Without a period the grid spans the samples.
+func (t *Timeseries) Resample(opts ResampleOptions) (*ResampledTimeseries, error) {
+	if opts.Step < MinStep {
+		return nil, fmt.Errorf("%w: %s is shorter than %s", ErrInvalidStep, opts.Step, MinStep)
+	}
+	if opts.Interpolation == "" {
+		opts.Interpolation = InterpolationLinear
+	}
+
+	samples := make([]Sample, len(t.Samples))
+	copy(samples, t.Samples)
+	sort.SliceStable(samples, func(i, j int) bool { return samples[i].Timestamp < samples[j].Timestamp })
+
+	start, end := t.Start, t.End
+	if start == 0 && end == 0 && len(samples) > 0 {
+		start, end = samples[0].Timestamp, samples[len(samples)-1].Timestamp
+	}
+
+	step := Epoch(opts.Step.Seconds())
+	first := (start + step - 1) / step * step // the first multiple of the step at or after the start
+	if end >= first && int64((end-first)/step)+1 > MaxResamplePoints {
+		return nil, fmt.Errorf("%w: more than %d points, use a longer step", ErrTooManyPoints, MaxResamplePoints)
+	}
+
+	resampled := &ResampledTimeseries{
+		Name:          t.Name,
+		Start:         start,
+		End:           end,
+		Step:          int64(step),
+		Interpolation: opts.Interpolation,
+		MaxGap:        int64(opts.MaxGap.Seconds()),
+		Points:        []Point{},
+		Gaps:          t.Gaps,
+		Measurement:   t.Measurement,
+	}
+
+	next := 0 // index of the first sample at or after the grid point
+	for ts := first; ts <= end; ts += step {
+		for next < len(samples) && samples[next].Timestamp < ts {
+			next++
+		}
+
+		point := Point{Timestamp: ts}
+		switch {
+		case next < len(samples) && samples[next].Timestamp == ts:
+			point.Value = floatPtr(samples[next].Value)
+		case next > 0:
+			point.Value = opts.fill(samples[next-1], samples[next:], ts)
+			point.Interpolated = point.Value != nil
+		}
+
+		resampled.Points = append(resampled.Points, point)
+	}
+
+	return resampled, nil
+}
+
+// fill interpolates the value at ts between the sample before and the first of the samples after it.
+func (opts ResampleOptions) fill(before Sample, after []Sample, ts Epoch) *float64 {
+	maxGap := Epoch(opts.MaxGap.Seconds())
+
+	switch opts.Interpolation {
+	case InterpolationPrevious:
+		span := ts - before.Timestamp
+		if len(after) > 0 {
+			span = after[0].Timestamp - before.Timestamp
+		}
+		if maxGap > 0 && span > maxGap {
+			return nil
+		}
+		return floatPtr(before.Value)
+	case InterpolationLinear:
+		if len(after) == 0 {
+			return nil // nothing to interpolate to after the last sample
+		}
+		span := after[0].Timestamp - before.Timestamp
+		if maxGap > 0 && span > maxGap {
+			return nil
+		}
+		ratio := float64(ts-before.Timestamp) / float64(span)
+		return floatPtr(before.Value + ratio*(after[0].Value-before.Value))
+	default:
+		return nil
+	}
+}
+
+func floatPtr(value float64) *float64 {
+	return &value
+}
@@ -0,0 +1,78 @@
+package measurement_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/timgluz/wasserspiegel/measurement"
+)
+
+func pointValues(points []measurement.Point) []any {
+	values := make([]any, 0, len(points))
+	for _, point := range points {
+		if point.Value == nil {
+			values = append(values, nil)
+			continue
+		}
+		values = append(values, *point.Value)
+	}
+
+	return values
+}
+
+func TestTimeseriesResample(t *testing.T) {
+	// samples at 22:05, 22:30 and 00:30, the grid is at the full quarters
+	timeseries := measurement.Timeseries{
+		Name:  "bonn",
+		Start: 1699999200, // 22:00
+		End:   1700010000, // 01:00
+		Samples: []measurement.Sample{
+			{Timestamp: 1699999500, Value: 400},
+			{Timestamp: 1700001000, Value: 450},
+			{Timestamp: 1700008200, Value: 500},
+		},
+	}
+
+	linear, err := timeseries.Resample(measurement.ResampleOptions{Step: 15 * time.Minute, MaxGap: time.Hour})
+	assert.NoError(t, err)
+	assert.Equal(t, measurement.InterpolationLinear, linear.Interpolation)
+	assert.Len(t, linear.Points, 13)
+	assert.Equal(t, measurement.Epoch(1699999200), linear.Points[0].Timestamp)
+	assert.Equal(t, []any{nil, 420.0, 450.0, nil}, pointValues(linear.Points[:4]), "nothing before the first sample, then the gap exceeds max_gap")
+	assert.True(t, linear.Points[1].Interpolated)
+	assert.False(t, linear.Points[2].Interpolated, "a sample on the grid is taken as is")
+
+	previous, err := timeseries.Resample(measurement.ResampleOptions{Step: 15 * time.Minute, Interpolation: measurement.InterpolationPrevious})
+	assert.NoError(t, err)
+	assert.Equal(t, []any{nil, 400.0, 450.0, 450.0}, pointValues(previous.Points[:4]))
+	assert.Equal(t, 500.0, *previous.Points[12].Value, "without max_gap the last value is carried on")
+
+	none, err := timeseries.Resample(measurement.ResampleOptions{Step: 15 * time.Minute, Interpolation: measurement.InterpolationNone})
+	assert.NoError(t, err)
+	assert.Equal(t, []any{nil, nil, 450.0, nil}, pointValues(none.Points[:4]))
+
+	_, err = timeseries.Resample(measurement.ResampleOptions{Step: time.Second})
+	assert.ErrorIs(t, err, measurement.ErrInvalidStep)
+
+	timeseries.End = timeseries.Start + measurement.Epoch(time.Duration(measurement.MaxResamplePoints)*time.Minute/time.Second)
+	_, err = timeseries.Resample(measurement.ResampleOptions{Step: time.Minute})
+	assert.ErrorIs(t, err, measurement.ErrTooManyPoints)
+}
+
+func TestParseStepAndInterpolation(t *testing.T) {
+	step, err := measurement.ParseStep("PT15M")
+	assert.NoError(t, err)
+	assert.Equal(t, 15*time.Minute, step)
+
+	_, err = measurement.ParseStep("PT30S")
+	assert.ErrorIs(t, err, measurement.ErrInvalidStep)
+
+	interpolation, err := measurement.ParseInterpolation("")
+	assert.NoError(t, err)
+	assert.Equal(t, measurement.InterpolationLinear, interpolation)
+
+	_, err = measurement.ParseInterpolation("cubic")
+	assert.ErrorIs(t, err, measurement.ErrInvalidInterpolation)
+}
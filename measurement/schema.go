@@ -0,0 +1,121 @@
+package measurement
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+// SchemaVersion is the user_version set by schema.sql.
+const SchemaVersion = 4
+
+// SchemaTables are the tables created by schema.sql.
+var SchemaTables = []string{"measurements", "samples", "baselines", "service_metrics"}
+
+// Schema creates the tables of measurements, samples, baselines and service metrics; all statements are idempotent.
+//
+//go:embed schema.sql
+var Schema string
+
+// migrations upgrade older databases, <version>_<name>.sql migrates to the version.
+//
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// ApplySchema migrates an older database and creates the missing tables and indexes,
+// e.g. when a native server starts with an empty database.
+func ApplySchema(ctx context.Context, db *sql.DB) error {
+	version, _ := CheckSchema(ctx, db)
+	if version == 0 {
+		// a new database has no measurements table, the tables of schema.sql are the latest;
+		// the first native servers created the tables of version 1 without setting it
+		exists, err := hasTable(ctx, db, "measurements")
+		if err != nil {
+			return err
+		}
+		if exists {
+			version = 1
+		}
+	}
+
+	if version > 0 && version < SchemaVersion {
+		if err := Migrate(ctx, db, version); err != nil {
+			return err
+		}
+	}
+
+	if _, err := db.ExecContext(ctx, Schema); err != nil {
+		return fmt.Errorf("failed to apply measurement schema: %w", err)
+	}
+
+	return nil
+}
+
+// Migrate applies the migrations after the given version in order.
+func Migrate(ctx context.Context, db *sql.DB, version int) error {
+	files, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return fmt.Errorf("failed to list migrations: %w", err)
+	}
+
+	for _, file := range files {
+		name := strings.TrimPrefix(file, "migrations/")
+		target, err := strconv.Atoi(strings.SplitN(name, "_", 2)[0])
+		if err != nil {
+			return fmt.Errorf("invalid migration name %s: %w", name, err)
+		}
+		if target <= version {
+			continue
+		}
+
+		statements, err := migrations.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read migration %s: %w", name, err)
+		}
+		if _, err := db.ExecContext(ctx, string(statements)); err != nil {
+			return fmt.Errorf("failed to apply migration %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// CheckSchema returns the schema version of the DB; it fails with ErrSchemaIncomplete
+// if tables are missing and with ErrSchemaOutdated if the version is older than SchemaVersion.
+func CheckSchema(ctx context.Context, db *sql.DB) (int, error) {
+	var version int
+	if err := db.QueryRowContext(ctx, `PRAGMA user_version`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	for _, table := range SchemaTables {
+		exists, err := hasTable(ctx, db, table)
+		if err != nil {
+			return version, err
+		}
+
+		if !exists {
+			return version, fmt.Errorf("%w: table %s is missing", ErrSchemaIncomplete, table)
+		}
+	}
+
+	if version < SchemaVersion {
+		return version, fmt.Errorf("%w: version %d, expected %d", ErrSchemaOutdated, version, SchemaVersion)
+	}
+
+	return version, nil
+}
+
+func hasTable(ctx context.Context, db *sql.DB, table string) (bool, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`
+	if err := db.QueryRowContext(ctx, query, table).Scan(&count); err != nil {
+		return false, fmt.Errorf("failed to look up table %s: %w", table, err)
+	}
+
+	return count > 0, nil
+}
@@ -0,0 +1,70 @@
+-- SQL queries to set up sqlite database schema for a measurement system
+-- measurement table holds the metadata for each measurement
+CREATE TABLE IF NOT EXISTS measurements (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  name VARCHAR(255) NOT NULL,
+  description TEXT,
+  unit VARCHAR(50) NOT NULL,
+  -- labels, see measurement.Labels
+  station_id TEXT NOT NULL DEFAULT '',
+  parameter TEXT NOT NULL DEFAULT '',
+  provider TEXT NOT NULL DEFAULT ''
+);
+
+-- index for faster lookups by name
+CREATE UNIQUE INDEX IF NOT EXISTS idx_measurement_name ON measurements (name);
+CREATE INDEX IF NOT EXISTS idx_measurement_labels ON measurements (station_id, parameter);
+
+-- sample table holds the actual measurement data
+-- each sample is linked to a measurement by measurement_id
+CREATE TABLE IF NOT EXISTS samples (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  measurement_id int NOT NULL,
+  ts INTEGER NOT NULL,
+  value FLOAT NOT NULL,
+  flags INTEGER NOT NULL DEFAULT 0, -- quality flags, see measurement.Flags
+  FOREIGN KEY (measurement_id) REFERENCES measurements (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_measurement_id ON samples (measurement_id, ts);
+
+-- baselines hold the percentiles of the samples of all years around every day
+-- of the year, they are recomputed by the buildBaselines task
+CREATE TABLE IF NOT EXISTS baselines (
+  measurement_id INTEGER NOT NULL,
+  day_of_year INTEGER NOT NULL,
+  window_days INTEGER NOT NULL,
+  samples INTEGER NOT NULL,
+  days INTEGER NOT NULL,
+  min FLOAT NOT NULL,
+  p5 FLOAT NOT NULL,
+  p10 FLOAT NOT NULL,
+  p25 FLOAT NOT NULL,
+  p50 FLOAT NOT NULL,
+  p75 FLOAT NOT NULL,
+  p90 FLOAT NOT NULL,
+  p95 FLOAT NOT NULL,
+  max FLOAT NOT NULL,
+  history_start INTEGER NOT NULL,
+  history_end INTEGER NOT NULL,
+  computed_at INTEGER NOT NULL,
+  PRIMARY KEY (measurement_id, day_of_year),
+  FOREIGN KEY (measurement_id) REFERENCES measurements (id) ON DELETE CASCADE
+);
+
+-- service_metrics holds counters and summaries reported by the components,
+-- e.g. collector runs or provider latency, and is exposed by the metrics component
+CREATE TABLE IF NOT EXISTS service_metrics (
+  name VARCHAR(255) NOT NULL,
+  suffix VARCHAR(50) NOT NULL DEFAULT '',
+  labels TEXT NOT NULL DEFAULT '[]',
+  type VARCHAR(20) NOT NULL,
+  help TEXT,
+  value FLOAT NOT NULL DEFAULT 0,
+  updated_at INTEGER NOT NULL,
+  PRIMARY KEY (name, suffix, labels)
+);
+
+-- version of this schema, compared by the health checks; increase it with every change
+-- and add a migration of older databases to measurement/migrations
+PRAGMA user_version = 4;
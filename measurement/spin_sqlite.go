@@ -0,0 +1,20 @@
+//go:build tinygo || wasm
+
+package measurement
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/spinframework/spin-go-sdk/v2/sqlite"
+)
+
+func NewSpinSqliteDB(dbName string) (*sql.DB, error) {
+	db := sqlite.Open(dbName)
+	// Check if the database is reachable
+	if err := db.Ping(); err != nil {
+		return nil, fmt.Errorf("failed to ping SQLite DB: %w", err)
+	}
+
+	return db, nil
+}
@@ -1,14 +1,15 @@
-//go:build tinygo || wasm
-
 package measurement
 
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"time"
 
-	"github.com/spinframework/spin-go-sdk/v2/sqlite"
+	"github.com/timgluz/wasserspiegel/health"
 )
 
 type SQLRepository struct {
@@ -16,16 +17,6 @@ type SQLRepository struct {
 	logger *slog.Logger
 }
 
-func NewSpinSqliteDB(dbName string) (*sql.DB, error) {
-	db := sqlite.Open(dbName)
-	// Check if the database is reachable
-	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("failed to ping SQLite DB: %w", err)
-	}
-
-	return db, nil
-}
-
 func NewSqlRepository(db *sql.DB, logger *slog.Logger) (*SQLRepository, error) {
 	if db == nil {
 		logger.Error("SQL DB is not initialized")
@@ -49,11 +40,45 @@ func (r *SQLRepository) IsReady() bool {
 		return false
 	}
 
-	// TODO: check if tables exists
-
 	return true
 }
 
+// CheckHealth pings the DB, compares the schema version and reports the time of the newest sample.
+// An outdated schema degrades the component, missing tables take it down.
+func (r *SQLRepository) CheckHealth(ctx context.Context) health.Check {
+	start := time.Now()
+	if r.db == nil {
+		return health.Down("measurements", ErrDBNotAvailable)
+	}
+
+	if err := r.db.PingContext(ctx); err != nil {
+		return health.Down("measurements", fmt.Errorf("failed to ping SQLite DB: %w", err)).WithLatency(time.Since(start))
+	}
+
+	check := health.Up("measurements")
+	version, err := CheckSchema(ctx, r.db)
+	switch {
+	case errors.Is(err, ErrSchemaOutdated):
+		check = health.Degraded("measurements", err.Error())
+	case err != nil:
+		return health.Down("measurements", err).WithLatency(time.Since(start))
+	}
+
+	var newest sql.NullInt64
+	if err := r.db.QueryRowContext(ctx, `SELECT MAX(ts) FROM samples`).Scan(&newest); err != nil {
+		return health.Down("measurements", fmt.Errorf("failed to read newest sample: %w", err)).WithLatency(time.Since(start))
+	}
+
+	newestSample := time.Time{}
+	if newest.Valid {
+		newestSample = time.Unix(newest.Int64, 0)
+	}
+
+	return check.WithLatency(time.Since(start)).
+		WithDetail("schema_version", version).
+		WithAge("newest_sample", newestSample, time.Now())
+}
+
 func (r *SQLRepository) Close() error {
 	if r.db == nil {
 		return fmt.Errorf("SQLite DB is not initialized")
@@ -115,8 +140,20 @@ func (r *SQLRepository) AddTimeseries(ctx context.Context, timeseries *Timeserie
 		return fmt.Errorf("measurement not found after adding: %s", measurementName)
 	}
 
+	newSamples, err := timeseries.SamplesInUnit(measurement.Unit)
+	if err != nil {
+		r.logger.Error("Failed to convert samples to the unit of the measurement", "name", measurementName, "unit", measurement.Unit, "error", err)
+		return err
+	}
+
+	samples, err := r.flagNewSamples(measurement, newSamples)
+	if err != nil {
+		r.logger.Error("Failed to check quality of samples", "name", measurementName, "error", err)
+		return err
+	}
+
 	// Insert samples into the database
-	for _, sample := range timeseries.Samples {
+	for _, sample := range samples {
 		if err := r.addSample(measurement.ID, sample); err != nil {
 			r.logger.Error("Failed to add sample", "sample", sample, "error", err)
 			return err
@@ -158,6 +195,49 @@ func (r *SQLRepository) GetTimeseries(ctx context.Context, measurementName strin
 	return timeseries, nil
 }
 
+// flagNewSamples drops the stored samples, they keep their flags, and flags the
+// new ones with the stored samples before them as context.
+func (r *SQLRepository) flagNewSamples(measurement *Measurement, samples []Sample) ([]Sample, error) {
+	var newSamples []Sample
+	earliest := Epoch(0)
+	for _, sample := range samples {
+		if sample.Timestamp == 0 {
+			return nil, fmt.Errorf("sample timestamp cannot be zero")
+		}
+
+		ok, err := r.hasSample(measurement.ID, sample.Timestamp)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			continue
+		}
+
+		if earliest == 0 || sample.Timestamp < earliest {
+			earliest = sample.Timestamp
+		}
+		newSamples = append(newSamples, sample)
+	}
+
+	if len(newSamples) == 0 {
+		return nil, nil
+	}
+
+	previous, err := r.getSamplesBefore(measurement.ID, earliest, QualityContextSamples)
+	if err != nil {
+		return nil, err
+	}
+
+	flagged := QualityPolicyFor(measurement.Unit).Flag(previous, newSamples)
+	for _, sample := range flagged {
+		if sample.Flags.Has(FlagsInvalid) {
+			r.logger.Warn("Sample failed quality checks", "measurement", measurement.Name, "timestamp", sample.Timestamp, "value", sample.Value, "flags", sample.Flags.Names())
+		}
+	}
+
+	return flagged, nil
+}
+
 // hasMeasurement checks if a measurement with the given name exists.
 func (r *SQLRepository) hasMeasurement(name string) (bool, error) {
 	query := `SELECT COUNT(*) FROM measurements WHERE name = ?`
@@ -182,9 +262,23 @@ func (r *SQLRepository) AddMeasurement(ctx context.Context, measurement *Measure
 		r.logger.Error("Cannot add nil measurement")
 		return fmt.Errorf("measurement cannot be nil")
 	}
-	query := `INSERT INTO measurements (name, unit, description) VALUES (?, ?, ?)`
-	_, err := r.db.Exec(query, measurement.Name, measurement.Unit, measurement.Description)
+
+	unit, err := NormalizeUnit(measurement.Unit)
+	if err != nil {
+		return err
+	}
+
+	ok, err := r.hasMeasurement(measurement.Name)
 	if err != nil {
+		return err
+	}
+	if ok {
+		return fmt.Errorf("%w: %s", ErrMeasurementExists, measurement.Name)
+	}
+
+	query := `INSERT INTO measurements (name, unit, description, station_id, parameter, provider) VALUES (?, ?, ?, ?, ?, ?)`
+	labels := measurement.Labels
+	if _, err := r.db.Exec(query, measurement.Name, unit, measurement.Description, labels.StationID, labels.Parameter, labels.Provider); err != nil {
 		r.logger.Error("Failed to insert measurement", "measurement", measurement, "error", err)
 		return err
 	}
@@ -192,26 +286,152 @@ func (r *SQLRepository) AddMeasurement(ctx context.Context, measurement *Measure
 	return nil
 }
 
+// GetMeasurement retrieves the measurement of the name, nil if it's unknown.
+func (r *SQLRepository) GetMeasurement(ctx context.Context, measurementName string) (*Measurement, error) {
+	defer ctx.Done()
+
+	return r.getMeasurementByName(measurementName)
+}
+
+// UpdateMeasurement replaces the description and labels of the measurement.
+func (r *SQLRepository) UpdateMeasurement(ctx context.Context, measurement *Measurement) error {
+	defer ctx.Done()
+
+	if measurement == nil {
+		return fmt.Errorf("measurement cannot be nil")
+	}
+
+	stored, err := r.getMeasurementByName(measurement.Name)
+	if err != nil {
+		return err
+	}
+	if stored == nil {
+		return fmt.Errorf("%w: %s", ErrMeasurementNotFound, measurement.Name)
+	}
+	if err := checkUnitUnchanged(stored.Unit, measurement.Unit); err != nil {
+		return err
+	}
+
+	query := `UPDATE measurements SET description = ?, station_id = ?, parameter = ?, provider = ? WHERE id = ?`
+	labels := measurement.Labels
+	if _, err := r.db.Exec(query, measurement.Description, labels.StationID, labels.Parameter, labels.Provider, stored.ID); err != nil {
+		r.logger.Error("Failed to update measurement", "measurement", measurement, "error", err)
+		return err
+	}
+
+	r.logger.Info("Measurement updated", "name", measurement.Name)
+	return nil
+}
+
+// DeleteMeasurement deletes the measurement with its samples and baselines. The
+// driver of Spin has no transactions and may not enforce foreign keys, so the
+// samples and baselines are deleted first and a failed delete can be retried.
+func (r *SQLRepository) DeleteMeasurement(ctx context.Context, measurementName string) error {
+	defer ctx.Done()
+
+	stored, err := r.getMeasurementByName(measurementName)
+	if err != nil {
+		return err
+	}
+	if stored == nil {
+		return fmt.Errorf("%w: %s", ErrMeasurementNotFound, measurementName)
+	}
+
+	for _, query := range []string{
+		`DELETE FROM samples WHERE measurement_id = ?`,
+		`DELETE FROM baselines WHERE measurement_id = ?`,
+		`DELETE FROM measurements WHERE id = ?`,
+	} {
+		if _, err := r.db.Exec(query, stored.ID); err != nil {
+			r.logger.Error("Failed to delete measurement", "name", measurementName, "error", err)
+			return err
+		}
+	}
+
+	r.logger.Info("Measurement deleted", "name", measurementName)
+	return nil
+}
+
 // GetMeasurements retrieves all measurements from the database.
 func (r *SQLRepository) GetMeasurements(ctx context.Context) ([]Measurement, error) {
+	measurements, _, err := r.FindMeasurements(ctx, MeasurementFilter{})
+	return measurements, err
+}
+
+// FindMeasurements retrieves the page of the measurements with the labels of the filter.
+func (r *SQLRepository) FindMeasurements(ctx context.Context, filter MeasurementFilter) ([]Measurement, int, error) {
 	defer ctx.Done()
 
-	query := `SELECT id, name, unit FROM measurements ORDER BY name`
-	rows, err := r.db.Query(query)
+	labels := filter.Labels
+	where := `WHERE (?1 = '' OR station_id = ?1) AND (?2 = '' OR parameter = ?2) AND (?3 = '' OR provider = ?3) AND (?4 = '' OR unit = ?4)`
+	args := []any{labels.StationID, labels.Parameter, labels.Provider, filter.Unit}
+
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM measurements `+where, args...).Scan(&total); err != nil {
+		r.logger.Error("Failed to count measurements", "error", err)
+		return nil, 0, err
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = -1 // no limit in SQLite
+	}
+	query := `SELECT ` + measurementColumns + ` FROM measurements ` + where + ` ORDER BY name LIMIT ?5 OFFSET ?6`
+	rows, err := r.db.Query(query, append(args, limit, max(filter.Offset, 0))...)
 	if err != nil {
 		r.logger.Error("Failed to query measurements", "error", err)
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
 	var measurements []Measurement
 	for rows.Next() {
-		var measurement Measurement
-		if err := rows.Scan(&measurement.ID, &measurement.Name, &measurement.Unit); err != nil {
+		measurement, err := scanMeasurement(rows)
+		if err != nil {
 			r.logger.Error("Failed to scan measurement row", "error", err)
+			return nil, 0, err
+		}
+		measurements = append(measurements, *measurement)
+	}
+
+	if err := rows.Err(); err != nil {
+		r.logger.Error("Error occurred during row iteration", "error", err)
+		return nil, 0, err
+	}
+
+	r.logger.Info("Measurements retrieved successfully", "count", len(measurements), "total", total)
+	return measurements, total, nil
+}
+
+// GetLatestSamples retrieves the most recent sample for each measurement.
+func (r *SQLRepository) GetLatestSamples(ctx context.Context) ([]LatestSample, error) {
+	defer ctx.Done()
+
+	query := `
+SELECT m.id, m.name, m.unit, s.id, s.measurement_id, s.value, s.ts, s.flags
+FROM measurements m
+JOIN samples s ON s.measurement_id = m.id
+WHERE s.ts = (SELECT MAX(ts) FROM samples WHERE measurement_id = m.id)
+ORDER BY m.name`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		r.logger.Error("Failed to query latest samples", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var latest []LatestSample
+	for rows.Next() {
+		var item LatestSample
+		if err := rows.Scan(
+			&item.Measurement.ID, &item.Measurement.Name, &item.Measurement.Unit,
+			&item.Sample.ID, &item.Sample.MeasurementID, &item.Sample.Value, &item.Sample.Timestamp, &item.Sample.Flags,
+		); err != nil {
+			r.logger.Error("Failed to scan latest sample row", "error", err)
 			return nil, err
 		}
-		measurements = append(measurements, measurement)
+		latest = append(latest, item)
 	}
 
 	if err := rows.Err(); err != nil {
@@ -219,17 +439,195 @@ func (r *SQLRepository) GetMeasurements(ctx context.Context) ([]Measurement, err
 		return nil, err
 	}
 
-	r.logger.Info("Measurements retrieved successfully", "count", len(measurements))
-	return measurements, nil
+	r.logger.Info("Latest samples retrieved successfully", "count", len(latest))
+	return latest, nil
+}
+
+// statsQuery ranks the good samples of every bucket by value and keeps the rows
+// of the minimum, the maximum and the neighbours of the percentiles; the buckets
+// are a JSON array of [start, end) pairs.
+const statsQuery = `
+WITH buckets AS (
+	SELECT CAST(key AS INTEGER) AS idx,
+		json_extract(value, '$[0]') AS start_ts,
+		json_extract(value, '$[1]') AS end_ts
+	FROM json_each(?)
+),
+ranked AS (
+	SELECT b.idx, s.value, s.ts,
+		ROW_NUMBER() OVER (PARTITION BY b.idx ORDER BY s.value, s.ts) - 1 AS value_rank,
+		ROW_NUMBER() OVER (PARTITION BY b.idx ORDER BY s.value DESC, s.ts) - 1 AS max_rank,
+		COUNT(*) OVER (PARTITION BY b.idx) AS count,
+		AVG(s.value) OVER (PARTITION BY b.idx) AS mean
+	FROM buckets b
+	JOIN samples s ON s.measurement_id = ? AND s.ts >= b.start_ts AND s.ts < b.end_ts
+	WHERE (s.flags & ?) = 0
+)
+SELECT idx, value_rank, max_rank, count, mean, value, ts
+FROM ranked
+WHERE max_rank = 0
+	OR value_rank IN (
+		CAST(0.05 * (count - 1) AS INTEGER), CAST(0.05 * (count - 1) AS INTEGER) + 1,
+		CAST(0.5 * (count - 1) AS INTEGER), CAST(0.5 * (count - 1) AS INTEGER) + 1,
+		CAST(0.95 * (count - 1) AS INTEGER), CAST(0.95 * (count - 1) AS INTEGER) + 1,
+		0
+	)
+ORDER BY idx, value_rank`
+
+// GetStats aggregates the buckets in SQLite, only the interpolation of the percentiles is done here.
+func (r *SQLRepository) GetStats(ctx context.Context, measurementName string, buckets []Bucket) ([]BucketStats, error) {
+	measurement, err := r.getMeasurementByName(measurementName)
+	if err != nil {
+		r.logger.Error("Failed to get measurement by name", "name", measurementName, "error", err)
+		return nil, err
+	}
+	if measurement == nil {
+		r.logger.Info("Measurement not found", "name", measurementName)
+		return nil, nil
+	}
+
+	spans := make([][2]int64, len(buckets))
+	for i, bucket := range buckets {
+		spans[i] = [2]int64{int64(bucket.Start), int64(bucket.End)}
+	}
+	spansJSON, err := json.Marshal(spans)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode buckets: %w", err)
+	}
+
+	rows, err := r.db.QueryContext(ctx, statsQuery, string(spansJSON), measurement.ID, int64(FlagsInvalid))
+	if err != nil {
+		r.logger.Error("Failed to query stats", "name", measurementName, "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := make([]BucketStats, len(buckets))
+	values := make([]map[int]float64, len(buckets)) // by value rank
+	for i, bucket := range buckets {
+		stats[i] = BucketStats{Bucket: bucket}
+		values[i] = make(map[int]float64)
+	}
+
+	for rows.Next() {
+		var (
+			idx, valueRank, maxRank, count int
+			mean, value                    float64
+			ts                             Epoch
+		)
+		if err := rows.Scan(&idx, &valueRank, &maxRank, &count, &mean, &value, &ts); err != nil {
+			r.logger.Error("Failed to scan stats row", "error", err)
+			return nil, err
+		}
+		if idx < 0 || idx >= len(buckets) {
+			return nil, fmt.Errorf("stats row of unknown bucket %d", idx)
+		}
+
+		bucketStats := &stats[idx]
+		bucketStats.Count = count
+		bucketStats.Mean = floatPtr(roundHundredths(mean))
+		values[idx][valueRank] = value
+		if valueRank == 0 {
+			bucketStats.Min = &Extreme{Value: value, Timestamp: ts}
+		}
+		if maxRank == 0 {
+			bucketStats.Max = &Extreme{Value: value, Timestamp: ts}
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		r.logger.Error("Error occurred during row iteration", "error", err)
+		return nil, err
+	}
+
+	for i := range stats {
+		if stats[i].Count > 0 {
+			stats[i].setPercentiles(func(rank int) float64 { return values[i][rank] })
+		}
+	}
+
+	r.logger.Info("Stats retrieved successfully", "name", measurementName, "buckets", len(buckets))
+	return stats, nil
+}
+
+// SaveBaselines upserts the baselines in one statement, so readers never see a
+// partial update, and drops the days that lost their baseline.
+func (r *SQLRepository) SaveBaselines(ctx context.Context, measurementName string, baselines []Baseline) error {
+	measurement, err := r.getMeasurementByName(measurementName)
+	if err != nil {
+		r.logger.Error("Failed to get measurement by name", "name", measurementName, "error", err)
+		return err
+	}
+	if measurement == nil {
+		return fmt.Errorf("%w: %s", ErrMeasurementNotFound, measurementName)
+	}
+
+	computedAt := CurrentEpoch()
+	if len(baselines) > 0 {
+		baselinesJSON, err := json.Marshal(baselines)
+		if err != nil {
+			return fmt.Errorf("failed to encode baselines: %w", err)
+		}
+
+		query := `
+INSERT OR REPLACE INTO baselines (measurement_id, day_of_year, window_days, samples, days,
+	min, p5, p10, p25, p50, p75, p90, p95, max, history_start, history_end, computed_at)
+SELECT ?, json_extract(value, '$.day_of_year'), json_extract(value, '$.window_days'),
+	json_extract(value, '$.samples'), json_extract(value, '$.days'),
+	json_extract(value, '$.min'), json_extract(value, '$.p5'), json_extract(value, '$.p10'),
+	json_extract(value, '$.p25'), json_extract(value, '$.p50'), json_extract(value, '$.p75'),
+	json_extract(value, '$.p90'), json_extract(value, '$.p95'), json_extract(value, '$.max'),
+	json_extract(value, '$.history_start'), json_extract(value, '$.history_end'), json_extract(value, '$.computed_at')
+FROM json_each(?)`
+		if _, err := r.db.ExecContext(ctx, query, measurement.ID, string(baselinesJSON)); err != nil {
+			r.logger.Error("Failed to save baselines", "name", measurementName, "error", err)
+			return err
+		}
+		computedAt = baselines[0].ComputedAt
+	}
+
+	query := `DELETE FROM baselines WHERE measurement_id = ? AND computed_at < ?`
+	if _, err := r.db.ExecContext(ctx, query, measurement.ID, int64(computedAt)); err != nil {
+		r.logger.Error("Failed to delete outdated baselines", "name", measurementName, "error", err)
+		return err
+	}
+
+	r.logger.Info("Baselines saved successfully", "name", measurementName, "count", len(baselines))
+	return nil
+}
+
+// GetBaseline retrieves the baseline of a day of the year.
+func (r *SQLRepository) GetBaseline(ctx context.Context, measurementName string, dayOfYear int) (*Baseline, error) {
+	query := `
+SELECT b.day_of_year, b.window_days, b.samples, b.days, b.min, b.p5, b.p10, b.p25, b.p50,
+	b.p75, b.p90, b.p95, b.max, b.history_start, b.history_end, b.computed_at
+FROM baselines b
+JOIN measurements m ON m.id = b.measurement_id
+WHERE m.name = ? AND b.day_of_year = ?`
+
+	var b Baseline
+	err := r.db.QueryRowContext(ctx, query, measurementName, dayOfYear).Scan(
+		&b.DayOfYear, &b.WindowDays, &b.Samples, &b.Days, &b.Min, &b.P5, &b.P10, &b.P25, &b.P50,
+		&b.P75, &b.P90, &b.P95, &b.Max, &b.HistoryStart, &b.HistoryEnd, &b.ComputedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		r.logger.Error("Failed to get baseline", "name", measurementName, "day_of_year", dayOfYear, "error", err)
+		return nil, err
+	}
+
+	return &b, nil
 }
 
 // GetMeasurementByID retrieves a measurement by its ID.
 func (r *SQLRepository) getMeasurementByName(id string) (*Measurement, error) {
-	query := `SELECT id, name, unit FROM measurements WHERE name = ?`
+	query := `SELECT ` + measurementColumns + ` FROM measurements WHERE name = ?`
 	row := r.db.QueryRow(query, id)
 
-	var measurement Measurement
-	if err := row.Scan(&measurement.ID, &measurement.Name, &measurement.Unit); err != nil {
+	measurement, err := scanMeasurement(row)
+	if err != nil {
 		if err == sql.ErrNoRows {
 			r.logger.Info("Measurement not found", "id", id)
 			return nil, nil // Measurement not found
@@ -238,6 +636,20 @@ func (r *SQLRepository) getMeasurementByName(id string) (*Measurement, error) {
 		return nil, err
 	}
 	r.logger.Info("Measurement retrieved", "id", measurement.ID, "name", measurement.Name)
+	return measurement, nil
+}
+
+const measurementColumns = `id, name, COALESCE(description, ''), unit, station_id, parameter, provider`
+
+// scanMeasurement scans a row of the measurementColumns.
+func scanMeasurement(row interface{ Scan(dest ...any) error }) (*Measurement, error) {
+	var measurement Measurement
+	labels := &measurement.Labels
+	if err := row.Scan(&measurement.ID, &measurement.Name, &measurement.Description, &measurement.Unit,
+		&labels.StationID, &labels.Parameter, &labels.Provider); err != nil {
+		return nil, err
+	}
+
 	return &measurement, nil
 }
 
@@ -275,8 +687,8 @@ func (r *SQLRepository) addSample(measurementID int64, sample Sample) error {
 	}
 
 	fmt.Println("Adding sample to database", "measurement_id", measurementID, "timestamp", sample.Timestamp, "value", sample.Value)
-	query := `INSERT INTO samples (measurement_id, value, ts) VALUES (?, ?, ?)`
-	if _, err := r.db.Exec(query, measurementID, sample.Value, int64(sample.Timestamp)); err != nil {
+	query := `INSERT INTO samples (measurement_id, value, ts, flags) VALUES (?, ?, ?, ?)`
+	if _, err := r.db.Exec(query, measurementID, sample.Value, int64(sample.Timestamp), int64(sample.Flags)); err != nil {
 		r.logger.Error("Failed to insert sample", "sample", sample, "error", err)
 		return err
 	}
@@ -287,13 +699,32 @@ func (r *SQLRepository) addSample(measurementID int64, sample Sample) error {
 
 func (r *SQLRepository) getSampleSpanByMeasurementID(measurementID int64, startAt, endAt Epoch) ([]Sample, error) {
 	query := `
-SELECT id, measurement_id, value, ts
+SELECT id, measurement_id, value, ts, flags
 FROM samples
 WHERE measurement_id = ?
 	AND ts >= ? AND ts <= ?
 ORDER BY ts ASC`
 
-	rows, err := r.db.Query(query, measurementID, int64(startAt), int64(endAt))
+	return r.querySamples(query, measurementID, int64(startAt), int64(endAt))
+}
+
+// getSamplesBefore returns the last samples before the timestamp, ordered by time.
+func (r *SQLRepository) getSamplesBefore(measurementID int64, before Epoch, limit int) ([]Sample, error) {
+	query := `
+SELECT id, measurement_id, value, ts, flags
+FROM (
+	SELECT * FROM samples
+	WHERE measurement_id = ? AND ts < ?
+	ORDER BY ts DESC
+	LIMIT ?
+)
+ORDER BY ts ASC`
+
+	return r.querySamples(query, measurementID, int64(before), limit)
+}
+
+func (r *SQLRepository) querySamples(query string, args ...any) ([]Sample, error) {
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		r.logger.Error("Failed to query samples", "error", err)
 		return nil, err
@@ -303,7 +734,7 @@ ORDER BY ts ASC`
 	var samples []Sample
 	for rows.Next() {
 		var sample Sample
-		if err := rows.Scan(&sample.ID, &sample.MeasurementID, &sample.Value, &sample.Timestamp); err != nil {
+		if err := rows.Scan(&sample.ID, &sample.MeasurementID, &sample.Value, &sample.Timestamp, &sample.Flags); err != nil {
 			r.logger.Error("Failed to scan sample row", "error", err)
 			return nil, err
 		}
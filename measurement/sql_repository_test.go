@@ -0,0 +1,129 @@
+package measurement_test
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+
+	"github.com/timgluz/wasserspiegel/health"
+	"github.com/timgluz/wasserspiegel/measurement"
+	"github.com/timgluz/wasserspiegel/measurement/measurementtest"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", ":memory:?_pragma=foreign_keys(1)")
+	assert.NoError(t, err)
+	db.SetMaxOpenConns(1) // every connection would open its own in-memory database
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func TestSQLRepositoryContract(t *testing.T) {
+	measurementtest.RunRepositoryContract(t, func(t *testing.T) measurement.Repository {
+		db := openTestDB(t)
+		assert.NoError(t, measurement.ApplySchema(context.Background(), db))
+
+		repo, err := measurement.NewSqlRepository(db, slog.Default())
+		assert.NoError(t, err)
+		return repo
+	})
+}
+
+func TestSQLRepositoryCheckHealth(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	repo, err := measurement.NewSqlRepository(db, slog.Default())
+	assert.NoError(t, err)
+
+	check := repo.CheckHealth(ctx)
+	assert.Equal(t, health.StatusDown, check.Status, "tables are missing")
+
+	assert.NoError(t, measurement.ApplySchema(ctx, db))
+	check = repo.CheckHealth(ctx)
+	assert.Equal(t, health.StatusUp, check.Status)
+	assert.Equal(t, measurement.SchemaVersion, check.Details["schema_version"])
+	assert.Nil(t, check.Details["newest_sample_at"])
+
+	assert.NoError(t, repo.AddTimeseries(ctx, &measurement.Timeseries{
+		Name:        "bonn",
+		Measurement: &measurement.Measurement{Name: "bonn", Unit: "cm"},
+		Samples:     []measurement.Sample{{Timestamp: 1700000000, Value: 42}},
+	}))
+	check = repo.CheckHealth(ctx)
+	assert.Equal(t, "2023-11-14T22:13:20Z", check.Details["newest_sample_at"])
+
+	_, err = db.Exec(`PRAGMA user_version = 0`)
+	assert.NoError(t, err)
+	check = repo.CheckHealth(ctx)
+	assert.Equal(t, health.StatusDegraded, check.Status, "an outdated schema still serves requests")
+}
+
+func TestApplySchemaMigratesUnversionedTables(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	// the schema of the first native server, which set no version
+	_, err := db.Exec(`
+CREATE TABLE measurements (id INTEGER PRIMARY KEY AUTOINCREMENT, name VARCHAR(255) NOT NULL, description TEXT, unit VARCHAR(50) NOT NULL);
+CREATE UNIQUE INDEX idx_measurement_name ON measurements (name);
+CREATE TABLE samples (id INTEGER PRIMARY KEY AUTOINCREMENT, measurement_id int NOT NULL, ts INTEGER NOT NULL, value FLOAT NOT NULL,
+  FOREIGN KEY (measurement_id) REFERENCES measurements (id) ON DELETE CASCADE);
+CREATE INDEX idx_measurement_id ON samples (measurement_id, ts);
+CREATE TABLE service_metrics (name VARCHAR(255) NOT NULL, suffix VARCHAR(50) NOT NULL DEFAULT '', labels TEXT NOT NULL DEFAULT '[]',
+  type VARCHAR(20) NOT NULL, help TEXT, value FLOAT NOT NULL DEFAULT 0, updated_at INTEGER NOT NULL, PRIMARY KEY (name, suffix, labels));
+INSERT INTO measurements (name, unit) VALUES ('waterlevel-bonn', 'cm');
+INSERT INTO samples (measurement_id, ts, value) VALUES (1, 1700000000, 400);`)
+	assert.NoError(t, err)
+
+	assert.NoError(t, measurement.ApplySchema(ctx, db))
+	version, err := measurement.CheckSchema(ctx, db)
+	assert.NoError(t, err)
+	assert.Equal(t, measurement.SchemaVersion, version)
+
+	repo, err := measurement.NewSqlRepository(db, slog.Default())
+	assert.NoError(t, err)
+	timeseries, err := repo.GetTimeseries(ctx, "waterlevel-bonn", measurement.Period{Start: 0, End: 1800000000})
+	assert.NoError(t, err)
+	if assert.NotNil(t, timeseries) && assert.Len(t, timeseries.Samples, 1) {
+		assert.Equal(t, measurement.Flags(0), timeseries.Samples[0].Flags)
+		assert.Equal(t, "bonn", timeseries.Measurement.Labels.StationID)
+	}
+}
+
+func TestApplySchemaMigratesVersion1(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	_, err := db.Exec(`
+CREATE TABLE measurements (id INTEGER PRIMARY KEY AUTOINCREMENT, name VARCHAR(255) NOT NULL, description TEXT, unit VARCHAR(50) NOT NULL);
+CREATE TABLE samples (id INTEGER PRIMARY KEY AUTOINCREMENT, measurement_id int NOT NULL, ts INTEGER NOT NULL, value FLOAT NOT NULL);
+INSERT INTO measurements (name, unit) VALUES ('bonn', 'cm');
+INSERT INTO measurements (name, unit) VALUES ('waterlevel-koeln', 'cm');
+INSERT INTO samples (measurement_id, ts, value) VALUES (1, 1700000000, 400);
+PRAGMA user_version = 1;`)
+	assert.NoError(t, err)
+
+	assert.NoError(t, measurement.ApplySchema(ctx, db))
+	version, err := measurement.CheckSchema(ctx, db)
+	assert.NoError(t, err)
+	assert.Equal(t, measurement.SchemaVersion, version)
+
+	repo, err := measurement.NewSqlRepository(db, slog.Default())
+	assert.NoError(t, err)
+	timeseries, err := repo.GetTimeseries(ctx, "bonn", measurement.Period{Start: 0, End: 1800000000})
+	assert.NoError(t, err)
+	if assert.NotNil(t, timeseries) && assert.Len(t, timeseries.Samples, 1) {
+		assert.Equal(t, measurement.Flags(0), timeseries.Samples[0].Flags, "existing samples are unflagged")
+	}
+
+	koeln, err := repo.GetMeasurement(ctx, "waterlevel-koeln")
+	assert.NoError(t, err)
+	if assert.NotNil(t, koeln) {
+		assert.Equal(t, measurement.Labels{StationID: "koeln", Parameter: "waterlevel"}, koeln.Labels, "labels are backfilled from the name")
+	}
+
+	assert.NoError(t, measurement.ApplySchema(ctx, db), "applying the schema again is a no-op")
+}
@@ -0,0 +1,198 @@
+package measurement
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// GroupBy is the calendar unit of the buckets of statistics.
+type GroupBy string
+
+const (
+	GroupByNone  GroupBy = ""      // a single bucket for the period
+	GroupByDay   GroupBy = "day"   // from midnight to midnight
+	GroupByWeek  GroupBy = "week"  // ISO weeks, starting on Monday
+	GroupByMonth GroupBy = "month" // calendar months
+)
+
+// MaxStatsBuckets limits the buckets of a request, e.g. a year of days.
+const MaxStatsBuckets = 400
+
+// ParseGroupBy returns the unit of a name like "week".
+func ParseGroupBy(name string) (GroupBy, error) {
+	switch groupBy := GroupBy(strings.ToLower(strings.TrimSpace(name))); groupBy {
+	case GroupByNone, GroupByDay, GroupByWeek, GroupByMonth:
+		return groupBy, nil
+	default:
+		return "", fmt.Errorf("%w: %q, expected day, week or month", ErrInvalidGroupBy, name)
+	}
+}
+
+// Bucket is a calendar span of statistics; End is exclusive.
+type Bucket struct {
+	Label string `json:"label"` // e.g. 2025-03-01, 2025-W09 or 2025-03
+	Start Epoch  `json:"start"`
+	End   Epoch  `json:"end"`
+}
+
+// CalendarBuckets splits the period at the calendar boundaries of the location;
+// the first and last bucket are cut to the period. Days have 23 or 25 hours at
+// the changes of daylight saving time.
+func CalendarBuckets(period Period, groupBy GroupBy, location *time.Location) ([]Bucket, error) {
+	if !period.IsValid() {
+		return nil, fmt.Errorf("%w: start must be before end", ErrInvalidEpoch)
+	}
+	if location == nil {
+		location = time.UTC
+	}
+
+	start := time.Unix(int64(period.Start), 0).In(location)
+	if groupBy == GroupByNone {
+		return []Bucket{{Label: start.Format(time.DateOnly), Start: period.Start, End: period.End}}, nil
+	}
+
+	var buckets []Bucket
+	for bucketStart := truncateToCalendar(start, groupBy); bucketStart.Unix() < int64(period.End); {
+		bucketEnd := nextCalendarStart(bucketStart, groupBy)
+		if len(buckets) == MaxStatsBuckets {
+			return nil, fmt.Errorf("%w: more than %d, use a shorter period or a longer group", ErrTooManyBuckets, MaxStatsBuckets)
+		}
+
+		buckets = append(buckets, Bucket{
+			Label: calendarLabel(bucketStart, groupBy),
+			Start: max(period.Start, Epoch(bucketStart.Unix())),
+			End:   min(period.End, Epoch(bucketEnd.Unix())),
+		})
+		bucketStart = bucketEnd
+	}
+
+	return buckets, nil
+}
+
+func truncateToCalendar(t time.Time, groupBy GroupBy) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	switch groupBy {
+	case GroupByWeek:
+		daysSinceMonday := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -daysSinceMonday)
+	case GroupByMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	default:
+		return day
+	}
+}
+
+func nextCalendarStart(t time.Time, groupBy GroupBy) time.Time {
+	switch groupBy {
+	case GroupByWeek:
+		return t.AddDate(0, 0, 7)
+	case GroupByMonth:
+		return t.AddDate(0, 1, 0)
+	default:
+		return t.AddDate(0, 0, 1)
+	}
+}
+
+func calendarLabel(t time.Time, groupBy GroupBy) string {
+	switch groupBy {
+	case GroupByWeek:
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	case GroupByMonth:
+		return t.Format("2006-01")
+	default:
+		return t.Format(time.DateOnly)
+	}
+}
+
+// Extreme is the value and the time of a minimum or maximum, the first one if it repeats.
+type Extreme struct {
+	Value     float64 `json:"value"`
+	Timestamp Epoch   `json:"timestamp"`
+}
+
+// BucketStats summarize the good samples of a bucket, flagged samples are left
+// out; without samples only the count is set.
+type BucketStats struct {
+	Bucket
+	Count  int      `json:"count"`
+	Min    *Extreme `json:"min"`
+	Max    *Extreme `json:"max"`
+	Mean   *float64 `json:"mean"`
+	Median *float64 `json:"median"`
+	P5     *float64 `json:"p5"`
+	P95    *float64 `json:"p95"`
+}
+
+// Stats are the statistics of a measurement per bucket.
+type Stats struct {
+	Name     string        `json:"name"`
+	Start    Epoch         `json:"start"`
+	End      Epoch         `json:"end"`
+	GroupBy  GroupBy       `json:"group_by,omitempty"`
+	Timezone string        `json:"timezone"`
+	Unit     string        `json:"unit,omitempty"`
+	Buckets  []BucketStats `json:"buckets"`
+}
+
+// ComputeBucketStats summarizes the samples within the bucket; repositories
+// that can't aggregate themselves use it.
+func ComputeBucketStats(bucket Bucket, samples []Sample) BucketStats {
+	var inside []Sample
+	sum := 0.0
+	for _, sample := range samples {
+		if sample.Timestamp >= bucket.Start && sample.Timestamp < bucket.End && !sample.Flags.Has(FlagsInvalid) {
+			inside = append(inside, sample)
+			sum += sample.Value
+		}
+	}
+
+	stats := BucketStats{Bucket: bucket, Count: len(inside)}
+	if len(inside) == 0 {
+		return stats
+	}
+
+	sort.SliceStable(inside, func(i, j int) bool {
+		if inside[i].Value != inside[j].Value {
+			return inside[i].Value < inside[j].Value
+		}
+		return inside[i].Timestamp < inside[j].Timestamp
+	})
+
+	stats.Min = &Extreme{Value: inside[0].Value, Timestamp: inside[0].Timestamp}
+	maxValue := inside[len(inside)-1].Value
+	first := sort.Search(len(inside), func(i int) bool { return inside[i].Value >= maxValue })
+	stats.Max = &Extreme{Value: maxValue, Timestamp: inside[first].Timestamp}
+
+	values := make([]float64, len(inside))
+	for i, sample := range inside {
+		values[i] = sample.Value
+	}
+	stats.setPercentiles(func(rank int) float64 { return values[rank] })
+	stats.Mean = floatPtr(roundHundredths(sum / float64(len(inside))))
+
+	return stats
+}
+
+// percentileRanks returns the 0-based ranks around the percentile of count
+// ordered values; the percentile interpolates linearly between them.
+func percentileRanks(count int, percentile float64) (int, int, float64) {
+	position := percentile * float64(count-1)
+	lower := int(math.Floor(position))
+	upper := min(lower+1, count-1)
+
+	return lower, upper, position - float64(lower)
+}
+
+// setPercentiles sets the median, P5 and P95 from the values at the ranks of the ordered values.
+func (s *BucketStats) setPercentiles(valueAt func(rank int) float64) {
+	percentile := func(p float64) *float64 {
+		lower, upper, fraction := percentileRanks(s.Count, p)
+		return floatPtr(roundHundredths(valueAt(lower) + fraction*(valueAt(upper)-valueAt(lower))))
+	}
+
+	s.P5, s.Median, s.P95 = percentile(0.05), percentile(0.5), percentile(0.95)
+}
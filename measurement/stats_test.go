@@ -0,0 +1,49 @@
+package measurement_test
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/timgluz/wasserspiegel/measurement"
+)
+
+func TestCalendarBuckets(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	assert.NoError(t, err)
+
+	at := func(year int, month time.Month, day, hour int) measurement.Epoch {
+		return measurement.Epoch(time.Date(year, month, day, hour, 0, 0, 0, berlin).Unix())
+	}
+	// the clocks go forward on Sunday, 2025-03-30
+	period := measurement.Period{Start: at(2025, 3, 29, 12), End: at(2025, 4, 1, 0)}
+
+	days, err := measurement.CalendarBuckets(period, measurement.GroupByDay, berlin)
+	assert.NoError(t, err)
+	assert.Equal(t, []measurement.Bucket{
+		{Label: "2025-03-29", Start: period.Start, End: at(2025, 3, 30, 0)},
+		{Label: "2025-03-30", Start: at(2025, 3, 30, 0), End: at(2025, 3, 31, 0)},
+		{Label: "2025-03-31", Start: at(2025, 3, 31, 0), End: period.End},
+	}, days, "the first bucket is cut to the period")
+	assert.Equal(t, measurement.Epoch(23*3600), days[1].End-days[1].Start)
+
+	weeks, err := measurement.CalendarBuckets(period, measurement.GroupByWeek, berlin)
+	assert.NoError(t, err)
+	if assert.Len(t, weeks, 2) {
+		assert.Equal(t, "2025-W13", weeks[0].Label)
+		assert.Equal(t, at(2025, 3, 31, 0), weeks[1].Start, "weeks start on Monday")
+	}
+
+	months, err := measurement.CalendarBuckets(period, measurement.GroupByMonth, berlin)
+	assert.NoError(t, err)
+	assert.Equal(t, []measurement.Bucket{{Label: "2025-03", Start: period.Start, End: period.End}}, months)
+
+	long := measurement.Period{Start: at(2020, 1, 1, 0), End: at(2025, 1, 1, 0)}
+	_, err = measurement.CalendarBuckets(long, measurement.GroupByDay, berlin)
+	assert.ErrorIs(t, err, measurement.ErrTooManyBuckets)
+
+	_, err = measurement.ParseGroupBy("year")
+	assert.ErrorIs(t, err, measurement.ErrInvalidGroupBy)
+}
@@ -0,0 +1,255 @@
+package measurement
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Dimension is the physical quantity of a unit, only units of the same dimension convert.
+type Dimension string
+
+const (
+	DimensionLength      Dimension = "length"
+	DimensionDischarge   Dimension = "discharge"
+	DimensionTemperature Dimension = "temperature"
+	DimensionVelocity    Dimension = "velocity"
+)
+
+// Unit converts its values to the base unit of its dimension by value*Scale + Offset.
+type Unit struct {
+	Symbol    string    `json:"symbol"`
+	Dimension Dimension `json:"dimension"`
+	Scale     float64   `json:"-"`
+	Offset    float64   `json:"-"`
+}
+
+// units are keyed by the canonical symbol; the base units are m, m3/s, C and m/s.
+var units = map[string]Unit{
+	"mm": {Symbol: "mm", Dimension: DimensionLength, Scale: 0.001},
+	"cm": {Symbol: "cm", Dimension: DimensionLength, Scale: 0.01},
+	"m":  {Symbol: "m", Dimension: DimensionLength, Scale: 1},
+	"in": {Symbol: "in", Dimension: DimensionLength, Scale: 0.0254},
+	"ft": {Symbol: "ft", Dimension: DimensionLength, Scale: 0.3048},
+
+	"m3/s":  {Symbol: "m3/s", Dimension: DimensionDischarge, Scale: 1},
+	"l/s":   {Symbol: "l/s", Dimension: DimensionDischarge, Scale: 0.001},
+	"ft3/s": {Symbol: "ft3/s", Dimension: DimensionDischarge, Scale: 0.028316846592},
+
+	"C": {Symbol: "C", Dimension: DimensionTemperature, Scale: 1},
+	"F": {Symbol: "F", Dimension: DimensionTemperature, Scale: 5.0 / 9, Offset: -160.0 / 9},
+	"K": {Symbol: "K", Dimension: DimensionTemperature, Scale: 1, Offset: -273.15},
+
+	"m/s":  {Symbol: "m/s", Dimension: DimensionVelocity, Scale: 1},
+	"cm/s": {Symbol: "cm/s", Dimension: DimensionVelocity, Scale: 0.01},
+	"km/h": {Symbol: "km/h", Dimension: DimensionVelocity, Scale: 1 / 3.6},
+	"ft/s": {Symbol: "ft/s", Dimension: DimensionVelocity, Scale: 0.3048},
+	// rates of change of water levels
+	"mm/h": {Symbol: "mm/h", Dimension: DimensionVelocity, Scale: 0.001 / 3600},
+	"cm/h": {Symbol: "cm/h", Dimension: DimensionVelocity, Scale: 0.01 / 3600},
+	"m/h":  {Symbol: "m/h", Dimension: DimensionVelocity, Scale: 1.0 / 3600},
+	"in/h": {Symbol: "in/h", Dimension: DimensionVelocity, Scale: 0.0254 / 3600},
+	"ft/h": {Symbol: "ft/h", Dimension: DimensionVelocity, Scale: 0.3048 / 3600},
+}
+
+// unitAliases are the other spellings of the symbols, e.g. of PegelOnline.
+var unitAliases = map[string]string{
+	"m³/s":    "m3/s",
+	"cumecs":  "m3/s",
+	"cfs":     "ft3/s",
+	"ft³/s":   "ft3/s",
+	"°c":      "C",
+	"celsius": "C",
+	"°f":      "F",
+	"kelvin":  "K",
+}
+
+// ParseUnit returns the registered unit of the symbol or one of its aliases.
+func ParseUnit(symbol string) (Unit, error) {
+	if unit, ok := units[symbol]; ok {
+		return unit, nil
+	}
+
+	normalized := strings.ToLower(strings.TrimSpace(symbol))
+	if alias, ok := unitAliases[normalized]; ok {
+		normalized = alias
+	}
+	for key, unit := range units {
+		if strings.EqualFold(key, normalized) {
+			return unit, nil
+		}
+	}
+
+	return Unit{}, fmt.Errorf("%w: %q, supported are %s", ErrUnknownUnit, symbol, strings.Join(UnitSymbols(), ", "))
+}
+
+// NormalizeUnit returns the canonical symbol of the unit of a new measurement;
+// measurements without a unit stay unitless.
+func NormalizeUnit(symbol string) (string, error) {
+	if symbol == "" {
+		return "", nil
+	}
+
+	unit, err := ParseUnit(symbol)
+	if err != nil {
+		return "", err
+	}
+
+	return unit.Symbol, nil
+}
+
+// checkUnitUnchanged fails with ErrIncompatibleUnits if an update sets another
+// unit than the stored one; an empty unit keeps the stored one.
+func checkUnitUnchanged(stored, updated string) error {
+	if updated == "" {
+		return nil
+	}
+
+	unit, err := NormalizeUnit(updated)
+	if err != nil {
+		return err
+	}
+	if unit != stored {
+		return fmt.Errorf("%w: the unit %s of a measurement can't change to %s", ErrIncompatibleUnits, stored, unit)
+	}
+
+	return nil
+}
+
+// UnitSymbols returns the sorted canonical symbols of the registered units.
+func UnitSymbols() []string {
+	symbols := make([]string, 0, len(units))
+	for symbol := range units {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+
+	return symbols
+}
+
+// UnitConverter converts values between two units of the same dimension.
+type UnitConverter struct {
+	from, to Unit
+}
+
+func NewUnitConverter(from, to string) (UnitConverter, error) {
+	fromUnit, err := ParseUnit(from)
+	if err != nil {
+		return UnitConverter{}, err
+	}
+
+	toUnit, err := ParseUnit(to)
+	if err != nil {
+		return UnitConverter{}, err
+	}
+
+	if fromUnit.Dimension != toUnit.Dimension {
+		return UnitConverter{}, fmt.Errorf("%w: %s is a %s, %s a %s", ErrIncompatibleUnits, fromUnit.Symbol, fromUnit.Dimension, toUnit.Symbol, toUnit.Dimension)
+	}
+
+	return UnitConverter{from: fromUnit, to: toUnit}, nil
+}
+
+// ConvertValue converts the value of a unit to another one.
+func ConvertValue(value float64, from, to string) (float64, error) {
+	converter, err := NewUnitConverter(from, to)
+	if err != nil {
+		return 0, err
+	}
+
+	return converter.Value(value), nil
+}
+
+func (c UnitConverter) From() string {
+	return c.from.Symbol
+}
+
+func (c UnitConverter) To() string {
+	return c.to.Symbol
+}
+
+// Value converts a value, e.g. a water level.
+func (c UnitConverter) Value(value float64) float64 {
+	base := value*c.from.Scale + c.from.Offset
+	return roundConverted((base - c.to.Offset) / c.to.Scale)
+}
+
+// Delta converts a difference of values, e.g. a change or a deviation, which has no offset.
+func (c UnitConverter) Delta(delta float64) float64 {
+	return roundConverted(delta * c.from.Scale / c.to.Scale)
+}
+
+// roundConverted drops the floating point noise of the scales, e.g. 312.00000000000006 cm.
+func roundConverted(value float64) float64 {
+	return math.Round(value*1e9) / 1e9
+}
+
+// SamplesInUnit returns the samples in the unit of the stored measurement,
+// converted if the measurement of the timeseries has another unit.
+func (t *Timeseries) SamplesInUnit(unit string) ([]Sample, error) {
+	if t.Measurement == nil || t.Measurement.Unit == "" || unit == "" || t.Measurement.Unit == unit {
+		return t.Samples, nil
+	}
+
+	converter, err := NewUnitConverter(t.Measurement.Unit, unit)
+	if err != nil {
+		return nil, err
+	}
+
+	samples := make([]Sample, len(t.Samples))
+	for i, sample := range t.Samples {
+		sample.Value = converter.Value(sample.Value)
+		samples[i] = sample
+	}
+
+	return samples, nil
+}
+
+// ConvertUnit converts the values of the samples and sets the unit of the measurement.
+func (t *Timeseries) ConvertUnit(c UnitConverter) {
+	for i := range t.Samples {
+		t.Samples[i].Value = c.Value(t.Samples[i].Value)
+	}
+
+	if t.Measurement != nil {
+		converted := *t.Measurement
+		converted.Unit = c.To()
+		t.Measurement = &converted
+	}
+}
+
+// ConvertUnit converts the projected values, the rate and the deviation.
+func (f *Forecast) ConvertUnit(c UnitConverter) {
+	for i := range f.Points {
+		f.Points[i].Value = c.Value(f.Points[i].Value)
+		f.Points[i].Lower = c.Value(f.Points[i].Lower)
+		f.Points[i].Upper = c.Value(f.Points[i].Upper)
+	}
+	f.Rate = c.Delta(f.Rate)
+	f.Sigma = c.Delta(f.Sigma)
+	f.Unit = c.To()
+}
+
+// ConvertUnit converts the statistics of the bucket.
+func (s *BucketStats) ConvertUnit(c UnitConverter) {
+	for _, extreme := range []*Extreme{s.Min, s.Max} {
+		if extreme != nil {
+			extreme.Value = c.Value(extreme.Value)
+		}
+	}
+	for _, value := range []*float64{s.Mean, s.Median, s.P5, s.P95} {
+		if value != nil {
+			*value = c.Value(*value)
+		}
+	}
+}
+
+// ConvertUnit converts the value and the baseline; the percentile stays the same.
+func (h *HistoricalContext) ConvertUnit(c UnitConverter) {
+	h.Value = c.Value(h.Value)
+	for _, value := range []*float64{&h.Baseline.Min, &h.Baseline.P5, &h.Baseline.P10, &h.Baseline.P25, &h.Baseline.P50,
+		&h.Baseline.P75, &h.Baseline.P90, &h.Baseline.P95, &h.Baseline.Max} {
+		*value = c.Value(*value)
+	}
+}
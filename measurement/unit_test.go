@@ -0,0 +1,44 @@
+package measurement_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/timgluz/wasserspiegel/measurement"
+)
+
+func TestUnitConverter(t *testing.T) {
+	testCases := []struct {
+		name  string
+		from  string
+		to    string
+		value float64
+		want  float64
+	}{
+		{name: "cm to m", from: "cm", to: "m", value: 312, want: 3.12},
+		{name: "m to cm", from: "m", to: "cm", value: 3.12, want: 312},
+		{name: "ft to in", from: "ft", to: "in", value: 2, want: 24},
+		{name: "discharge alias", from: "m³/s", to: "l/s", value: 1.5, want: 1500},
+		{name: "fahrenheit to celsius", from: "F", to: "°C", value: 212, want: 100},
+		{name: "celsius to kelvin", from: "C", to: "K", value: 0, want: 273.15},
+		{name: "rate of change", from: "cm/h", to: "m/h", value: 4, want: 0.04},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := measurement.ConvertValue(tc.value, tc.from, tc.to)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	converter, err := measurement.NewUnitConverter("C", "F")
+	assert.NoError(t, err)
+	assert.Equal(t, 9.0, converter.Delta(5), "differences have no offset")
+
+	_, err = measurement.NewUnitConverter("cm", "m3/s")
+	assert.ErrorIs(t, err, measurement.ErrIncompatibleUnits)
+	_, err = measurement.NewUnitConverter("cm", "furlong")
+	assert.ErrorIs(t, err, measurement.ErrUnknownUnit)
+}
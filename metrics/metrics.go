@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	TypeCounter = "counter"
+	TypeGauge   = "gauge"
+	TypeSummary = "summary"
+)
+
+var (
+	ErrInvalidMetricName = fmt.Errorf("invalid metric name")
+	ErrInvalidLabelName  = fmt.Errorf("invalid label name")
+)
+
+// Descriptor describes a metric family without any samples.
+type Descriptor struct {
+	Name string
+	Type string
+	Help string
+	Unit string
+}
+
+type Label struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type Labels []Label
+
+// NewLabels builds a label set from name-value pairs, e.g. NewLabels("station_id", "rhein-koeln").
+// A trailing name without a value is ignored.
+func NewLabels(pairs ...string) Labels {
+	labels := make(Labels, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		labels = append(labels, Label{Name: pairs[i], Value: pairs[i+1]})
+	}
+
+	return labels
+}
+
+// Sorted returns a copy of the labels ordered by name, which is used as the canonical form.
+func (l Labels) Sorted() Labels {
+	sorted := make(Labels, len(l))
+	copy(sorted, l)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	return sorted
+}
+
+// Sample is a single line of a metric family, e.g. the `_total` line of a counter.
+type Sample struct {
+	Suffix string
+	Labels Labels
+	Value  float64
+}
+
+type Family struct {
+	Descriptor
+	Samples []Sample
+}
+
+func NewFamily(desc Descriptor) *Family {
+	return &Family{Descriptor: desc, Samples: []Sample{}}
+}
+
+func (f *Family) Add(labels Labels, value float64) {
+	f.AddWithSuffix("", labels, value)
+}
+
+func (f *Family) AddWithSuffix(suffix string, labels Labels, value float64) {
+	f.Samples = append(f.Samples, Sample{Suffix: suffix, Labels: labels, Value: value})
+}
+
+// Recorder records service counters; implementations must be safe to use
+// from stateless handlers, so state is kept in a backing store.
+type Recorder interface {
+	IncCounter(ctx context.Context, desc Descriptor, labels Labels) error
+	ObserveDuration(ctx context.Context, desc Descriptor, labels Labels, d time.Duration) error
+}
+
+// NopRecorder discards all recorded values.
+type NopRecorder struct{}
+
+func (NopRecorder) IncCounter(ctx context.Context, desc Descriptor, labels Labels) error {
+	return nil
+}
+
+func (NopRecorder) ObserveDuration(ctx context.Context, desc Descriptor, labels Labels, d time.Duration) error {
+	return nil
+}
@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const ContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+var (
+	metricNamePattern = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	labelNamePattern  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+// WriteOpenMetrics renders the metric families in the OpenMetrics text format,
+// including the mandatory `# EOF` terminator.
+func WriteOpenMetrics(w io.Writer, families []*Family) error {
+	buf := bufio.NewWriter(w)
+
+	for _, family := range families {
+		if family == nil {
+			continue
+		}
+
+		if err := writeFamily(buf, family); err != nil {
+			return err
+		}
+	}
+
+	if _, err := buf.WriteString("# EOF\n"); err != nil {
+		return err
+	}
+
+	return buf.Flush()
+}
+
+func writeFamily(w *bufio.Writer, family *Family) error {
+	if !metricNamePattern.MatchString(family.Name) {
+		return fmt.Errorf("%w: %q", ErrInvalidMetricName, family.Name)
+	}
+
+	metricType := family.Type
+	if metricType == "" {
+		metricType = "unknown"
+	}
+
+	fmt.Fprintf(w, "# TYPE %s %s\n", family.Name, metricType)
+	if family.Unit != "" {
+		fmt.Fprintf(w, "# UNIT %s %s\n", family.Name, family.Unit)
+	}
+	if family.Help != "" {
+		fmt.Fprintf(w, "# HELP %s %s\n", family.Name, labelValueEscaper.Replace(family.Help))
+	}
+
+	for _, sample := range family.Samples {
+		suffix := sample.Suffix
+		if suffix == "" && family.Type == TypeCounter {
+			suffix = "_total"
+		}
+
+		labels, err := formatLabels(sample.Labels)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(w, "%s%s%s %s\n", family.Name, suffix, labels, formatValue(sample.Value))
+	}
+
+	return nil
+}
+
+func formatLabels(labels Labels) (string, error) {
+	if len(labels) == 0 {
+		return "", nil
+	}
+
+	parts := make([]string, 0, len(labels))
+	for _, label := range labels {
+		if !labelNamePattern.MatchString(label.Name) {
+			return "", fmt.Errorf("%w: %q", ErrInvalidLabelName, label.Name)
+		}
+
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, label.Name, labelValueEscaper.Replace(label.Value)))
+	}
+
+	return "{" + strings.Join(parts, ",") + "}", nil
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsNaN(value):
+		return "NaN"
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteOpenMetrics(t *testing.T) {
+	gauge := NewFamily(Descriptor{Name: "wasserspiegel_water_level_cm", Type: TypeGauge, Unit: "cm", Help: "Latest water level."})
+	gauge.Add(NewLabels("station_id", "rhein-koeln", "name", `K"oln`), 412.5)
+
+	counter := NewFamily(Descriptor{Name: "wasserspiegel_collector_runs", Type: TypeCounter})
+	counter.Add(NewLabels("task", "collect"), 3)
+
+	summary := NewFamily(Descriptor{Name: "wasserspiegel_provider_request_duration_seconds", Type: TypeSummary})
+	summary.AddWithSuffix("_count", nil, 2)
+	summary.AddWithSuffix("_sum", nil, math.Inf(1))
+
+	var buf bytes.Buffer
+	err := WriteOpenMetrics(&buf, []*Family{gauge, counter, summary})
+	assert.NoError(t, err)
+
+	expected := `# TYPE wasserspiegel_water_level_cm gauge
+# UNIT wasserspiegel_water_level_cm cm
+# HELP wasserspiegel_water_level_cm Latest water level.
+wasserspiegel_water_level_cm{station_id="rhein-koeln",name="K\"oln"} 412.5
+# TYPE wasserspiegel_collector_runs counter
+wasserspiegel_collector_runs_total{task="collect"} 3
+# TYPE wasserspiegel_provider_request_duration_seconds summary
+wasserspiegel_provider_request_duration_seconds_count 2
+wasserspiegel_provider_request_duration_seconds_sum +Inf
+# EOF
+`
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestWriteOpenMetricsRejectsInvalidNames(t *testing.T) {
+	testCases := []struct {
+		name   string
+		family *Family
+	}{
+		{
+			name:   "invalid metric name",
+			family: NewFamily(Descriptor{Name: "water-level", Type: TypeGauge}),
+		},
+		{
+			name: "invalid label name",
+			family: &Family{
+				Descriptor: Descriptor{Name: "water_level", Type: TypeGauge},
+				Samples:    []Sample{{Labels: NewLabels("station-id", "x"), Value: 1}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			assert.Error(t, WriteOpenMetrics(&buf, []*Family{tc.family}))
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/timgluz/wasserspiegel/health"
+)
+
+var ErrDBNotAvailable = fmt.Errorf("metrics DB is not available")
+
+// SQLStore persists service counters in the `service_metrics` table,
+// so they survive across stateless component invocations.
+type SQLStore struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+func NewSQLStore(db *sql.DB, logger *slog.Logger) (*SQLStore, error) {
+	if db == nil {
+		logger.Error("SQL DB is not initialized")
+		return nil, ErrDBNotAvailable
+	}
+
+	return &SQLStore{
+		db:     db,
+		logger: logger,
+	}, nil
+}
+
+func (s *SQLStore) IsReady() bool {
+	if s.logger == nil {
+		fmt.Println("Logger of metrics SQLStore is not initialized")
+		return false
+	}
+
+	if s.db == nil {
+		s.logger.Error("Metrics DB is not initialized")
+		return false
+	}
+
+	return true
+}
+
+// CheckHealth pings the DB of the service metrics.
+func (s *SQLStore) CheckHealth(ctx context.Context) health.Check {
+	if s.db == nil {
+		return health.Down("metrics", ErrDBNotAvailable)
+	}
+
+	start := time.Now()
+	if err := s.db.PingContext(ctx); err != nil {
+		return health.Down("metrics", fmt.Errorf("failed to ping metrics DB: %w", err)).WithLatency(time.Since(start))
+	}
+
+	return health.Up("metrics").WithLatency(time.Since(start))
+}
+
+func (s *SQLStore) IncCounter(ctx context.Context, desc Descriptor, labels Labels) error {
+	return s.add(ctx, desc, "_total", labels, 1)
+}
+
+// ObserveDuration records the duration in seconds as the `_sum` and `_count` lines of a summary.
+func (s *SQLStore) ObserveDuration(ctx context.Context, desc Descriptor, labels Labels, d time.Duration) error {
+	if err := s.add(ctx, desc, "_sum", labels, d.Seconds()); err != nil {
+		return err
+	}
+
+	return s.add(ctx, desc, "_count", labels, 1)
+}
+
+func (s *SQLStore) add(ctx context.Context, desc Descriptor, suffix string, labels Labels, delta float64) error {
+	if !s.IsReady() {
+		return ErrDBNotAvailable
+	}
+
+	if !metricNamePattern.MatchString(desc.Name) {
+		return fmt.Errorf("%w: %q", ErrInvalidMetricName, desc.Name)
+	}
+
+	labelBlob, err := json.Marshal(labels.Sorted())
+	if err != nil {
+		return fmt.Errorf("failed to marshal labels: %w", err)
+	}
+
+	query := `
+INSERT INTO service_metrics (name, suffix, labels, type, help, value, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (name, suffix, labels) DO UPDATE SET
+	value = value + excluded.value,
+	updated_at = excluded.updated_at`
+
+	_, err = s.db.ExecContext(ctx, query, desc.Name, suffix, string(labelBlob), desc.Type, desc.Help, delta, time.Now().Unix())
+	if err != nil {
+		s.logger.Error("Failed to update service metric", "name", desc.Name, "suffix", suffix, "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// Families returns all stored service metrics grouped into families.
+func (s *SQLStore) Families(ctx context.Context) ([]*Family, error) {
+	if !s.IsReady() {
+		return nil, ErrDBNotAvailable
+	}
+
+	query := `SELECT name, suffix, labels, type, help, value FROM service_metrics ORDER BY name, labels, suffix`
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		s.logger.Error("Failed to query service metrics", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var families []*Family
+	var current *Family
+	for rows.Next() {
+		var (
+			desc      Descriptor
+			suffix    string
+			labelBlob string
+			help      sql.NullString
+			value     float64
+		)
+		if err := rows.Scan(&desc.Name, &suffix, &labelBlob, &desc.Type, &help, &value); err != nil {
+			s.logger.Error("Failed to scan service metric row", "error", err)
+			return nil, err
+		}
+		desc.Help = help.String
+
+		var labels Labels
+		if err := json.Unmarshal([]byte(labelBlob), &labels); err != nil {
+			s.logger.Warn("Skipping service metric with invalid labels", "name", desc.Name, "error", err)
+			continue
+		}
+
+		if current == nil || current.Name != desc.Name {
+			current = NewFamily(desc)
+			families = append(families, current)
+		}
+		current.AddWithSuffix(suffix, labels, value)
+	}
+
+	if err := rows.Err(); err != nil {
+		s.logger.Error("Error occurred during row iteration", "error", err)
+		return nil, err
+	}
+
+	return families, nil
+}
@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/timgluz/wasserspiegel/log"
+)
+
+// RouteFunc returns the route pattern of the request, e.g. `/stations/:id`.
+type RouteFunc func(r *http.Request) string
+
+// accessLogEntry collects what the handlers learn about the request, like the
+// name of the API key, because they only see copies of the request.
+type accessLogEntry struct {
+	keyName string
+}
+
+// AccessLog writes one line per request with method, route, status, duration
+// and the name of the API key. The line goes to the logger of the request
+// context, so it carries the request ID when RequestID runs first.
+func AccessLog(h http.Handler, logger *slog.Logger, route RouteFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		entry := &accessLogEntry{}
+		recorder := &statusRecorder{ResponseWriter: w}
+
+		ctx := r.Context()
+		r = r.WithContext(context.WithValue(ctx, accessLogContextKey, entry))
+		h.ServeHTTP(recorder, r)
+
+		routePattern := r.URL.Path
+		if route != nil {
+			if pattern := route(r); pattern != "" {
+				routePattern = pattern
+			}
+		}
+
+		attrs := []any{
+			"method", r.Method,
+			"route", routePattern,
+			"path", r.URL.Path,
+			"status", recorder.Status(),
+			"bytes", recorder.bytes,
+			"duration_ms", float64(time.Since(start).Microseconds()) / 1000,
+		}
+		if entry.keyName != "" {
+			attrs = append(attrs, "key", entry.keyName)
+		}
+
+		level := slog.LevelInfo
+		if recorder.Status() >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+
+		log.FromContext(ctx, logger).Log(ctx, level, "Request", attrs...)
+	})
+}
+
+// statusRecorder remembers the status and the size of the response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Write(body []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	n, err := w.ResponseWriter.Write(body)
+	w.bytes += n
+	return n, err
+}
+
+// Status is 200 if the handler wrote nothing, like net/http does.
+func (w *statusRecorder) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+
+	return w.status
+}
+
+func (w *statusRecorder) wroteHeader() bool {
+	return w.status != 0
+}
+
+// Unwrap lets http.ResponseController reach the original writer.
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -1,53 +1,73 @@
 package middleware
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 
 	"github.com/julienschmidt/httprouter"
+	"github.com/timgluz/wasserspiegel/api/apierror"
+	"github.com/timgluz/wasserspiegel/response"
 	"github.com/timgluz/wasserspiegel/secret"
 )
 
-func BearerAuth(h httprouter.Handle, secretStore secret.Store) httprouter.Handle {
+// BearerAuth checks the bearer token against the secret store and requires
+// the key to hold all given scopes. The authenticated key is added to the request context.
+func BearerAuth(h httprouter.Handle, secretStore secret.Store, scopes ...string) httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" || len(authHeader) < 7 {
 			w.Header().Set("WWW-Authenticate", "Bearer")
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			response.RenderProblem(w, response.NewProblem(http.StatusUnauthorized, response.CodeUnauthorized, "missing bearer token"))
 			return
 		}
 
 		authType := strings.ToLower(strings.TrimSpace(authHeader[:7]))
 		if authType != "bearer" {
 			w.Header().Set("WWW-Authenticate", "Bearer")
-			http.Error(w, "Unsupported authorization type", http.StatusBadRequest)
+			response.RenderProblem(w, response.NewProblem(http.StatusBadRequest, response.CodeBadRequest, "unsupported authorization type, expected Bearer"))
 			return
 		}
 
 		token := strings.TrimSpace(authHeader[7:]) // Extract the token part
 		if token == "" {
 			w.Header().Set("WWW-Authenticate", "Bearer")
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			response.RenderProblem(w, response.NewProblem(http.StatusUnauthorized, response.CodeUnauthorized, "missing bearer token"))
 			return
 		}
 
 		if secretStore == nil {
-			http.Error(w, "Service is not ready", http.StatusInternalServerError)
+			response.RenderProblem(w, response.NewProblem(http.StatusServiceUnavailable, response.CodeServiceUnavailable, "service is not ready"))
 			return
 		}
 
-		if _, err := secretStore.Get(token); err != nil {
+		apiKey, err := secret.Authenticate(secretStore, token)
+		if err != nil {
 			w.Header().Set("WWW-Authenticate", "Bearer")
-			if err == secret.ErrSecretNotFound {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			if errors.Is(err, secret.ErrSecretNotFound) {
+				// don't tell whether the key exists
+				response.RenderProblem(w, response.NewProblem(http.StatusUnauthorized, response.CodeUnauthorized, "invalid API key"))
 				return
 			}
 
-			http.Error(w, fmt.Sprintf("Invalid token: %s", err), http.StatusInternalServerError)
+			if errors.Is(err, secret.ErrKeyExpired) || errors.Is(err, secret.ErrKeyRevoked) {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="invalid_token", error_description="%s"`, err))
+				apierror.Render(w, err, http.StatusUnauthorized)
+				return
+			}
+
+			apierror.RenderFatal(w, fmt.Errorf("failed to authenticate API key: %w", err))
+			return
+		}
+
+		if !apiKey.HasScopes(scopes...) {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope="%s"`, strings.Join(scopes, " ")))
+			response.RenderProblem(w, response.NewProblem(http.StatusForbidden, response.CodeForbidden,
+				"the API key lacks the scopes "+strings.Join(scopes, ", ")))
 			return
 		}
 
-		h(w, r, ps)
+		h(w, r.WithContext(WithAPIKey(r.Context(), apiKey)), ps)
 	}
 }
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/timgluz/wasserspiegel/secret"
+)
+
+type contextKey string
+
+const (
+	apiKeyContextKey    contextKey = "api_key"
+	requestIDContextKey contextKey = "request_id"
+	accessLogContextKey contextKey = "access_log"
+)
+
+// WithAPIKey returns a copy of the context carrying the authenticated API key.
+// The name of the key is also noted for the access log of the request.
+func WithAPIKey(ctx context.Context, apiKey *secret.APIKey) context.Context {
+	if entry, ok := ctx.Value(accessLogContextKey).(*accessLogEntry); ok && apiKey != nil {
+		entry.keyName = apiKey.Name
+	}
+
+	return context.WithValue(ctx, apiKeyContextKey, apiKey)
+}
+
+// APIKeyFromContext returns the API key authenticated by BearerAuth, if any.
+func APIKeyFromContext(ctx context.Context) (*secret.APIKey, bool) {
+	apiKey, ok := ctx.Value(apiKeyContextKey).(*secret.APIKey)
+	return apiKey, ok && apiKey != nil
+}
+
+// WithRequestID returns a copy of the context carrying the ID of the request.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey, requestID)
+}
+
+// RequestIDFromContext returns the ID assigned by RequestID, if any.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	requestID, ok := ctx.Value(requestIDContextKey).(string)
+	return requestID, ok && requestID != ""
+}
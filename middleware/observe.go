@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Observe wraps the handler of a component with RequestID, AccessLog and
+// Recover, so a panic is logged and answered with a 500 like any other error.
+func Observe(h http.Handler, logger *slog.Logger, route RouteFunc) http.Handler {
+	return RequestID(AccessLog(Recover(h, logger), logger, route), logger)
+}
+
+// ObserveRouter is Observe for a router, the access log uses its routes.
+func ObserveRouter(router *httprouter.Router, logger *slog.Logger) http.Handler {
+	return Observe(router, logger, RouterRoute(router))
+}
+
+// RouterRoute returns the route of the request by looking it up in the router.
+// httprouter v1.3 doesn't expose the matched pattern, so it is rebuilt from the
+// parameters; requests without a route return an empty string.
+func RouterRoute(router *httprouter.Router) RouteFunc {
+	return func(r *http.Request) string {
+		handle, params, _ := router.Lookup(r.Method, r.URL.Path)
+		if handle == nil {
+			return ""
+		}
+
+		return routeFromParams(r.URL.Path, params)
+	}
+}
+
+func routeFromParams(path string, params httprouter.Params) string {
+	segments := strings.Split(path, "/")
+	next := 0
+	for _, param := range params {
+		if strings.HasPrefix(param.Value, "/") {
+			// a catch-all parameter consumes the rest of the path
+			prefix := strings.Split(strings.TrimSuffix(path, param.Value), "/")
+			return strings.Join(segments[:len(prefix)], "/") + "/*" + param.Key
+		}
+
+		for i := next; i < len(segments); i++ {
+			if segments[i] == param.Value {
+				segments[i] = ":" + param.Key
+				next = i + 1
+				break
+			}
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"github.com/timgluz/wasserspiegel/log"
+	"github.com/timgluz/wasserspiegel/response"
+	"github.com/timgluz/wasserspiegel/secret"
+)
+
+func newTestRouter() *httprouter.Router {
+	router := httprouter.New()
+	router.GET("/stations/:id", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		ctx := WithAPIKey(r.Context(), &secret.APIKey{Name: "grafana"}) // as BearerAuth does
+		log.FromContext(ctx, nil).Info("Fetching station", "id", ps.ByName("id"))
+		w.WriteHeader(http.StatusNoContent)
+	})
+	router.GET("/panic", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		panic("secret internals")
+	})
+
+	return router
+}
+
+func decodeLogLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	var lines []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]any
+		assert.NoError(t, json.Unmarshal([]byte(line), &entry))
+		lines = append(lines, entry)
+	}
+
+	return lines
+}
+
+func TestObserveRouter(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(log.NewHandler(&buf, log.FormatJSON, nil))
+	handler := ObserveRouter(newTestRouter(), logger)
+
+	req := httptest.NewRequest(http.MethodGet, "/stations/bonn", nil)
+	req.Header.Set(response.RequestIDHeader, "trigger-42")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "trigger-42", rec.Header().Get(response.RequestIDHeader))
+
+	lines := decodeLogLines(t, &buf)
+	if assert.Len(t, lines, 2) {
+		assert.Equal(t, "trigger-42", lines[0]["request_id"], "handlers log with the request logger")
+
+		access := lines[1]
+		assert.Equal(t, "Request", access["msg"])
+		assert.Equal(t, "trigger-42", access["request_id"])
+		assert.Equal(t, "/stations/:id", access["route"])
+		assert.Equal(t, "/stations/bonn", access["path"])
+		assert.Equal(t, float64(http.StatusNoContent), access["status"])
+		assert.Equal(t, "grafana", access["key"])
+	}
+}
+
+func TestObserveRouterRecoversPanic(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(log.NewHandler(&buf, log.FormatJSON, nil))
+	handler := ObserveRouter(newTestRouter(), logger)
+
+	req := httptest.NewRequest(http.MethodGet, "/panic", nil)
+	req.Header.Set(response.RequestIDHeader, "bad id\n")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, response.ProblemContentType, rec.Header().Get("Content-Type"))
+
+	requestID := rec.Header().Get(response.RequestIDHeader)
+	assert.Len(t, requestID, 32, "an invalid ID is replaced")
+
+	var problem response.Problem
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+	assert.Equal(t, response.CodeInternal, problem.Code)
+	assert.Equal(t, requestID, problem.RequestID)
+	assert.NotContains(t, rec.Body.String(), "secret internals")
+
+	lines := decodeLogLines(t, &buf)
+	if assert.Len(t, lines, 2) {
+		assert.Equal(t, "secret internals", lines[0]["panic"])
+		assert.Equal(t, float64(http.StatusInternalServerError), lines[1]["status"])
+	}
+}
+
+func TestRouteFromParams(t *testing.T) {
+	params := httprouter.Params{{Key: "id", Value: "bonn"}, {Key: "path", Value: "/a/b"}}
+	assert.Equal(t, "/stations/:id/files/*path", routeFromParams("/stations/bonn/files/a/b", params))
+	assert.Equal(t, "/stations", routeFromParams("/stations", nil))
+}
+
+func TestIsValidRequestID(t *testing.T) {
+	assert.True(t, IsValidRequestID("0f8c2f0e-6f3c-4b8e-9b1a-3c4d5e6f7a8b"))
+	assert.True(t, IsValidRequestID(NewRequestID()))
+	assert.False(t, IsValidRequestID(""))
+	assert.False(t, IsValidRequestID("id with spaces"))
+	assert.False(t, IsValidRequestID(strings.Repeat("a", MaxRequestIDLength+1)))
+}
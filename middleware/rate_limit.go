@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/timgluz/wasserspiegel/ratelimit"
+	"github.com/timgluz/wasserspiegel/response"
+)
+
+const anonymousKeyID = "anonymous"
+
+// RateLimit limits the requests per API key and route class. Wrap it with
+// BearerAuth, so the authenticated key is known; requests without a key share one bucket.
+// If the limiter state can't be read, the request is let through.
+func RateLimit(h httprouter.Handle, limiter *ratelimit.Limiter, class string) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if limiter == nil {
+			h(w, r, ps)
+			return
+		}
+
+		keyID := anonymousKeyID
+		if apiKey, ok := APIKeyFromContext(r.Context()); ok {
+			keyID = apiKey.ID
+		}
+
+		decision, err := limiter.Allow(keyID, class)
+		if err != nil {
+			// the limiter logs the failure, an unavailable store must not lock out all clients
+			h(w, r, ps)
+			return
+		}
+
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(decision.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+
+		if !decision.Allowed {
+			retryAfter := int(math.Max(1, decision.RetryAfter.Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			response.RenderProblem(w, response.NewProblem(http.StatusTooManyRequests, response.CodeRateLimited,
+				fmt.Sprintf("rate limit of %d requests exceeded, retry after %d seconds", decision.Limit, retryAfter)))
+			return
+		}
+
+		h(w, r, ps)
+	}
+}
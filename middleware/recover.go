@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/timgluz/wasserspiegel/log"
+	"github.com/timgluz/wasserspiegel/response"
+)
+
+// Recover turns a panic of the handler into a 500 problem and logs it with the stack.
+// The panic value isn't rendered, it may contain internals.
+func Recover(h http.Handler, logger *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder := &statusRecorder{ResponseWriter: w}
+
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+
+			if recovered == http.ErrAbortHandler {
+				panic(recovered) // net/http aborts the response silently
+			}
+
+			log.FromContext(r.Context(), logger).Error("Recovered from panic in handler",
+				"panic", recovered, "method", r.Method, "path", r.URL.Path, "stack", string(debug.Stack()))
+
+			if recorder.wroteHeader() {
+				return // too late for a problem, the client gets a truncated response
+			}
+
+			response.RenderProblem(recorder, response.NewProblem(http.StatusInternalServerError, response.CodeInternal, "internal server error"))
+		}()
+
+		h.ServeHTTP(recorder, r)
+	})
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+
+	"github.com/timgluz/wasserspiegel/log"
+	"github.com/timgluz/wasserspiegel/response"
+)
+
+// MaxRequestIDLength limits the accepted X-Request-ID headers, longer IDs are replaced.
+const MaxRequestIDLength = 128
+
+// RequestID accepts the X-Request-ID of the caller or generates one. The ID is
+// echoed in the response header and added to the context, together with a
+// logger that adds the ID to every line, see log.FromContext.
+func RequestID(h http.Handler, logger *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(response.RequestIDHeader)
+		if !IsValidRequestID(requestID) {
+			requestID = NewRequestID()
+		}
+
+		w.Header().Set(response.RequestIDHeader, requestID)
+
+		ctx := WithRequestID(r.Context(), requestID)
+		ctx = log.WithLogger(ctx, logger.With("request_id", requestID))
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// NewRequestID returns a random ID of 32 hex characters.
+func NewRequestID() string {
+	var id [16]byte
+	_, _ = rand.Read(id[:]) // never fails, see crypto/rand.Read
+	return hex.EncodeToString(id[:])
+}
+
+// IsValidRequestID allows IDs of letters, digits and -_.: so they can't inject into logs or headers.
+func IsValidRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > MaxRequestIDLength {
+		return false
+	}
+
+	for _, c := range requestID {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
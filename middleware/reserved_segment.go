@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// ReservedSegment serves reserved instead of h if the parameter has the given
+// value, e.g. `/stations/health` next to `/stations/:id`; httprouter v1.3
+// panics on a static segment next to a parameter. It must wrap the
+// authentication of h, the reserved handle decides about its own.
+func ReservedSegment(param, value string, reserved httprouter.Handle, h httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if ps.ByName(param) == value {
+			reserved(w, r, ps)
+			return
+		}
+
+		h(w, r, ps)
+	}
+}
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/timgluz/wasserspiegel/api/apierror"
+	"github.com/timgluz/wasserspiegel/response"
+	"github.com/timgluz/wasserspiegel/secret"
+)
+
+// ShareVerifier validates share tokens for a resource.
+type ShareVerifier interface {
+	Verify(token, resource string) (*secret.ShareGrant, error)
+}
+
+// ResourceFunc maps the route params to the shared resource, e.g. `dashboard:<id>`.
+type ResourceFunc func(ps httprouter.Params) string
+
+// ShareAuth grants read-only access to h for requests carrying a valid
+// `share_token` query parameter; all other requests are passed to authenticated,
+// usually h wrapped with BearerAuth. Share link responses allow cross-origin embedding.
+func ShareAuth(h httprouter.Handle, authenticated httprouter.Handle, verifier ShareVerifier, resource ResourceFunc, scopes ...string) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		token := r.URL.Query().Get(secret.ShareTokenParam)
+		if token == "" {
+			authenticated(w, r, ps)
+			return
+		}
+
+		SetCORSHeaders(w)
+		if verifier == nil {
+			response.RenderProblem(w, response.NewProblem(http.StatusServiceUnavailable, response.CodeServiceUnavailable, "service is not ready"))
+			return
+		}
+
+		grant, err := verifier.Verify(token, resource(ps))
+		if err != nil {
+			// invalid, expired and revoked tokens are mapped to 401
+			apierror.Render(w, fmt.Errorf("failed to verify share token: %w", err), http.StatusInternalServerError)
+			return
+		}
+
+		// the response depends on the token, shared caches must not reuse it for other links
+		w.Header().Set("Cache-Control", "private")
+		h(w, r.WithContext(WithAPIKey(r.Context(), grant.APIKey(scopes...))), ps)
+	}
+}
+
+// SetCORSHeaders allows read-only cross-origin requests, e.g. from embedded widgets.
+func SetCORSHeaders(w http.ResponseWriter) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Accept, If-None-Match, If-Modified-Since")
+	w.Header().Set("Access-Control-Max-Age", "86400")
+}
+
+// CORSPreflight answers OPTIONS requests of browsers before cross-origin GET requests.
+func CORSPreflight(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	SetCORSHeaders(w)
+	w.WriteHeader(http.StatusNoContent)
+}
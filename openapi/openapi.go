@@ -0,0 +1,155 @@
+package openapi
+
+import (
+	"net/http"
+	"strings"
+)
+
+const (
+	Version = "3.1.0"
+	// DocumentPath is where the root serves the generated document; every
+	// component serves it below its prefix too, e.g. /stations/openapi.json.
+	DocumentPath = "/openapi.json"
+
+	bearerAuthScheme = "bearerAuth"
+)
+
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas,omitempty"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme,omitempty"`
+}
+
+type SecurityRequirement map[string][]string
+
+type PathItem struct {
+	Get    *Operation `json:"get,omitempty"`
+	Post   *Operation `json:"post,omitempty"`
+	Put    *Operation `json:"put,omitempty"`
+	Delete *Operation `json:"delete,omitempty"`
+}
+
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []SecurityRequirement `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required,omitempty"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string                `json:"description"`
+	Headers     map[string]*Header    `json:"headers,omitempty"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+func NewDocument(title, version string) *Document {
+	return &Document{
+		OpenAPI: Version,
+		Info:    Info{Title: title, Version: version},
+		Paths:   map[string]*PathItem{},
+		Components: Components{
+			Schemas: map[string]*Schema{},
+			SecuritySchemes: map[string]*SecurityScheme{
+				bearerAuthScheme: {Type: "http", Scheme: "bearer"},
+			},
+		},
+	}
+}
+
+// AddOperation registers the operation for the method and path; the path uses
+// OpenAPI templates, e.g. /stations/{id}.
+func (d *Document) AddOperation(method, path string, op *Operation) {
+	item, ok := d.Paths[path]
+	if !ok {
+		item = &PathItem{}
+		d.Paths[path] = item
+	}
+
+	switch method {
+	case http.MethodGet:
+		item.Get = op
+	case http.MethodPost:
+		item.Post = op
+	case http.MethodPut:
+		item.Put = op
+	case http.MethodDelete:
+		item.Delete = op
+	}
+}
+
+// OperationLink returns a link into the document served below the prefix that points
+// at the operation, e.g. /tasks/openapi.json#/paths/~1tasks~1buildDashboard/post.
+func OperationLink(prefix, method, path string) string {
+	pointer := strings.NewReplacer("~", "~0", "/", "~1").Replace(path)
+	return prefix + DocumentPath + "#/paths/" + pointer + "/" + strings.ToLower(method)
+}
+
+// ServiceDescLink is the value of the Link header that components attach to
+// their responses; it points at the document served below the prefix, see ServeDocument.
+func ServiceDescLink(prefix string) string {
+	return "<" + prefix + DocumentPath + `>; rel="service-desc"`
+}
+
+func QueryParam(name, description string, required bool, schema *Schema) Parameter {
+	return Parameter{Name: name, In: "query", Description: description, Required: required, Schema: schema}
+}
+
+func PathParam(name, description string) Parameter {
+	return Parameter{Name: name, In: "path", Description: description, Required: true, Schema: &Schema{Type: "string"}}
+}
+
+func JSONContent(schema *Schema) map[string]*MediaType {
+	return map[string]*MediaType{"application/json": {Schema: schema}}
+}
+
+// ProblemContent is the content of RFC 7807 error responses.
+func ProblemContent(schema *Schema) map[string]*MediaType {
+	return map[string]*MediaType{"application/problem+json": {Schema: schema}}
+}
+
+func BearerSecurity() []SecurityRequirement {
+	return []SecurityRequirement{{bearerAuthScheme: []string{}}}
+}
@@ -0,0 +1,205 @@
+package openapi
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/timgluz/wasserspiegel/measurement"
+)
+
+var (
+	timeType  = reflect.TypeOf(time.Time{})
+	flagsType = reflect.TypeOf(measurement.Flags(0))
+)
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+}
+
+func StringSchema(description string) *Schema {
+	return &Schema{Type: "string", Description: description}
+}
+
+func IntegerSchema(description string) *Schema {
+	return &Schema{Type: "integer", Description: description}
+}
+
+func ArrayOf(items *Schema) *Schema {
+	return &Schema{Type: "array", Items: items}
+}
+
+// ObjectOf builds an inline object schema, all given properties are required.
+func ObjectOf(properties map[string]*Schema) *Schema {
+	required := make([]string, 0, len(properties))
+	for name := range properties {
+		required = append(required, name)
+	}
+
+	return &Schema{Type: "object", Properties: properties, Required: sortedStrings(required)}
+}
+
+// SchemaOf derives a schema from the Go value using its `json` struct tags.
+// Named structs are registered in the document components and referenced by name.
+func (d *Document) SchemaOf(value any) *Schema {
+	return d.schemaOfType(reflect.TypeOf(value))
+}
+
+func (d *Document) schemaOfType(t reflect.Type) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"} // time.Time encodes as RFC 3339
+	}
+	if t == flagsType {
+		return ArrayOf(&Schema{Type: "string", Enum: (measurement.FlagsInvalid | measurement.FlagFlatline | measurement.FlagGap).Names()}) // quality flags encode as names
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return ArrayOf(d.schemaOfType(t.Elem()))
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.schemaOfType(t.Elem())}
+	case reflect.Struct:
+		return d.structSchema(t)
+	}
+
+	// interfaces and other kinds accept any value
+	return &Schema{}
+}
+
+func (d *Document) structSchema(t reflect.Type) *Schema {
+	name := componentName(t)
+	if name == "" {
+		return d.inlineStructSchema(t)
+	}
+
+	ref := &Schema{Ref: "#/components/schemas/" + name}
+	if _, ok := d.Components.Schemas[name]; ok {
+		return ref
+	}
+
+	// register a placeholder first, so recursive types terminate
+	d.Components.Schemas[name] = &Schema{}
+	*d.Components.Schemas[name] = *d.inlineStructSchema(t)
+	return ref
+}
+
+func (d *Document) inlineStructSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	var required []string
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, omitEmpty, skip := parseJSONTag(field)
+		if skip {
+			continue
+		}
+
+		if field.Anonymous && name == "" && indirect(field.Type).Kind() == reflect.Struct {
+			embedded := d.inlineStructSchema(indirect(field.Type))
+			for propName, propSchema := range embedded.Properties {
+				schema.Properties[propName] = propSchema
+			}
+			required = append(required, embedded.Required...)
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		schema.Properties[name] = d.schemaOfType(field.Type)
+		if !omitEmpty && field.Type.Kind() != reflect.Pointer {
+			required = append(required, name)
+		}
+	}
+
+	schema.Required = sortedStrings(required)
+	return schema
+}
+
+// componentName returns a package qualified name like `station.Station`;
+// anonymous and generic types are inlined instead.
+func componentName(t reflect.Type) string {
+	if t.Name() == "" || strings.Contains(t.Name(), "[") {
+		return ""
+	}
+
+	pkgPath := t.PkgPath()
+	pkgName := pkgPath[strings.LastIndex(pkgPath, "/")+1:]
+	if pkgName == "" {
+		return t.Name()
+	}
+
+	return pkgName + "." + t.Name()
+}
+
+func parseJSONTag(field reflect.StructField) (name string, omitEmpty bool, skip bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false, true
+	}
+
+	parts := strings.Split(tag, ",")
+	for _, option := range parts[1:] {
+		if option == "omitempty" || option == "omitzero" {
+			omitEmpty = true
+		}
+	}
+
+	return parts[0], omitEmpty, false
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+func sortedStrings(values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+
+	sorted := make([]string, len(values))
+	copy(sorted, values)
+	sort.Strings(sorted)
+	return sorted
+}
@@ -0,0 +1,622 @@
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/timgluz/wasserspiegel/dashboard"
+	"github.com/timgluz/wasserspiegel/health"
+	"github.com/timgluz/wasserspiegel/i18n"
+	"github.com/timgluz/wasserspiegel/measurement"
+	"github.com/timgluz/wasserspiegel/ratelimit"
+	"github.com/timgluz/wasserspiegel/response"
+	"github.com/timgluz/wasserspiegel/secret"
+	"github.com/timgluz/wasserspiegel/station"
+)
+
+const (
+	DocumentTitle   = "Wasserspiegel API"
+	DocumentVersion = "0.1.0"
+)
+
+// NewWasserspiegelDocument describes the routes of all wasserspiegel components.
+func NewWasserspiegelDocument() *Document {
+	doc := NewDocument(DocumentTitle, DocumentVersion)
+	doc.Info.Description = "Water levels of German federal waterways, collected from PegelOnline."
+
+	errorSchema := doc.SchemaOf(response.Problem{})
+	postResponseSchema := doc.SchemaOf(response.Response{})
+	paginationSchema := doc.SchemaOf(response.Pagination{})
+	paginationParams := []Parameter{
+		QueryParam("limit", "Maximum number of items to return", false, IntegerSchema("")),
+		QueryParam("offset", "Number of items to skip", false, IntegerSchema("")),
+	}
+
+	addStationOperations(doc, paginationParams, paginationSchema, errorSchema)
+	addSearchOperations(doc, paginationParams, paginationSchema, errorSchema)
+	addMeasurementOperations(doc, paginationParams, postResponseSchema, errorSchema)
+	addDashboardOperations(doc, paginationParams, errorSchema)
+	addTaskOperations(doc, postResponseSchema, errorSchema)
+	addAdminOperations(doc, errorSchema)
+	addServiceOperations(doc)
+	addHealthOperations(doc)
+
+	addConditionalResponses(doc,
+		"/stations", "/stations/{id}", "/stations/{id}/waterlevel/", "/search/stations",
+		"/measurements", "/measurements/{name}", "/dashboards", "/dashboards/{id}",
+	)
+
+	return doc
+}
+
+// componentPrefixes are the routes of the components, see spin.toml.
+var componentPrefixes = []string{"/stations", "/search", "/measurements", "/dashboards", "/tasks", "/metrics", "/admin"}
+
+// Handler serves the document as JSON.
+func Handler(doc *Document) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response.RenderJSON(w, doc)
+	}
+}
+
+// ServeDocument answers a request of the document below the prefix of a
+// component, so the service-desc link of a component deployed alone resolves.
+// It returns false for other paths.
+func ServeDocument(w http.ResponseWriter, r *http.Request, prefix string) bool {
+	if r.Method != http.MethodGet || r.URL.Path != prefix+DocumentPath {
+		return false
+	}
+
+	Handler(NewWasserspiegelDocument())(w, r)
+	return true
+}
+
+// unitParam is the parameter of the read operations that convert the values to another unit.
+func unitParam() Parameter {
+	return QueryParam("unit", "Unit to convert the values to, e.g. m, of the same dimension as the unit of the measurement: "+strings.Join(measurement.UnitSymbols(), ", "), false, StringSchema(""))
+}
+
+func addStationOperations(doc *Document, paginationParams []Parameter, paginationSchema, errorSchema *Schema) {
+	doc.AddOperation(http.MethodGet, "/stations", &Operation{
+		OperationID: "listStations",
+		Summary:     "List stations",
+		Tags:        []string{"stations"},
+		Parameters:  paginationParams,
+		Responses: map[string]*Response{
+			"200": jsonResponse("Stations", ObjectOf(map[string]*Schema{
+				"stations":   ArrayOf(doc.SchemaOf(station.Station{})),
+				"pagination": paginationSchema,
+			})),
+			"401": problemResponse("Missing or invalid API key", errorSchema),
+		},
+		Security: BearerSecurity(),
+	})
+
+	stationDashboardSchema := ObjectOf(map[string]*Schema{
+		"station":     doc.SchemaOf(station.Station{}),
+		"water_level": doc.SchemaOf(station.WaterLevelCollection{}),
+	})
+	// missing until the buildBaselines task found enough history for the day
+	stationDashboardSchema.Properties["historical"] = doc.SchemaOf(measurement.HistoricalContext{})
+
+	trendParams := []Parameter{
+		QueryParam("trend", fmt.Sprintf("Comma separated ISO 8601 durations to compare the latest water level with, at most %d, default P1D,P3D,P7D", station.MaxTrendWindows), false, StringSchema("")),
+		QueryParam("tz", "IANA time zone of the day boundaries of the trend, e.g. Europe/Berlin, default UTC", false, StringSchema("")),
+		QueryParam("rate_window", "ISO 8601 duration of the rate of change between PT15M and P1D, default PT3H", false, StringSchema("")),
+	}
+
+	doc.AddOperation(http.MethodGet, "/stations/{id}", &Operation{
+		OperationID: "getStation",
+		Summary:     "Get a station with its latest water levels",
+		Description: "`historical` ranks the latest water level against the levels of all years around the same day of the year.",
+		Tags:        []string{"stations"},
+		Parameters:  append([]Parameter{PathParam("id", "Station ID, e.g. rhein-mannheim"), unitParam()}, trendParams...),
+		Responses: map[string]*Response{
+			"200": jsonResponse("Station with water levels", stationDashboardSchema),
+			"404": problemResponse("Station not found", errorSchema),
+		},
+		Security: BearerSecurity(),
+	})
+
+	doc.AddOperation(http.MethodGet, "/stations/{id}/waterlevel/", &Operation{
+		OperationID: "getStationWaterLevel",
+		Summary:     "Get the water levels of a station",
+		Description: "`trend` compares the latest water level with the average of the day a window ago, or the nearest earlier one without water levels on that day; `rate_of_change` is its change per hour.",
+		Tags:        []string{"stations"},
+		Parameters:  append([]Parameter{PathParam("id", "Station ID"), unitParam()}, trendParams...),
+		Responses: map[string]*Response{
+			"200": jsonResponse("Water levels", doc.SchemaOf(station.WaterLevelCollection{})),
+			"404": problemResponse("No water levels found", errorSchema),
+		},
+		Security: BearerSecurity(),
+	})
+}
+
+func addSearchOperations(doc *Document, paginationParams []Parameter, paginationSchema, errorSchema *Schema) {
+	params := append([]Parameter{
+		QueryParam("q", "Search query, between 3 and 100 characters", true, StringSchema("")),
+	}, paginationParams...)
+
+	doc.AddOperation(http.MethodGet, "/search/stations", &Operation{
+		OperationID: "searchStations",
+		Summary:     "Search stations by name or ID",
+		Tags:        []string{"search"},
+		Parameters:  params,
+		Responses: map[string]*Response{
+			"200": jsonResponse("Matching stations", ObjectOf(map[string]*Schema{
+				"results":    ArrayOf(doc.SchemaOf(station.Station{})),
+				"pagination": paginationSchema,
+			})),
+			"400": problemResponse("Invalid search query", errorSchema),
+			"429": rateLimitedResponse(errorSchema),
+		},
+		Security: BearerSecurity(),
+	})
+}
+
+func addMeasurementOperations(doc *Document, paginationParams []Parameter, postResponseSchema, errorSchema *Schema) {
+	labelParams := []Parameter{
+		QueryParam("label.station_id", "Only measurements of the station, e.g. bonn", false, StringSchema("")),
+		QueryParam("label.parameter", "Only measurements of the parameter, e.g. waterlevel", false, StringSchema("")),
+		QueryParam("label.provider", "Only measurements of the provider, e.g. pegelonline", false, StringSchema("")),
+		QueryParam("label.unit", "Only measurements in the unit, e.g. cm", false, StringSchema("")),
+	}
+	doc.AddOperation(http.MethodGet, "/measurements", &Operation{
+		OperationID: "listMeasurements",
+		Summary:     "List measurements",
+		Description: "Measurements ordered by name whose labels equal all given `label.<key>` parameters.",
+		Tags:        []string{"measurements"},
+		Parameters:  append(labelParams, paginationParams...),
+		Responses: map[string]*Response{
+			"200": jsonResponse("Measurements", doc.SchemaOf(response.CollectionResponse[measurement.Measurement]{})),
+			"400": problemResponse("Unknown label or invalid pagination", errorSchema),
+		},
+		Security: BearerSecurity(),
+	})
+
+	doc.AddOperation(http.MethodPost, "/measurements", &Operation{
+		OperationID: "createMeasurement",
+		Summary:     "Create a measurement",
+		Tags:        []string{"measurements"},
+		RequestBody: &RequestBody{Required: true, Content: JSONContent(doc.SchemaOf(measurement.Measurement{}))},
+		Responses: map[string]*Response{
+			"200": jsonResponse("Measurement created", postResponseSchema),
+			"400": problemResponse("Invalid measurement", errorSchema),
+			"409": problemResponse("Measurement already exists", errorSchema),
+		},
+		Security: BearerSecurity(),
+	})
+
+	doc.AddOperation(http.MethodGet, "/measurements/_freshness", &Operation{
+		OperationID: "getMeasurementFreshness",
+		Summary:     "Report stale measurements",
+		Description: "A series is stale when no sample arrived within a multiple of its cadence, the median interval of its recent samples.",
+		Tags:        []string{"measurements"},
+		Parameters: []Parameter{
+			QueryParam("all", "Include fresh series", false, &Schema{Type: "boolean"}),
+		},
+		Responses: map[string]*Response{
+			"200": jsonResponse("Freshness report", doc.SchemaOf(measurement.FreshnessReport{})),
+			"400": problemResponse("Invalid parameter", errorSchema),
+		},
+		Security: BearerSecurity(),
+	})
+
+	doc.AddOperation(http.MethodGet, "/measurements/{name}", &Operation{
+		OperationID: "getTimeseries",
+		Summary:     "Get the timeseries of a measurement",
+		Description: "Either `period` or both `start` and `end` are required.",
+		Tags:        []string{"measurements"},
+		Parameters: []Parameter{
+			PathParam("name", "Measurement name, e.g. waterlevel-rhein-koeln"),
+			QueryParam("period", "ISO 8601 duration until now, e.g. P3D", false, StringSchema("")),
+			QueryParam("start", "Start as epoch seconds", false, IntegerSchema("")),
+			QueryParam("end", "End as epoch seconds", false, IntegerSchema("")),
+			QueryParam("flagged", "Whether to include or exclude samples flagged as range or spike; defaults to include", false, &Schema{Type: "string", Enum: []string{"include", "exclude"}}),
+			QueryParam("step", "ISO 8601 duration of at least PT1M, resamples to the multiples of the step, e.g. PT15M", false, StringSchema("")),
+			QueryParam("interpolation", "Fills the grid points between samples; defaults to linear", false, &Schema{Type: "string", Enum: []string{"linear", "previous", "none"}}),
+			QueryParam("max_gap", "ISO 8601 duration, gaps between samples longer than it stay null; defaults to PT1H, P0D fills all gaps", false, StringSchema("")),
+			unitParam(),
+		},
+		Responses: map[string]*Response{
+			"200": jsonResponse("Timeseries, resampled with a step", &Schema{OneOf: []*Schema{
+				doc.SchemaOf(measurement.Timeseries{}),
+				doc.SchemaOf(measurement.ResampledTimeseries{}),
+			}}),
+			"400": problemResponse("Invalid period, flagged, resampling parameter or unit", errorSchema),
+		},
+		Security: BearerSecurity(),
+	})
+
+	doc.AddOperation(http.MethodGet, "/measurements/{name}/forecast", &Operation{
+		OperationID: "getForecast",
+		Summary:     "Forecast a measurement",
+		Description: "Fits a robust regression to the samples of the window before now and projects it with prediction intervals.",
+		Tags:        []string{"measurements"},
+		Parameters: []Parameter{
+			PathParam("name", "Measurement name, e.g. waterlevel-rhein-koeln"),
+			QueryParam("model", "Fitted curve; defaults to linear", false, &Schema{Type: "string", Enum: []string{"linear", "quadratic"}}),
+			QueryParam("window", "ISO 8601 duration of the fitted samples between PT3H and P7D; defaults to PT24H", false, StringSchema("")),
+			QueryParam("horizon", "ISO 8601 duration of the projection between PT6H and PT48H; defaults to PT24H", false, StringSchema("")),
+			QueryParam("step", "ISO 8601 duration between the projected points; defaults to PT1H", false, StringSchema("")),
+			QueryParam("confidence", "Confidence of the prediction intervals; defaults to 0.9", false, &Schema{Type: "number"}),
+			unitParam(),
+		},
+		Responses: map[string]*Response{
+			"200": jsonResponse("Forecast", doc.SchemaOf(measurement.Forecast{})),
+			"400": problemResponse("Invalid forecast options", errorSchema),
+			"404": problemResponse("Unknown measurement", errorSchema),
+			"422": problemResponse("Not enough samples in the window", errorSchema),
+		},
+		Security: BearerSecurity(),
+	})
+
+	doc.AddOperation(http.MethodGet, "/measurements/{name}/stats", &Operation{
+		OperationID: "getMeasurementStats",
+		Summary:     "Get statistics of a measurement",
+		Description: "Summarizes the samples of the period per calendar day, ISO week or month of the time zone; flagged samples are left out. Either `period` or both `start` and `end` are required.",
+		Tags:        []string{"measurements"},
+		Parameters: []Parameter{
+			PathParam("name", "Measurement name, e.g. waterlevel-rhein-koeln"),
+			QueryParam("period", "ISO 8601 duration until now, e.g. P30D", false, StringSchema("")),
+			QueryParam("start", "Start as epoch seconds", false, IntegerSchema("")),
+			QueryParam("end", "End as epoch seconds", false, IntegerSchema("")),
+			QueryParam("group_by", "Calendar bucket; without it the period is one bucket", false, &Schema{Type: "string", Enum: []string{"day", "week", "month"}}),
+			QueryParam("tz", "IANA time zone of the calendar, e.g. Europe/Berlin; defaults to UTC", false, StringSchema("")),
+			unitParam(),
+		},
+		Responses: map[string]*Response{
+			"200": jsonResponse("Statistics per bucket", doc.SchemaOf(measurement.Stats{})),
+			"400": problemResponse("Invalid period, group_by, tz or unit", errorSchema),
+			"404": problemResponse("Unknown measurement", errorSchema),
+		},
+		Security: BearerSecurity(),
+	})
+
+	doc.AddOperation(http.MethodPost, "/measurements/{name}", &Operation{
+		OperationID: "addTimeseries",
+		Summary:     "Add samples to a measurement",
+		Tags:        []string{"measurements"},
+		Parameters:  []Parameter{PathParam("name", "Measurement name")},
+		RequestBody: &RequestBody{Required: true, Content: JSONContent(doc.SchemaOf(measurement.Timeseries{}))},
+		Responses: map[string]*Response{
+			"200": jsonResponse("Timeseries added", postResponseSchema),
+			"400": problemResponse("Invalid timeseries", errorSchema),
+		},
+		Security: BearerSecurity(),
+	})
+
+	doc.AddOperation(http.MethodPut, "/measurements/{name}", &Operation{
+		OperationID: "updateMeasurement",
+		Summary:     "Update a measurement",
+		Description: "Replaces the description and labels; the name and unit can't change.",
+		Tags:        []string{"measurements"},
+		Parameters:  []Parameter{PathParam("name", "Measurement name")},
+		RequestBody: &RequestBody{Required: true, Content: JSONContent(doc.SchemaOf(measurement.Measurement{}))},
+		Responses: map[string]*Response{
+			"200": jsonResponse("Updated measurement", doc.SchemaOf(measurement.Measurement{})),
+			"400": problemResponse("Invalid measurement", errorSchema),
+			"404": problemResponse("Unknown measurement", errorSchema),
+			"422": problemResponse("Another unit than the one of the measurement", errorSchema),
+		},
+		Security: BearerSecurity(),
+	})
+
+	doc.AddOperation(http.MethodDelete, "/measurements/{name}", &Operation{
+		OperationID: "deleteMeasurement",
+		Summary:     "Delete a measurement",
+		Description: "Deletes the measurement with its samples and baselines.",
+		Tags:        []string{"measurements"},
+		Parameters:  []Parameter{PathParam("name", "Measurement name")},
+		Responses: map[string]*Response{
+			"200": jsonResponse("Measurement deleted", postResponseSchema),
+			"404": problemResponse("Unknown measurement", errorSchema),
+		},
+		Security: BearerSecurity(),
+	})
+}
+
+func addDashboardOperations(doc *Document, paginationParams []Parameter, errorSchema *Schema) {
+	doc.AddOperation(http.MethodGet, "/dashboards", &Operation{
+		OperationID: "listDashboards",
+		Summary:     "List dashboards",
+		Tags:        []string{"dashboards"},
+		Parameters:  paginationParams,
+		Responses: map[string]*Response{
+			"200": jsonResponse("Dashboards", doc.SchemaOf(dashboard.Collection{})),
+		},
+		Security: BearerSecurity(),
+	})
+
+	doc.AddOperation(http.MethodGet, "/dashboards/{id}", &Operation{
+		OperationID: "getDashboard",
+		Summary:     "Get a dashboard",
+		Tags:        []string{"dashboards"},
+		Parameters: []Parameter{
+			PathParam("id", "Dashboard ID, e.g. rhein-koeln-en-utc"),
+			QueryParam(secret.ShareTokenParam, "Share token, grants read-only access without an API key", false, StringSchema("")),
+			unitParam(),
+		},
+		Responses: map[string]*Response{
+			"200": jsonResponse("Dashboard", doc.SchemaOf(dashboard.Dashboard{})),
+			"401": problemResponse("Missing API key or invalid, expired or revoked share token", errorSchema),
+			"404": problemResponse("Dashboard not found", errorSchema),
+		},
+		// an empty requirement makes the API key optional, as a share token can be used instead
+		Security: append(BearerSecurity(), SecurityRequirement{}),
+	})
+
+	doc.AddOperation(http.MethodPost, "/dashboards/{id}/share", &Operation{
+		OperationID: "shareDashboard",
+		Summary:     "Create a signed, expiring share link for a dashboard",
+		Description: "Requires the `dashboards:write` scope. The token is only returned in this response.",
+		Tags:        []string{"dashboards"},
+		Parameters:  []Parameter{PathParam("id", "Dashboard ID")},
+		RequestBody: &RequestBody{Content: JSONContent(&Schema{
+			Type: "object",
+			Properties: map[string]*Schema{
+				"expires_in": StringSchema("ISO 8601 duration until the link expires, default P7D, at most P365D"),
+			},
+		})},
+		Responses: map[string]*Response{
+			"200": jsonResponse("Share link created", ObjectOf(map[string]*Schema{
+				"success": {Type: "boolean"},
+				"data":    doc.SchemaOf(dashboard.ShareLink{}),
+			})),
+			"400": problemResponse("Invalid expiry", errorSchema),
+			"404": problemResponse("Dashboard not found", errorSchema),
+		},
+		Security: BearerSecurity(),
+	})
+
+	doc.AddOperation(http.MethodGet, "/dashboards/{id}/share", &Operation{
+		OperationID: "listDashboardShares",
+		Summary:     "List the share links of a dashboard, including revoked ones",
+		Tags:        []string{"dashboards"},
+		Parameters:  []Parameter{PathParam("id", "Dashboard ID")},
+		Responses: map[string]*Response{
+			"200": jsonResponse("Share links", doc.SchemaOf(response.CollectionResponse[secret.ShareGrant]{})),
+		},
+		Security: BearerSecurity(),
+	})
+
+	doc.AddOperation(http.MethodDelete, "/dashboards/{id}/share/{share_id}", &Operation{
+		OperationID: "revokeDashboardShare",
+		Summary:     "Revoke a share link",
+		Tags:        []string{"dashboards"},
+		Parameters:  []Parameter{PathParam("id", "Dashboard ID"), PathParam("share_id", "Share link ID")},
+		Responses: map[string]*Response{
+			"200": jsonResponse("Share link revoked", doc.SchemaOf(response.Response{})),
+			"404": problemResponse("Share link not found", errorSchema),
+		},
+		Security: BearerSecurity(),
+	})
+}
+
+func addTaskOperations(doc *Document, postResponseSchema, errorSchema *Schema) {
+	doc.AddOperation(http.MethodPost, "/tasks/collectStationMeasurements", &Operation{
+		OperationID: "collectStationMeasurements",
+		Summary:     "Collect water level measurements for a station",
+		Tags:        []string{"tasks"},
+		Parameters: []Parameter{
+			QueryParam("station_id", "ID of the station to collect measurements for", true, StringSchema("")),
+			QueryParam("period", "ISO 8601 duration to collect, default P3D", false, StringSchema("")),
+		},
+		Responses: map[string]*Response{
+			"200": jsonResponse("Measurements collected, with the retries and circuit breaker events of the upstream requests", postResponseSchema),
+			"400": problemResponse("Missing station ID or invalid period", errorSchema),
+			"429": rateLimitedResponse(errorSchema),
+			"500": problemResponse("Collection failed, the data of the problem has the retries and circuit breaker events of the upstream requests", errorSchema),
+			"503": jsonResponse("Circuit breaker is open, upstream requests are paused", postResponseSchema),
+		},
+		Security: BearerSecurity(),
+	})
+
+	doc.AddOperation(http.MethodPost, "/tasks/buildDashboard", &Operation{
+		OperationID: "buildDashboard",
+		Summary:     "Build the dashboard for a station",
+		Tags:        []string{"tasks"},
+		Parameters: []Parameter{
+			QueryParam("station_id", "ID of the station to build the dashboard for", true, StringSchema("")),
+			QueryParam("language_code", "Language of the display fields of the dashboard, "+strings.Join(i18n.SupportedLanguages(), " or ")+", default en", false, StringSchema("")),
+			QueryParam("timezone", "IANA timezone of the display fields and trend days of the dashboard, e.g. Europe/Berlin, default utc", false, StringSchema("")),
+			QueryParam("forecast_horizon", "ISO 8601 duration of the water level forecast between PT6H and PT48H, default PT24H", false, StringSchema("")),
+			QueryParam("trend", fmt.Sprintf("Comma separated ISO 8601 durations of the trend windows, at most %d, default P1D,P3D,P7D", station.MaxTrendWindows), false, StringSchema("")),
+			QueryParam("rate_window", "ISO 8601 duration of the rate of change between PT15M and P1D, default PT3H", false, StringSchema("")),
+		},
+		Responses: map[string]*Response{
+			"200": jsonResponse("Dashboard built", postResponseSchema),
+			"400": problemResponse("Missing station ID", errorSchema),
+			"429": rateLimitedResponse(errorSchema),
+		},
+		Security: BearerSecurity(),
+	})
+
+	doc.AddOperation(http.MethodPost, "/tasks/buildBaselines", &Operation{
+		OperationID: "buildBaselines",
+		Summary:     "Build the historical baselines of the water levels",
+		Description: "Computes the percentiles of the water levels of all years around every day of the year; meant to run daily.",
+		Tags:        []string{"tasks"},
+		Parameters: []Parameter{
+			QueryParam("station_id", "ID of the station, all stations if missing", false, StringSchema("")),
+			QueryParam("window_days", fmt.Sprintf("Days before and after the day of the year, between 1 and %d, default %d", measurement.MaxBaselineWindowDays, measurement.DefaultBaselineWindowDays), false, IntegerSchema("")),
+		},
+		Responses: map[string]*Response{
+			"200": jsonResponse("Baselines built", postResponseSchema),
+			"400": problemResponse("Invalid window", errorSchema),
+			"404": problemResponse("Station has no water levels", errorSchema),
+			"429": rateLimitedResponse(errorSchema),
+		},
+		Security: BearerSecurity(),
+	})
+}
+
+func addAdminOperations(doc *Document, errorSchema *Schema) {
+	keyRequestSchema := ObjectOf(map[string]*Schema{
+		"name":   StringSchema("Name of the key, e.g. the device using it"),
+		"scopes": ArrayOf(&Schema{Type: "string", Enum: secret.KnownScopes}),
+	})
+	keyRequestSchema.Properties["expires_in"] = StringSchema("ISO 8601 duration until the key expires, e.g. P90D; keys without expiry never expire")
+
+	doc.AddOperation(http.MethodPost, "/admin/keys", &Operation{
+		OperationID: "createAPIKey",
+		Summary:     "Create a scoped API key",
+		Description: "Requires the `keys:admin` scope. The token is only returned in this response.",
+		Tags:        []string{"admin"},
+		RequestBody: &RequestBody{Required: true, Content: JSONContent(keyRequestSchema)},
+		Responses: map[string]*Response{
+			"200": jsonResponse("API key created", ObjectOf(map[string]*Schema{
+				"success": {Type: "boolean"},
+				"data":    doc.SchemaOf(secret.IssuedKey{}),
+			})),
+			"400": problemResponse("Invalid name, scopes or expiry", errorSchema),
+			"403": problemResponse("Missing keys:admin scope", errorSchema),
+		},
+		Security: BearerSecurity(),
+	})
+
+	doc.AddOperation(http.MethodGet, "/admin/keys", &Operation{
+		OperationID: "listAPIKeys",
+		Summary:     "List API keys, including revoked and expired ones",
+		Tags:        []string{"admin"},
+		Responses: map[string]*Response{
+			"200": jsonResponse("API keys", doc.SchemaOf(response.CollectionResponse[secret.APIKey]{})),
+			"403": problemResponse("Missing keys:admin scope", errorSchema),
+		},
+		Security: BearerSecurity(),
+	})
+
+	doc.AddOperation(http.MethodGet, "/admin/keys/{id}/usage", &Operation{
+		OperationID: "getAPIKeyUsage",
+		Summary:     "Daily request counters of an API key, newest day first",
+		Tags:        []string{"admin"},
+		Parameters: []Parameter{
+			PathParam("id", "Key ID"),
+			QueryParam("days", "Number of days including today, between 1 and 90, default 7", false, IntegerSchema("")),
+		},
+		Responses: map[string]*Response{
+			"200": jsonResponse("Daily usage", doc.SchemaOf(response.CollectionResponse[ratelimit.DailyUsage]{})),
+			"400": problemResponse("Invalid number of days", errorSchema),
+		},
+		Security: BearerSecurity(),
+	})
+
+	doc.AddOperation(http.MethodGet, "/admin/usage", &Operation{
+		OperationID: "getDailyUsage",
+		Summary:     "Request counters of all API keys on a UTC day",
+		Tags:        []string{"admin"},
+		Parameters:  []Parameter{QueryParam("date", "Day as YYYY-MM-DD, default today", false, StringSchema(""))},
+		Responses: map[string]*Response{
+			"200": jsonResponse("Daily usage", doc.SchemaOf(response.CollectionResponse[ratelimit.DailyUsage]{})),
+			"400": problemResponse("Invalid date", errorSchema),
+		},
+		Security: BearerSecurity(),
+	})
+
+	doc.AddOperation(http.MethodDelete, "/admin/keys/{id}", &Operation{
+		OperationID: "revokeAPIKey",
+		Summary:     "Revoke an API key",
+		Tags:        []string{"admin"},
+		Parameters:  []Parameter{PathParam("id", "Key ID")},
+		Responses: map[string]*Response{
+			"200": jsonResponse("API key revoked", doc.SchemaOf(response.Response{})),
+			"404": problemResponse("API key not found", errorSchema),
+		},
+		Security: BearerSecurity(),
+	})
+}
+
+func addServiceOperations(doc *Document) {
+	doc.AddOperation(http.MethodGet, "/metrics", &Operation{
+		OperationID: "getMetrics",
+		Summary:     "Latest readings and service counters in the OpenMetrics text format",
+		Tags:        []string{"service"},
+		Responses: map[string]*Response{
+			"200": {
+				Description: "OpenMetrics exposition",
+				Content:     map[string]*MediaType{"application/openmetrics-text": {Schema: StringSchema("")}},
+			},
+		},
+		Security: BearerSecurity(),
+	})
+
+	doc.AddOperation(http.MethodGet, DocumentPath, &Operation{
+		OperationID: "getOpenAPIDocument",
+		Summary:     "This document",
+		Tags:        []string{"service"},
+		Responses: map[string]*Response{
+			"200": jsonResponse("OpenAPI document", &Schema{Type: "object"}),
+		},
+	})
+	for _, prefix := range componentPrefixes {
+		component := strings.TrimPrefix(prefix, "/")
+		doc.AddOperation(http.MethodGet, prefix+DocumentPath, &Operation{
+			OperationID: "get" + strings.ToUpper(component[:1]) + component[1:] + "OpenAPIDocument",
+			Summary:     "This document, served by the " + component + " component",
+			Tags:        []string{"service"},
+			Responses: map[string]*Response{
+				"200": jsonResponse("OpenAPI document", &Schema{Type: "object"}),
+			},
+		})
+	}
+}
+
+// addHealthOperations documents the health report of each component, see health.NewHandler.
+func addHealthOperations(doc *Document) {
+	reportSchema := doc.SchemaOf(health.Report{})
+	for _, prefix := range componentPrefixes {
+		component := strings.TrimPrefix(prefix, "/")
+		doc.AddOperation(http.MethodGet, prefix+health.HealthPath, &Operation{
+			OperationID: "get" + strings.ToUpper(component[:1]) + component[1:] + "Health",
+			Summary:     "Status of the dependencies of the " + component + " component, for uptime monitors",
+			Tags:        []string{"service"},
+			Responses: map[string]*Response{
+				"200": jsonResponse("The component is up or degraded", reportSchema),
+				"503": jsonResponse("A required dependency is down", reportSchema),
+			},
+		})
+	}
+}
+
+// addConditionalResponses documents the ETag validation of GET operations rendered with response.RenderConditionalJSON.
+func addConditionalResponses(doc *Document, paths ...string) {
+	for _, path := range paths {
+		item, ok := doc.Paths[path]
+		if !ok || item.Get == nil {
+			continue
+		}
+
+		// copy first, operations may share their parameter slices
+		item.Get.Parameters = append(append([]Parameter{}, item.Get.Parameters...),
+			Parameter{Name: "If-None-Match", In: "header", Description: "ETag of a cached response", Schema: StringSchema("")},
+			Parameter{Name: "If-Modified-Since", In: "header", Description: "Last-Modified time of a cached response", Schema: StringSchema("")},
+		)
+		item.Get.Responses["304"] = &Response{
+			Description: "Not modified, the cached response is still valid",
+			Headers: map[string]*Header{
+				"ETag":          {Schema: StringSchema("")},
+				"Cache-Control": {Schema: StringSchema("")},
+			},
+		}
+	}
+}
+
+func jsonResponse(description string, schema *Schema) *Response {
+	return &Response{Description: description, Content: JSONContent(schema)}
+}
+
+// problemResponse describes an error rendered as application/problem+json.
+func problemResponse(description string, schema *Schema) *Response {
+	return &Response{Description: description, Content: ProblemContent(schema)}
+}
+
+func rateLimitedResponse(errorSchema *Schema) *Response {
+	return &Response{
+		Description: "Rate limit of the API key exceeded",
+		Content:     ProblemContent(errorSchema),
+		Headers: map[string]*Header{
+			"Retry-After": {Description: "Seconds until a request is allowed again", Schema: IntegerSchema("")},
+		},
+	}
+}
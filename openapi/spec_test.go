@@ -0,0 +1,55 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewWasserspiegelDocument(t *testing.T) {
+	doc := NewWasserspiegelDocument()
+
+	assert.Equal(t, Version, doc.OpenAPI)
+	for _, path := range []string{"/stations", "/stations/{id}", "/search/stations", "/measurements/{name}", "/dashboards/{id}", "/tasks/buildDashboard", "/stations/health", "/measurements/_freshness", "/measurements/{name}/stats", "/tasks/buildBaselines"} {
+		assert.Contains(t, doc.Paths, path)
+	}
+
+	for _, name := range []string{"station.Station", "measurement.Timeseries", "dashboard.Dashboard", "response.Pagination", "response.Problem"} {
+		assert.Contains(t, doc.Components.Schemas, name)
+	}
+
+	stationSchema := doc.Components.Schemas["station.Station"]
+	assert.Equal(t, "object", stationSchema.Type)
+	assert.Contains(t, stationSchema.Required, "id")
+	assert.NotContains(t, stationSchema.Required, "external_ids", "omitempty fields are optional")
+	assert.Equal(t, "#/components/schemas/station.Location", stationSchema.Properties["location"].Ref)
+
+	reportSchema := doc.Components.Schemas["health.Report"]
+	assert.Equal(t, "date-time", reportSchema.Properties["checked_at"].Format)
+
+	_, err := json.Marshal(doc)
+	assert.NoError(t, err)
+}
+
+func TestOperationLink(t *testing.T) {
+	link := OperationLink("/tasks", http.MethodPost, "/tasks/buildDashboard")
+	assert.Equal(t, "/tasks/openapi.json#/paths/~1tasks~1buildDashboard/post", link)
+}
+
+func TestServeDocument(t *testing.T) {
+	w := httptest.NewRecorder()
+	assert.True(t, ServeDocument(w, httptest.NewRequest(http.MethodGet, "/stations/openapi.json", nil), "/stations"))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"/stations/openapi.json"`)
+
+	assert.False(t, ServeDocument(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/stations/bonn", nil), "/stations"))
+	assert.False(t, ServeDocument(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/openapi.json", nil), "/stations"))
+}
+
+func TestServiceDescLink(t *testing.T) {
+	assert.Equal(t, `</openapi.json>; rel="service-desc"`, ServiceDescLink(""))
+	assert.Equal(t, `</stations/openapi.json>; rel="service-desc"`, ServiceDescLink("/stations"))
+}
@@ -0,0 +1,287 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/timgluz/wasserspiegel/health"
+	"github.com/timgluz/wasserspiegel/kvstore"
+)
+
+const (
+	bucketPrefix   = "ratelimit:"
+	usagePrefix    = "usage:"
+	usagePrunedKey = "usagepruned" // the UTC day the usage counters were last pruned
+	dateLayout     = "2006-01-02"
+)
+
+// UsageRetentionDays is the number of days, including today, the usage counters are kept.
+const UsageRetentionDays = 90
+
+var ErrUnknownClass = fmt.Errorf("unknown route class")
+
+// Decision is the outcome of a single rate limit check.
+type Decision struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+}
+
+type bucket struct {
+	Tokens    float64 `json:"tokens"`
+	UpdatedAt int64   `json:"updated_at"` // unix milliseconds
+}
+
+// DailyUsage counts the requests of an API key per route class and UTC day.
+type DailyUsage struct {
+	KeyID    string         `json:"key_id"`
+	Date     string         `json:"date"`
+	Requests map[string]int `json:"requests"`
+	Limited  map[string]int `json:"limited,omitempty"`
+	Total    int            `json:"total"`
+}
+
+// Limiter applies token buckets kept in a key-value store, so the state
+// survives between the stateless invocations of a component. The store
+// offers no compare-and-swap, concurrent requests may therefore slightly exceed the limit.
+type Limiter struct {
+	db       kvstore.Store
+	policies map[string]Policy
+	logger   *slog.Logger
+	now      func() time.Time
+}
+
+func NewLimiter(db kvstore.Store, policies map[string]Policy, logger *slog.Logger) *Limiter {
+	if policies == nil {
+		policies = DefaultPolicies()
+	}
+
+	return &Limiter{
+		db:       db,
+		policies: policies,
+		logger:   logger,
+		now:      time.Now,
+	}
+}
+
+func (l *Limiter) IsReady() bool {
+	if l.logger == nil {
+		fmt.Println("Logger of rate limiter is not initialized")
+		return false
+	}
+
+	if l.db == nil {
+		l.logger.Error("Rate limit store is not initialized")
+		return false
+	}
+
+	return true
+}
+
+// CheckHealth reads from the KV store of the token buckets.
+func (l *Limiter) CheckHealth(ctx context.Context) health.Check {
+	return kvstore.CheckHealth(ctx, "ratelimits", l.db)
+}
+
+func (l *Limiter) Close() error {
+	if l.db != nil {
+		l.db.Close()
+		l.db = nil
+	}
+
+	return nil
+}
+
+// Allow takes a token from the bucket of the key and route class and records the usage.
+func (l *Limiter) Allow(keyID, class string) (Decision, error) {
+	policy, ok := l.policies[class]
+	if !ok {
+		l.logger.Error("No rate limit policy for route class", "class", class)
+		return Decision{}, fmt.Errorf("%w: %s", ErrUnknownClass, class)
+	}
+
+	now := l.now()
+	bucketKey := bucketPrefix + keyID + ":" + class
+
+	state := &bucket{}
+	found, err := kvstore.GetJSON(l.db, bucketKey, state)
+	if err != nil {
+		l.logger.Error("Failed to read rate limit state", "key_id", keyID, "class", class, "error", err)
+		return Decision{}, fmt.Errorf("failed to read rate limit state: %w", err)
+	}
+
+	capacity := float64(policy.Burst)
+	if !found {
+		state.Tokens = capacity
+	} else {
+		elapsed := now.Sub(time.UnixMilli(state.UpdatedAt)).Seconds()
+		if elapsed > 0 {
+			state.Tokens = math.Min(capacity, state.Tokens+elapsed*policy.refillPerSecond())
+		}
+	}
+	state.UpdatedAt = now.UnixMilli()
+
+	decision := Decision{Limit: policy.Burst}
+	if state.Tokens >= 1 {
+		state.Tokens--
+		decision.Allowed = true
+	} else {
+		missing := (1 - state.Tokens) / policy.refillPerSecond()
+		decision.RetryAfter = time.Duration(math.Ceil(missing)) * time.Second
+	}
+	decision.Remaining = int(math.Floor(state.Tokens))
+
+	if err := kvstore.SetJSON(l.db, bucketKey, state); err != nil {
+		l.logger.Error("Failed to store rate limit state", "key_id", keyID, "class", class, "error", err)
+		return decision, fmt.Errorf("failed to store rate limit state: %w", err)
+	}
+
+	if err := l.recordUsage(keyID, class, now, decision.Allowed); err != nil {
+		l.logger.Warn("Failed to record API key usage", "key_id", keyID, "class", class, "error", err)
+	}
+
+	return decision, nil
+}
+
+func (l *Limiter) recordUsage(keyID, class string, now time.Time, allowed bool) error {
+	date := now.UTC().Format(dateLayout)
+	usage, err := l.getUsage(keyID, date)
+	if err != nil {
+		return err
+	}
+
+	if usage.Total == 0 {
+		// the first request of the key today
+		if err := l.pruneUsage(now); err != nil {
+			l.logger.Warn("Failed to prune usage counters", "error", err)
+		}
+	}
+
+	usage.Requests[class]++
+	usage.Total++
+	if !allowed {
+		if usage.Limited == nil {
+			usage.Limited = make(map[string]int)
+		}
+		usage.Limited[class]++
+	}
+
+	return kvstore.SetJSON(l.db, usageKey(keyID, date), usage)
+}
+
+// Usage returns the daily usage of the key for the last days, including today,
+// newest first; at most UsageRetentionDays are read.
+func (l *Limiter) Usage(keyID string, days int) ([]DailyUsage, error) {
+	if days <= 0 {
+		days = 1
+	}
+	if days > UsageRetentionDays {
+		days = UsageRetentionDays
+	}
+
+	today := l.now().UTC()
+	usages := make([]DailyUsage, 0, days)
+	for i := 0; i < days; i++ {
+		date := today.AddDate(0, 0, -i).Format(dateLayout)
+		usage, err := l.getUsage(keyID, date)
+		if err != nil {
+			return nil, err
+		}
+		usages = append(usages, *usage)
+	}
+
+	return usages, nil
+}
+
+// UsageByDate returns the usage of all keys on the given UTC day, ordered by key ID;
+// days before the retention have no counters left.
+func (l *Limiter) UsageByDate(date string) ([]DailyUsage, error) {
+	if _, err := time.Parse(dateLayout, date); err != nil {
+		return nil, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", date)
+	}
+	if date < retentionStart(l.now()) {
+		return nil, nil
+	}
+
+	keys, err := kvstore.KeysWithPrefix(l.db, usagePrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var usages []DailyUsage
+	for _, key := range keys {
+		if !strings.HasSuffix(key, ":"+date) {
+			continue
+		}
+
+		usage := DailyUsage{}
+		if _, err := kvstore.GetJSON(l.db, key, &usage); err != nil {
+			l.logger.Warn("Skipping unreadable usage counter", "key", key, "error", err)
+			continue
+		}
+		usages = append(usages, usage)
+	}
+
+	sort.Slice(usages, func(i, j int) bool { return usages[i].KeyID < usages[j].KeyID })
+	return usages, nil
+}
+
+func (l *Limiter) getUsage(keyID, date string) (*DailyUsage, error) {
+	usage := &DailyUsage{}
+	found, err := kvstore.GetJSON(l.db, usageKey(keyID, date), usage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read usage counter: %w", err)
+	}
+
+	if !found {
+		usage = &DailyUsage{KeyID: keyID, Date: date}
+	}
+	if usage.Requests == nil {
+		usage.Requests = make(map[string]int)
+	}
+
+	return usage, nil
+}
+
+// pruneUsage deletes the counters older than UsageRetentionDays, at most once per UTC day.
+func (l *Limiter) pruneUsage(now time.Time) error {
+	today := now.UTC().Format(dateLayout)
+	prunedOn := ""
+	if _, err := kvstore.GetJSON(l.db, usagePrunedKey, &prunedOn); err != nil {
+		return err
+	}
+	if prunedOn == today {
+		return nil
+	}
+
+	keys, err := kvstore.KeysWithPrefix(l.db, usagePrefix)
+	if err != nil {
+		return err
+	}
+
+	start := retentionStart(now)
+	for _, key := range keys {
+		if date := key[strings.LastIndex(key, ":")+1:]; date < start {
+			if err := l.db.Delete(key); err != nil {
+				return fmt.Errorf("failed to delete usage counter %s: %w", key, err)
+			}
+		}
+	}
+
+	return kvstore.SetJSON(l.db, usagePrunedKey, today)
+}
+
+// retentionStart is the oldest UTC day whose usage counters are kept.
+func retentionStart(now time.Time) string {
+	return now.UTC().AddDate(0, 0, 1-UsageRetentionDays).Format(dateLayout)
+}
+
+func usageKey(keyID, date string) string {
+	return usagePrefix + keyID + ":" + date
+}
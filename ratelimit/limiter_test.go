@@ -0,0 +1,120 @@
+package ratelimit
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/timgluz/wasserspiegel/kvstore"
+)
+
+func TestLimiterTokenBucket(t *testing.T) {
+	now := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+	limiter := NewLimiter(kvstore.NewMemoryStore(), map[string]Policy{
+		ClassTask: {Requests: 6, Per: time.Minute, Burst: 2},
+	}, slog.Default())
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		decision, err := limiter.Allow("key", ClassTask)
+		assert.NoError(t, err)
+		assert.True(t, decision.Allowed)
+	}
+
+	decision, err := limiter.Allow("key", ClassTask)
+	assert.NoError(t, err)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, 10*time.Second, decision.RetryAfter)
+
+	// other keys have their own bucket
+	decision, err = limiter.Allow("other", ClassTask)
+	assert.NoError(t, err)
+	assert.True(t, decision.Allowed)
+
+	now = now.Add(10 * time.Second)
+	decision, err = limiter.Allow("key", ClassTask)
+	assert.NoError(t, err)
+	assert.True(t, decision.Allowed)
+	assert.Equal(t, 0, decision.Remaining)
+
+	usages, err := limiter.Usage("key", 2)
+	assert.NoError(t, err)
+	assert.Len(t, usages, 2)
+	assert.Equal(t, "2025-07-01", usages[0].Date)
+	assert.Equal(t, 4, usages[0].Total)
+	assert.Equal(t, 1, usages[0].Limited[ClassTask])
+	assert.Equal(t, 0, usages[1].Total)
+
+	byDate, err := limiter.UsageByDate("2025-07-01")
+	assert.NoError(t, err)
+	assert.Len(t, byDate, 2)
+	assert.Equal(t, "key", byDate[0].KeyID)
+
+	_, err = limiter.Allow("key", "unknown")
+	assert.ErrorIs(t, err, ErrUnknownClass)
+}
+
+func TestLimiterPrunesUsage(t *testing.T) {
+	now := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+	db := kvstore.NewMemoryStore()
+	limiter := NewLimiter(db, nil, slog.Default())
+	limiter.now = func() time.Time { return now }
+
+	for _, keyID := range []string{"key", "retired"} {
+		_, err := limiter.Allow(keyID, ClassRead)
+		assert.NoError(t, err)
+	}
+
+	now = now.AddDate(0, 0, UsageRetentionDays-1)
+	_, err := limiter.Allow("key", ClassRead)
+	assert.NoError(t, err)
+
+	usages, err := limiter.Usage("key", 365)
+	assert.NoError(t, err)
+	if assert.Len(t, usages, UsageRetentionDays, "reads are capped at the retention") {
+		assert.Equal(t, 1, usages[UsageRetentionDays-1].Total, "the oldest day is kept")
+	}
+
+	now = now.AddDate(0, 0, 1)
+	_, err = limiter.Allow("key", ClassRead)
+	assert.NoError(t, err)
+
+	keys, err := kvstore.KeysWithPrefix(db, usagePrefix)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"usage:key:2025-09-28", "usage:key:2025-09-29"}, keys, "counters of all keys are pruned")
+
+	byDate, err := limiter.UsageByDate("2025-07-01")
+	assert.NoError(t, err)
+	assert.Empty(t, byDate)
+}
+
+func TestParsePolicies(t *testing.T) {
+	testCases := []struct {
+		name        string
+		spec        string
+		class       string
+		expected    Policy
+		expectError bool
+	}{
+		{name: "defaults", spec: "", class: ClassSearch, expected: DefaultPolicies()[ClassSearch]},
+		{name: "override", spec: "search=10/m", class: ClassSearch, expected: Policy{Requests: 10, Per: time.Minute, Burst: 10}},
+		{name: "with burst", spec: "task=20/h:5, read=5/s", class: ClassTask, expected: Policy{Requests: 20, Per: time.Hour, Burst: 5}},
+		{name: "invalid unit", spec: "task=20/w", expectError: true},
+		{name: "missing rate", spec: "task", expectError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policies, err := ParsePolicies(tc.spec)
+			if tc.expectError {
+				assert.ErrorIs(t, err, ErrInvalidPolicy)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, policies[tc.class])
+		})
+	}
+}
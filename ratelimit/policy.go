@@ -0,0 +1,111 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Route classes group routes sharing a limit.
+const (
+	ClassRead   = "read"
+	ClassSearch = "search"
+	ClassWrite  = "write"
+	ClassTask   = "task"
+	ClassAdmin  = "admin"
+)
+
+var ErrInvalidPolicy = fmt.Errorf("invalid rate limit policy")
+
+// Policy configures a token bucket: it holds up to Burst tokens and refills
+// Requests tokens per Per interval.
+type Policy struct {
+	Requests int           `json:"requests"`
+	Per      time.Duration `json:"per"`
+	Burst    int           `json:"burst"`
+}
+
+func (p Policy) refillPerSecond() float64 {
+	return float64(p.Requests) / p.Per.Seconds()
+}
+
+func (p Policy) String() string {
+	return fmt.Sprintf("%d/%s", p.Requests, p.Per)
+}
+
+// DefaultPolicies keep a misbehaving client from hammering the search
+// and the tasks, which call the upstream API.
+func DefaultPolicies() map[string]Policy {
+	return map[string]Policy{
+		ClassRead:   {Requests: 120, Per: time.Minute, Burst: 60},
+		ClassSearch: {Requests: 30, Per: time.Minute, Burst: 10},
+		ClassWrite:  {Requests: 60, Per: time.Minute, Burst: 30},
+		ClassTask:   {Requests: 6, Per: time.Minute, Burst: 3},
+		ClassAdmin:  {Requests: 30, Per: time.Minute, Burst: 10},
+	}
+}
+
+// ParsePolicies overrides the default policies with a comma separated list
+// like `search=30/m,task=10/h:5`; the optional suffix after the colon sets the burst.
+func ParsePolicies(spec string) (map[string]Policy, error) {
+	policies := DefaultPolicies()
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		class, value, ok := strings.Cut(entry, "=")
+		if !ok || class == "" {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidPolicy, entry)
+		}
+
+		policy, err := parsePolicy(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidPolicy, entry)
+		}
+
+		policies[strings.TrimSpace(class)] = policy
+	}
+
+	return policies, nil
+}
+
+func parsePolicy(value string) (Policy, error) {
+	rate, burstValue, hasBurst := strings.Cut(value, ":")
+	countValue, unit, ok := strings.Cut(rate, "/")
+	if !ok {
+		return Policy{}, ErrInvalidPolicy
+	}
+
+	requests, err := strconv.Atoi(strings.TrimSpace(countValue))
+	if err != nil || requests <= 0 {
+		return Policy{}, ErrInvalidPolicy
+	}
+
+	var per time.Duration
+	switch strings.TrimSpace(unit) {
+	case "s":
+		per = time.Second
+	case "m":
+		per = time.Minute
+	case "h":
+		per = time.Hour
+	case "d":
+		per = 24 * time.Hour
+	default:
+		return Policy{}, ErrInvalidPolicy
+	}
+
+	burst := requests
+	if hasBurst {
+		burst, err = strconv.Atoi(strings.TrimSpace(burstValue))
+		if err != nil || burst <= 0 {
+			return Policy{}, ErrInvalidPolicy
+		}
+	}
+
+	return Policy{Requests: requests, Per: per, Burst: burst}, nil
+}
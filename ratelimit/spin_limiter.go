@@ -0,0 +1,27 @@
+//go:build tinygo || wasm
+
+package ratelimit
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/timgluz/wasserspiegel/kvstore"
+)
+
+// NewSpinLimiter opens the Spin KV store for the limiter state; policySpec
+// overrides the default policies, see ParsePolicies.
+func NewSpinLimiter(storeName string, policySpec string, logger *slog.Logger) (*Limiter, error) {
+	policies, err := ParsePolicies(policySpec)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := kvstore.OpenSpinStore(storeName)
+	if err != nil {
+		logger.Error("Failed to open Spin KV store for rate limits", "store", storeName, "error", err)
+		return nil, fmt.Errorf("failed to open rate limit store %s: %w", storeName, err)
+	}
+
+	return NewLimiter(db, policies, logger), nil
+}
@@ -0,0 +1,155 @@
+package response
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Route names used to look up cache policies.
+const (
+	CacheRouteDashboards   = "dashboards"
+	CacheRouteStations     = "stations"
+	CacheRouteWaterLevels  = "waterlevels"
+	CacheRouteMeasurements = "measurements"
+	CacheRouteTimeseries   = "timeseries"
+	CacheRouteSearch       = "search"
+)
+
+var ErrInvalidCachePolicy = fmt.Errorf("invalid cache policy")
+
+// CachePolicies maps route names to Cache-Control values.
+type CachePolicies map[string]string
+
+// DefaultCachePolicies keep responses out of shared caches, as they require an API key.
+// Clients revalidate cheaply with the ETag once the max-age has passed.
+func DefaultCachePolicies() CachePolicies {
+	return CachePolicies{
+		CacheRouteDashboards:   "private, max-age=60, must-revalidate",
+		CacheRouteStations:     "private, max-age=300",
+		CacheRouteWaterLevels:  "private, max-age=60, must-revalidate",
+		CacheRouteMeasurements: "private, max-age=60",
+		CacheRouteTimeseries:   "private, no-cache",
+		CacheRouteSearch:       "private, max-age=300",
+	}
+}
+
+// ParseCachePolicies overrides the default policies with a semicolon separated
+// list like `dashboards=public, max-age=300;timeseries=no-store`.
+func ParseCachePolicies(spec string) (CachePolicies, error) {
+	policies := DefaultCachePolicies()
+
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		route, value, ok := strings.Cut(entry, "=")
+		route, value = strings.TrimSpace(route), strings.TrimSpace(value)
+		if !ok || route == "" || value == "" {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidCachePolicy, entry)
+		}
+
+		policies[route] = value
+	}
+
+	return policies, nil
+}
+
+// Get returns the Cache-Control value of the route, or an empty string if none is configured.
+func (p CachePolicies) Get(route string) string {
+	if p == nil {
+		return ""
+	}
+
+	return p[route]
+}
+
+// Validators describe the representation for conditional requests.
+type Validators struct {
+	LastModified time.Time // zero if unknown
+	CacheControl string
+}
+
+// RenderConditionalJSON renders the data with an ETag over the JSON body,
+// Last-Modified and Cache-Control headers. It answers with 304 Not Modified
+// if the request's If-None-Match or If-Modified-Since headers match. A Cache-Control
+// header set earlier, e.g. by a middleware, is kept.
+func RenderConditionalJSON(w http.ResponseWriter, r *http.Request, data any, validators Validators) {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		RenderFatal(w, fmt.Errorf("failed to marshal data: %w", err))
+		return
+	}
+
+	etag := ComputeETag(jsonData)
+	header := w.Header()
+	header.Set("ETag", etag)
+	if !validators.LastModified.IsZero() {
+		header.Set("Last-Modified", validators.LastModified.UTC().Format(http.TimeFormat))
+	}
+	if validators.CacheControl != "" && header.Get("Cache-Control") == "" {
+		header.Set("Cache-Control", validators.CacheControl)
+	}
+
+	if IsNotModified(r, etag, validators.LastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	header.Set("Content-Type", JSONContentType)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(jsonData)
+}
+
+// ComputeETag returns a strong entity tag over the content.
+func ComputeETag(content []byte) string {
+	sum := sha256.Sum256(content)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// IsNotModified evaluates the conditional headers of GET and HEAD requests as in RFC 9110:
+// If-None-Match takes precedence, If-Modified-Since is only used without it.
+func IsNotModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		return etagListMatches(ifNoneMatch, etag)
+	}
+
+	ifModifiedSince := r.Header.Get("If-Modified-Since")
+	if ifModifiedSince == "" || lastModified.IsZero() {
+		return false
+	}
+
+	since, err := http.ParseTime(ifModifiedSince)
+	if err != nil {
+		return false
+	}
+
+	// HTTP dates have a resolution of seconds
+	return !lastModified.Truncate(time.Second).After(since)
+}
+
+// etagListMatches uses the weak comparison required for If-None-Match.
+func etagListMatches(list, etag string) bool {
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+
+		if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
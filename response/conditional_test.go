@@ -0,0 +1,83 @@
+package response
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsNotModified(t *testing.T) {
+	lastModified := time.Date(2025, 7, 1, 12, 0, 0, 500, time.UTC)
+	etag := `"abc"`
+
+	testCases := []struct {
+		name     string
+		method   string
+		headers  map[string]string
+		expected bool
+	}{
+		{name: "no conditions", method: http.MethodGet, expected: false},
+		{name: "matching etag", method: http.MethodGet, headers: map[string]string{"If-None-Match": `"abc"`}, expected: true},
+		{name: "etag in list", method: http.MethodGet, headers: map[string]string{"If-None-Match": `"xyz", W/"abc"`}, expected: true},
+		{name: "wildcard", method: http.MethodGet, headers: map[string]string{"If-None-Match": "*"}, expected: true},
+		{name: "other etag", method: http.MethodGet, headers: map[string]string{"If-None-Match": `"xyz"`}, expected: false},
+		{
+			name:     "etag takes precedence",
+			method:   http.MethodGet,
+			headers:  map[string]string{"If-None-Match": `"xyz"`, "If-Modified-Since": lastModified.Add(time.Hour).Format(http.TimeFormat)},
+			expected: false,
+		},
+		{name: "not modified since", method: http.MethodGet, headers: map[string]string{"If-Modified-Since": lastModified.Format(http.TimeFormat)}, expected: true},
+		{name: "modified since", method: http.MethodGet, headers: map[string]string{"If-Modified-Since": lastModified.Add(-time.Minute).Format(http.TimeFormat)}, expected: false},
+		{name: "invalid date", method: http.MethodGet, headers: map[string]string{"If-Modified-Since": "yesterday"}, expected: false},
+		{name: "post is never cached", method: http.MethodPost, headers: map[string]string{"If-None-Match": `"abc"`}, expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(tc.method, "/dashboards/rhein-koeln-en-utc", nil)
+			for name, value := range tc.headers {
+				r.Header.Set(name, value)
+			}
+
+			assert.Equal(t, tc.expected, IsNotModified(r, etag, lastModified))
+		})
+	}
+}
+
+func TestRenderConditionalJSON(t *testing.T) {
+	data := map[string]string{"id": "rhein-koeln-en-utc"}
+	validators := Validators{LastModified: time.Unix(1751371200, 0), CacheControl: "private, max-age=60"}
+
+	first := httptest.NewRecorder()
+	RenderConditionalJSON(first, httptest.NewRequest(http.MethodGet, "/", nil), data, validators)
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.Equal(t, "private, max-age=60", first.Header().Get("Cache-Control"))
+	assert.Equal(t, "Tue, 01 Jul 2025 12:00:00 GMT", first.Header().Get("Last-Modified"))
+	etag := first.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("If-None-Match", etag)
+	second := httptest.NewRecorder()
+	second.Header().Set("Cache-Control", "private")
+	RenderConditionalJSON(second, r, data, validators)
+	assert.Equal(t, http.StatusNotModified, second.Code)
+	assert.Empty(t, second.Body.String())
+	assert.Equal(t, etag, second.Header().Get("ETag"))
+	assert.Equal(t, "private", second.Header().Get("Cache-Control"), "earlier Cache-Control is kept")
+}
+
+func TestParseCachePolicies(t *testing.T) {
+	policies, err := ParseCachePolicies("dashboards=public, max-age=300; timeseries=no-store")
+	assert.NoError(t, err)
+	assert.Equal(t, "public, max-age=300", policies.Get(CacheRouteDashboards))
+	assert.Equal(t, "no-store", policies.Get(CacheRouteTimeseries))
+	assert.Equal(t, DefaultCachePolicies()[CacheRouteStations], policies.Get(CacheRouteStations))
+
+	_, err = ParseCachePolicies("dashboards")
+	assert.ErrorIs(t, err, ErrInvalidCachePolicy)
+}
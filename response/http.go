@@ -12,17 +12,13 @@ const (
 )
 
 func RenderFatal(w http.ResponseWriter, err error) {
-	w.Header().Set("Content-Type", JSONContentType)
-
-	jsonError := fmt.Sprintf(`{"error": "%s"}`, err.Error())
-	http.Error(w, jsonError, http.StatusInternalServerError)
+	RenderError(w, err, http.StatusInternalServerError)
 }
 
+// RenderError renders err as a problem with the status; use apierror.Render
+// to map the sentinel errors of the domain packages to their status and code.
 func RenderError(w http.ResponseWriter, err error, statusCode int) {
-	w.Header().Set("Content-Type", JSONContentType)
-
-	jsonError := fmt.Sprintf(`{"error": "%s"}`, err.Error())
-	http.Error(w, jsonError, statusCode)
+	RenderProblem(w, NewProblemFromError(err, statusCode))
 }
 
 func RenderSuccess(w http.ResponseWriter, data []byte) {
@@ -32,6 +28,10 @@ func RenderSuccess(w http.ResponseWriter, data []byte) {
 }
 
 func RenderJSON(w http.ResponseWriter, data interface{}) {
+	RenderJSONWithStatus(w, data, http.StatusOK)
+}
+
+func RenderJSONWithStatus(w http.ResponseWriter, data interface{}, statusCode int) {
 	jsonData, err := json.Marshal(data)
 	if err != nil {
 		RenderFatal(w, fmt.Errorf("failed to marshal data: %w", err))
@@ -39,7 +39,7 @@ func RenderJSON(w http.ResponseWriter, data interface{}) {
 	}
 
 	w.Header().Set("Content-Type", JSONContentType)
-	w.WriteHeader(http.StatusOK)
+	w.WriteHeader(statusCode)
 	_, _ = w.Write(jsonData)
 }
 
@@ -14,3 +14,10 @@ func NewNotFoundHandler(logger *slog.Logger) http.HandlerFunc {
 		RenderError(w, ErrNotFound, http.StatusNotFound)
 	}
 }
+
+// NewMethodNotAllowedHandler renders 405 problems; httprouter sets the Allow header before calling it.
+func NewMethodNotAllowedHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		RenderProblem(w, NewProblem(http.StatusMethodNotAllowed, CodeMethodNotAllowed, fmt.Sprintf("method %s is not allowed", r.Method)))
+	}
+}
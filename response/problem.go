@@ -0,0 +1,153 @@
+package response
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+const (
+	ProblemContentType = "application/problem+json"
+
+	// RequestIDHeader carries the ID of the request, it is copied into rendered problems.
+	RequestIDHeader = "X-Request-ID"
+)
+
+// Codes of the generic problems; components add codes for their own errors, e.g. `station_not_found`.
+const (
+	CodeBadRequest         = "bad_request"
+	CodeValidationFailed   = "validation_failed"
+	CodeUnauthorized       = "unauthorized"
+	CodeForbidden          = "forbidden"
+	CodeNotFound           = "not_found"
+	CodeMethodNotAllowed   = "method_not_allowed"
+	CodeConflict           = "conflict"
+	CodeRateLimited        = "rate_limited"
+	CodeInternal           = "internal_error"
+	CodeNotImplemented     = "not_implemented"
+	CodeUpstreamFailed     = "upstream_failed"
+	CodeServiceUnavailable = "service_unavailable"
+	CodeTimeout            = "timeout"
+)
+
+// FieldError describes why a single field of the request is invalid.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Problem is an RFC 7807 problem detail with a machine-readable code.
+// Its type is always about:blank, so the title is the text of the status.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Code      string       `json:"code"`
+	Detail    string       `json:"detail,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+	Data      any          `json:"data,omitempty"` // details of the failed operation, e.g. the upstream requests of a task
+}
+
+func NewProblem(status int, code string, detail string) *Problem {
+	if code == "" {
+		code = CodeForStatus(status)
+	}
+
+	return &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Code:   code,
+		Detail: detail,
+	}
+}
+
+// NewValidationProblem reports invalid fields of the request with status 400.
+func NewValidationProblem(fieldErrors ...FieldError) *Problem {
+	problem := NewProblem(http.StatusBadRequest, CodeValidationFailed, "the request has invalid fields")
+	problem.Errors = fieldErrors
+	return problem
+}
+
+// NewProblemFromError returns the problem wrapped by err or a problem with the status and the message of err.
+func NewProblemFromError(err error, status int) *Problem {
+	var problem *Problem
+	if errors.As(err, &problem) {
+		copied := *problem
+		return &copied
+	}
+
+	return NewProblem(status, "", err.Error())
+}
+
+// WithFieldError adds an invalid field to the problem.
+func (p *Problem) WithFieldError(field, message string) *Problem {
+	p.Errors = append(p.Errors, FieldError{Field: field, Message: message})
+	return p
+}
+
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return p.Detail
+	}
+
+	return p.Title
+}
+
+// CodeForStatus returns the generic code of the status.
+func CodeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusUnprocessableEntity:
+		return CodeValidationFailed
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusMethodNotAllowed:
+		return CodeMethodNotAllowed
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	case http.StatusNotImplemented:
+		return CodeNotImplemented
+	case http.StatusBadGateway:
+		return CodeUpstreamFailed
+	case http.StatusServiceUnavailable:
+		return CodeServiceUnavailable
+	case http.StatusGatewayTimeout:
+		return CodeTimeout
+	}
+
+	if status >= 500 {
+		return CodeInternal
+	}
+
+	return CodeBadRequest
+}
+
+// RenderProblem writes the problem as application/problem+json; the request
+// ID is taken from the X-Request-ID response header, if it was set.
+func RenderProblem(w http.ResponseWriter, problem *Problem) {
+	if problem.RequestID == "" {
+		problem.RequestID = w.Header().Get(RequestIDHeader)
+	}
+
+	body, err := json.Marshal(problem)
+	if err != nil {
+		// only Data can fail to encode, drop it rather than the problem
+		problem.Data = nil
+		body, _ = json.Marshal(problem)
+	}
+
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(problem.Status)
+	_, _ = w.Write(body)
+}
@@ -0,0 +1,42 @@
+package response
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderError(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rec.Header().Set(RequestIDHeader, "req-1")
+
+	RenderError(rec, fmt.Errorf("station \"bonn\"\nnot found"), http.StatusNotFound)
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, ProblemContentType, rec.Header().Get("Content-Type"))
+
+	var problem Problem
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem), "quotes and newlines must be escaped")
+	assert.Equal(t, "about:blank", problem.Type)
+	assert.Equal(t, "Not Found", problem.Title)
+	assert.Equal(t, CodeNotFound, problem.Code)
+	assert.Equal(t, "station \"bonn\"\nnot found", problem.Detail)
+	assert.Equal(t, "req-1", problem.RequestID)
+}
+
+func TestNewProblemFromError(t *testing.T) {
+	validation := NewValidationProblem().WithFieldError("q", "too short")
+
+	problem := NewProblemFromError(fmt.Errorf("search failed: %w", validation), http.StatusInternalServerError)
+	assert.Equal(t, http.StatusBadRequest, problem.Status, "a wrapped problem keeps its status")
+	assert.Equal(t, CodeValidationFailed, problem.Code)
+	assert.Equal(t, []FieldError{{Field: "q", Message: "too short"}}, problem.Errors)
+
+	problem = NewProblemFromError(fmt.Errorf("boom"), http.StatusBadGateway)
+	assert.Equal(t, http.StatusBadGateway, problem.Status)
+	assert.Equal(t, CodeUpstreamFailed, problem.Code)
+}
@@ -24,7 +24,8 @@ func NewSuccessResponse(message string, data any) Response {
 
 type APIDocumentation struct {
 	Title string `json:"title,omitempty"`
-	Text  string `json:"text"`
+	Text  string `json:"text,omitempty"`
+	Link  string `json:"link,omitempty"` // link into the OpenAPI document
 }
 
 func NewAPIDocumentationResponse(title, text string) Response {
@@ -33,3 +34,10 @@ func NewAPIDocumentationResponse(title, text string) Response {
 		Data:    APIDocumentation{Text: text},
 	}
 }
+
+func NewAPIDocumentationLinkResponse(title, link string) Response {
+	return Response{
+		Success: true,
+		Data:    APIDocumentation{Title: title, Link: link},
+	}
+}
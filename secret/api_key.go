@@ -0,0 +1,150 @@
+package secret
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	ScopeAll               = "*"
+	ScopeStationsRead      = "stations:read"
+	ScopeMeasurementsRead  = "measurements:read"
+	ScopeMeasurementsWrite = "measurements:write"
+	ScopeDashboardsRead    = "dashboards:read"
+	ScopeDashboardsWrite   = "dashboards:write"
+	ScopeTasksRun          = "tasks:run"
+	ScopeMetricsRead       = "metrics:read"
+	ScopeKeysAdmin         = "keys:admin"
+)
+
+// KnownScopes lists the scopes that can be granted to an API key.
+var KnownScopes = []string{
+	ScopeAll,
+	ScopeStationsRead,
+	ScopeMeasurementsRead,
+	ScopeMeasurementsWrite,
+	ScopeDashboardsRead,
+	ScopeDashboardsWrite,
+	ScopeTasksRun,
+	ScopeMetricsRead,
+	ScopeKeysAdmin,
+}
+
+var (
+	ErrKeyExpired   = fmt.Errorf("API key has expired")
+	ErrKeyRevoked   = fmt.Errorf("API key has been revoked")
+	ErrUnknownScope = fmt.Errorf("unknown scope")
+)
+
+// APIKey is the stored metadata of an API key; the key itself is only kept as a hash.
+type APIKey struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Hash       string   `json:"-"`
+	Scopes     []string `json:"scopes"`
+	CreatedAt  int64    `json:"created_at"`
+	ExpiresAt  int64    `json:"expires_at,omitempty"` // 0 means the key never expires
+	LastUsedAt int64    `json:"last_used_at,omitempty"`
+	RevokedAt  int64    `json:"revoked_at,omitempty"`
+}
+
+// IssuedKey is returned once when a key is created; the token can't be retrieved afterwards.
+type IssuedKey struct {
+	APIKey
+	Token string `json:"token"`
+}
+
+func (k *APIKey) IsExpired(now time.Time) bool {
+	return k.ExpiresAt > 0 && now.Unix() >= k.ExpiresAt
+}
+
+func (k *APIKey) IsRevoked() bool {
+	return k.RevokedAt > 0
+}
+
+// HasScopes reports whether the key grants all required scopes. A key grants
+// a scope if it holds it, the wildcard scope or the resource wildcard, e.g. `tasks:*`.
+func (k *APIKey) HasScopes(required ...string) bool {
+	for _, scope := range required {
+		if !k.hasScope(scope) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (k *APIKey) hasScope(required string) bool {
+	resource, _, _ := strings.Cut(required, ":")
+	for _, scope := range k.Scopes {
+		if scope == ScopeAll || scope == required || scope == resource+":*" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// KeyStore manages scoped API keys.
+type KeyStore interface {
+	Store
+
+	Authenticate(token string) (*APIKey, error)
+	CreateKey(name string, scopes []string, expiresAt int64) (string, *APIKey, error)
+	ListKeys() ([]APIKey, error)
+	RevokeKey(id string) error
+}
+
+// Authenticate resolves the token to an API key. Stores without key support
+// only know a single shared key, which is granted all scopes.
+func Authenticate(store Store, token string) (*APIKey, error) {
+	if keyStore, ok := store.(KeyStore); ok {
+		return keyStore.Authenticate(token)
+	}
+
+	if _, err := store.Get(token); err != nil {
+		return nil, err
+	}
+
+	return &APIKey{ID: "default", Name: "default", Scopes: []string{ScopeAll}}, nil
+}
+
+// ValidateScopes checks that all scopes are known.
+func ValidateScopes(scopes []string) error {
+	for _, scope := range scopes {
+		if !isKnownScope(scope) {
+			return fmt.Errorf("%w: %s", ErrUnknownScope, scope)
+		}
+	}
+
+	return nil
+}
+
+func isKnownScope(scope string) bool {
+	for _, known := range KnownScopes {
+		if scope == known {
+			return true
+		}
+	}
+
+	resource, action, ok := strings.Cut(scope, ":")
+	if !ok || action != "*" {
+		return false
+	}
+
+	for _, known := range KnownScopes {
+		if strings.HasPrefix(known, resource+":") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
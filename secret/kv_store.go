@@ -0,0 +1,280 @@
+package secret
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/timgluz/wasserspiegel/health"
+	"github.com/timgluz/wasserspiegel/kvstore"
+)
+
+const (
+	apiKeyPrefix = "apikey:"
+	tokenPrefix  = "wsk_"
+	tokenBytes   = 32
+	keyIDLength  = 12
+
+	masterKeyName = "master"
+	// lastUsedResolution limits how often the last-used time is written back to the store.
+	lastUsedResolution = time.Minute
+	// lastUsedPrefix keeps the last-used time apart from the key, so writing it
+	// can't overwrite a revocation that landed after the key was read.
+	lastUsedPrefix = "lastused:"
+)
+
+// KVStore keeps hashed API keys in a key-value store. The master key from the
+// configuration is only kept in memory and is granted all scopes.
+type KVStore struct {
+	db         kvstore.Store
+	masterHash string
+	logger     *slog.Logger
+}
+
+func NewKVStore(db kvstore.Store, masterKey string, logger *slog.Logger) *KVStore {
+	masterHash := ""
+	if masterKey != "" {
+		masterHash = hashToken(masterKey)
+	}
+
+	return &KVStore{
+		db:         db,
+		masterHash: masterHash,
+		logger:     logger,
+	}
+}
+
+func (s *KVStore) IsReady() bool {
+	if s.logger == nil {
+		fmt.Println("Logger of secret KVStore is not initialized")
+		return false
+	}
+
+	if s.db == nil {
+		s.logger.Error("Secret KV store is not initialized")
+		return false
+	}
+
+	return true
+}
+
+// CheckHealth reads from the KV store of the API keys.
+func (s *KVStore) CheckHealth(ctx context.Context) health.Check {
+	return kvstore.CheckHealth(ctx, "secrets", s.db)
+}
+
+func (s *KVStore) Close() error {
+	if s.db == nil {
+		return nil
+	}
+
+	s.db.Close()
+	s.db = nil
+	return nil
+}
+
+// Get returns the name of the key, if the token is valid.
+func (s *KVStore) Get(key string) (string, error) {
+	apiKey, err := s.Authenticate(key)
+	if err != nil {
+		return "", err
+	}
+
+	return apiKey.Name, nil
+}
+
+// Set stores the key with the given name and grants it all scopes.
+func (s *KVStore) Set(key, value string) error {
+	if key == "" {
+		return fmt.Errorf("key cannot be empty")
+	}
+	if value == "" {
+		return fmt.Errorf("value cannot be empty")
+	}
+
+	hash := hashToken(key)
+	apiKey := &APIKey{
+		ID:        hash[:keyIDLength],
+		Name:      value,
+		Hash:      hash,
+		Scopes:    []string{ScopeAll},
+		CreatedAt: time.Now().Unix(),
+	}
+
+	return s.saveKey(apiKey)
+}
+
+func (s *KVStore) Authenticate(token string) (*APIKey, error) {
+	if token == "" {
+		return nil, ErrSecretNotFound
+	}
+
+	hash := hashToken(token)
+	if s.masterHash != "" && subtle.ConstantTimeCompare([]byte(hash), []byte(s.masterHash)) == 1 {
+		return &APIKey{ID: masterKeyName, Name: masterKeyName, Scopes: []string{ScopeAll}}, nil
+	}
+
+	if !s.IsReady() {
+		return nil, fmt.Errorf("secret store is not ready")
+	}
+
+	apiKey, err := s.getKey(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if apiKey.IsRevoked() {
+		return nil, ErrKeyRevoked
+	}
+	if apiKey.IsExpired(now) {
+		return nil, ErrKeyExpired
+	}
+
+	if now.Unix()-apiKey.LastUsedAt >= int64(lastUsedResolution.Seconds()) {
+		apiKey.LastUsedAt = now.Unix()
+		if err := kvstore.SetJSON(s.db, lastUsedPrefix+hash, apiKey.LastUsedAt); err != nil {
+			s.logger.Warn("Failed to update last used time of API key", "id", apiKey.ID, "error", err)
+		}
+	}
+
+	return apiKey, nil
+}
+
+// CreateKey generates a new key; the returned token is not stored and can't be retrieved again.
+func (s *KVStore) CreateKey(name string, scopes []string, expiresAt int64) (string, *APIKey, error) {
+	if name == "" {
+		return "", nil, fmt.Errorf("key name cannot be empty")
+	}
+	if len(scopes) == 0 {
+		return "", nil, fmt.Errorf("at least one scope is required")
+	}
+	if err := ValidateScopes(scopes); err != nil {
+		return "", nil, err
+	}
+
+	token, err := generateToken()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	hash := hashToken(token)
+	apiKey := &APIKey{
+		ID:        hash[:keyIDLength],
+		Name:      name,
+		Hash:      hash,
+		Scopes:    scopes,
+		CreatedAt: time.Now().Unix(),
+		ExpiresAt: expiresAt,
+	}
+
+	if err := s.saveKey(apiKey); err != nil {
+		return "", nil, err
+	}
+
+	s.logger.Info("API key created", "id", apiKey.ID, "name", name, "scopes", scopes)
+	return token, apiKey, nil
+}
+
+// ListKeys returns all keys, including revoked and expired ones, ordered by creation time.
+func (s *KVStore) ListKeys() ([]APIKey, error) {
+	if !s.IsReady() {
+		return nil, fmt.Errorf("secret store is not ready")
+	}
+
+	storeKeys, err := kvstore.KeysWithPrefix(s.db, apiKeyPrefix)
+	if err != nil {
+		s.logger.Error("Failed to list API keys", "error", err)
+		return nil, err
+	}
+
+	apiKeys := make([]APIKey, 0, len(storeKeys))
+	for _, storeKey := range storeKeys {
+		apiKey, err := s.getKey(strings.TrimPrefix(storeKey, apiKeyPrefix))
+		if err != nil {
+			s.logger.Warn("Skipping unreadable API key", "key", storeKey, "error", err)
+			continue
+		}
+		apiKeys = append(apiKeys, *apiKey)
+	}
+
+	sort.Slice(apiKeys, func(i, j int) bool { return apiKeys[i].CreatedAt < apiKeys[j].CreatedAt })
+	return apiKeys, nil
+}
+
+func (s *KVStore) RevokeKey(id string) error {
+	apiKeys, err := s.ListKeys()
+	if err != nil {
+		return err
+	}
+
+	for i := range apiKeys {
+		apiKey := &apiKeys[i]
+		if apiKey.ID != id {
+			continue
+		}
+
+		if !apiKey.IsRevoked() {
+			apiKey.RevokedAt = time.Now().Unix()
+			if err := s.saveKey(apiKey); err != nil {
+				return err
+			}
+		}
+
+		s.logger.Info("API key revoked", "id", id, "name", apiKey.Name)
+		return nil
+	}
+
+	return ErrSecretNotFound
+}
+
+func (s *KVStore) getKey(hash string) (*APIKey, error) {
+	apiKey := &APIKey{}
+	ok, err := kvstore.GetJSON(s.db, apiKeyPrefix+hash, apiKey)
+	if err != nil {
+		s.logger.Error("Failed to read API key", "error", err)
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrSecretNotFound
+	}
+
+	apiKey.Hash = hash
+
+	// keys stored before lastUsedPrefix keep their last-used time in the record
+	var lastUsedAt int64
+	if _, err := kvstore.GetJSON(s.db, lastUsedPrefix+hash, &lastUsedAt); err != nil {
+		s.logger.Warn("Failed to read last used time of API key", "id", apiKey.ID, "error", err)
+	}
+	apiKey.LastUsedAt = max(apiKey.LastUsedAt, lastUsedAt)
+
+	return apiKey, nil
+}
+
+func (s *KVStore) saveKey(apiKey *APIKey) error {
+	if !s.IsReady() {
+		return fmt.Errorf("secret store is not ready")
+	}
+
+	if err := kvstore.SetJSON(s.db, apiKeyPrefix+apiKey.Hash, apiKey); err != nil {
+		s.logger.Error("Failed to store API key", "id", apiKey.ID, "error", err)
+		return err
+	}
+
+	return nil
+}
+
+func generateToken() (string, error) {
+	buf := make([]byte, tokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return tokenPrefix + base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -0,0 +1,121 @@
+package secret
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/timgluz/wasserspiegel/kvstore"
+)
+
+func TestAPIKeyHasScopes(t *testing.T) {
+	testCases := []struct {
+		name     string
+		scopes   []string
+		required []string
+		expected bool
+	}{
+		{name: "no scopes required", scopes: []string{ScopeStationsRead}, required: nil, expected: true},
+		{name: "exact scope", scopes: []string{ScopeStationsRead}, required: []string{ScopeStationsRead}, expected: true},
+		{name: "wildcard", scopes: []string{ScopeAll}, required: []string{ScopeKeysAdmin}, expected: true},
+		{name: "resource wildcard", scopes: []string{"dashboards:*"}, required: []string{ScopeDashboardsWrite}, expected: true},
+		{name: "other resource", scopes: []string{"dashboards:*"}, required: []string{ScopeTasksRun}, expected: false},
+		{name: "read does not grant write", scopes: []string{ScopeMeasurementsRead}, required: []string{ScopeMeasurementsWrite}, expected: false},
+		{name: "all required", scopes: []string{ScopeStationsRead}, required: []string{ScopeStationsRead, ScopeTasksRun}, expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			apiKey := &APIKey{Scopes: tc.scopes}
+			assert.Equal(t, tc.expected, apiKey.HasScopes(tc.required...))
+		})
+	}
+}
+
+func TestKVStoreKeyLifecycle(t *testing.T) {
+	backend := kvstore.NewMemoryStore()
+	store := NewKVStore(backend, "master-key", slog.Default())
+
+	token, created, err := store.CreateKey("display", []string{ScopeDashboardsRead}, 0)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(token, tokenPrefix))
+
+	keys, err := backend.GetKeys()
+	assert.NoError(t, err)
+	for _, key := range keys {
+		assert.NotContains(t, key, token, "token must not be stored in plain text")
+	}
+
+	apiKey, err := store.Authenticate(token)
+	assert.NoError(t, err)
+	assert.Equal(t, created.ID, apiKey.ID)
+	assert.True(t, apiKey.HasScopes(ScopeDashboardsRead))
+	assert.False(t, apiKey.HasScopes(ScopeTasksRun))
+
+	master, err := store.Authenticate("master-key")
+	assert.NoError(t, err)
+	assert.True(t, master.HasScopes(ScopeKeysAdmin))
+
+	_, err = store.Authenticate("wsk_unknown")
+	assert.ErrorIs(t, err, ErrSecretNotFound)
+
+	assert.NoError(t, store.RevokeKey(created.ID))
+	_, err = store.Authenticate(token)
+	assert.ErrorIs(t, err, ErrKeyRevoked)
+
+	listed, err := store.ListKeys()
+	assert.NoError(t, err)
+	assert.Len(t, listed, 1)
+	assert.True(t, listed[0].IsRevoked())
+
+	assert.ErrorIs(t, store.RevokeKey("missing"), ErrSecretNotFound)
+}
+
+func TestKVStoreLastUsedKeepsRevocation(t *testing.T) {
+	backend := kvstore.NewMemoryStore()
+	store := NewKVStore(backend, "", slog.Default())
+
+	token, created, err := store.CreateKey("display", []string{ScopeDashboardsRead}, 0)
+	assert.NoError(t, err)
+	record, err := backend.Get(apiKeyPrefix + created.Hash)
+	assert.NoError(t, err)
+
+	_, err = store.Authenticate(token)
+	assert.NoError(t, err)
+	stored, err := backend.Get(apiKeyPrefix + created.Hash)
+	assert.NoError(t, err)
+	assert.Equal(t, record, stored, "a request never writes the key, so it can't undo a revocation it raced with")
+
+	listed, err := store.ListKeys()
+	assert.NoError(t, err)
+	if assert.Len(t, listed, 1) {
+		assert.NotZero(t, listed[0].LastUsedAt)
+	}
+
+	assert.NoError(t, store.RevokeKey(created.ID))
+	_, err = store.Authenticate(token)
+	assert.ErrorIs(t, err, ErrKeyRevoked)
+}
+
+func TestKVStoreRejectsExpiredKeys(t *testing.T) {
+	store := NewKVStore(kvstore.NewMemoryStore(), "", slog.Default())
+
+	token, _, err := store.CreateKey("expired", []string{ScopeStationsRead}, time.Now().Add(-time.Minute).Unix())
+	assert.NoError(t, err)
+
+	_, err = store.Authenticate(token)
+	assert.ErrorIs(t, err, ErrKeyExpired)
+}
+
+func TestKVStoreRejectsUnknownScopes(t *testing.T) {
+	store := NewKVStore(kvstore.NewMemoryStore(), "", slog.Default())
+
+	_, _, err := store.CreateKey("invalid", []string{"stations:delete"}, 0)
+	assert.ErrorIs(t, err, ErrUnknownScope)
+
+	_, _, err = store.CreateKey("wildcard", []string{"stations:*"}, 0)
+	assert.NoError(t, err)
+}
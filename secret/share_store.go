@@ -0,0 +1,273 @@
+package secret
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/timgluz/wasserspiegel/health"
+	"github.com/timgluz/wasserspiegel/kvstore"
+)
+
+// ShareTokenParam is the query parameter carrying share tokens.
+const ShareTokenParam = "share_token"
+
+const (
+	sharePrefix       = "share:"
+	shareTokenVersion = "v1"
+	shareIDBytes      = 9
+)
+
+var (
+	ErrInvalidShareToken = fmt.Errorf("invalid share token")
+	ErrShareExpired      = fmt.Errorf("share token has expired")
+	ErrShareRevoked      = fmt.Errorf("share token has been revoked")
+)
+
+// ShareGrant gives read-only access to a single resource, e.g. `dashboard:rhein-koeln-en-utc`.
+type ShareGrant struct {
+	ID        string `json:"id"`
+	Resource  string `json:"resource"`
+	CreatedBy string `json:"created_by,omitempty"`
+	CreatedAt int64  `json:"created_at"`
+	ExpiresAt int64  `json:"expires_at"`
+	RevokedAt int64  `json:"revoked_at,omitempty"`
+}
+
+func (g *ShareGrant) IsRevoked() bool {
+	return g.RevokedAt > 0
+}
+
+// APIKey represents the grant as a key with the given scopes, so the
+// middleware can treat share links like other clients.
+func (g *ShareGrant) APIKey(scopes ...string) *APIKey {
+	return &APIKey{
+		ID:        "share-" + g.ID,
+		Name:      "share link for " + g.Resource,
+		Scopes:    scopes,
+		CreatedAt: g.CreatedAt,
+		ExpiresAt: g.ExpiresAt,
+	}
+}
+
+// ShareStore signs share tokens with HMAC-SHA256 over the grant ID, resource
+// and expiry. The grants are kept in the key-value store, so single tokens can be revoked.
+type ShareStore struct {
+	db         kvstore.Store
+	signingKey []byte
+	logger     *slog.Logger
+	now        func() time.Time
+}
+
+func NewShareStore(db kvstore.Store, signingKey string, logger *slog.Logger) *ShareStore {
+	return &ShareStore{
+		db:         db,
+		signingKey: []byte(signingKey),
+		logger:     logger,
+		now:        time.Now,
+	}
+}
+
+func (s *ShareStore) IsReady() bool {
+	if s.logger == nil {
+		fmt.Println("Logger of ShareStore is not initialized")
+		return false
+	}
+
+	if s.db == nil {
+		s.logger.Error("Share KV store is not initialized")
+		return false
+	}
+
+	if len(s.signingKey) == 0 {
+		s.logger.Error("Share signing key is not configured")
+		return false
+	}
+
+	return true
+}
+
+// CheckHealth reads from the KV store of the share grants; a missing signing key is reported as down.
+func (s *ShareStore) CheckHealth(ctx context.Context) health.Check {
+	if len(s.signingKey) == 0 {
+		return health.Down("shares", fmt.Errorf("share signing key is not configured"))
+	}
+
+	return kvstore.CheckHealth(ctx, "shares", s.db)
+}
+
+func (s *ShareStore) Close() error {
+	if s.db != nil {
+		s.db.Close()
+		s.db = nil
+	}
+
+	return nil
+}
+
+// Create stores a new grant for the resource and returns its signed token.
+func (s *ShareStore) Create(resource string, ttl time.Duration, createdBy string) (string, *ShareGrant, error) {
+	if resource == "" {
+		return "", nil, fmt.Errorf("share resource cannot be empty")
+	}
+	if ttl <= 0 {
+		return "", nil, fmt.Errorf("share link must expire")
+	}
+
+	buf := make([]byte, shareIDBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, fmt.Errorf("failed to generate share ID: %w", err)
+	}
+
+	now := s.now()
+	grant := &ShareGrant{
+		ID:        hex.EncodeToString(buf),
+		Resource:  resource,
+		CreatedBy: createdBy,
+		CreatedAt: now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+	}
+
+	if err := kvstore.SetJSON(s.db, sharePrefix+grant.ID, grant); err != nil {
+		s.logger.Error("Failed to store share grant", "resource", resource, "error", err)
+		return "", nil, err
+	}
+
+	s.logger.Info("Share link created", "id", grant.ID, "resource", resource, "expires_at", grant.ExpiresAt)
+	return s.sign(grant), grant, nil
+}
+
+// Verify checks the signature, expiry and revocation of the token and that it grants access to the resource.
+func (s *ShareStore) Verify(token, resource string) (*ShareGrant, error) {
+	grantID, tokenResource, expiresAt, err := s.parse(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if tokenResource != resource {
+		return nil, ErrInvalidShareToken
+	}
+
+	if s.now().Unix() >= expiresAt {
+		return nil, ErrShareExpired
+	}
+
+	grant := &ShareGrant{}
+	found, err := kvstore.GetJSON(s.db, sharePrefix+grantID, grant)
+	if err != nil {
+		s.logger.Error("Failed to read share grant", "id", grantID, "error", err)
+		return nil, err
+	}
+
+	// a grant removed from the store counts as revoked
+	if !found || grant.IsRevoked() {
+		return nil, ErrShareRevoked
+	}
+
+	return grant, nil
+}
+
+// Revoke marks the grant as revoked; the resource must match to avoid revoking grants of other resources.
+func (s *ShareStore) Revoke(id, resource string) error {
+	grant := &ShareGrant{}
+	found, err := kvstore.GetJSON(s.db, sharePrefix+id, grant)
+	if err != nil {
+		return err
+	}
+	if !found || grant.Resource != resource {
+		return ErrSecretNotFound
+	}
+
+	if grant.IsRevoked() {
+		return nil
+	}
+
+	grant.RevokedAt = s.now().Unix()
+	if err := kvstore.SetJSON(s.db, sharePrefix+id, grant); err != nil {
+		s.logger.Error("Failed to revoke share grant", "id", id, "error", err)
+		return err
+	}
+
+	s.logger.Info("Share link revoked", "id", id, "resource", resource)
+	return nil
+}
+
+// List returns the grants of the resource ordered by creation time.
+func (s *ShareStore) List(resource string) ([]ShareGrant, error) {
+	keys, err := kvstore.KeysWithPrefix(s.db, sharePrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	grants := make([]ShareGrant, 0)
+	for _, key := range keys {
+		grant := ShareGrant{}
+		if _, err := kvstore.GetJSON(s.db, key, &grant); err != nil {
+			s.logger.Warn("Skipping unreadable share grant", "key", key, "error", err)
+			continue
+		}
+
+		if grant.Resource == resource {
+			grants = append(grants, grant)
+		}
+	}
+
+	sort.Slice(grants, func(i, j int) bool { return grants[i].CreatedAt < grants[j].CreatedAt })
+	return grants, nil
+}
+
+// token layout: v1.<grant id>.<base64url resource>.<expiry>.<base64url signature>
+func (s *ShareStore) sign(grant *ShareGrant) string {
+	payload := strings.Join([]string{
+		shareTokenVersion,
+		grant.ID,
+		base64.RawURLEncoding.EncodeToString([]byte(grant.Resource)),
+		strconv.FormatInt(grant.ExpiresAt, 10),
+	}, ".")
+
+	return payload + "." + base64.RawURLEncoding.EncodeToString(s.mac(payload))
+}
+
+func (s *ShareStore) parse(token string) (grantID, resource string, expiresAt int64, err error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 5 || parts[0] != shareTokenVersion {
+		return "", "", 0, ErrInvalidShareToken
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[4])
+	if err != nil {
+		return "", "", 0, ErrInvalidShareToken
+	}
+
+	payload := strings.Join(parts[:4], ".")
+	if !hmac.Equal(signature, s.mac(payload)) {
+		return "", "", 0, ErrInvalidShareToken
+	}
+
+	resourceBytes, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", "", 0, ErrInvalidShareToken
+	}
+
+	expiresAt, err = strconv.ParseInt(parts[3], 10, 64)
+	if err != nil {
+		return "", "", 0, ErrInvalidShareToken
+	}
+
+	return parts[1], string(resourceBytes), expiresAt, nil
+}
+
+func (s *ShareStore) mac(payload string) []byte {
+	h := hmac.New(sha256.New, s.signingKey)
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
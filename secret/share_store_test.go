@@ -0,0 +1,83 @@
+package secret
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/timgluz/wasserspiegel/kvstore"
+)
+
+func TestShareStoreVerify(t *testing.T) {
+	now := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+	store := NewShareStore(kvstore.NewMemoryStore(), "signing-key", slog.Default())
+	store.now = func() time.Time { return now }
+
+	token, grant, err := store.Create("dashboard:rhein-koeln-en-utc", time.Hour, "admin")
+	assert.NoError(t, err)
+
+	otherToken, _, err := store.Create("dashboard:elbe-dresden-de-utc", time.Hour, "admin")
+	assert.NoError(t, err)
+
+	parts := strings.Split(token, ".")
+	tampered := strings.Join(append(parts[:3:3], "9999999999", parts[4]), ".")
+
+	testCases := []struct {
+		name     string
+		token    string
+		resource string
+		store    *ShareStore
+		expected error
+	}{
+		{name: "valid", token: token, resource: "dashboard:rhein-koeln-en-utc", store: store},
+		{name: "other resource", token: otherToken, resource: "dashboard:rhein-koeln-en-utc", store: store, expected: ErrInvalidShareToken},
+		{name: "tampered expiry", token: tampered, resource: "dashboard:rhein-koeln-en-utc", store: store, expected: ErrInvalidShareToken},
+		{name: "malformed", token: "not-a-token", resource: "dashboard:rhein-koeln-en-utc", store: store, expected: ErrInvalidShareToken},
+		{
+			name:     "other signing key",
+			token:    token,
+			resource: "dashboard:rhein-koeln-en-utc",
+			store:    &ShareStore{db: store.db, signingKey: []byte("other-key"), logger: slog.Default(), now: store.now},
+			expected: ErrInvalidShareToken,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			verified, err := tc.store.Verify(tc.token, tc.resource)
+			if tc.expected != nil {
+				assert.ErrorIs(t, err, tc.expected)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, grant.ID, verified.ID)
+		})
+	}
+
+	now = now.Add(time.Hour)
+	_, err = store.Verify(token, "dashboard:rhein-koeln-en-utc")
+	assert.ErrorIs(t, err, ErrShareExpired)
+}
+
+func TestShareStoreRevoke(t *testing.T) {
+	store := NewShareStore(kvstore.NewMemoryStore(), "signing-key", slog.Default())
+	resource := "dashboard:rhein-koeln-en-utc"
+
+	token, grant, err := store.Create(resource, time.Hour, "")
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, store.Revoke(grant.ID, "dashboard:other"), ErrSecretNotFound)
+	assert.NoError(t, store.Revoke(grant.ID, resource))
+
+	_, err = store.Verify(token, resource)
+	assert.ErrorIs(t, err, ErrShareRevoked)
+
+	grants, err := store.List(resource)
+	assert.NoError(t, err)
+	assert.Len(t, grants, 1)
+	assert.True(t, grants[0].IsRevoked())
+}
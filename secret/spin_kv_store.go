@@ -0,0 +1,32 @@
+//go:build tinygo || wasm
+
+package secret
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/timgluz/wasserspiegel/kvstore"
+)
+
+// NewSpinKVStore opens the Spin KV store holding the API keys.
+func NewSpinKVStore(storeName string, masterKey string, logger *slog.Logger) (*KVStore, error) {
+	db, err := kvstore.OpenSpinStore(storeName)
+	if err != nil {
+		logger.Error("Failed to open Spin KV store for secrets", "store", storeName, "error", err)
+		return nil, fmt.Errorf("failed to open secret store %s: %w", storeName, err)
+	}
+
+	return NewKVStore(db, masterKey, logger), nil
+}
+
+// NewSpinShareStore opens the Spin KV store holding the share grants.
+func NewSpinShareStore(storeName string, signingKey string, logger *slog.Logger) (*ShareStore, error) {
+	db, err := kvstore.OpenSpinStore(storeName)
+	if err != nil {
+		logger.Error("Failed to open Spin KV store for share links", "store", storeName, "error", err)
+		return nil, fmt.Errorf("failed to open share store %s: %w", storeName, err)
+	}
+
+	return NewShareStore(db, signingKey, logger), nil
+}
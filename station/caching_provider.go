@@ -0,0 +1,228 @@
+package station
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/timgluz/wasserspiegel/health"
+	"github.com/timgluz/wasserspiegel/kvstore"
+)
+
+// Cached resources of the provider, each with its own TTL.
+const (
+	CacheResourceStations   = "stations"
+	CacheResourceStation    = "station"
+	CacheResourceWaterLevel = "waterlevel"
+
+	providerCachePrefix = "provider:"
+)
+
+var ErrInvalidCacheTTL = fmt.Errorf("invalid cache TTL")
+
+// CacheTTLs maps cached resources to the time their data is served without asking the provider.
+type CacheTTLs map[string]time.Duration
+
+// DefaultCacheTTLs follow the update rate of PegelOnline: station metadata
+// rarely changes, water levels are published every 15 minutes.
+func DefaultCacheTTLs() CacheTTLs {
+	return CacheTTLs{
+		CacheResourceStations:   24 * time.Hour,
+		CacheResourceStation:    24 * time.Hour,
+		CacheResourceWaterLevel: 15 * time.Minute,
+	}
+}
+
+// ParseCacheTTLs overrides the default TTLs with a comma separated list like `waterlevel=5m,stations=12h`.
+func ParseCacheTTLs(spec string) (CacheTTLs, error) {
+	ttls := DefaultCacheTTLs()
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		resource, value, ok := strings.Cut(entry, "=")
+		resource = strings.TrimSpace(resource)
+		if _, known := ttls[resource]; !ok || !known {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidCacheTTL, entry)
+		}
+
+		ttl, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || ttl < 0 {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidCacheTTL, entry)
+		}
+
+		ttls[resource] = ttl
+	}
+
+	return ttls, nil
+}
+
+type providerCacheEntry struct {
+	StoredAt int64           `json:"stored_at"`
+	Data     json.RawMessage `json:"data"`
+}
+
+// CachingProvider keeps the responses of a provider in a key-value store.
+// Entries younger than their TTL are served without calling the provider; if
+// the provider fails, expired entries are served and water levels are flagged as stale.
+type CachingProvider struct {
+	provider  Provider
+	db        kvstore.Store
+	ttls      CacheTTLs
+	namespace string
+	now       func() time.Time
+
+	logger *slog.Logger
+}
+
+func NewCachingProvider(provider Provider, db kvstore.Store, ttls CacheTTLs, logger *slog.Logger) *CachingProvider {
+	if ttls == nil {
+		ttls = DefaultCacheTTLs()
+	}
+
+	return &CachingProvider{
+		provider: provider,
+		db:       db,
+		ttls:     ttls,
+		now:      time.Now,
+		logger:   logger,
+	}
+}
+
+// WithNamespace separates the entries of providers sharing a store.
+func (c *CachingProvider) WithNamespace(namespace string) *CachingProvider {
+	c.namespace = namespace
+	return c
+}
+
+func (c *CachingProvider) IsReady() bool {
+	if c.logger == nil {
+		fmt.Println("Logger of CachingProvider is not initialized")
+		return false
+	}
+
+	if c.db == nil {
+		c.logger.Error("Provider cache store is not initialized")
+		return false
+	}
+
+	if c.provider == nil || !c.provider.IsReady() {
+		c.logger.Error("Cached provider is not initialized or not ready")
+		return false
+	}
+
+	return true
+}
+
+// CheckHealth checks the cached provider and the cache store.
+func (c *CachingProvider) CheckHealth(ctx context.Context) health.Check {
+	if check := kvstore.CheckHealth(ctx, "providercache", c.db); check.Status != health.StatusUp {
+		return check
+	}
+
+	return health.CheckDependency(ctx, health.Dependency{Name: "provider", Target: c.provider})
+}
+
+// Close closes the cached provider; the store is owned by the caller and may be shared.
+func (c *CachingProvider) Close() error {
+	if c.provider == nil {
+		return nil
+	}
+
+	return c.provider.Close()
+}
+
+func (c *CachingProvider) GetStations(ctx context.Context) (*StationCollection, error) {
+	stations, _, _, err := cachedFetch(c, CacheResourceStations, CacheResourceStations, func() (*StationCollection, error) {
+		return c.provider.GetStations(ctx)
+	})
+
+	return stations, err
+}
+
+func (c *CachingProvider) GetStation(ctx context.Context, id string) (*Station, error) {
+	station, _, _, err := cachedFetch(c, CacheResourceStation, CacheResourceStation+":"+id, func() (*Station, error) {
+		return c.provider.GetStation(ctx, id)
+	})
+
+	return station, err
+}
+
+func (c *CachingProvider) GetStationWaterLevel(ctx context.Context, id string) (*WaterLevelCollection, error) {
+	waterLevels, fetchedAt, stale, err := cachedFetch(c, CacheResourceWaterLevel, CacheResourceWaterLevel+":"+id, func() (*WaterLevelCollection, error) {
+		return c.provider.GetStationWaterLevel(ctx, id)
+	})
+	if err != nil || waterLevels == nil {
+		return waterLevels, err
+	}
+
+	waterLevels.FetchedAt = fetchedAt.UTC().Format(time.RFC3339)
+	waterLevels.Stale = stale
+	return waterLevels, nil
+}
+
+// cachedFetch returns the cached value of the key while it is fresh and
+// refreshes it otherwise. It returns when the value was fetched and whether
+// an expired value was served, because the provider failed.
+func cachedFetch[T any](c *CachingProvider, resource, key string, fetch func() (*T, error)) (*T, time.Time, bool, error) {
+	if !c.IsReady() {
+		return nil, time.Time{}, false, ErrProviderNotReady
+	}
+
+	if c.namespace != "" {
+		key = c.namespace + ":" + key
+	}
+
+	now := c.now()
+	entry := &providerCacheEntry{}
+	cached, err := kvstore.GetJSON(c.db, providerCachePrefix+key, entry)
+	if err != nil {
+		c.logger.Warn("Failed to read provider cache", "key", key, "error", err)
+		cached = false
+	}
+
+	storedAt := time.Unix(entry.StoredAt, 0)
+	if cached && now.Sub(storedAt) < c.ttls[resource] {
+		value := new(T)
+		if err := json.Unmarshal(entry.Data, value); err == nil {
+			c.logger.Debug("Serving cached provider response", "key", key, "age", now.Sub(storedAt).String())
+			return value, storedAt, false, nil
+		}
+		c.logger.Warn("Failed to decode provider cache entry", "key", key)
+		cached = false
+	}
+
+	value, err := fetch()
+	if err != nil {
+		if !cached || errors.Is(err, ErrResourceNotFound) {
+			return nil, time.Time{}, false, err
+		}
+
+		stale := new(T)
+		if decodeErr := json.Unmarshal(entry.Data, stale); decodeErr != nil {
+			return nil, time.Time{}, false, err
+		}
+
+		c.logger.Warn("Provider failed, serving stale cached response", "key", key, "age", now.Sub(storedAt).String(), "error", err)
+		return stale, storedAt, true, nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		c.logger.Warn("Failed to encode provider response for cache", "key", key, "error", err)
+		return value, now, false, nil
+	}
+
+	if err := kvstore.SetJSON(c.db, providerCachePrefix+key, &providerCacheEntry{StoredAt: now.Unix(), Data: data}); err != nil {
+		c.logger.Warn("Failed to cache provider response", "key", key, "error", err)
+	}
+
+	return value, now, false, nil
+}
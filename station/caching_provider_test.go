@@ -0,0 +1,122 @@
+package station
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/timgluz/wasserspiegel/kvstore"
+)
+
+type stubProvider struct {
+	calls       int
+	waterLevels *WaterLevelCollection
+	err         error
+}
+
+func (p *stubProvider) GetStations(ctx context.Context) (*StationCollection, error) {
+	return nil, ErrResourceNotFound
+}
+
+func (p *stubProvider) GetStation(ctx context.Context, id string) (*Station, error) {
+	return nil, ErrResourceNotFound
+}
+
+func (p *stubProvider) GetStationWaterLevel(ctx context.Context, id string) (*WaterLevelCollection, error) {
+	p.calls++
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	return p.waterLevels, nil
+}
+
+func (p *stubProvider) IsReady() bool { return true }
+func (p *stubProvider) Close() error  { return nil }
+
+func TestCachingProviderWaterLevel(t *testing.T) {
+	now := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+	provider := &stubProvider{waterLevels: &WaterLevelCollection{
+		StationID:    "abc",
+		Measurements: MeasurementList{{Timestamp: "2025-07-01T11:45:00+02:00", Value: 312}},
+		Unit:         UnitCM,
+	}}
+	cache := NewCachingProvider(provider, kvstore.NewMemoryStore(), CacheTTLs{CacheResourceWaterLevel: 15 * time.Minute}, slog.Default())
+	cache.now = func() time.Time { return now }
+
+	waterLevels, err := cache.GetStationWaterLevel(context.Background(), "abc")
+	assert.NoError(t, err)
+	assert.False(t, waterLevels.Stale)
+	assert.Equal(t, "2025-07-01T12:00:00Z", waterLevels.FetchedAt)
+
+	// fresh entries are served from the cache
+	now = now.Add(10 * time.Minute)
+	waterLevels, err = cache.GetStationWaterLevel(context.Background(), "abc")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, provider.calls)
+	assert.Equal(t, 312.0, waterLevels.Measurements[0].Value)
+
+	// expired entries are served as stale while the provider fails
+	now = now.Add(10 * time.Minute)
+	provider.err = fmt.Errorf("service unavailable")
+	waterLevels, err = cache.GetStationWaterLevel(context.Background(), "abc")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, provider.calls)
+	assert.True(t, waterLevels.Stale)
+	assert.Equal(t, "2025-07-01T12:00:00Z", waterLevels.FetchedAt)
+
+	// missing resources are not hidden by the cache
+	provider.err = ErrResourceNotFound
+	_, err = cache.GetStationWaterLevel(context.Background(), "abc")
+	assert.ErrorIs(t, err, ErrResourceNotFound)
+
+	_, err = cache.GetStationWaterLevel(context.Background(), "unknown")
+	assert.Error(t, err)
+}
+
+func TestHTTPProviderRevalidatesContent(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, `[{"timestamp":"2025-07-01T12:00:00+02:00","value":312}]`)
+	}))
+	defer server.Close()
+
+	provider := NewHTTPProvider(server.Client(), slog.Default()).WithContentCache(kvstore.NewMemoryStore())
+	for i := 0; i < 2; i++ {
+		content, err := provider.RetrieveContent(context.Background(), server.URL)
+		assert.NoError(t, err)
+
+		body := make([]byte, 64)
+		n, _ := content.Read(body)
+		assert.Contains(t, string(body[:n]), `"value":312`)
+	}
+
+	assert.Equal(t, 2, requests)
+}
+
+func TestParseCacheTTLs(t *testing.T) {
+	ttls, err := ParseCacheTTLs("waterlevel=5m, stations=12h")
+	assert.NoError(t, err)
+	assert.Equal(t, 5*time.Minute, ttls[CacheResourceWaterLevel])
+	assert.Equal(t, 12*time.Hour, ttls[CacheResourceStations])
+	assert.Equal(t, 24*time.Hour, ttls[CacheResourceStation])
+
+	_, err = ParseCacheTTLs("dashboards=5m")
+	assert.ErrorIs(t, err, ErrInvalidCacheTTL)
+
+	_, err = ParseCacheTTLs("waterlevel=soon")
+	assert.ErrorIs(t, err, ErrInvalidCacheTTL)
+}
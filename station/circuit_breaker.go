@@ -0,0 +1,150 @@
+package station
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/timgluz/wasserspiegel/kvstore"
+)
+
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+
+	circuitBreakerPrefix = "breaker:"
+)
+
+var ErrCircuitOpen = fmt.Errorf("circuit breaker is open, upstream requests are paused")
+
+// BreakerPolicy opens the circuit after FailureThreshold failed fetches in a
+// row and lets a trial request through after OpenFor.
+type BreakerPolicy struct {
+	FailureThreshold int           `json:"failure_threshold"`
+	OpenFor          time.Duration `json:"open_for"`
+}
+
+func DefaultBreakerPolicy() BreakerPolicy {
+	return BreakerPolicy{
+		FailureThreshold: 5,
+		OpenFor:          time.Minute,
+	}
+}
+
+// BreakerState is the persisted state of a circuit breaker.
+type BreakerState struct {
+	State     string `json:"state"`
+	Failures  int    `json:"failures"`
+	OpenedAt  int64  `json:"opened_at,omitempty"`
+	LastError string `json:"last_error,omitempty"`
+}
+
+// CircuitBreaker stops requests to an upstream during an outage. Its state is
+// kept in a key-value store, so it survives the short lived Spin invocations;
+// without compare-and-swap in the store, concurrent invocations may let a few
+// more trial requests through than a single process would.
+type CircuitBreaker struct {
+	name   string
+	db     kvstore.Store
+	policy BreakerPolicy
+	now    func() time.Time
+
+	logger *slog.Logger
+}
+
+func NewCircuitBreaker(name string, db kvstore.Store, policy BreakerPolicy, logger *slog.Logger) *CircuitBreaker {
+	return &CircuitBreaker{
+		name:   name,
+		db:     db,
+		policy: policy,
+		now:    time.Now,
+		logger: logger,
+	}
+}
+
+func (b *CircuitBreaker) IsReady() bool {
+	if b.logger == nil {
+		fmt.Println("Logger of CircuitBreaker is not initialized")
+		return false
+	}
+
+	if b.db == nil {
+		b.logger.Error("Circuit breaker store is not initialized")
+		return false
+	}
+
+	return true
+}
+
+// State returns the current state; an open circuit turns half open once OpenFor has passed.
+func (b *CircuitBreaker) State() BreakerState {
+	state := BreakerState{State: CircuitClosed}
+	if !b.IsReady() {
+		return state
+	}
+
+	if _, err := kvstore.GetJSON(b.db, circuitBreakerPrefix+b.name, &state); err != nil {
+		b.logger.Warn("Failed to read circuit breaker state, assuming closed", "name", b.name, "error", err)
+		return BreakerState{State: CircuitClosed}
+	}
+
+	if state.State == CircuitOpen && b.now().Sub(time.Unix(state.OpenedAt, 0)) >= b.policy.OpenFor {
+		state.State = CircuitHalfOpen
+	}
+
+	return state
+}
+
+// Allow returns ErrCircuitOpen while the circuit is open.
+func (b *CircuitBreaker) Allow(ctx context.Context) error {
+	state := b.State()
+	if state.State != CircuitOpen {
+		return nil
+	}
+
+	b.logger.Warn("Circuit breaker is open, rejecting upstream request", "name", b.name, "failures", state.Failures)
+	reportFetchEvent(ctx, FetchEvent{Type: FetchEventCircuitRejected, Error: state.LastError})
+	return ErrCircuitOpen
+}
+
+func (b *CircuitBreaker) RecordSuccess(ctx context.Context) {
+	state := b.State()
+	if state.State == CircuitClosed && state.Failures == 0 {
+		return
+	}
+
+	if state.State != CircuitClosed {
+		b.logger.Info("Circuit breaker closed, upstream recovered", "name", b.name)
+		reportFetchEvent(ctx, FetchEvent{Type: FetchEventCircuitClosed})
+	}
+
+	b.save(BreakerState{State: CircuitClosed})
+}
+
+func (b *CircuitBreaker) RecordFailure(ctx context.Context, cause error) {
+	state := b.State()
+	state.Failures++
+	state.LastError = cause.Error()
+
+	if state.State == CircuitHalfOpen || state.Failures >= b.policy.FailureThreshold {
+		state.State = CircuitOpen
+		state.OpenedAt = b.now().Unix()
+
+		b.logger.Warn("Circuit breaker opened", "name", b.name, "failures", state.Failures, "openFor", b.policy.OpenFor.String(), "error", cause)
+		reportFetchEvent(ctx, FetchEvent{Type: FetchEventCircuitOpened, Error: state.LastError})
+	}
+
+	b.save(state)
+}
+
+func (b *CircuitBreaker) save(state BreakerState) {
+	if !b.IsReady() {
+		return
+	}
+
+	if err := kvstore.SetJSON(b.db, circuitBreakerPrefix+b.name, state); err != nil {
+		b.logger.Warn("Failed to store circuit breaker state", "name", b.name, "error", err)
+	}
+}
@@ -4,4 +4,6 @@ import "errors"
 
 var (
 	ErrKVStoreNotAvailable = errors.New("KV store not available")
+	ErrStationNotFound     = errors.New("station not found")
+	ErrStationExists       = errors.New("station already exists")
 )
@@ -0,0 +1,438 @@
+package station
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+
+	TimestampRFC3339 = "rfc3339"
+	TimestampUnix    = "unix"
+	TimestampUnixMS  = "unix_ms"
+
+	// mapped fields of the records
+	FieldID        = "id"
+	FieldName      = "name"
+	FieldWater     = "water"
+	FieldLatitude  = "latitude"
+	FieldLongitude = "longitude"
+	FieldKM        = "km"
+	FieldTimestamp = "timestamp"
+	FieldValue     = "value"
+)
+
+var ErrInvalidProviderConfig = fmt.Errorf("invalid provider configuration")
+
+// FieldMapping maps the records of a response to fields. For JSON, Items is the
+// JSONPath of the records and the fields are JSONPaths relative to a record,
+// e.g. `$.value`. For CSV, the fields are names or 0-based indexes of the
+// columns; the first row of the response is the header.
+type FieldMapping struct {
+	Items  string            `json:"items,omitempty"`
+	Fields map[string]string `json:"fields"`
+}
+
+// GenericProviderConfig describes the HTTP API of an agency.
+type GenericProviderConfig struct {
+	Name            string  `json:"name"`                       // name of the external IDs of the stations
+	Format          string  `json:"format"`                     // json or csv
+	WaterLevelURL   string  `json:"waterlevel_url"`             // {id} is replaced by the external ID of the station
+	StationsURL     string  `json:"stations_url,omitempty"`     // optional, lists the stations of the agency
+	Unit            string  `json:"unit,omitempty"`             // unit of the values after applying the factor, default cm
+	Factor          float64 `json:"factor,omitempty"`           // multiplies the values, e.g. 100 to convert meters to centimeters
+	TimestampFormat string  `json:"timestamp_format,omitempty"` // rfc3339 (default), unix, unix_ms or a Go time layout
+	Delimiter       string  `json:"delimiter,omitempty"`        // CSV delimiter, default comma
+
+	WaterLevels FieldMapping `json:"waterlevels"`
+	Stations    FieldMapping `json:"stations,omitempty"`
+}
+
+// ParseGenericProviderConfigs reads a JSON array of provider configurations.
+func ParseGenericProviderConfigs(spec string) ([]GenericProviderConfig, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, nil
+	}
+
+	var configs []GenericProviderConfig
+	if err := json.Unmarshal([]byte(spec), &configs); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidProviderConfig, err)
+	}
+
+	for i := range configs {
+		if err := configs[i].Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return configs, nil
+}
+
+func (c GenericProviderConfig) Validate() error {
+	if c.Name == "" || c.Name == PegelOnlineProviderName {
+		return fmt.Errorf("%w: provider name %q is empty or reserved", ErrInvalidProviderConfig, c.Name)
+	}
+
+	if c.Format != FormatJSON && c.Format != FormatCSV {
+		return fmt.Errorf("%w: unknown format %q of provider %s", ErrInvalidProviderConfig, c.Format, c.Name)
+	}
+
+	if !strings.Contains(c.WaterLevelURL, "{id}") {
+		return fmt.Errorf("%w: waterlevel_url of provider %s lacks the {id} placeholder", ErrInvalidProviderConfig, c.Name)
+	}
+
+	if err := c.validateMapping(c.WaterLevels, FieldTimestamp, FieldValue); err != nil {
+		return err
+	}
+
+	if c.StationsURL != "" {
+		return c.validateMapping(c.Stations, FieldID, FieldName, FieldWater)
+	}
+
+	return nil
+}
+
+func (c GenericProviderConfig) validateMapping(mapping FieldMapping, required ...string) error {
+	for _, field := range required {
+		if mapping.Fields[field] == "" {
+			return fmt.Errorf("%w: provider %s has no mapping for %s", ErrInvalidProviderConfig, c.Name, field)
+		}
+	}
+
+	if c.Format != FormatJSON {
+		return nil
+	}
+
+	paths := []string{mapping.Items}
+	if mapping.Items == "" {
+		paths[0] = "$"
+	}
+	for _, path := range mapping.Fields {
+		paths = append(paths, path)
+	}
+
+	for _, path := range paths {
+		if _, err := parseJSONPath(path); err != nil {
+			return fmt.Errorf("%w: provider %s: %s", ErrInvalidProviderConfig, c.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// GenericProvider reads water levels and stations from JSON or CSV APIs,
+// configured by field mappings instead of code.
+type GenericProvider struct {
+	HTTPProvider
+
+	config GenericProviderConfig
+	logger *slog.Logger
+}
+
+func NewGenericProvider(config GenericProviderConfig, client *http.Client, logger *slog.Logger) (*GenericProvider, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	if config.Unit == "" {
+		config.Unit = UnitCM
+	}
+	if config.Factor == 0 {
+		config.Factor = 1
+	}
+
+	return &GenericProvider{
+		HTTPProvider: *NewHTTPProvider(client, logger),
+		config:       config,
+		logger:       logger.With("provider", config.Name),
+	}, nil
+}
+
+func (p *GenericProvider) Name() string {
+	return p.config.Name
+}
+
+func (p *GenericProvider) GetStations(ctx context.Context) (*StationCollection, error) {
+	if !p.IsReady() {
+		return nil, ErrProviderNotReady
+	}
+
+	if p.config.StationsURL == "" {
+		return nil, fmt.Errorf("%w: provider %s has no stations_url", ErrNotSupported, p.config.Name)
+	}
+
+	records, err := p.fetchRecords(ctx, p.config.StationsURL, p.config.Stations)
+	if err != nil {
+		return nil, err
+	}
+
+	stations := make([]Station, 0, len(records))
+	for _, record := range records {
+		station, err := p.mapStation(record)
+		if err != nil {
+			p.logger.Warn("Skipping unmappable station record", "error", err)
+			continue
+		}
+		stations = append(stations, *station)
+	}
+
+	return &StationCollection{Stations: stations}, nil
+}
+
+func (p *GenericProvider) GetStation(ctx context.Context, id string) (*Station, error) {
+	stations, err := p.GetStations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range stations.Stations {
+		if externalID, _ := stations.Stations[i].GetExternalID(p.config.Name); externalID == id {
+			return &stations.Stations[i], nil
+		}
+	}
+
+	return nil, ErrResourceNotFound
+}
+
+func (p *GenericProvider) GetStationWaterLevel(ctx context.Context, id string) (*WaterLevelCollection, error) {
+	if !p.IsReady() {
+		return nil, ErrProviderNotReady
+	}
+
+	resourceURL := strings.ReplaceAll(p.config.WaterLevelURL, "{id}", url.PathEscape(id))
+	records, err := p.fetchRecords(ctx, resourceURL, p.config.WaterLevels)
+	if err != nil {
+		return nil, err
+	}
+
+	measurements := make([]Measurement, 0, len(records))
+	for _, record := range records {
+		measurement, err := p.mapMeasurement(record)
+		if err != nil {
+			p.logger.Warn("Skipping unmappable measurement record", "id", id, "error", err)
+			continue
+		}
+		measurements = append(measurements, *measurement)
+	}
+
+	// timestamps are normalized to RFC 3339 in UTC, so they sort as strings
+	sort.SliceStable(measurements, func(i, j int) bool { return measurements[i].Timestamp < measurements[j].Timestamp })
+
+	p.logger.Info("Successfully fetched water level for station", "id", id, "count", len(measurements))
+	return &WaterLevelCollection{
+		StationID:    id,
+		Start:        DefaultTimePeriod,
+		Measurements: measurements,
+		Unit:         p.config.Unit,
+	}, nil
+}
+
+func (p *GenericProvider) Close() error {
+	p.client = nil
+	return nil
+}
+
+// fetchRecords returns the mapped fields of each record of the response.
+func (p *GenericProvider) fetchRecords(ctx context.Context, resourceURL string, mapping FieldMapping) ([]map[string]any, error) {
+	content, err := p.RetrieveContent(ctx, resourceURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.config.Format == FormatCSV {
+		return p.readCSVRecords(content, mapping)
+	}
+
+	return readJSONRecords(content, mapping)
+}
+
+func readJSONRecords(content io.Reader, mapping FieldMapping) ([]map[string]any, error) {
+	var doc any
+	if err := json.NewDecoder(content).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnmarshalFailed, err)
+	}
+
+	itemsPath := mapping.Items
+	if itemsPath == "" {
+		itemsPath = "$"
+	}
+
+	items, err := evalJSONPath(doc, itemsPath)
+	if err != nil {
+		return nil, err
+	}
+	// a path to an array selects its elements
+	if len(items) == 1 {
+		if array, ok := items[0].([]any); ok {
+			items = array
+		}
+	}
+
+	records := make([]map[string]any, 0, len(items))
+	for _, item := range items {
+		record := make(map[string]any, len(mapping.Fields))
+		for field, path := range mapping.Fields {
+			matches, err := evalJSONPath(item, path)
+			if err != nil {
+				return nil, err
+			}
+			if len(matches) > 0 {
+				record[field] = matches[0]
+			}
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+func (p *GenericProvider) readCSVRecords(content io.Reader, mapping FieldMapping) ([]map[string]any, error) {
+	reader := csv.NewReader(content)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	if p.config.Delimiter != "" {
+		reader.Comma = []rune(p.config.Delimiter)[0]
+	}
+
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnmarshalFailed, err)
+	}
+	if len(rows) == 0 {
+		return nil, ErrNoContent
+	}
+
+	columns := make(map[string]int, len(mapping.Fields))
+	for field, column := range mapping.Fields {
+		index, err := strconv.Atoi(column)
+		if err != nil {
+			index = indexOf(rows[0], column)
+		}
+		if index < 0 {
+			return nil, fmt.Errorf("%w: column %q of %s not found", ErrUnmarshalFailed, column, field)
+		}
+		columns[field] = index
+	}
+
+	records := make([]map[string]any, 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		record := make(map[string]any, len(columns))
+		for field, index := range columns {
+			if index < len(row) {
+				record[field] = row[index]
+			}
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+func (p *GenericProvider) mapStation(record map[string]any) (*Station, error) {
+	id, name, water := stringField(record, FieldID), stringField(record, FieldName), stringField(record, FieldWater)
+	if id == "" || name == "" || water == "" {
+		return nil, fmt.Errorf("station record lacks id, name or water: %v", record)
+	}
+
+	latitude, _ := floatField(record, FieldLatitude)
+	longitude, _ := floatField(record, FieldLongitude)
+	km, _ := floatField(record, FieldKM)
+
+	return &Station{
+		ID:    NewStationID(water, name),
+		Name:  toCapitalize(name),
+		Water: toCapitalize(water),
+		Location: Location{
+			KM:        km,
+			Latitude:  latitude,
+			Longitude: longitude,
+		},
+		ExternalIDs: []ExternalID{
+			{Name: p.config.Name, ID: id},
+		},
+	}, nil
+}
+
+func (p *GenericProvider) mapMeasurement(record map[string]any) (*Measurement, error) {
+	timestamp, err := parseTimestampField(record[FieldTimestamp], p.config.TimestampFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	value, ok := floatField(record, FieldValue)
+	if !ok {
+		return nil, fmt.Errorf("measurement record has no numeric value: %v", record)
+	}
+
+	return &Measurement{
+		Timestamp: timestamp.UTC().Format(time.RFC3339),
+		Value:     value * p.config.Factor,
+	}, nil
+}
+
+func parseTimestampField(value any, format string) (time.Time, error) {
+	raw := strings.TrimSpace(fmt.Sprint(value))
+	if value == nil || raw == "" {
+		return time.Time{}, fmt.Errorf("measurement record has no timestamp")
+	}
+
+	switch format {
+	case "", TimestampRFC3339:
+		return time.Parse(time.RFC3339, raw)
+	case TimestampUnix, TimestampUnixMS:
+		epoch, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid epoch timestamp %q: %w", raw, err)
+		}
+		if format == TimestampUnixMS {
+			return time.UnixMilli(int64(epoch)), nil
+		}
+		return time.Unix(int64(epoch), 0), nil
+	default:
+		return time.Parse(format, raw)
+	}
+}
+
+func stringField(record map[string]any, field string) string {
+	value, ok := record[field]
+	if !ok || value == nil {
+		return ""
+	}
+
+	return strings.TrimSpace(fmt.Sprint(value))
+}
+
+func floatField(record map[string]any, field string) (float64, bool) {
+	switch value := record[field].(type) {
+	case float64:
+		return value, true
+	case string:
+		number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		return number, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func indexOf(values []string, value string) int {
+	for i := range values {
+		if strings.TrimSpace(values[i]) == value {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,118 @@
+package station
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenericProviderWaterLevel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/json/g-17":
+			fmt.Fprint(w, `{"gauge":{"readings":[
+				{"t":"2025-07-01T12:15:00+02:00","level":{"m":1.25}},
+				{"t":"2025-07-01T12:00:00+02:00","level":{"m":1.2}},
+				{"t":"bad","level":{"m":1.1}}
+			]}}`)
+		case "/csv/g-17":
+			fmt.Fprint(w, "time;level_cm\n1751364000;120\n1751364900;125\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	testCases := []struct {
+		name   string
+		config GenericProviderConfig
+	}{
+		{
+			name: "json with JSONPath mapping",
+			config: GenericProviderConfig{
+				Name:          "agency",
+				Format:        FormatJSON,
+				WaterLevelURL: server.URL + "/json/{id}",
+				Factor:        100,
+				WaterLevels: FieldMapping{
+					Items:  "$.gauge.readings[*]",
+					Fields: map[string]string{FieldTimestamp: "$.t", FieldValue: "$.level.m"},
+				},
+			},
+		},
+		{
+			name: "csv with column mapping",
+			config: GenericProviderConfig{
+				Name:            "agency",
+				Format:          FormatCSV,
+				WaterLevelURL:   server.URL + "/csv/{id}",
+				TimestampFormat: TimestampUnix,
+				Delimiter:       ";",
+				WaterLevels: FieldMapping{
+					Fields: map[string]string{FieldTimestamp: "time", FieldValue: "1"},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			provider, err := NewGenericProvider(tc.config, server.Client(), slog.Default())
+			assert.NoError(t, err)
+
+			waterLevels, err := provider.GetStationWaterLevel(context.Background(), "g-17")
+			assert.NoError(t, err)
+			assert.Equal(t, UnitCM, waterLevels.Unit)
+			assert.Len(t, waterLevels.Measurements, 2)
+			assert.Equal(t, "2025-07-01T10:00:00Z", waterLevels.Measurements[0].Timestamp)
+			assert.InDelta(t, 120.0, waterLevels.Measurements[0].Value, 1e-9)
+			assert.InDelta(t, 125.0, waterLevels.Measurements[1].Value, 1e-9)
+
+			_, err = provider.GetStationWaterLevel(context.Background(), "unknown")
+			assert.ErrorIs(t, err, ErrResourceNotFound)
+		})
+	}
+}
+
+func TestParseGenericProviderConfigs(t *testing.T) {
+	configs, err := ParseGenericProviderConfigs(`[{"name":"agency","format":"csv","waterlevel_url":"https://example.org/{id}.csv",
+		"waterlevels":{"fields":{"timestamp":"0","value":"1"}}}]`)
+	assert.NoError(t, err)
+	assert.Len(t, configs, 1)
+
+	invalid := []string{
+		`[{"name":"pegelonline","format":"json","waterlevel_url":"https://example.org/{id}"}]`,
+		`[{"name":"agency","format":"xml","waterlevel_url":"https://example.org/{id}"}]`,
+		`[{"name":"agency","format":"json","waterlevel_url":"https://example.org/levels"}]`,
+		`[{"name":"agency","format":"json","waterlevel_url":"https://example.org/{id}","waterlevels":{"fields":{"timestamp":"t","value":"$.v"}}}]`,
+	}
+	for _, spec := range invalid {
+		_, err := ParseGenericProviderConfigs(spec)
+		assert.ErrorIs(t, err, ErrInvalidProviderConfig, spec)
+	}
+}
+
+func TestRegistryForStation(t *testing.T) {
+	registry := NewRegistry(slog.Default())
+	pegelOnline, agency := &stubProvider{}, &stubProvider{}
+	registry.Register(PegelOnlineProviderName, pegelOnline)
+	registry.Register("agency", agency)
+
+	provider, externalID, err := registry.ForStation(Station{ID: "rhein-bonn", ExternalIDs: []ExternalID{
+		{Name: "unknown", ID: "1"},
+		{Name: "agency", ID: "g-17"},
+		{Name: PegelOnlineProviderName, ID: "uuid"},
+	}})
+	assert.NoError(t, err)
+	assert.Same(t, agency, provider)
+	assert.Equal(t, ExternalID{Name: "agency", ID: "g-17"}, externalID)
+
+	_, _, err = registry.ForStation(Station{ID: "elbe-dresden", ExternalIDs: []ExternalID{{Name: "unknown", ID: "1"}}})
+	assert.ErrorIs(t, err, ErrNoProviderForStation)
+	assert.Equal(t, []string{PegelOnlineProviderName, "agency"}, registry.Names())
+}
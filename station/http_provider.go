@@ -3,24 +3,65 @@ package station
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
+	"time"
+
+	"github.com/timgluz/wasserspiegel/kvstore"
 )
 
+const contentCachePrefix = "content:"
+
 type HTTPProvider struct {
-	client *http.Client
-	logger *slog.Logger
+	client  *http.Client
+	cache   kvstore.Store   // optional, keeps validators and bodies for conditional requests
+	breaker *CircuitBreaker // optional, pauses requests during an upstream outage
+	retry   RetryPolicy
+	sleep   func(ctx context.Context, d time.Duration) error
+	logger  *slog.Logger
+}
+
+// cachedContent is the last upstream response of a URL, kept to revalidate it with a conditional request.
+type cachedContent struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	Body         []byte `json:"body"`
+	FetchedAt    int64  `json:"fetched_at"`
 }
 
 func NewHTTPProvider(client *http.Client, logger *slog.Logger) *HTTPProvider {
 	return &HTTPProvider{
 		client: client,
+		retry:  DefaultRetryPolicy(),
+		sleep:  sleepContext,
 		logger: logger,
 	}
 }
 
+// WithRetryPolicy replaces the default retries of failed requests.
+func (p *HTTPProvider) WithRetryPolicy(policy RetryPolicy) *HTTPProvider {
+	p.retry = policy
+	return p
+}
+
+// WithCircuitBreaker stops requests while the breaker is open and reports the outcome of each fetch to it.
+func (p *HTTPProvider) WithCircuitBreaker(breaker *CircuitBreaker) *HTTPProvider {
+	p.breaker = breaker
+	return p
+}
+
+// WithContentCache keeps the ETag and Last-Modified of responses in the store,
+// so that unchanged resources are revalidated instead of downloaded again.
+func (p *HTTPProvider) WithContentCache(store kvstore.Store) *HTTPProvider {
+	p.cache = store
+	return p
+}
+
 func (p *HTTPProvider) IsReady() bool {
 	if p.logger == nil {
 		fmt.Println("Logger of HTTPProvider is not initialized")
@@ -35,6 +76,34 @@ func (p *HTTPProvider) IsReady() bool {
 	return true
 }
 
+// Probe sends a single HEAD request to the URL, bypassing the retries, the
+// content cache and the circuit breaker, and returns how long it took.
+func (p *HTTPProvider) Probe(ctx context.Context, url string) (time.Duration, error) {
+	if !p.IsReady() {
+		return 0, ErrProviderNotReady
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	start := time.Now()
+	resp, err := p.client.Do(req)
+	latency := time.Since(start)
+	if err != nil {
+		return latency, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return latency, &upstreamStatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	return latency, nil
+}
+
+// RetrieveContent fetches the URL; 5xx, 429 and network errors are retried with backoff.
 func (p *HTTPProvider) RetrieveContent(ctx context.Context, url string) (io.Reader, error) {
 	defer ctx.Done()
 
@@ -42,35 +111,171 @@ func (p *HTTPProvider) RetrieveContent(ctx context.Context, url string) (io.Read
 		return nil, fmt.Errorf("HTTPProvider is not ready")
 	}
 
-	req, err := p.client.Get(url)
+	if p.breaker != nil {
+		if err := p.breaker.Allow(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	content, upstreamFailed, err := p.retrieveWithRetries(ctx, url)
+	if p.breaker != nil && ctx.Err() == nil {
+		if upstreamFailed {
+			p.breaker.RecordFailure(ctx, err)
+		} else {
+			p.breaker.RecordSuccess(ctx)
+		}
+	}
+
+	return content, err
+}
+
+// retrieveWithRetries returns whether the request failed because of the
+// upstream, as opposed to e.g. a missing resource.
+func (p *HTTPProvider) retrieveWithRetries(ctx context.Context, url string) (io.Reader, bool, error) {
+	cached := p.getCachedContent(url)
+
+	for attempt := 1; ; attempt++ {
+		content, retryAfter, retryable, err := p.retrieve(ctx, url, cached)
+		if err == nil || !retryable {
+			return content, false, err
+		}
+
+		if attempt >= p.retry.MaxAttempts {
+			p.logger.Error("Upstream request failed, giving up", "url", url, "attempts", attempt, "error", err)
+			return nil, true, err
+		}
+
+		delay, ok := p.retry.delay(attempt, retryAfter)
+		if !ok {
+			p.logger.Error("Upstream asked to retry later than allowed, giving up", "url", url, "retryAfter", retryAfter.String(), "error", err)
+			return nil, true, err
+		}
+
+		p.logger.Warn("Upstream request failed, retrying", "url", url, "attempt", attempt, "delay", delay.String(), "error", err)
+		reportFetchEvent(ctx, FetchEvent{Type: FetchEventRetry, URL: url, Attempt: attempt, Delay: delay.String(), Error: err.Error(), Status: statusCodeOf(err)})
+
+		if err := p.sleep(ctx, delay); err != nil {
+			return nil, false, err
+		}
+	}
+}
+
+// upstreamStatusError is a failed response of the upstream.
+type upstreamStatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *upstreamStatusError) Error() string {
+	return "failed to fetch stations: " + e.Status
+}
+
+func statusCodeOf(err error) int {
+	var statusErr *upstreamStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode
+	}
+
+	return 0
+}
+
+// retrieve makes a single request; it returns the Retry-After of the
+// response and whether the request may be retried.
+func (p *HTTPProvider) retrieve(ctx context.Context, url string, cached *cachedContent) (io.Reader, time.Duration, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, 0, false, fmt.Errorf("failed to create request for URL %s: %w", url, err)
+	}
+
+	if cached != nil {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	res, err := p.client.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, 0, isRetryableError(err) && ctx.Err() == nil, err
 	}
 
-	defer func(req *http.Response) {
-		if err := req.Body.Close(); err != nil {
+	defer func(res *http.Response) {
+		if err := res.Body.Close(); err != nil {
 			p.logger.Error("Failed to close response body", "url", url, "error", err)
 		}
-	}(req)
+	}(res)
 
-	if req.StatusCode != http.StatusOK {
-		if req.StatusCode == http.StatusNotFound {
-			return nil, ErrResourceNotFound
+	if res.StatusCode == http.StatusNotModified && cached != nil {
+		p.logger.Info("Content not modified, using cached content", "url", url, "length", len(cached.Body))
+		cached.FetchedAt = time.Now().Unix()
+		p.setCachedContent(url, cached)
+		return bytes.NewReader(cached.Body), 0, false, nil
+	}
+
+	if res.StatusCode != http.StatusOK {
+		if res.StatusCode == http.StatusNotFound {
+			return nil, 0, false, ErrResourceNotFound
 		}
 
-		return nil, fmt.Errorf("failed to fetch stations: %s", req.Status)
+		retryAfter := parseRetryAfter(res.Header.Get("Retry-After"), time.Now())
+		return nil, retryAfter, isRetryableStatus(res.StatusCode), &upstreamStatusError{StatusCode: res.StatusCode, Status: res.Status}
 	}
 
-	content, err := io.ReadAll(req.Body)
+	content, err := io.ReadAll(res.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read content from URL %s: %w", url, err)
+		return nil, 0, true, fmt.Errorf("failed to read content from URL %s: %w", url, err)
 	}
 
 	if len(content) == 0 {
 		p.logger.Warn("No content received from URL", "url", url)
-		return nil, ErrNoContent
+		return nil, 0, false, ErrNoContent
+	}
+
+	etag, lastModified := res.Header.Get("ETag"), res.Header.Get("Last-Modified")
+	if etag != "" || lastModified != "" {
+		p.setCachedContent(url, &cachedContent{
+			ETag:         etag,
+			LastModified: lastModified,
+			Body:         content,
+			FetchedAt:    time.Now().Unix(),
+		})
 	}
 
 	p.logger.Info("Content retrieved successfully", "url", url, "length", len(content))
-	return bytes.NewReader(content), nil
+	return bytes.NewReader(content), 0, false, nil
+}
+
+func (p *HTTPProvider) getCachedContent(url string) *cachedContent {
+	if p.cache == nil {
+		return nil
+	}
+
+	cached := &cachedContent{}
+	ok, err := kvstore.GetJSON(p.cache, contentCacheKey(url), cached)
+	if err != nil {
+		p.logger.Warn("Failed to read cached content", "url", url, "error", err)
+		return nil
+	}
+	if !ok || len(cached.Body) == 0 {
+		return nil
+	}
+
+	return cached
+}
+
+func (p *HTTPProvider) setCachedContent(url string, cached *cachedContent) {
+	if p.cache == nil {
+		return
+	}
+
+	if err := kvstore.SetJSON(p.cache, contentCacheKey(url), cached); err != nil {
+		p.logger.Warn("Failed to cache content", "url", url, "error", err)
+	}
+}
+
+func contentCacheKey(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return contentCachePrefix + hex.EncodeToString(sum[:])
 }
@@ -0,0 +1,104 @@
+package station
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidJSONPath = fmt.Errorf("invalid JSONPath")
+
+// evalJSONPath evaluates the subset of JSONPath used by the provider mappings:
+// the root `$`, child names `.name` or `['name']`, indexes `[0]` and the
+// wildcards `[*]` and `.*`. It returns all matching values of the decoded JSON document.
+func evalJSONPath(doc any, path string) ([]any, error) {
+	segments, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := []any{doc}
+	for _, segment := range segments {
+		next := make([]any, 0, len(matches))
+		for _, match := range matches {
+			next = append(next, selectJSONPathSegment(match, segment)...)
+		}
+		matches = next
+	}
+
+	return matches, nil
+}
+
+func selectJSONPathSegment(value any, segment string) []any {
+	switch node := value.(type) {
+	case map[string]any:
+		if segment == "*" {
+			children := make([]any, 0, len(node))
+			for _, child := range node {
+				children = append(children, child)
+			}
+			return children
+		}
+
+		if child, ok := node[segment]; ok {
+			return []any{child}
+		}
+	case []any:
+		if segment == "*" {
+			return node
+		}
+
+		index, err := strconv.Atoi(segment)
+		if err != nil {
+			return nil
+		}
+		if index < 0 {
+			index += len(node)
+		}
+		if index >= 0 && index < len(node) {
+			return []any{node[index]}
+		}
+	}
+
+	return nil
+}
+
+func parseJSONPath(path string) ([]string, error) {
+	path = strings.TrimSpace(path)
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("%w: %q must start with $", ErrInvalidJSONPath, path)
+	}
+
+	segments := make([]string, 0)
+	rest := path[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("%w: %q has an empty name", ErrInvalidJSONPath, path)
+			}
+			segments = append(segments, rest[:end])
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("%w: %q misses a closing bracket", ErrInvalidJSONPath, path)
+			}
+			segment := strings.Trim(rest[1:end], `'"`)
+			if segment == "" {
+				return nil, fmt.Errorf("%w: %q has an empty index", ErrInvalidJSONPath, path)
+			}
+			segments = append(segments, segment)
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("%w: unexpected %q in %q", ErrInvalidJSONPath, rest[0], path)
+		}
+	}
+
+	return segments, nil
+}
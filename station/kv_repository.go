@@ -0,0 +1,262 @@
+package station
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+
+	"github.com/timgluz/wasserspiegel/health"
+	"github.com/timgluz/wasserspiegel/kvstore"
+)
+
+// KVRepository stores stations as JSON in a key-value store, keyed by their ID;
+// stations are listed in the order of their IDs.
+type KVRepository struct {
+	db     kvstore.Store
+	logger *slog.Logger
+}
+
+func NewKVRepository(db kvstore.Store, logger *slog.Logger) *KVRepository {
+	return &KVRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *KVRepository) IsReady() bool {
+	if r.logger == nil {
+		fmt.Println("Logger of KVRepository  is not initialized")
+		return false
+	}
+
+	if r.db == nil {
+		r.logger.Error("KV store is not initialized")
+		return false
+	}
+
+	r.logger.Debug("KV store is ready")
+	return true
+}
+
+// CheckHealth reads from the KV store of the stations.
+func (r *KVRepository) CheckHealth(ctx context.Context) health.Check {
+	return kvstore.CheckHealth(ctx, "stations", r.db)
+}
+
+func (r *KVRepository) List(ctx context.Context, offset int, limit int) (*StationCollection, error) {
+	defer ctx.Done()
+
+	keys, err := r.stationKeys()
+	if err != nil {
+		r.logger.Error("Failed to retrieve keys from KV store", "error", err)
+		return nil, err
+	}
+
+	r.logger.Debug("Retrieved keys from KV store", "count", len(keys))
+
+	if len(keys) == 0 {
+		r.logger.Info("No stations found in KV store")
+		return &StationCollection{Stations: []Station{}}, nil
+	}
+
+	if limit <= 0 || limit > len(keys) {
+		limit = len(keys)
+	}
+
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= len(keys) {
+		r.logger.Warn("Offset exceeds number of available stations", "offset", offset, "available", len(keys))
+		return &StationCollection{Stations: []Station{}}, nil
+	}
+
+	if offset+limit > len(keys) {
+		limit = len(keys) - offset
+	}
+
+	r.logger.Debug("Listing stations from KV store", "limit", limit, "offset", offset)
+	stations := make([]Station, 0, limit)
+	for i := offset; i < offset+limit; i++ {
+		station, err := r.GetByID(ctx, keys[i])
+		if err != nil {
+			r.logger.Error("Failed to get station by ID", "id", keys[i], "error", err)
+			continue
+		}
+
+		if station == nil {
+			r.logger.Warn("Got nil station for key", "key", keys[i])
+			continue
+		}
+
+		stations = append(stations, *station)
+	}
+
+	return &StationCollection{Stations: stations}, nil
+}
+
+// stationKeys returns the sorted keys of the stations, without the AllStationsKey.
+func (r *KVRepository) stationKeys() ([]string, error) {
+	keys, err := r.db.GetKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	stationKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if key != AllStationsKey {
+			stationKeys = append(stationKeys, key)
+		}
+	}
+	sort.Strings(stationKeys)
+
+	return stationKeys, nil
+}
+
+func (r *KVRepository) Has(ctx context.Context, id string) bool {
+	defer ctx.Done()
+
+	if id == "" {
+		r.logger.Warn("Empty station ID provided, cannot check existence")
+		return false
+	}
+
+	if !r.IsReady() {
+		r.logger.Error("KV store is not ready, cannot check existence")
+		return false
+	}
+
+	r.logger.Debug("Checking if station exists in KV store", "id", id)
+	ok, err := r.db.Exists(id)
+	if err != nil {
+		r.logger.Error("Failed to check existence of station in KV store", "id", id, "error", err)
+		return false
+	}
+
+	return ok
+}
+
+func (r *KVRepository) GetByID(ctx context.Context, id string) (*Station, error) {
+	defer ctx.Done()
+
+	if !r.Has(ctx, id) {
+		return nil, fmt.Errorf("%w: %s", ErrStationNotFound, id)
+	}
+
+	jsonBlob, err := r.getKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	station := &Station{}
+	if err := json.Unmarshal(jsonBlob, station); err != nil {
+		r.logger.Error("Failed to unmarshal station", "error", err)
+		return nil, err
+	}
+
+	if station.ID == "" {
+		r.logger.Warn("Unmarshalling returned empty station", "id", id)
+	}
+
+	return station, nil
+}
+
+func (r *KVRepository) Create(ctx context.Context, station *Station) error {
+	if station == nil {
+		return errors.New("station cannot be nil")
+	}
+
+	if r.Has(ctx, station.ID) {
+		return fmt.Errorf("%w: %s", ErrStationExists, station.ID)
+	}
+
+	jsonBlob, err := json.Marshal(station)
+	if err != nil {
+		r.logger.Error("Failed to marshal station", "error", err)
+		return err
+	}
+
+	if err := r.setKey(ctx, station.ID, jsonBlob); err != nil {
+		r.logger.Error("Failed to add station to KV store", "error", err)
+		return err
+	}
+
+	r.logger.Debug("Station added to KV store", "id", station.ID)
+	return nil
+}
+
+func (r *KVRepository) Delete(ctx context.Context, id string) error {
+	defer ctx.Done()
+	if id == "" {
+		return errors.New("station ID cannot be empty")
+	}
+
+	if !r.IsReady() {
+		return ErrKVStoreNotAvailable
+	}
+
+	r.logger.Debug("Deleting station from KV store", "id", id)
+	if err := r.db.Delete(id); err != nil {
+		r.logger.Error("Failed to delete station from KV store", "id", id, "error", err)
+		return err
+	}
+	r.logger.Info("Station deleted successfully from KV store", "id", id)
+	return nil
+}
+
+func (r *KVRepository) setKey(ctx context.Context, key string, data []byte) error {
+	defer ctx.Done()
+
+	if key == "" || data == nil {
+		return errors.New("key and data cannot be empty")
+	}
+
+	if !r.IsReady() {
+		return ErrKVStoreNotAvailable
+	}
+
+	r.logger.Debug("Storing blob in KV store", "key", key)
+	if err := r.db.Set(key, data); err != nil {
+		r.logger.Error("Failed to store blob in KV store", "error", err)
+		return err
+	}
+
+	r.logger.Info("Blob stored successfully in KV store", "key", key)
+	return nil
+}
+
+func (r *KVRepository) getKey(ctx context.Context, key string) ([]byte, error) {
+	defer ctx.Done()
+
+	if key == "" {
+		return nil, errors.New("key cannot be empty")
+	}
+
+	if !r.IsReady() {
+		return nil, ErrKVStoreNotAvailable
+	}
+
+	r.logger.Debug("Retrieving blob from KV store", "key", key)
+	data, err := r.db.Get(key)
+	if err != nil {
+		r.logger.Error("Failed to retrieve blob from KV store", "error", err)
+		return nil, err
+	}
+
+	r.logger.Info("Blob retrieved successfully from KV store", "key", key)
+	return data, nil
+}
+
+func (r *KVRepository) Close() error {
+	if r.db == nil {
+		r.logger.Warn("KV store is nil, nothing to close")
+		return nil
+	}
+
+	r.db.Close() // Ensure the store is closed properly
+	r.logger.Info("KV store closed successfully")
+	return nil
+}
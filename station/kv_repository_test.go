@@ -0,0 +1,27 @@
+package station_test
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/timgluz/wasserspiegel/kvstore"
+	"github.com/timgluz/wasserspiegel/station"
+	"github.com/timgluz/wasserspiegel/station/stationtest"
+)
+
+func TestKVRepositoryContract(t *testing.T) {
+	stationtest.RunRepositoryContract(t, func(t *testing.T) station.Repository {
+		return station.NewKVRepository(kvstore.NewMemoryStore(), slog.Default())
+	})
+}
+
+func TestKVRepositoryContractOnFileStore(t *testing.T) {
+	stationtest.RunRepositoryContract(t, func(t *testing.T) station.Repository {
+		db, err := kvstore.OpenFileStore(t.TempDir(), "stations")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return station.NewKVRepository(db, slog.Default())
+	})
+}
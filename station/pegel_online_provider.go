@@ -8,6 +8,9 @@ import (
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/timgluz/wasserspiegel/health"
+	"github.com/timgluz/wasserspiegel/kvstore"
 )
 
 const PegelOnlineProviderName = "pegelonline"
@@ -52,15 +55,30 @@ func NewHTTPClientWithTimeout(timeout int) *http.Client {
 
 func NewPegelOnlineProvider(apiEndpoint string, client *http.Client, logger *slog.Logger) *PegelOnlineProvider {
 	return &PegelOnlineProvider{
-		APIEndpoint: apiEndpoint,
-		logger:      logger,
-		HTTPProvider: HTTPProvider{
-			client: client,
-			logger: logger,
-		},
+		APIEndpoint:  apiEndpoint,
+		logger:       logger,
+		HTTPProvider: *NewHTTPProvider(client, logger),
 	}
 }
 
+// WithContentCache revalidates PegelOnline resources with conditional requests, see HTTPProvider.WithContentCache.
+func (p *PegelOnlineProvider) WithContentCache(store kvstore.Store) *PegelOnlineProvider {
+	p.HTTPProvider.WithContentCache(store)
+	return p
+}
+
+// WithRetryPolicy replaces the default retries, see HTTPProvider.WithRetryPolicy.
+func (p *PegelOnlineProvider) WithRetryPolicy(policy RetryPolicy) *PegelOnlineProvider {
+	p.HTTPProvider.WithRetryPolicy(policy)
+	return p
+}
+
+// WithCircuitBreaker pauses requests to PegelOnline during an outage, see HTTPProvider.WithCircuitBreaker.
+func (p *PegelOnlineProvider) WithCircuitBreaker(breaker *CircuitBreaker) *PegelOnlineProvider {
+	p.HTTPProvider.WithCircuitBreaker(breaker)
+	return p
+}
+
 func (p *PegelOnlineProvider) IsReady() bool {
 	if !p.HTTPProvider.IsReady() {
 		return false
@@ -70,6 +88,16 @@ func (p *PegelOnlineProvider) IsReady() bool {
 	return true
 }
 
+// CheckHealth probes the station list of PegelOnline and reports the latency.
+func (p *PegelOnlineProvider) CheckHealth(ctx context.Context) health.Check {
+	latency, err := p.Probe(ctx, p.APIEndpoint+"/stations.json")
+	if err != nil {
+		return health.Down(PegelOnlineProviderName, err).WithLatency(latency)
+	}
+
+	return health.Up(PegelOnlineProviderName).WithLatency(latency).WithDetail("endpoint", p.APIEndpoint)
+}
+
 func (p *PegelOnlineProvider) GetStations(ctx context.Context) (*StationCollection, error) {
 	defer ctx.Done()
 
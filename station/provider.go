@@ -13,6 +13,7 @@ var (
 	ErrUnmarshalFailed  = fmt.Errorf("failed to unmarshal content")
 	ErrResourceNotFound = fmt.Errorf("resource not found")
 	ErrProviderNotReady = fmt.Errorf("provider is not ready")
+	ErrNotSupported     = fmt.Errorf("operation not supported by provider")
 )
 
 type Provider interface {
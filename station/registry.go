@@ -0,0 +1,233 @@
+package station
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/timgluz/wasserspiegel/health"
+	"github.com/timgluz/wasserspiegel/kvstore"
+)
+
+var ErrNoProviderForStation = fmt.Errorf("no provider registered for the external IDs of the station")
+
+// HealthProbeTTL is how long the probe of a provider is reused, so polling the
+// public health route can't drive requests to the provider.
+const HealthProbeTTL = 30 * time.Second
+
+const healthProbePrefix = "healthprobe:"
+
+type healthProbeEntry struct {
+	CheckedAt int64        `json:"checked_at"`
+	Check     health.Check `json:"check"`
+}
+
+// Registry holds the providers keyed by the name of the external IDs they serve.
+type Registry struct {
+	providers map[string]Provider
+	breakers  map[string]*CircuitBreaker
+	order     []string
+	store     kvstore.Store // keeps the health probes; optional
+	now       func() time.Time
+
+	logger *slog.Logger
+}
+
+func NewRegistry(logger *slog.Logger) *Registry {
+	return &Registry{
+		providers: make(map[string]Provider),
+		breakers:  make(map[string]*CircuitBreaker),
+		now:       time.Now,
+		logger:    logger,
+	}
+}
+
+// Register adds the provider for external IDs with the given name; it replaces a provider of the same name.
+func (r *Registry) Register(name string, provider Provider) {
+	if _, ok := r.providers[name]; !ok {
+		r.order = append(r.order, name)
+	}
+
+	r.providers[name] = provider
+}
+
+func (r *Registry) Get(name string) (Provider, bool) {
+	provider, ok := r.providers[name]
+	return provider, ok
+}
+
+// Names returns the names of the providers in the order of registration.
+func (r *Registry) Names() []string {
+	names := make([]string, len(r.order))
+	copy(names, r.order)
+	return names
+}
+
+// ForStation returns the provider of the first external ID of the station with a registered provider.
+func (r *Registry) ForStation(station Station) (Provider, ExternalID, error) {
+	for _, externalID := range station.ExternalIDs {
+		if externalID.ID == "" {
+			continue
+		}
+
+		if provider, ok := r.providers[externalID.Name]; ok {
+			return provider, externalID, nil
+		}
+	}
+
+	return nil, ExternalID{}, fmt.Errorf("%w: %s", ErrNoProviderForStation, station.ID)
+}
+
+// CircuitStates returns the state of the circuit breaker of each provider having one.
+func (r *Registry) CircuitStates() map[string]string {
+	states := make(map[string]string, len(r.breakers))
+	for name, breaker := range r.breakers {
+		states[name] = breaker.State().State
+	}
+
+	return states
+}
+
+// HealthDependencies returns a dependency per provider, named `provider:<name>`.
+// Providers are optional: while one is down, stations are served from the cache.
+// An open circuit breaker degrades an otherwise reachable provider.
+func (r *Registry) HealthDependencies() []health.Dependency {
+	dependencies := make([]health.Dependency, 0, len(r.order))
+	for _, name := range r.order {
+		provider, breaker := r.providers[name], r.breakers[name]
+		check := func(ctx context.Context) health.Check {
+			check := r.probe(ctx, name, provider)
+			if breaker == nil {
+				return check
+			}
+
+			state := breaker.State().State
+			if state != CircuitClosed && check.Status == health.StatusUp {
+				check.Status, check.Error = health.StatusDegraded, "circuit breaker is "+state
+			}
+
+			return check.WithDetail("circuit", state)
+		}
+
+		dependencies = append(dependencies, health.Optional("provider:"+name, health.CheckerFunc(check)))
+	}
+
+	return dependencies
+}
+
+// probe checks the provider; with a store, a probe younger than HealthProbeTTL is reused.
+func (r *Registry) probe(ctx context.Context, name string, provider Provider) health.Check {
+	if r.store == nil {
+		return health.CheckDependency(ctx, health.Dependency{Name: name, Target: provider})
+	}
+
+	now := r.now()
+	entry := &healthProbeEntry{}
+	found, err := kvstore.GetJSON(r.store, healthProbePrefix+name, entry)
+	if err != nil {
+		r.logger.Warn("Failed to read health probe", "provider", name, "error", err)
+	}
+	if found && err == nil && now.Sub(time.Unix(entry.CheckedAt, 0)) < HealthProbeTTL {
+		return entry.Check
+	}
+
+	check := health.CheckDependency(ctx, health.Dependency{Name: name, Target: provider})
+	if err := kvstore.SetJSON(r.store, healthProbePrefix+name, &healthProbeEntry{CheckedAt: now.Unix(), Check: check}); err != nil {
+		r.logger.Warn("Failed to store health probe", "provider", name, "error", err)
+	}
+
+	return check
+}
+
+func (r *Registry) IsReady() bool {
+	if r.logger == nil {
+		fmt.Println("Logger of provider Registry is not initialized")
+		return false
+	}
+
+	if len(r.providers) == 0 {
+		r.logger.Error("No providers registered")
+		return false
+	}
+
+	for _, name := range r.order {
+		if !r.providers[name].IsReady() {
+			r.logger.Error("Provider is not ready", "provider", name)
+			return false
+		}
+	}
+
+	return true
+}
+
+func (r *Registry) Close() error {
+	var firstErr error
+	for _, name := range r.order {
+		if err := r.providers[name].Close(); err != nil {
+			r.logger.Error("Failed to close provider", "provider", name, "error", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	return firstErr
+}
+
+// RegistryConfig configures the providers created by NewRegistryFromConfig.
+type RegistryConfig struct {
+	PegelOnlineEndpoint string
+	GenericProviders    []GenericProviderConfig
+
+	// Store keeps the validators for conditional requests, the circuit breaker
+	// state and the health probes; optional.
+	Store       kvstore.Store
+	RetryPolicy RetryPolicy
+	// CacheTTLs enable caching the responses of each provider in the Store.
+	CacheTTLs CacheTTLs
+}
+
+// NewRegistryFromConfig registers PegelOnline and the generic providers.
+func NewRegistryFromConfig(config RegistryConfig, client *http.Client, logger *slog.Logger) (*Registry, error) {
+	registry := NewRegistry(logger)
+	registry.store = config.Store
+
+	pegelOnline := NewPegelOnlineProvider(config.PegelOnlineEndpoint, client, logger)
+	registry.add(PegelOnlineProviderName, pegelOnline, &pegelOnline.HTTPProvider, config)
+
+	configs := make([]GenericProviderConfig, len(config.GenericProviders))
+	copy(configs, config.GenericProviders)
+	sort.SliceStable(configs, func(i, j int) bool { return configs[i].Name < configs[j].Name })
+
+	for _, providerConfig := range configs {
+		generic, err := NewGenericProvider(providerConfig, client, logger)
+		if err != nil {
+			return nil, err
+		}
+		registry.add(providerConfig.Name, generic, &generic.HTTPProvider, config)
+	}
+
+	logger.Info("Provider registry initialized", "providers", registry.Names())
+	return registry, nil
+}
+
+func (r *Registry) add(name string, provider Provider, httpProvider *HTTPProvider, config RegistryConfig) {
+	if config.RetryPolicy.MaxAttempts > 0 {
+		httpProvider.WithRetryPolicy(config.RetryPolicy)
+	}
+
+	if config.Store != nil {
+		breaker := NewCircuitBreaker(name, config.Store, DefaultBreakerPolicy(), r.logger)
+		httpProvider.WithContentCache(config.Store).WithCircuitBreaker(breaker)
+		r.breakers[name] = breaker
+	}
+
+	if config.Store != nil && config.CacheTTLs != nil {
+		provider = NewCachingProvider(provider, config.Store, config.CacheTTLs, r.logger).WithNamespace(name)
+	}
+
+	r.Register(name, provider)
+}
@@ -0,0 +1,66 @@
+package station
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/timgluz/wasserspiegel/health"
+	"github.com/timgluz/wasserspiegel/kvstore"
+)
+
+func TestRegistryHealthDependencies(t *testing.T) {
+	status, probes := http.StatusOK, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		probes++
+		assert.Equal(t, http.MethodHead, r.Method)
+		assert.Equal(t, "/stations.json", r.URL.Path)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	registry, err := NewRegistryFromConfig(RegistryConfig{
+		PegelOnlineEndpoint: server.URL,
+		Store:               kvstore.NewMemoryStore(),
+		CacheTTLs:           DefaultCacheTTLs(),
+	}, server.Client(), slog.Default())
+	assert.NoError(t, err)
+	now := time.Unix(1700000000, 0)
+	registry.now = func() time.Time { return now }
+
+	dependencies := registry.HealthDependencies()
+	if !assert.Len(t, dependencies, 1) {
+		return
+	}
+	assert.Equal(t, "provider:pegelonline", dependencies[0].Name)
+	assert.True(t, dependencies[0].Optional)
+
+	ctx := context.Background()
+	check := health.CheckDependency(ctx, dependencies[0])
+	assert.Equal(t, health.StatusUp, check.Status)
+	assert.Equal(t, CircuitClosed, check.Details["circuit"])
+
+	status = http.StatusServiceUnavailable
+	check = health.CheckDependency(ctx, dependencies[0])
+	assert.Equal(t, health.StatusUp, check.Status, "the probe is reused")
+	assert.Equal(t, 1, probes)
+
+	now = now.Add(HealthProbeTTL)
+	check = health.CheckDependency(ctx, dependencies[0])
+	assert.Equal(t, health.StatusDown, check.Status)
+
+	status = http.StatusOK
+	now = now.Add(HealthProbeTTL)
+	for range DefaultBreakerPolicy().FailureThreshold {
+		registry.breakers[PegelOnlineProviderName].RecordFailure(ctx, fmt.Errorf("upstream failed"))
+	}
+	check = health.CheckDependency(ctx, dependencies[0])
+	assert.Equal(t, health.StatusDegraded, check.Status, "reachable, but requests are paused")
+	assert.Equal(t, CircuitOpen, check.Details["circuit"])
+}
@@ -0,0 +1,143 @@
+package station
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Types of the events reported while fetching upstream resources.
+const (
+	FetchEventRetry           = "retry"
+	FetchEventCircuitOpened   = "circuit_opened"
+	FetchEventCircuitClosed   = "circuit_closed"
+	FetchEventCircuitRejected = "circuit_rejected"
+)
+
+// RetryPolicy configures the retries of failed upstream requests. The delay
+// grows exponentially from BaseDelay up to MaxDelay and is jittered.
+type RetryPolicy struct {
+	MaxAttempts int           `json:"max_attempts"`
+	BaseDelay   time.Duration `json:"base_delay"`
+	MaxDelay    time.Duration `json:"max_delay"`
+}
+
+// DefaultRetryPolicy keeps the worst case short, as the requests of the
+// station endpoints wait for the upstream API.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   250 * time.Millisecond,
+		MaxDelay:    2 * time.Second,
+	}
+}
+
+// delay returns the wait before the given retry; it honours the Retry-After
+// of the upstream, unless it exceeds MaxDelay and the retry is given up.
+func (p RetryPolicy) delay(retry int, retryAfter time.Duration) (time.Duration, bool) {
+	if retryAfter > 0 {
+		return retryAfter, retryAfter <= p.MaxDelay
+	}
+
+	backoff := p.BaseDelay << (retry - 1)
+	if backoff <= 0 || backoff > p.MaxDelay {
+		backoff = p.MaxDelay
+	}
+
+	// full jitter spreads the retries of concurrent requests
+	return time.Duration(rand.Int63n(int64(backoff) + 1)), true
+}
+
+func isRetryableStatus(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= http.StatusInternalServerError
+}
+
+func isRetryableError(err error) bool {
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+// parseRetryAfter reads the Retry-After header given in seconds or as HTTP date.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+
+	return 0
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// FetchEvent is a retry or a state change of the circuit breaker.
+type FetchEvent struct {
+	Type      string `json:"type"`
+	URL       string `json:"url,omitempty"`
+	Attempt   int    `json:"attempt,omitempty"`
+	Status    int    `json:"status,omitempty"`
+	Delay     string `json:"delay,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Timestamp string `json:"timestamp"`
+}
+
+// FetchReport collects the fetch events of a request, e.g. to add them to task results.
+type FetchReport struct {
+	mu     sync.Mutex
+	events []FetchEvent
+}
+
+type fetchReportKey struct{}
+
+// WithFetchReport returns a context, whose upstream requests report their events to the returned report.
+func WithFetchReport(ctx context.Context) (context.Context, *FetchReport) {
+	report := &FetchReport{}
+	return context.WithValue(ctx, fetchReportKey{}, report), report
+}
+
+func (r *FetchReport) Events() []FetchEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	events := make([]FetchEvent, len(r.events))
+	copy(events, r.events)
+	return events
+}
+
+func (r *FetchReport) add(event FetchEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = append(r.events, event)
+}
+
+func reportFetchEvent(ctx context.Context, event FetchEvent) {
+	report, ok := ctx.Value(fetchReportKey{}).(*FetchReport)
+	if !ok || report == nil {
+		return
+	}
+
+	if event.Timestamp == "" {
+		event.Timestamp = time.Now().UTC().Format(time.RFC3339)
+	}
+	report.add(event)
+}
@@ -0,0 +1,114 @@
+package station
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/timgluz/wasserspiegel/kvstore"
+)
+
+func TestHTTPProviderRetries(t *testing.T) {
+	testCases := []struct {
+		name             string
+		statuses         []int
+		retryAfter       string
+		expectedRequests int
+		expectError      bool
+	}{
+		{name: "recovers after 503", statuses: []int{503, 200}, expectedRequests: 2},
+		{name: "retries 429 with Retry-After", statuses: []int{429, 200}, retryAfter: "1", expectedRequests: 2},
+		{name: "gives up after max attempts", statuses: []int{500, 502, 503}, expectedRequests: 3, expectError: true},
+		{name: "gives up on long Retry-After", statuses: []int{503, 200}, retryAfter: "120", expectedRequests: 1, expectError: true},
+		{name: "no retry for client errors", statuses: []int{400, 200}, expectedRequests: 1, expectError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				status := tc.statuses[requests]
+				requests++
+				if status != http.StatusOK {
+					w.Header().Set("Retry-After", tc.retryAfter)
+					w.WriteHeader(status)
+					return
+				}
+				fmt.Fprint(w, `[]`)
+			}))
+			defer server.Close()
+
+			var delays []time.Duration
+			provider := NewHTTPProvider(server.Client(), slog.Default()).WithRetryPolicy(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: 5 * time.Second})
+			provider.sleep = func(ctx context.Context, d time.Duration) error {
+				delays = append(delays, d)
+				return nil
+			}
+
+			ctx, report := WithFetchReport(context.Background())
+			_, err := provider.RetrieveContent(ctx, server.URL)
+			if tc.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.Equal(t, tc.expectedRequests, requests)
+			assert.Len(t, report.Events(), tc.expectedRequests-1)
+			for _, delay := range delays {
+				assert.LessOrEqual(t, delay, 5*time.Second)
+			}
+			if tc.retryAfter == "1" {
+				assert.Equal(t, []time.Duration{time.Second}, delays)
+			}
+		})
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+	db := kvstore.NewMemoryStore()
+	newBreaker := func() *CircuitBreaker {
+		// a new breaker for every invocation, sharing the store
+		breaker := NewCircuitBreaker(PegelOnlineProviderName, db, BreakerPolicy{FailureThreshold: 2, OpenFor: time.Minute}, slog.Default())
+		breaker.now = func() time.Time { return now }
+		return breaker
+	}
+
+	ctx, report := WithFetchReport(context.Background())
+	newBreaker().RecordFailure(ctx, fmt.Errorf("503 Service Unavailable"))
+	assert.NoError(t, newBreaker().Allow(ctx))
+
+	newBreaker().RecordFailure(ctx, fmt.Errorf("503 Service Unavailable"))
+	assert.ErrorIs(t, newBreaker().Allow(ctx), ErrCircuitOpen)
+
+	now = now.Add(time.Minute)
+	assert.Equal(t, CircuitHalfOpen, newBreaker().State().State)
+	assert.NoError(t, newBreaker().Allow(ctx))
+
+	// a failed trial request opens the circuit again
+	newBreaker().RecordFailure(ctx, fmt.Errorf("timeout"))
+	assert.ErrorIs(t, newBreaker().Allow(ctx), ErrCircuitOpen)
+
+	now = now.Add(time.Minute)
+	newBreaker().RecordSuccess(ctx)
+	assert.Equal(t, BreakerState{State: CircuitClosed}, newBreaker().State())
+
+	eventTypes := make([]string, 0)
+	for _, event := range report.Events() {
+		eventTypes = append(eventTypes, event.Type)
+	}
+	assert.Equal(t, []string{
+		FetchEventCircuitOpened,
+		FetchEventCircuitRejected,
+		FetchEventCircuitOpened,
+		FetchEventCircuitRejected,
+		FetchEventCircuitClosed,
+	}, eventTypes)
+}
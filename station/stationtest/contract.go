@@ -0,0 +1,124 @@
+package stationtest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/timgluz/wasserspiegel/station"
+)
+
+// RunRepositoryContract checks the pagination, not-found and duplicate
+// semantics shared by all station.Repository implementations;
+// newRepository must return an empty repository.
+func RunRepositoryContract(t *testing.T, newRepository func(t *testing.T) station.Repository) {
+	ctx := context.Background()
+
+	newStation := func(id string) *station.Station {
+		return &station.Station{
+			ID:          id,
+			Name:        "Station " + id,
+			Water:       "Rhein",
+			ExternalIDs: []station.ExternalID{{Name: station.PegelOnlineProviderName, ID: "uuid-" + id}},
+		}
+	}
+
+	t.Run("empty repository lists no stations", func(t *testing.T) {
+		repo := newRepository(t)
+
+		collection, err := repo.List(ctx, 0, 10)
+		assert.NoError(t, err)
+		assert.NotNil(t, collection)
+		assert.Empty(t, collection.Stations)
+	})
+
+	t.Run("list pages through stations ordered by ID", func(t *testing.T) {
+		repo := newRepository(t)
+		for _, id := range []string{"d", "b", "e", "a", "c"} {
+			assert.NoError(t, repo.Create(ctx, newStation(id)))
+		}
+
+		testCases := []struct {
+			name     string
+			offset   int
+			limit    int
+			expected []string
+		}{
+			{name: "first page", offset: 0, limit: 2, expected: []string{"a", "b"}},
+			{name: "middle page", offset: 2, limit: 2, expected: []string{"c", "d"}},
+			{name: "last partial page", offset: 4, limit: 2, expected: []string{"e"}},
+			{name: "offset past the end", offset: 5, limit: 2, expected: []string{}},
+			{name: "no limit lists all", offset: 1, limit: 0, expected: []string{"b", "c", "d", "e"}},
+			{name: "negative offset starts at the beginning", offset: -1, limit: 1, expected: []string{"a"}},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				collection, err := repo.List(ctx, tc.offset, tc.limit)
+				assert.NoError(t, err)
+				assert.NotNil(t, collection)
+
+				ids := make([]string, 0, len(collection.Stations))
+				for _, s := range collection.Stations {
+					ids = append(ids, s.ID)
+				}
+				assert.Equal(t, tc.expected, ids)
+			})
+		}
+	})
+
+	t.Run("created station is returned unchanged", func(t *testing.T) {
+		repo := newRepository(t)
+		created := newStation("koeln")
+		assert.NoError(t, repo.Create(ctx, created))
+
+		assert.True(t, repo.Has(ctx, "koeln"))
+		found, err := repo.GetByID(ctx, "koeln")
+		assert.NoError(t, err)
+		assert.Equal(t, created, found)
+	})
+
+	t.Run("missing station is not found", func(t *testing.T) {
+		repo := newRepository(t)
+
+		assert.False(t, repo.Has(ctx, "missing"))
+		found, err := repo.GetByID(ctx, "missing")
+		assert.ErrorIs(t, err, station.ErrStationNotFound)
+		assert.Nil(t, found)
+	})
+
+	t.Run("duplicate station is rejected", func(t *testing.T) {
+		repo := newRepository(t)
+		assert.NoError(t, repo.Create(ctx, newStation("bonn")))
+
+		duplicate := newStation("bonn")
+		duplicate.Name = "Other"
+		assert.ErrorIs(t, repo.Create(ctx, duplicate), station.ErrStationExists)
+
+		found, err := repo.GetByID(ctx, "bonn")
+		assert.NoError(t, err)
+		assert.Equal(t, "Station bonn", found.Name)
+	})
+
+	t.Run("invalid stations are rejected", func(t *testing.T) {
+		repo := newRepository(t)
+
+		assert.Error(t, repo.Create(ctx, nil))
+		assert.Error(t, repo.Create(ctx, &station.Station{Name: "No ID"}))
+	})
+
+	t.Run("deleted station is not found", func(t *testing.T) {
+		repo := newRepository(t)
+		assert.NoError(t, repo.Create(ctx, newStation("mainz")))
+
+		assert.NoError(t, repo.Delete(ctx, "mainz"))
+		assert.False(t, repo.Has(ctx, "mainz"))
+		_, err := repo.GetByID(ctx, "mainz")
+		assert.ErrorIs(t, err, station.ErrStationNotFound)
+
+		// deleting is idempotent and the ID can be reused
+		assert.NoError(t, repo.Delete(ctx, "mainz"))
+		assert.NoError(t, repo.Create(ctx, newStation("mainz")))
+	})
+}
@@ -0,0 +1,192 @@
+package stationtest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/timgluz/wasserspiegel/station"
+)
+
+const (
+	MethodGetStations          = "GetStations"
+	MethodGetStation           = "GetStation"
+	MethodGetStationWaterLevel = "GetStationWaterLevel"
+)
+
+// Call records a request to the FakeProvider; ID is empty for GetStations.
+type Call struct {
+	Method string
+	ID     string
+}
+
+// FakeProvider is a station.Provider serving scripted stations and water
+// levels, keyed by the ID the provider is asked for. Errors queued with
+// FailNext are returned before any scripted response.
+type FakeProvider struct {
+	mu          sync.Mutex
+	stations    []station.Station
+	waterLevels map[string]*station.WaterLevelCollection
+	failures    map[string][]error
+	calls       []Call
+	notReady    bool
+	closed      bool
+}
+
+var _ station.Provider = (*FakeProvider)(nil)
+
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{
+		waterLevels: make(map[string]*station.WaterLevelCollection),
+		failures:    make(map[string][]error),
+	}
+}
+
+// WithStations adds stations returned by GetStations and, by their ID, by GetStation.
+func (p *FakeProvider) WithStations(stations ...station.Station) *FakeProvider {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.stations = append(p.stations, stations...)
+	return p
+}
+
+// WithWaterLevels sets the water levels returned for the ID.
+func (p *FakeProvider) WithWaterLevels(id string, waterLevels *station.WaterLevelCollection) *FakeProvider {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.waterLevels[id] = waterLevels
+	return p
+}
+
+// FailNext makes the next calls of the method fail with the errors, in order.
+func (p *FakeProvider) FailNext(method string, errs ...error) *FakeProvider {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.failures[method] = append(p.failures[method], errs...)
+	return p
+}
+
+// SetReady changes the result of IsReady.
+func (p *FakeProvider) SetReady(ready bool) *FakeProvider {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.notReady = !ready
+	return p
+}
+
+// Calls returns the requests made so far, optionally only those of the given methods.
+func (p *FakeProvider) Calls(methods ...string) []Call {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	calls := make([]Call, 0, len(p.calls))
+	for _, call := range p.calls {
+		if len(methods) == 0 || containsString(methods, call.Method) {
+			calls = append(calls, call)
+		}
+	}
+
+	return calls
+}
+
+func (p *FakeProvider) IsClosed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.closed
+}
+
+func (p *FakeProvider) GetStations(ctx context.Context) (*station.StationCollection, error) {
+	if err := p.record(MethodGetStations, ""); err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stations := make([]station.Station, len(p.stations))
+	copy(stations, p.stations)
+	return &station.StationCollection{Stations: stations}, nil
+}
+
+func (p *FakeProvider) GetStation(ctx context.Context, id string) (*station.Station, error) {
+	if err := p.record(MethodGetStation, id); err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, s := range p.stations {
+		if s.ID == id {
+			return &s, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: station %s", station.ErrResourceNotFound, id)
+}
+
+func (p *FakeProvider) GetStationWaterLevel(ctx context.Context, id string) (*station.WaterLevelCollection, error) {
+	if err := p.record(MethodGetStationWaterLevel, id); err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	waterLevels, ok := p.waterLevels[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: water levels of %s", station.ErrResourceNotFound, id)
+	}
+
+	copied := *waterLevels
+	copied.Measurements = append(station.MeasurementList(nil), waterLevels.Measurements...)
+	return &copied, nil
+}
+
+func (p *FakeProvider) IsReady() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return !p.notReady && !p.closed
+}
+
+func (p *FakeProvider) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+	return nil
+}
+
+// record logs the call and returns the next queued failure of the method.
+func (p *FakeProvider) record(method string, id string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.calls = append(p.calls, Call{Method: method, ID: id})
+	if p.closed {
+		return station.ErrProviderNotReady
+	}
+
+	if queued := p.failures[method]; len(queued) > 0 {
+		p.failures[method] = queued[1:]
+		return queued[0]
+	}
+
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
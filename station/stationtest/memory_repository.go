@@ -0,0 +1,122 @@
+// Package stationtest provides in-memory implementations of the station
+// repository and provider for tests, and the contract tests every
+// station.Repository has to pass.
+package stationtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/timgluz/wasserspiegel/station"
+)
+
+// MemoryRepository keeps stations in memory, listed in the order of their IDs.
+type MemoryRepository struct {
+	mu       sync.RWMutex
+	stations map[string]station.Station
+}
+
+var _ station.Repository = (*MemoryRepository)(nil)
+
+func NewMemoryRepository(stations ...station.Station) *MemoryRepository {
+	r := &MemoryRepository{stations: make(map[string]station.Station)}
+	for _, s := range stations {
+		r.stations[s.ID] = cloneStation(s)
+	}
+
+	return r
+}
+
+func (r *MemoryRepository) List(ctx context.Context, offset int, limit int) (*station.StationCollection, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ids := make([]string, 0, len(r.stations))
+	for id := range r.stations {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > len(ids) {
+		offset = len(ids)
+	}
+
+	until := len(ids)
+	if limit > 0 && offset+limit < until {
+		until = offset + limit
+	}
+
+	stations := make([]station.Station, 0, until-offset)
+	for _, id := range ids[offset:until] {
+		stations = append(stations, cloneStation(r.stations[id]))
+	}
+
+	return &station.StationCollection{Stations: stations}, nil
+}
+
+func (r *MemoryRepository) Has(ctx context.Context, id string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	_, ok := r.stations[id]
+	return ok
+}
+
+func (r *MemoryRepository) GetByID(ctx context.Context, id string) (*station.Station, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	s, ok := r.stations[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", station.ErrStationNotFound, id)
+	}
+
+	s = cloneStation(s)
+	return &s, nil
+}
+
+func (r *MemoryRepository) Create(ctx context.Context, s *station.Station) error {
+	if s == nil {
+		return errors.New("station cannot be nil")
+	}
+	if s.ID == "" {
+		return errors.New("station ID cannot be empty")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.stations[s.ID]; ok {
+		return fmt.Errorf("%w: %s", station.ErrStationExists, s.ID)
+	}
+
+	r.stations[s.ID] = cloneStation(*s)
+	return nil
+}
+
+func (r *MemoryRepository) Delete(ctx context.Context, id string) error {
+	if id == "" {
+		return errors.New("station ID cannot be empty")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.stations, id)
+	return nil
+}
+
+func (r *MemoryRepository) IsReady() bool { return true }
+func (r *MemoryRepository) Close() error  { return nil }
+
+// cloneStation copies the external IDs, so callers can't change stored stations.
+func cloneStation(s station.Station) station.Station {
+	s.ExternalIDs = append([]station.ExternalID(nil), s.ExternalIDs...)
+	return s
+}
@@ -0,0 +1,13 @@
+package stationtest
+
+import (
+	"testing"
+
+	"github.com/timgluz/wasserspiegel/station"
+)
+
+func TestMemoryRepositoryContract(t *testing.T) {
+	RunRepositoryContract(t, func(t *testing.T) station.Repository {
+		return NewMemoryRepository()
+	})
+}
@@ -2,15 +2,35 @@ package station
 
 import (
 	"fmt"
+	"math"
+	"strings"
 	"time"
+
+	"github.com/sosodev/duration"
 )
 
 const (
 	DefaultTimePeriod = "P10D" // Default time period for water level data (10 days)
 	UnitCM            = "cm"   // Default unit for water level measurements
+
+	// DefaultRateWindow is how far back the rate of change of the latest water level looks.
+	DefaultRateWindow = 3 * time.Hour
+	MinRateWindow     = 15 * time.Minute
+	MaxRateWindow     = 24 * time.Hour
+
+	MaxTrendWindows = 5
+	// MaxNearestDistance bounds how far the fallback water level of a trend window
+	// may be from its target time; half the window bounds shorter windows.
+	MaxNearestDistance = 12 * time.Hour
+)
+
+var (
+	ErrUnitsMismatch      = fmt.Errorf("units mismatch")
+	ErrInvalidTrendWindow = fmt.Errorf("invalid trend window")
 )
 
-var ErrUnitsMismatch = fmt.Errorf("units mismatch")
+// DefaultTrendWindows are the ISO 8601 durations the latest water level is compared over.
+var DefaultTrendWindows = []string{"P1D", "P3D", "P7D"}
 
 type MeasurementList []Measurement
 
@@ -22,6 +42,11 @@ type WaterLevelCollection struct {
 	Latest       Measurement      `json:"latest"` // Latest measurement
 	Trend        MeasurementTrend `json:"trend"`  // changes in over n days
 	Unit         string           `json:"unit"`   // Unit of measurement, e.g., "m" for meters
+	// RateOfChange of the latest water level per hour, e.g. in cm/h, missing without a sample in the rate window.
+	RateOfChange *Measurement `json:"rate_of_change,omitempty"`
+
+	FetchedAt string `json:"fetched_at,omitempty"` // When the data was fetched from the provider, set for cached data
+	Stale     bool   `json:"stale,omitempty"`      // The provider failed and cached data past its TTL was served
 }
 
 func (wlc *WaterLevelCollection) GetLatestMeasurement() Measurement {
@@ -34,13 +59,68 @@ func (wlc *WaterLevelCollection) GetLatestMeasurement() Measurement {
 	return wlc.Measurements[len(wlc.Measurements)-1]
 }
 
-type MeasurementTrend struct {
-	P1D *Measurement `json:"p1d,omitempty"` // Difference from the average of measurements from 1 day ago
-	P3D *Measurement `json:"p3d,omitempty"` // Difference from the average of measurements from 3 days ago
-	P7D *Measurement `json:"p7d,omitempty"` // Difference from the average of measurements from 7 days ago
+// LastModified returns the time of the latest measurement, or the zero time if it is unknown.
+func (wlc *WaterLevelCollection) LastModified() time.Time {
+	latest := wlc.Latest
+	if latest.Timestamp == "" {
+		latest = wlc.GetLatestMeasurement()
+	}
+
+	t, err := ParseTimestamp(latest.Timestamp)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return t
+}
+
+// MeasurementTrend holds the difference of the latest measurement to the average of the
+// day a window ago, keyed by the lower case window, e.g. "p1d"; windows without
+// measurements are missing.
+type MeasurementTrend map[string]*Measurement
+
+// TrendOptions configures the trend windows, the time zone of their day
+// boundaries and the window of the rate of change.
+type TrendOptions struct {
+	Windows    []string // ISO 8601 durations, e.g. P1D
+	Location   *time.Location
+	RateWindow time.Duration
+}
+
+func DefaultTrendOptions() TrendOptions {
+	return TrendOptions{
+		Windows:    DefaultTrendWindows,
+		Location:   time.UTC,
+		RateWindow: DefaultRateWindow,
+	}
+}
+
+// Validate checks the number and format of the windows and the bounds of the rate window.
+func (opts TrendOptions) Validate() error {
+	if len(opts.Windows) > MaxTrendWindows {
+		return fmt.Errorf("%w: at most %d windows are allowed", ErrInvalidTrendWindow, MaxTrendWindows)
+	}
+
+	for _, window := range opts.Windows {
+		if _, err := ParseTrendWindow(window); err != nil {
+			return err
+		}
+	}
+
+	if opts.RateWindow < MinRateWindow || opts.RateWindow > MaxRateWindow {
+		return fmt.Errorf("%w: rate window must be between %s and %s", ErrInvalidTrendWindow, MinRateWindow, MaxRateWindow)
+	}
+
+	return nil
 }
 
 func (wlc *WaterLevelCollection) CalculateTrends(measurements MeasurementList) error {
+	return wlc.CalculateTrendsWithOptions(measurements, DefaultTrendOptions())
+}
+
+// CalculateTrendsWithOptions compares the latest measurement with the days a window
+// ago in the time zone of the options, and calculates its rate of change.
+func (wlc *WaterLevelCollection) CalculateTrendsWithOptions(measurements MeasurementList, opts TrendOptions) error {
 	if len(measurements) == 0 {
 		return fmt.Errorf("no measurements available to calculate trends")
 	}
@@ -48,24 +128,89 @@ func (wlc *WaterLevelCollection) CalculateTrends(measurements MeasurementList) e
 	// Calculate the latest measurement
 	wlc.Latest = wlc.GetLatestMeasurement()
 
-	// Calculate trends for P1D, P3D, and P7D
-	var err error
-	wlc.Trend.P1D, err = calculateDifferenceNDays(wlc.Latest, measurements, 1)
+	trend, err := CalculateTrend(wlc.Latest, measurements, opts)
 	if err != nil {
-		return fmt.Errorf("error calculating P1D trend: %w", err)
+		return err
 	}
+	wlc.Trend = trend
 
-	wlc.Trend.P3D, err = calculateDifferenceNDays(wlc.Latest, measurements, 3)
+	wlc.RateOfChange, err = CalculateRateOfChange(wlc.Latest, measurements, opts.RateWindow)
 	if err != nil {
-		return fmt.Errorf("error calculating P3D trend: %w", err)
+		return fmt.Errorf("error calculating rate of change: %w", err)
 	}
 
-	wlc.Trend.P7D, err = calculateDifferenceNDays(wlc.Latest, measurements, 7)
+	return nil
+}
+
+// CalculateTrend compares the latest measurement with the measurements of every window.
+func CalculateTrend(latest Measurement, ms MeasurementList, opts TrendOptions) (MeasurementTrend, error) {
+	location := opts.Location
+	if location == nil {
+		location = time.UTC
+	}
+
+	trend := make(MeasurementTrend, len(opts.Windows))
+	for _, window := range opts.Windows {
+		windowDuration, err := ParseTrendWindow(window)
+		if err != nil {
+			return nil, err
+		}
+
+		difference, err := calculateDifference(latest, ms, windowDuration, location)
+		if err != nil {
+			return nil, fmt.Errorf("error calculating %s trend: %w", window, err)
+		}
+		if difference != nil {
+			trend[strings.ToLower(window)] = difference
+		}
+	}
+
+	return trend, nil
+}
+
+// CalculateRateOfChange returns the change per hour between the oldest measurement
+// within the window before the latest one and the latest one; it returns nil
+// without an older measurement in the window.
+func CalculateRateOfChange(latest Measurement, ms MeasurementList, window time.Duration) (*Measurement, error) {
+	latestTime, err := ParseTimestamp(latest.Timestamp)
 	if err != nil {
-		return fmt.Errorf("error calculating P7D trend: %w", err)
+		return nil, fmt.Errorf("invalid timestamp format: %w", err)
 	}
 
-	return nil
+	var oldest *Measurement
+	var oldestTime time.Time
+	for i := range ms {
+		timestamp, err := ParseTimestamp(ms[i].Timestamp)
+		if err != nil || !timestamp.Before(latestTime) || latestTime.Sub(timestamp) > window {
+			continue
+		}
+		if oldest == nil || timestamp.Before(oldestTime) {
+			oldest, oldestTime = &ms[i], timestamp
+		}
+	}
+	if oldest == nil {
+		return nil, nil
+	}
+
+	difference, err := latest.Difference(Measurement{Timestamp: oldest.Timestamp, Value: oldest.Value, Unit: latest.Unit})
+	if err != nil {
+		return nil, err
+	}
+
+	hours := latestTime.Sub(oldestTime).Hours()
+	difference.Value = math.Round(difference.Value/hours*100) / 100
+	difference.Unit = latest.Unit + "/h"
+	return difference, nil
+}
+
+// ParseTrendWindow parses a positive ISO 8601 duration, e.g. P1D.
+func ParseTrendWindow(window string) (*duration.Duration, error) {
+	windowDuration, err := duration.Parse(window)
+	if err != nil || windowDuration.Negative || windowDuration.ToTimeDuration() <= 0 {
+		return nil, fmt.Errorf("%w: %q must be a positive ISO 8601 duration, e.g. P1D", ErrInvalidTrendWindow, window)
+	}
+
+	return windowDuration, nil
 }
 
 type Measurement struct {
@@ -92,11 +237,13 @@ func (m Measurement) Difference(other Measurement) (*Measurement, error) {
 	}, nil
 }
 
-// calculateDifferenceNDays calculates the difference between a measurement and the average of measurements from n days ago.
-// It returns a new Measurement with the calculated difference.
-func calculateDifferenceNDays(m Measurement, ms MeasurementList, nDays int) (*Measurement, error) {
+// calculateDifference calculates the difference between a measurement and the average of the measurements
+// on the day, in the location, the window before it. Without measurements on that day it falls back to
+// the measurement nearest to the target time within MaxNearestDistance or half the window; it returns nil
+// if there is no such measurement, so a window longer than the water levels is left out.
+func calculateDifference(m Measurement, ms MeasurementList, window *duration.Duration, location *time.Location) (*Measurement, error) {
 	if len(ms) == 0 {
-		return nil, fmt.Errorf("no measurements available for P1D difference")
+		return nil, fmt.Errorf("no measurements available for difference")
 	}
 
 	startDate, err := ParseTimestamp(m.Timestamp)
@@ -104,10 +251,15 @@ func calculateDifferenceNDays(m Measurement, ms MeasurementList, nDays int) (*Me
 		return nil, fmt.Errorf("invalid timestamp format: %w", err)
 	}
 
-	targetDate := startDate.AddDate(0, 0, -1*nDays)
-	targetMeasurements := getSameDayMeasurements(ms, targetDate)
+	targetDate := subtractWindow(startDate.In(location), window)
+	targetMeasurements := getSameDayMeasurements(ms, targetDate, location)
 	if len(targetMeasurements) == 0 {
-		return nil, fmt.Errorf("no measurements found for the target date: %s", targetDate.Format(time.RFC3339))
+		maxDistance := min(MaxNearestDistance, startDate.Sub(targetDate)/2)
+		nearest := getNearestMeasurement(ms, targetDate, startDate, maxDistance)
+		if nearest == nil {
+			return nil, nil
+		}
+		return m.Difference(Measurement{Timestamp: nearest.Timestamp, Value: nearest.Value, Unit: m.Unit})
 	}
 
 	totalValue := 0.0
@@ -122,7 +274,43 @@ func calculateDifferenceNDays(m Measurement, ms MeasurementList, nDays int) (*Me
 	})
 }
 
-func getSameDayMeasurements(ms MeasurementList, targetDate time.Time) MeasurementList {
+// subtractWindow goes back by the calendar parts of the window in the location of t, so
+// P1D is the same wall clock time on the previous day even across DST changes.
+func subtractWindow(t time.Time, window *duration.Duration) time.Time {
+	years, months, days := int(window.Years), int(window.Months), int(window.Weeks*7+window.Days)
+	if float64(years) != window.Years || float64(months) != window.Months || float64(days) != window.Weeks*7+window.Days {
+		// fractions of calendar units have no wall clock meaning
+		return t.Add(-window.ToTimeDuration())
+	}
+
+	clock := duration.Duration{Hours: window.Hours, Minutes: window.Minutes, Seconds: window.Seconds}
+	return t.AddDate(-years, -months, -days).Add(-clock.ToTimeDuration())
+}
+
+// getNearestMeasurement returns the measurement before the latest one closest to the target
+// time, nil if none is within maxDistance of it.
+func getNearestMeasurement(ms MeasurementList, targetDate, latest time.Time, maxDistance time.Duration) *Measurement {
+	var nearest *Measurement
+	var nearestDistance time.Duration
+	for i := range ms {
+		timestamp, err := ParseTimestamp(ms[i].Timestamp)
+		if err != nil || !timestamp.Before(latest) {
+			continue
+		}
+
+		distance := timestamp.Sub(targetDate).Abs()
+		if distance > maxDistance {
+			continue
+		}
+		if nearest == nil || distance < nearestDistance {
+			nearest, nearestDistance = &ms[i], distance
+		}
+	}
+
+	return nearest
+}
+
+func getSameDayMeasurements(ms MeasurementList, targetDate time.Time, location *time.Location) MeasurementList {
 	var sameDayMeasurements MeasurementList
 
 	for _, m := range ms {
@@ -131,7 +319,7 @@ func getSameDayMeasurements(ms MeasurementList, targetDate time.Time) Measuremen
 			continue // Skip invalid timestamps
 		}
 
-		if IsDameDay(timestamp, targetDate) {
+		if IsSameDay(timestamp, targetDate, location) {
 			sameDayMeasurements = append(sameDayMeasurements, m)
 		}
 	}
@@ -150,8 +338,15 @@ func ParseTimestamp(timestamp string) (time.Time, error) {
 	return time.Parse(time.RFC3339, timestamp)
 }
 
+// IsDameDay reports whether both times fall on the same calendar day in UTC.
+//
+// Deprecated: use IsSameDay with the location of the calendar.
 func IsDameDay(t1, t2 time.Time) bool {
-	loc := time.UTC
+	return IsSameDay(t1, t2, time.UTC)
+}
+
+// IsSameDay reports whether both times fall on the same calendar day in the location.
+func IsSameDay(t1, t2 time.Time, loc *time.Location) bool {
 	t1Local := t1.In(loc)
 	t2Local := t2.In(loc)
 
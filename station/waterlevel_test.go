@@ -2,6 +2,8 @@ package station
 
 import (
 	"testing"
+	"time"
+	_ "time/tzdata"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -59,3 +61,53 @@ func TestMeasurementDifference(t *testing.T) {
 		})
 	}
 }
+
+func TestCalculateTrend(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	assert.NoError(t, err)
+
+	waterLevels := MeasurementList{
+		{Timestamp: "2025-03-29T23:30:00Z", Value: 100, Unit: UnitCM}, // after midnight in Berlin
+		{Timestamp: "2025-03-30T12:00:00Z", Value: 110, Unit: UnitCM},
+		{Timestamp: "2025-03-31T07:00:00Z", Value: 120, Unit: UnitCM},
+		{Timestamp: "2025-03-31T10:00:00Z", Value: 126, Unit: UnitCM},
+	}
+	latest := waterLevels[len(waterLevels)-1]
+	opts := TrendOptions{Windows: []string{"P1D", "P3D"}, Location: berlin, RateWindow: DefaultRateWindow}
+
+	trend, err := CalculateTrend(latest, waterLevels, opts)
+	assert.NoError(t, err)
+	if assert.Contains(t, trend, "p1d") {
+		assert.Equal(t, 21.0, trend["p1d"].Value, "the day of 2025-03-30 starts in Berlin")
+	}
+	assert.NotContains(t, trend, "p3d", "the nearest water level is 36 hours from the target")
+
+	trend, err = CalculateTrend(latest, MeasurementList{waterLevels[0], latest}, TrendOptions{Windows: []string{"P1D"}, Location: time.UTC, RateWindow: DefaultRateWindow})
+	assert.NoError(t, err)
+	if assert.Contains(t, trend, "p1d") {
+		assert.Equal(t, 26.0, trend["p1d"].Value, "falls back to the nearest water level")
+		assert.Equal(t, "2025-03-29T23:30:00Z", trend["p1d"].StartAt, "the actual span starts at it")
+		assert.Equal(t, latest.Timestamp, trend["p1d"].EndAt)
+	}
+
+	opts.Location = time.UTC
+	trend, err = CalculateTrend(latest, waterLevels, opts)
+	assert.NoError(t, err)
+	if assert.Contains(t, trend, "p1d") {
+		assert.Equal(t, 16.0, trend["p1d"].Value)
+	}
+
+	rate, err := CalculateRateOfChange(latest, waterLevels, DefaultRateWindow)
+	assert.NoError(t, err)
+	if assert.NotNil(t, rate) {
+		assert.Equal(t, 2.0, rate.Value)
+		assert.Equal(t, "cm/h", rate.Unit)
+	}
+
+	rate, err = CalculateRateOfChange(latest, waterLevels[3:], DefaultRateWindow)
+	assert.NoError(t, err)
+	assert.Nil(t, rate, "no earlier water level in the window")
+
+	assert.ErrorIs(t, TrendOptions{Windows: []string{"1D"}, RateWindow: DefaultRateWindow}.Validate(), ErrInvalidTrendWindow)
+	assert.ErrorIs(t, TrendOptions{Windows: DefaultTrendWindows, RateWindow: time.Minute}.Validate(), ErrInvalidTrendWindow)
+}
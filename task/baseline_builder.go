@@ -0,0 +1,108 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/timgluz/wasserspiegel/measurement"
+)
+
+type BaselineBuilderOptions struct {
+	StationID  string // all stations if empty
+	WindowDays int
+}
+
+func NewDefaultBaselineBuilderOptions(stationID string) BaselineBuilderOptions {
+	return BaselineBuilderOptions{
+		StationID:  stationID,
+		WindowDays: measurement.DefaultBaselineWindowDays,
+	}
+}
+
+// BaselineResult tells how many days of the year of a measurement have a baseline.
+type BaselineResult struct {
+	MeasurementName string `json:"measurement_name"`
+	Samples         int    `json:"samples"`
+	Baselines       int    `json:"baselines"`
+}
+
+// BaselineBuilder recomputes the historical baselines of the water levels from
+// all collected samples; it is meant to run daily, e.g. after midnight.
+type BaselineBuilder struct {
+	measurementRepo measurement.Repository
+
+	logger *slog.Logger
+}
+
+func NewBaselineBuilder(measurementRepo measurement.Repository, logger *slog.Logger) *BaselineBuilder {
+	return &BaselineBuilder{
+		measurementRepo: measurementRepo,
+		logger:          logger,
+	}
+}
+
+func (b *BaselineBuilder) Run(ctx context.Context, opts BaselineBuilderOptions) ([]BaselineResult, error) {
+	measurementNames, err := b.waterLevelMeasurements(ctx, opts.StationID)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]BaselineResult, 0, len(measurementNames))
+	for _, measurementName := range measurementNames {
+		result, err := b.build(ctx, measurementName, opts.WindowDays)
+		if err != nil {
+			b.logger.Error("Failed to build baselines", "measurementName", measurementName, "error", err)
+			return results, err
+		}
+		results = append(results, result)
+	}
+
+	b.logger.Info("Baseline building process completed", "measurements", len(results))
+	return results, nil
+}
+
+// waterLevelMeasurements returns the water level measurement of the station, or all of them.
+func (b *BaselineBuilder) waterLevelMeasurements(ctx context.Context, stationID string) ([]string, error) {
+	if stationID != "" {
+		return []string{measurement.NewMeasurementName("waterlevel", stationID)}, nil
+	}
+
+	measurements, err := b.measurementRepo.GetMeasurements(ctx)
+	if err != nil {
+		b.logger.Error("Failed to list measurements", "error", err)
+		return nil, err
+	}
+
+	var names []string
+	for _, m := range measurements {
+		if strings.HasPrefix(m.Name, "waterlevel-") {
+			names = append(names, m.Name)
+		}
+	}
+
+	return names, nil
+}
+
+func (b *BaselineBuilder) build(ctx context.Context, measurementName string, windowDays int) (BaselineResult, error) {
+	now := measurement.CurrentEpoch()
+	timeseries, err := b.measurementRepo.GetTimeseries(ctx, measurementName, measurement.Period{Start: 0, End: now})
+	if err != nil {
+		return BaselineResult{}, err
+	}
+	if timeseries == nil {
+		return BaselineResult{}, fmt.Errorf("%w: %s", measurement.ErrMeasurementNotFound, measurementName)
+	}
+
+	baselines := measurement.ComputeBaselines(timeseries.Samples, windowDays, now)
+	if err := b.measurementRepo.SaveBaselines(ctx, measurementName, baselines); err != nil {
+		return BaselineResult{}, err
+	}
+
+	if len(baselines) == 0 {
+		b.logger.Info("Not enough history for baselines yet", "measurementName", measurementName, "samples", len(timeseries.Samples))
+	}
+
+	return BaselineResult{MeasurementName: measurementName, Samples: len(timeseries.Samples), Baselines: len(baselines)}, nil
+}
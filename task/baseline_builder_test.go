@@ -0,0 +1,77 @@
+package task
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/timgluz/wasserspiegel/dashboard/dashboardtest"
+	"github.com/timgluz/wasserspiegel/measurement"
+	"github.com/timgluz/wasserspiegel/measurement/measurementtest"
+	"github.com/timgluz/wasserspiegel/station/stationtest"
+)
+
+// addDailyHistory adds a sample at noon of each of the days around today one year ago,
+// 300 cm plus the day's number.
+func addDailyHistory(t *testing.T, repo measurement.Repository, name string, days int) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	timeseries := &measurement.Timeseries{Name: name, Measurement: &measurement.Measurement{Name: name, Unit: "cm"}}
+	for i := days; i >= 1; i-- {
+		ts := today.AddDate(-1, 0, days/2-i).Add(12 * time.Hour)
+		timeseries.Samples = append(timeseries.Samples, measurement.Sample{Timestamp: measurement.Epoch(ts.Unix()), Value: float64(300 + i%30)})
+	}
+	assert.NoError(t, repo.AddTimeseries(context.Background(), timeseries))
+}
+
+func TestBaselineBuilderStoresBaselines(t *testing.T) {
+	ctx := context.Background()
+	measurementRepo := measurementtest.NewMemoryRepository()
+	addDailyHistory(t, measurementRepo, "waterlevel-bonn", 30)
+	addDailyHistory(t, measurementRepo, "waterlevel-koeln", 5)
+	assert.NoError(t, measurementRepo.AddMeasurement(ctx, &measurement.Measurement{Name: "temperature-bonn", Unit: "C"}))
+
+	results, err := NewBaselineBuilder(measurementRepo, slog.Default()).Run(ctx, NewDefaultBaselineBuilderOptions(""))
+	assert.NoError(t, err)
+	if assert.Len(t, results, 2, "only water levels have baselines") {
+		assert.Equal(t, "waterlevel-bonn", results[0].MeasurementName)
+		assert.Equal(t, 30, results[0].Samples)
+		assert.NotZero(t, results[0].Baselines)
+		assert.Zero(t, results[1].Baselines, "5 days of history are too few")
+	}
+
+	baseline, err := measurementRepo.GetBaseline(ctx, "waterlevel-bonn", measurement.DayOfYear(measurement.CurrentEpoch()))
+	assert.NoError(t, err)
+	assert.NotNil(t, baseline)
+
+	_, err = NewBaselineBuilder(measurementRepo, slog.Default()).Run(ctx, NewDefaultBaselineBuilderOptions("missing"))
+	assert.ErrorIs(t, err, measurement.ErrMeasurementNotFound)
+}
+
+func TestDashboardBuilderAddsHistoricalContext(t *testing.T) {
+	ctx := context.Background()
+	dashboardRepo := dashboardtest.NewMemoryRepository()
+	measurementRepo := measurementtest.NewMemoryRepository()
+	name := measurement.NewMeasurementName("waterlevel", "bonn")
+	addDailyHistory(t, measurementRepo, name, 30)
+	_, err := NewBaselineBuilder(measurementRepo, slog.Default()).Run(ctx, NewDefaultBaselineBuilderOptions("bonn"))
+	assert.NoError(t, err)
+
+	timeseries, err := mapWaterLevelCollectionToTimeseries(newTestWaterLevels("bonn", 340), name, measurement.Period{})
+	assert.NoError(t, err)
+	assert.NoError(t, measurementRepo.AddTimeseries(ctx, timeseries))
+
+	builder := NewDashboardBuilder(stationtest.NewMemoryRepository(newTestStation("bonn")), dashboardRepo, measurementRepo, slog.Default())
+	opts := NewDefaultDashboardBuilderOptions("bonn")
+	assert.NoError(t, builder.Run(ctx, opts))
+
+	item, err := dashboardRepo.GetByID(ctx, dashboardID(t, opts))
+	assert.NoError(t, err)
+	if assert.NotNil(t, item) && assert.NotNil(t, item.Historical) {
+		assert.Equal(t, 340.0, item.Historical.Value)
+		assert.Equal(t, 100.0, item.Historical.Percentile, "above the highest level of the history")
+		assert.Equal(t, measurement.LevelVeryHigh, item.Historical.Class)
+	}
+}
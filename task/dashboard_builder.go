@@ -2,24 +2,30 @@ package task
 
 import (
 	"context"
+	"errors"
 	"log/slog"
+	"time"
 
 	"github.com/timgluz/wasserspiegel/dashboard"
+	"github.com/timgluz/wasserspiegel/i18n"
 	"github.com/timgluz/wasserspiegel/measurement"
 	"github.com/timgluz/wasserspiegel/station"
 )
 
 const (
 	DefaultPeriod       = "P15D" // ISO 8601 duration for the last 15 days
-	DefaultLanguageCode = "en"
+	DefaultLanguageCode = i18n.DefaultLanguageCode
 	DefaultTimezone     = "utc"
 )
 
 type DashboardBuilderOptions struct {
-	StationID    string
-	Period       string
-	LanguageCode string
-	Timezone     string
+	StationID       string
+	Period          string
+	LanguageCode    string
+	Timezone        string
+	ForecastHorizon time.Duration
+	TrendWindows    []string // ISO 8601 durations
+	RateWindow      time.Duration
 }
 
 func NewDefaultDashboardBuilderOptions(stationID string) DashboardBuilderOptions {
@@ -28,6 +34,10 @@ func NewDefaultDashboardBuilderOptions(stationID string) DashboardBuilderOptions
 		Period:       DefaultPeriod,
 		LanguageCode: DefaultLanguageCode,
 		Timezone:     DefaultTimezone,
+
+		ForecastHorizon: measurement.DefaultForecastOptions().Horizon,
+		TrendWindows:    station.DefaultTrendWindows,
+		RateWindow:      station.DefaultRateWindow,
 	}
 }
 
@@ -57,6 +67,18 @@ func (b *DashboardBuilder) Run(ctx context.Context, opts DashboardBuilderOptions
 	defer ctx.Done()
 	b.logger.Info("Building dashboards...")
 
+	localizer, err := i18n.NewLocalizer(opts.LanguageCode, opts.Timezone)
+	if err != nil {
+		b.logger.Error("Failed to localize dashboard", "error", err)
+		return err
+	}
+
+	trendOptions := station.TrendOptions{Windows: opts.TrendWindows, Location: localizer.Location(), RateWindow: opts.RateWindow}
+	if err := trendOptions.Validate(); err != nil {
+		b.logger.Error("Invalid trend options", "error", err)
+		return err
+	}
+
 	newDashboard := dashboard.NewEmptyDashboard(opts.StationID, opts.LanguageCode, opts.Timezone)
 
 	dashboardID, err := dashboard.GenerateDashboardID(newDashboard)
@@ -75,8 +97,8 @@ func (b *DashboardBuilder) Run(ctx context.Context, opts DashboardBuilderOptions
 			return err
 		}
 
-		newDashboard.Name = "Dashboard for " + newDashboard.Station.Name
-		newDashboard.Description = "Auto-generated dashboard for station " + newDashboard.Station.Name
+		newDashboard.Name = localizer.T("dashboard.name", newDashboard.Station.Name)
+		newDashboard.Description = localizer.T("dashboard.description", newDashboard.Station.Name)
 	}
 
 	// Fetch water level measurements
@@ -93,7 +115,37 @@ func (b *DashboardBuilder) Run(ctx context.Context, opts DashboardBuilderOptions
 		return err
 	}
 
+	if waterLevelTimeseries == nil {
+		// nothing collected yet for the station
+		b.logger.Warn("No water level timeseries found", "measurementName", measurementName)
+		waterLevelTimeseries = &measurement.Timeseries{Name: measurementName, Start: period.Start, End: period.End}
+	}
+
+	// glitches like -777 shouldn't show up on displays
+	waterLevelTimeseries.Gaps = measurement.DefaultQualityPolicy().DetectGaps(waterLevelTimeseries.Samples)
+	if excluded := waterLevelTimeseries.ExcludeFlagged(measurement.FlagsInvalid); excluded > 0 {
+		b.logger.Info("Excluded flagged water levels", "measurementName", measurementName, "count", excluded)
+	}
+
 	newDashboard.WaterLevel = *waterLevelTimeseries
+	if err := b.addForecast(newDashboard, opts.ForecastHorizon); err != nil {
+		b.logger.Error("Failed to forecast water levels", "error", err)
+		return err
+	}
+	if err := b.addHistoricalContext(ctx, newDashboard); err != nil {
+		b.logger.Error("Failed to rank water level against its baseline", "error", err)
+		return err
+	}
+	if err := b.addTrend(newDashboard, trendOptions); err != nil {
+		b.logger.Error("Failed to calculate water level trend", "error", err)
+		return err
+	}
+	now := time.Now()
+	newDashboard.UpdateFreshness(measurement.DefaultFreshnessPolicy(), now)
+	newDashboard.Localize(localizer, now)
+	if newDashboard.Stale {
+		b.logger.Warn("Water levels of dashboard are stale", "measurementName", measurementName, "samples", len(newDashboard.WaterLevel.Samples))
+	}
 
 	// store the updated dashboard
 	// TODO: if pattern repeats, refactor into upsert method in repository
@@ -116,6 +168,68 @@ func (b *DashboardBuilder) Run(ctx context.Context, opts DashboardBuilderOptions
 	return nil
 }
 
+// addForecast projects the water levels; without enough recent samples the dashboard has no forecast.
+func (b *DashboardBuilder) addForecast(dashboard *dashboard.Dashboard, horizon time.Duration) error {
+	forecastOptions := measurement.DefaultForecastOptions()
+	if horizon > 0 {
+		forecastOptions.Horizon = horizon
+	}
+
+	forecast, err := dashboard.WaterLevel.Forecast(forecastOptions)
+	if errors.Is(err, measurement.ErrNotEnoughSamples) {
+		b.logger.Warn("Skipping water level forecast", "measurementName", dashboard.WaterLevel.Name, "reason", err)
+		dashboard.Forecast = nil
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	dashboard.Forecast = forecast
+	return nil
+}
+
+// addHistoricalContext ranks the latest water level; without a baseline for its day the dashboard has none.
+func (b *DashboardBuilder) addHistoricalContext(ctx context.Context, dashboard *dashboard.Dashboard) error {
+	dashboard.Historical = nil
+	samples := dashboard.WaterLevel.Samples
+	if len(samples) == 0 {
+		return nil
+	}
+
+	latest := samples[len(samples)-1]
+	historical, err := measurement.RankAgainstBaseline(ctx, b.measurementRepo, dashboard.WaterLevel.Name, latest.Value, latest.Timestamp)
+	if err != nil {
+		return err
+	}
+
+	dashboard.Historical = historical
+	return nil
+}
+
+// addTrend compares the latest water level with the previous days and calculates its rate of change.
+func (b *DashboardBuilder) addTrend(dashboard *dashboard.Dashboard, opts station.TrendOptions) error {
+	dashboard.Trend, dashboard.RateOfChange = nil, nil
+	waterLevels := mapTimeseriesToMeasurements(dashboard.WaterLevel)
+	if len(waterLevels) == 0 {
+		return nil
+	}
+
+	latest := waterLevels[len(waterLevels)-1]
+	trend, err := station.CalculateTrend(latest, waterLevels, opts)
+	if err != nil {
+		return err
+	}
+
+	rateOfChange, err := station.CalculateRateOfChange(latest, waterLevels, opts.RateWindow)
+	if err != nil {
+		return err
+	}
+
+	dashboard.Trend, dashboard.RateOfChange = trend, rateOfChange
+	return nil
+}
+
 func (b *DashboardBuilder) addStationDetails(dashboard *dashboard.Dashboard, stationID string) error {
 	stationDetails, err := b.stationRepo.GetByID(context.Background(), stationID)
 	if err != nil {
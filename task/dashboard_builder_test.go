@@ -0,0 +1,199 @@
+package task
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/timgluz/wasserspiegel/dashboard"
+	"github.com/timgluz/wasserspiegel/dashboard/dashboardtest"
+	"github.com/timgluz/wasserspiegel/measurement"
+	"github.com/timgluz/wasserspiegel/measurement/measurementtest"
+	"github.com/timgluz/wasserspiegel/station"
+	"github.com/timgluz/wasserspiegel/station/stationtest"
+)
+
+func TestDashboardBuilderCreatesDashboard(t *testing.T) {
+	ctx := context.Background()
+	dashboardRepo := dashboardtest.NewMemoryRepository()
+	measurementRepo := measurementtest.NewMemoryRepository()
+	builder := NewDashboardBuilder(stationtest.NewMemoryRepository(newTestStation("bonn")), dashboardRepo, measurementRepo, slog.Default())
+
+	timeseries, err := mapWaterLevelCollectionToTimeseries(newTestWaterLevels("bonn", 310, -777, 312),
+		measurement.NewMeasurementName("waterlevel", "bonn"), measurement.Period{})
+	assert.NoError(t, err)
+	assert.NoError(t, measurementRepo.AddTimeseries(ctx, timeseries))
+
+	opts := NewDefaultDashboardBuilderOptions("bonn")
+	assert.NoError(t, builder.Run(ctx, opts))
+
+	item, err := dashboardRepo.GetByID(ctx, dashboardID(t, opts))
+	assert.NoError(t, err)
+	if assert.NotNil(t, item) {
+		assert.Equal(t, "Dashboard for Station bonn", item.Name)
+		assert.Equal(t, "bonn", item.Station.ID)
+		assert.Len(t, item.WaterLevel.Samples, 2, "the -777 glitch is excluded")
+		assert.Nil(t, item.Forecast, "2 samples are too few to forecast")
+		if assert.NotNil(t, item.RateOfChange) {
+			assert.Equal(t, 4.0, item.RateOfChange.Value, "2 cm in half an hour")
+		}
+	}
+}
+
+func TestDashboardBuilderAddsForecast(t *testing.T) {
+	ctx := context.Background()
+	dashboardRepo := dashboardtest.NewMemoryRepository()
+	measurementRepo := measurementtest.NewMemoryRepository()
+	builder := NewDashboardBuilder(stationtest.NewMemoryRepository(newTestStation("bonn")), dashboardRepo, measurementRepo, slog.Default())
+
+	timeseries, err := mapWaterLevelCollectionToTimeseries(newTestWaterLevels("bonn", 300, 301, 302, 303, 304, 305, 306, 307),
+		measurement.NewMeasurementName("waterlevel", "bonn"), measurement.Period{})
+	assert.NoError(t, err)
+	assert.NoError(t, measurementRepo.AddTimeseries(ctx, timeseries))
+
+	opts := NewDefaultDashboardBuilderOptions("bonn")
+	opts.ForecastHorizon = 6 * time.Hour
+	assert.NoError(t, builder.Run(ctx, opts))
+
+	item, err := dashboardRepo.GetByID(ctx, dashboardID(t, opts))
+	assert.NoError(t, err)
+	if assert.NotNil(t, item) && assert.NotNil(t, item.Forecast) {
+		assert.InDelta(t, 4, item.Forecast.Rate, 0.01, "1 cm every 15 minutes")
+		assert.Len(t, item.Forecast.Points, 6)
+	}
+}
+
+func TestDashboardBuilderWithoutMeasurements(t *testing.T) {
+	ctx := context.Background()
+	dashboardRepo := dashboardtest.NewMemoryRepository()
+	builder := NewDashboardBuilder(stationtest.NewMemoryRepository(newTestStation("bonn")), dashboardRepo,
+		measurementtest.NewMemoryRepository(), slog.Default())
+
+	opts := NewDefaultDashboardBuilderOptions("bonn")
+	assert.NoError(t, builder.Run(ctx, opts))
+
+	item, err := dashboardRepo.GetByID(ctx, dashboardID(t, opts))
+	assert.NoError(t, err)
+	if assert.NotNil(t, item) {
+		assert.Equal(t, measurement.NewMeasurementName("waterlevel", "bonn"), item.WaterLevel.Name)
+		assert.Empty(t, item.WaterLevel.Samples)
+	}
+}
+
+func TestDashboardBuilderUpdatesExistingDashboard(t *testing.T) {
+	ctx := context.Background()
+	dashboardRepo := dashboardtest.NewMemoryRepository()
+	measurementRepo := measurementtest.NewMemoryRepository()
+	builder := NewDashboardBuilder(stationtest.NewMemoryRepository(newTestStation("bonn")), dashboardRepo, measurementRepo, slog.Default())
+
+	opts := NewDefaultDashboardBuilderOptions("bonn")
+	assert.NoError(t, builder.Run(ctx, opts))
+
+	timeseries, err := mapWaterLevelCollectionToTimeseries(newTestWaterLevels("bonn", 310, 312, 315),
+		measurement.NewMeasurementName("waterlevel", "bonn"), measurement.Period{})
+	assert.NoError(t, err)
+	assert.NoError(t, measurementRepo.AddTimeseries(ctx, timeseries))
+	assert.NoError(t, builder.Run(ctx, opts))
+
+	collection, err := dashboardRepo.List(ctx, 0, 10)
+	assert.NoError(t, err)
+	assert.Len(t, collection.Items, 1)
+
+	item, err := dashboardRepo.GetByID(ctx, dashboardID(t, opts))
+	assert.NoError(t, err)
+	if assert.NotNil(t, item) {
+		assert.Equal(t, "Dashboard for Station bonn", item.Name)
+		assert.Len(t, item.WaterLevel.Samples, 3)
+	}
+}
+
+func TestDashboardBuilderRebuildsStaleDashboard(t *testing.T) {
+	ctx := context.Background()
+	dashboardRepo := dashboardtest.NewMemoryRepository()
+	measurementRepo := measurementtest.NewMemoryRepository()
+	builder := NewDashboardBuilder(stationtest.NewMemoryRepository(newTestStation("bonn")), dashboardRepo, measurementRepo, slog.Default())
+
+	opts := NewDefaultDashboardBuilderOptions("bonn")
+	dataAge := int64(60)
+	existing := dashboard.NewEmptyDashboard(opts.StationID, opts.LanguageCode, opts.Timezone)
+	existing.ID = dashboardID(t, opts)
+	existing.Name = "Bonn"
+	existing.DataAge = &dataAge
+	existing.Forecast = &measurement.Forecast{Rate: 4}
+	existing.RateOfChange = &station.Measurement{Value: 4, Unit: "cm/h"}
+	assert.NoError(t, dashboardRepo.Add(ctx, existing))
+
+	// the water levels stopped updating 6 hours ago
+	timeseries, err := mapWaterLevelCollectionToTimeseries(newTestWaterLevels("bonn", 310, 312),
+		measurement.NewMeasurementName("waterlevel", "bonn"), measurement.Period{})
+	assert.NoError(t, err)
+	for i := range timeseries.Samples {
+		timeseries.Samples[i].Timestamp -= measurement.Epoch(6 * time.Hour / time.Second)
+	}
+	assert.NoError(t, measurementRepo.AddTimeseries(ctx, timeseries))
+	assert.NoError(t, builder.Run(ctx, opts))
+
+	item, err := dashboardRepo.GetByID(ctx, existing.ID)
+	assert.NoError(t, err)
+	if assert.NotNil(t, item) {
+		assert.Equal(t, "Bonn", item.Name, "set fields are kept")
+		assert.Equal(t, existing.CreatedAt, item.CreatedAt)
+		assert.True(t, item.Stale)
+		if assert.NotNil(t, item.DataAge) {
+			assert.GreaterOrEqual(t, *item.DataAge, int64(6*3600))
+		}
+		assert.Nil(t, item.Forecast, "the forecast of the fresh water levels is dropped")
+		if assert.NotNil(t, item.RateOfChange) {
+			assert.Equal(t, 8.0, item.RateOfChange.Value, "2 cm in 15 minutes")
+		}
+	}
+}
+
+func TestDashboardBuilderKeepsFlatWaterLevels(t *testing.T) {
+	ctx := context.Background()
+	dashboardRepo := dashboardtest.NewMemoryRepository()
+	measurementRepo := measurementtest.NewMemoryRepository()
+	builder := NewDashboardBuilder(stationtest.NewMemoryRepository(newTestStation("bonn")), dashboardRepo, measurementRepo, slog.Default())
+
+	// a steady river reads the same centimetre for 8 hours
+	values := make([]float64, 33)
+	for i := range values {
+		values[i] = 310
+	}
+	timeseries, err := mapWaterLevelCollectionToTimeseries(newTestWaterLevels("bonn", values...),
+		measurement.NewMeasurementName("waterlevel", "bonn"), measurement.Period{})
+	assert.NoError(t, err)
+	assert.NoError(t, measurementRepo.AddTimeseries(ctx, timeseries))
+
+	opts := NewDefaultDashboardBuilderOptions("bonn")
+	assert.NoError(t, builder.Run(ctx, opts))
+
+	item, err := dashboardRepo.GetByID(ctx, dashboardID(t, opts))
+	assert.NoError(t, err)
+	if assert.NotNil(t, item) {
+		if assert.Len(t, item.WaterLevel.Samples, 33, "flatlines are kept") {
+			assert.True(t, item.WaterLevel.Samples[32].Flags.Has(measurement.FlagFlatline))
+		}
+		assert.False(t, item.Stale)
+		if assert.NotNil(t, item.DataAge) {
+			assert.Less(t, *item.DataAge, int64(3600))
+		}
+	}
+}
+
+func TestDashboardBuilderUnknownStation(t *testing.T) {
+	builder := NewDashboardBuilder(stationtest.NewMemoryRepository(), dashboardtest.NewMemoryRepository(),
+		measurementtest.NewMemoryRepository(), slog.Default())
+
+	err := builder.Run(context.Background(), NewDefaultDashboardBuilderOptions("missing"))
+	assert.ErrorIs(t, err, station.ErrStationNotFound)
+}
+
+func dashboardID(t *testing.T, opts DashboardBuilderOptions) string {
+	id, err := dashboard.GenerateDashboardID(dashboard.NewEmptyDashboard(opts.StationID, opts.LanguageCode, opts.Timezone))
+	assert.NoError(t, err)
+	return id
+}
@@ -4,28 +4,78 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"time"
 
 	"github.com/timgluz/wasserspiegel/measurement"
+	"github.com/timgluz/wasserspiegel/metrics"
 	"github.com/timgluz/wasserspiegel/station"
 )
 
+const collectorTaskName = "collect_station_measurements"
+
+var (
+	CollectorRunsMetric = metrics.Descriptor{
+		Name: "wasserspiegel_collector_runs",
+		Type: metrics.TypeCounter,
+		Help: "Number of water level collector runs.",
+	}
+	CollectorFailuresMetric = metrics.Descriptor{
+		Name: "wasserspiegel_collector_failures",
+		Type: metrics.TypeCounter,
+		Help: "Number of failed water level collector runs.",
+	}
+	ProviderLatencyMetric = metrics.Descriptor{
+		Name: "wasserspiegel_provider_request_duration_seconds",
+		Type: metrics.TypeSummary,
+		Unit: "seconds",
+		Help: "Time spent waiting for the station provider.",
+	}
+)
+
 type StationWaterLevelCollector struct {
 	measurementRepo measurement.Repository
 	stationRepo     station.Repository
-	stationProvider station.Provider
+	providers       *station.Registry
+	recorder        metrics.Recorder
 
 	logger *slog.Logger
 }
 
 func NewStationWaterLevelCollector(measurementRepo measurement.Repository,
 	stationRepo station.Repository,
-	stationProvider station.Provider,
+	providers *station.Registry,
 	logger *slog.Logger,
 ) *StationWaterLevelCollector {
-	return &StationWaterLevelCollector{measurementRepo, stationRepo, stationProvider, logger}
+	return &StationWaterLevelCollector{measurementRepo, stationRepo, providers, metrics.NopRecorder{}, logger}
+}
+
+// WithRecorder sets the recorder used to count runs, failures and provider latency.
+func (t *StationWaterLevelCollector) WithRecorder(recorder metrics.Recorder) *StationWaterLevelCollector {
+	if recorder != nil {
+		t.recorder = recorder
+	}
+
+	return t
 }
 
 func (t *StationWaterLevelCollector) Run(ctx context.Context, stationID string, period measurement.Period) error {
+	err := t.collect(ctx, stationID, period)
+
+	labels := metrics.NewLabels("task", collectorTaskName)
+	if recErr := t.recorder.IncCounter(ctx, CollectorRunsMetric, labels); recErr != nil {
+		t.logger.Warn("Failed to record collector run", "error", recErr)
+	}
+
+	if err != nil {
+		if recErr := t.recorder.IncCounter(ctx, CollectorFailuresMetric, labels); recErr != nil {
+			t.logger.Warn("Failed to record collector failure", "error", recErr)
+		}
+	}
+
+	return err
+}
+
+func (t *StationWaterLevelCollector) collect(ctx context.Context, stationID string, period measurement.Period) error {
 	defer ctx.Done()
 	t.logger.Info("Fetching water level data for station", "stationID", stationID, "period", period.String())
 
@@ -45,15 +95,20 @@ func (t *StationWaterLevelCollector) Run(ctx context.Context, stationID string,
 		return nil
 	}
 
-	pegelOnlineID, ok := stationDetails.GetPegelOnlineID()
-	if !ok || pegelOnlineID == "" {
-		t.logger.Error("Station does not have a valid PegelOnline ID", "stationID", stationID)
-		return fmt.Errorf("station does not have a valid PegelOnline ID")
+	provider, externalID, err := t.providers.ForStation(*stationDetails)
+	if err != nil {
+		t.logger.Error("Station has no external ID with a registered provider", "stationID", stationID, "error", err)
+		return err
 	}
 
 	// Fetch the water level data from the provider
-	t.logger.Debug("Fetching water levels from provider", "pegelOnlineID", pegelOnlineID, "stationID", stationID)
-	waterLevels, err := t.stationProvider.GetStationWaterLevel(ctx, pegelOnlineID)
+	t.logger.Debug("Fetching water levels from provider", "provider", externalID.Name, "externalID", externalID.ID, "stationID", stationID)
+	startedAt := time.Now()
+	waterLevels, err := provider.GetStationWaterLevel(ctx, externalID.ID)
+	providerLabels := metrics.NewLabels("provider", externalID.Name)
+	if recErr := t.recorder.ObserveDuration(ctx, ProviderLatencyMetric, providerLabels, time.Since(startedAt)); recErr != nil {
+		t.logger.Warn("Failed to record provider latency", "error", recErr)
+	}
 	if err != nil {
 		t.logger.Error("Failed to fetch water levels", "error", err)
 		return err
@@ -71,6 +126,7 @@ func (t *StationWaterLevelCollector) Run(ctx context.Context, stationID string,
 		t.logger.Error("Failed to map water level collection to timeseries", "error", err)
 		return err
 	}
+	timeseries.Measurement.Labels = measurement.Labels{StationID: stationID, Parameter: "waterlevel", Provider: externalID.Name}
 
 	// Add the timeseries to the repository
 	t.logger.Debug("Adding timeseries to repository", "measurementName", measurementName)
@@ -101,6 +157,15 @@ func mapWaterLevelCollectionToTimeseries(waterLevels *station.WaterLevelCollecti
 		})
 	}
 
+	unit := station.UnitCM
+	if waterLevels.Unit != "" {
+		normalized, err := measurement.NormalizeUnit(waterLevels.Unit)
+		if err != nil {
+			return nil, fmt.Errorf("water levels of station %s: %w", waterLevels.StationID, err)
+		}
+		unit = normalized
+	}
+
 	return &measurement.Timeseries{
 		Name:    measurementName,
 		Samples: samples,
@@ -109,7 +174,25 @@ func mapWaterLevelCollectionToTimeseries(waterLevels *station.WaterLevelCollecti
 		Measurement: &measurement.Measurement{
 			Name:        measurementName,
 			Description: "Water level measurements for station " + waterLevels.StationID,
-			Unit:        "cm",
+			Unit:        unit,
 		},
 	}, nil
 }
+
+func mapTimeseriesToMeasurements(timeseries measurement.Timeseries) station.MeasurementList {
+	unit := station.UnitCM
+	if timeseries.Measurement != nil && timeseries.Measurement.Unit != "" {
+		unit = timeseries.Measurement.Unit
+	}
+
+	waterLevels := make(station.MeasurementList, 0, len(timeseries.Samples))
+	for _, sample := range timeseries.Samples {
+		waterLevels = append(waterLevels, station.Measurement{
+			Timestamp: time.Unix(int64(sample.Timestamp), 0).UTC().Format(time.RFC3339),
+			Value:     sample.Value,
+			Unit:      unit,
+		})
+	}
+
+	return waterLevels
+}
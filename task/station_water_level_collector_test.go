@@ -0,0 +1,106 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/timgluz/wasserspiegel/measurement"
+	"github.com/timgluz/wasserspiegel/measurement/measurementtest"
+	"github.com/timgluz/wasserspiegel/station"
+	"github.com/timgluz/wasserspiegel/station/stationtest"
+)
+
+func newTestStation(id string) station.Station {
+	return station.Station{
+		ID:          id,
+		Name:        "Station " + id,
+		Water:       "Rhein",
+		ExternalIDs: []station.ExternalID{{Name: station.PegelOnlineProviderName, ID: "ext-" + id}},
+	}
+}
+
+func newTestWaterLevels(stationID string, values ...float64) *station.WaterLevelCollection {
+	now := time.Now().UTC().Truncate(time.Minute)
+
+	waterLevels := &station.WaterLevelCollection{StationID: stationID, Unit: station.UnitCM}
+	for i, value := range values {
+		timestamp := now.Add(-time.Duration(len(values)-i) * 15 * time.Minute)
+		waterLevels.Measurements = append(waterLevels.Measurements, station.Measurement{
+			Timestamp: timestamp.Format(time.RFC3339),
+			Value:     value,
+		})
+	}
+
+	return waterLevels
+}
+
+func newTestCollector(stations ...station.Station) (*StationWaterLevelCollector, *stationtest.FakeProvider, *measurementtest.MemoryRepository) {
+	provider := stationtest.NewFakeProvider()
+	providers := station.NewRegistry(slog.Default())
+	providers.Register(station.PegelOnlineProviderName, provider)
+
+	measurementRepo := measurementtest.NewMemoryRepository()
+	collector := NewStationWaterLevelCollector(measurementRepo, stationtest.NewMemoryRepository(stations...), providers, slog.Default())
+
+	return collector, provider, measurementRepo
+}
+
+func TestStationWaterLevelCollectorStoresWaterLevels(t *testing.T) {
+	ctx := context.Background()
+	collector, provider, measurementRepo := newTestCollector(newTestStation("bonn"))
+	provider.WithWaterLevels("ext-bonn", newTestWaterLevels("ext-bonn", 310, 312, 315))
+
+	period, err := measurement.NewFromISO8601Duration("P1D")
+	assert.NoError(t, err)
+	assert.NoError(t, collector.Run(ctx, "bonn", *period))
+
+	assert.Equal(t, []stationtest.Call{{Method: stationtest.MethodGetStationWaterLevel, ID: "ext-bonn"}}, provider.Calls())
+
+	timeseries, err := measurementRepo.GetTimeseries(ctx, measurement.NewMeasurementName("waterlevel", "bonn"), *period)
+	assert.NoError(t, err)
+	if assert.NotNil(t, timeseries) && assert.Len(t, timeseries.Samples, 3) {
+		assert.Equal(t, 315.0, timeseries.Samples[2].Value)
+		assert.Equal(t, station.UnitCM, timeseries.Measurement.Unit)
+		assert.Equal(t, measurement.Labels{StationID: "bonn", Parameter: "waterlevel", Provider: station.PegelOnlineProviderName}, timeseries.Measurement.Labels)
+	}
+}
+
+func TestStationWaterLevelCollectorReturnsProviderErrors(t *testing.T) {
+	ctx := context.Background()
+	collector, provider, measurementRepo := newTestCollector(newTestStation("bonn"))
+	upstreamErr := errors.New("upstream unavailable")
+	provider.FailNext(stationtest.MethodGetStationWaterLevel, upstreamErr)
+
+	period, err := measurement.NewFromISO8601Duration("P1D")
+	assert.NoError(t, err)
+	assert.ErrorIs(t, collector.Run(ctx, "bonn", *period), upstreamErr)
+
+	measurements, err := measurementRepo.GetMeasurements(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, measurements)
+}
+
+func TestStationWaterLevelCollectorSkipsDisabledStations(t *testing.T) {
+	disabled := newTestStation("bonn")
+	disabled.IsDisabled = true
+	collector, provider, _ := newTestCollector(disabled)
+
+	period, err := measurement.NewFromISO8601Duration("P1D")
+	assert.NoError(t, err)
+	assert.NoError(t, collector.Run(context.Background(), "bonn", *period))
+	assert.Empty(t, provider.Calls())
+}
+
+func TestStationWaterLevelCollectorUnknownStation(t *testing.T) {
+	collector, provider, _ := newTestCollector()
+
+	period, err := measurement.NewFromISO8601Duration("P1D")
+	assert.NoError(t, err)
+	assert.ErrorIs(t, collector.Run(context.Background(), "missing", *period), station.ErrStationNotFound)
+	assert.Empty(t, provider.Calls())
+}